@@ -6,7 +6,7 @@ type AdminInfoDto struct {
 	// comma separated lists of admin-only flags, allowed choices are convention dependent
 	Flags string `json:"flags"` // security, dealer, ...
 
-	// comma separated list of permissions, currently read_all, admin, payments, bans, announcements
+	// comma separated list of permissions, currently read_all, admin, payments, regdesk
 	Permissions string `json:"permissions"`
 
 	// comments
@@ -33,6 +33,19 @@ type AttendeeDto struct {
 
 	// comments
 	UserComments string `json:"user_comments"`
+
+	// optional voucher code, upper case
+	Voucher string `json:"voucher"`
+
+	// optional language for mails, one of the configured languages, empty means the default language
+	Language string `json:"language"`
+
+	// read only, see the email verification endpoints
+	EmailVerified bool `json:"email_verified"`
+}
+
+type EmailVerificationDto struct {
+	Token string `json:"token"` // from the verification email
 }
 
 type AttendeeMaxIdDto struct {
@@ -43,6 +56,15 @@ type AttendeeIdList struct {
 	Ids []int64 `json:"ids"`
 }
 
+type AttendeeWithStatusList struct {
+	Attendees []AttendeeWithStatusDto `json:"attendees"`
+}
+
+type AttendeeWithStatusDto struct {
+	Attendee AttendeeDto `json:"attendee"`
+	Status   string      `json:"status"` // the current status
+}
+
 // --- search criteria ---
 
 type AttendeeSearchCriteria struct {
@@ -106,3 +128,56 @@ type AttendeeSearchResult struct {
 	CurrentDues    *int64  `json:"current_dues,omitempty"`
 	DueDate        *string `json:"due_date,omitempty"`
 }
+
+// --- list export ---
+
+type AttendeeListExportRequest struct {
+	Criteria AttendeeSearchCriteria `json:"criteria"`
+	Columns  []string               `json:"columns"`
+	Format   string                 `json:"format"`
+}
+
+// --- bulk import ---
+
+type AttendeeImportResultDto struct {
+	DryRun bool                      `json:"dry_run"`
+	Count  int                       `json:"count"`
+	Rows   []AttendeeImportRowResult `json:"rows"`
+}
+
+type AttendeeImportRowResult struct {
+	Row int    `json:"row"` // row number in the csv file, the header row is row 1
+	Id  string `json:"id"`  // badge number, empty for a dry run
+}
+
+// --- registrations created by admins ---
+
+// AttendeeCreateForDto is used by admins to create a registration on behalf of someone else, e.g. a guest of honour.
+type AttendeeCreateForDto struct {
+	Attendee   AttendeeDto `json:"attendee"`
+	AdminFlags string      `json:"admin_flags"` // comma separated list of admin only flags, e.g. guest
+	Status     string      `json:"status"`      // new or approved, blank means new
+
+	// optional, makes the registration complimentary
+	CompReason   string `json:"comp_reason"`
+	CompDiscount int64  `json:"comp_discount"` // in cents, 0 waives all package dues, requires a comp_reason
+}
+
+// InvitationDto contains the code with which the guest can claim the registration, see /transfers/redeem.
+//
+// It is also sent to the guest by email.
+type InvitationDto struct {
+	Code    string `json:"code"`
+	Expires string `json:"expires"`
+}
+
+// --- choice eligibility ---
+
+// ChoiceEligibilityDto lists the choices an attendee is not eligible for, mapped to the reason.
+//
+// Choices not listed can be picked as far as eligibility is concerned.
+type ChoiceEligibilityDto struct {
+	Flags    map[string]string `json:"flags"`
+	Packages map[string]string `json:"packages"`
+	Options  map[string]string `json:"options"`
+}
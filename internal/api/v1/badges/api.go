@@ -0,0 +1,30 @@
+package badges
+
+type BadgeListDto struct {
+	Badges []BadgeDto `json:"badges"`
+}
+
+// BadgeDto is what is printed on a badge, together with its print state.
+type BadgeDto struct {
+	Id                string          `json:"id"` // badge number
+	Nickname          string          `json:"nickname"`
+	CountryBadge      string          `json:"country_badge"`
+	SponsorTier       string          `json:"sponsor_tier"` // the package key of the sponsor tier, or empty
+	Markers           []string        `json:"markers"`      // special markers, see the badges configuration
+	Status            string          `json:"status"`
+	PrintCount        int             `json:"print_count"`
+	LastPrinted       string          `json:"last_printed,omitempty"`
+	ChangedSincePrint bool            `json:"changed_since_print"` // only ever true if the badge was printed
+	Prints            []BadgePrintDto `json:"prints"`
+}
+
+type BadgePrintDto struct {
+	Timestamp string `json:"timestamp"`
+	Operator  string `json:"operator"` // who marked the badge as printed
+	Reason    string `json:"reason,omitempty"`
+}
+
+type BadgesPrintedDto struct {
+	Ids    []uint `json:"ids"`    // badge numbers
+	Reason string `json:"reason"` // required if any of the badges was printed before
+}
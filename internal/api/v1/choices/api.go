@@ -0,0 +1,18 @@
+package choices
+
+type AvailableChoicesDto struct {
+	Flags    []ChoiceDto `json:"flags"`
+	Packages []ChoiceDto `json:"packages"`
+	Options  []ChoiceDto `json:"options"`
+}
+
+type ChoiceDto struct {
+	Key            string  `json:"key"`
+	Description    string  `json:"description"`
+	HelpUrl        string  `json:"help_url"`
+	PriceCent      int64   `json:"price_cent"` // current price including VAT, only for packages
+	VatPercent     float64 `json:"vat_percent"`
+	Default        bool    `json:"default"`
+	ReadOnly       bool    `json:"read_only"`                 // only an admin can change this choice
+	AvailableUntil string  `json:"available_until,omitempty"` // ISO date/time, if the choice is only available for a limited time
+}
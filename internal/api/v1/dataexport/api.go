@@ -0,0 +1,39 @@
+package dataexport
+
+import (
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+)
+
+type PersonalDataExportDto struct {
+	Attendee attendee.AttendeeDto `json:"attendee"`
+
+	// status history, including the current status as the last entry
+	StatusHistory []status.StatusChangeDto `json:"status_history"`
+
+	// comma separated list of admin-only flags, only included for admins
+	AdminFlags string `json:"admin_flags,omitempty"`
+
+	// additional info by area, only contains the areas the caller may see
+	AdditionalInfo map[string]interface{} `json:"additional_info"`
+
+	// payment transactions from the payment service
+	Transactions []TransactionDto `json:"transactions"`
+}
+
+type TransactionDto struct {
+	Id            string    `json:"id"`
+	Type          string    `json:"type"`   // due / payment
+	Method        string    `json:"method"` // credit / paypal / transfer / internal / gift
+	Amount        AmountDto `json:"amount"`
+	Comment       string    `json:"comment"`
+	Status        string    `json:"status"`         // pending / tentative / valid / deleted
+	EffectiveDate string    `json:"effective_date"` // ISO date (format yyyy-MM-dd)
+	DueDate       string    `json:"due_date"`       // ISO date (format yyyy-MM-dd)
+}
+
+type AmountDto struct {
+	Currency  string  `json:"currency"`
+	GrossCent int64   `json:"gross_cent"`
+	VatRate   float64 `json:"vat_rate"`
+}
@@ -0,0 +1,54 @@
+package groups
+
+type GroupCreateDto struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`  // one of room, dealers, fursuit, other
+	Owner uint   `json:"owner"` // badge number of the registration that owns the group
+}
+
+// GroupUpdateDto changes the name or the owner of a group. The type cannot be changed.
+type GroupUpdateDto struct {
+	Name  string `json:"name"`
+	Owner uint   `json:"owner"` // badge number, must already be a member
+}
+
+type GroupListDto struct {
+	Groups []GroupDto `json:"groups"`
+}
+
+type GroupDto struct {
+	Id       string           `json:"id"`
+	Name     string           `json:"name"`
+	Type     string           `json:"type"`
+	Owner    string           `json:"owner"` // badge number
+	Members  []GroupMemberDto `json:"members"`
+	Problems []string         `json:"problems"` // consistency problems, e.g. members of a room group with different room packages
+}
+
+type GroupMemberDto struct {
+	Id          string `json:"id"` // badge number
+	Nickname    string `json:"nickname"`
+	Status      string `json:"status"`
+	RoomPackage string `json:"room_package,omitempty"` // the room package of the member, see the groups configuration
+}
+
+type InviteCodeDto struct {
+	Code string `json:"code"` // pass this on to whoever should join the group
+}
+
+type JoinGroupDto struct {
+	Code     string `json:"code"`
+	Attendee uint   `json:"attendee"` // badge number of the registration that joins
+}
+
+type UnmatchedPartnerListDto struct {
+	Attendees []UnmatchedPartnerDto `json:"attendees"`
+}
+
+// UnmatchedPartnerDto is an attendee who has filled in the partner field, but is not in a room group.
+type UnmatchedPartnerDto struct {
+	Id       string `json:"id"` // badge number
+	Nickname string `json:"nickname"`
+	Partner  string `json:"partner"`
+	Status   string `json:"status"`
+}
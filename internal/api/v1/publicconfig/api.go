@@ -0,0 +1,70 @@
+package publicconfig
+
+// ConfigDto contains the parts of the service configuration that a registration frontend needs.
+type ConfigDto struct {
+	RequireLoginForReg bool        `json:"require_login_for_reg"`
+	GoLive             GoLiveDto   `json:"go_live"`
+	Choices            ChoicesDto  `json:"choices"`
+	TshirtSizes        []string    `json:"tshirtsizes"`
+	Birthday           BirthdayDto `json:"birthday"`
+	Countries          []string    `json:"countries"`
+	Languages          []string    `json:"languages"` // the languages attendees can choose for their mails, may be empty
+}
+
+type GoLiveDto struct {
+	StartIsoDatetime         string `json:"start_iso_datetime"`
+	EarlyRegStartIsoDatetime string `json:"early_reg_start_iso_datetime,omitempty"`
+}
+
+type ChoicesDto struct {
+	Flags        map[string]ChoiceDto `json:"flags"`
+	Packages     map[string]ChoiceDto `json:"packages"`
+	Options      map[string]ChoiceDto `json:"options"`
+	FlagRules    []ChoiceRuleDto      `json:"flag_rules"`
+	PackageRules []ChoiceRuleDto      `json:"package_rules"`
+	OptionRules  []ChoiceRuleDto      `json:"option_rules"`
+}
+
+type ChoiceDto struct {
+	Description         string          `json:"description"`
+	HelpUrl             string          `json:"help_url"`
+	PriceEarly          float64         `json:"price_early"`
+	PriceLate           float64         `json:"price_late"`
+	PriceAtCon          float64         `json:"price_atcon"`
+	PriceCurrent        float64         `json:"price_current"`
+	VatPercent          float64         `json:"vat_percent"`
+	Default             bool            `json:"default"`
+	AdminOnly           bool            `json:"admin_only"`
+	ReadOnly            bool            `json:"read_only"`
+	Constraint          string          `json:"constraint,omitempty"`
+	ConstraintMsg       string          `json:"constraint_msg,omitempty"`
+	Eligibility         *EligibilityDto `json:"eligibility,omitempty"`
+	AvailableFrom       string          `json:"available_from,omitempty"`
+	AvailableUntil      string          `json:"available_until,omitempty"`
+	RemovalPolicy       string          `json:"removal_policy,omitempty"`
+	RemovalAllowedUntil string          `json:"removal_allowed_until,omitempty"`
+}
+
+type EligibilityDto struct {
+	MinAge       int      `json:"min_age,omitempty"`
+	MaxAge       int      `json:"max_age,omitempty"`
+	Countries    []string `json:"countries,omitempty"`
+	BeforeStatus string   `json:"before_status,omitempty"`
+}
+
+type ChoiceRuleDto struct {
+	Name          string `json:"name"`
+	Constraint    string `json:"constraint"`
+	ConstraintMsg string `json:"constraint_msg"`
+}
+
+type BirthdayDto struct {
+	Earliest    string `json:"earliest"`
+	Latest      string `json:"latest"`
+	FirstConDay string `json:"first_con_day,omitempty"`
+}
+
+// ConfigReloadResultDto lists the changes, with secret values masked.
+type ConfigReloadResultDto struct {
+	Changes []string `json:"changes"`
+}
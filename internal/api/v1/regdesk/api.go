@@ -0,0 +1,36 @@
+package regdesk
+
+type RegdeskAttendeeListDto struct {
+	Attendees []RegdeskAttendeeDto `json:"attendees"`
+}
+
+// RegdeskAttendeeDto is what the regdesk needs to see when an attendee comes to pick up their badge.
+type RegdeskAttendeeDto struct {
+	Id              string `json:"id"` // badge number
+	Nickname        string `json:"nickname"`
+	FirstName       string `json:"first_name"`
+	LastName        string `json:"last_name"`
+	Status          string `json:"status"`
+	TotalDues       int64  `json:"total_dues"`       // in cents
+	PaymentBalance  int64  `json:"payment_balance"`  // in cents
+	OutstandingDues int64  `json:"outstanding_dues"` // in cents, negative if the attendee has overpaid
+	TshirtSize      string `json:"tshirt_size"`
+	Packages        string `json:"packages"`
+	Flags           string `json:"flags"` // comma separated, including admin only flags such as guest
+
+	// only set while the attendee is checked in
+	CheckIn *CheckInInfoDto `json:"check_in,omitempty"`
+}
+
+type CheckInInfoDto struct {
+	Timestamp string   `json:"timestamp"`
+	Desk      string   `json:"desk"`
+	Operator  string   `json:"operator"` // who performed the check-in
+	Items     []string `json:"items"`    // what was handed out
+}
+
+type CheckInDto struct {
+	Desk    string   `json:"desk"`    // which regdesk, e.g. "desk 3"
+	Items   []string `json:"items"`   // what was handed out, see the regdesk configuration
+	Comment string   `json:"comment"` // optional, added to the status history
+}
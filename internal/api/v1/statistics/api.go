@@ -0,0 +1,23 @@
+package statistics
+
+type StatisticsDto struct {
+	Total        int64            `json:"total"`          // all registrations except deleted ones
+	ByStatus     map[string]int64 `json:"by_status"`      // includes deleted registrations
+	ByCountry    map[string]int64 `json:"by_country"`     // this and the following counts do not include deleted registrations
+	ByPackage    map[string]int64 `json:"by_package"`     // a registration counts once for each package it has
+	ByFlag       map[string]int64 `json:"by_flag"`        // a registration counts once for each flag it has
+	ByTshirtSize map[string]int64 `json:"by_tshirt_size"` // registrations without a t-shirt size count as "none"
+	PerDay       []DailyCountDto  `json:"registrations_per_day"`
+}
+
+type DailyCountDto struct {
+	Day   string `json:"day"` // ISO date (format yyyy-MM-dd)
+	Count int64  `json:"count"`
+}
+
+// PublicStatisticsDto is the anonymised subset of the statistics that may be shown on the website.
+type PublicStatisticsDto struct {
+	Total     int64            `json:"total"`
+	ByStatus  map[string]int64 `json:"by_status"`  // without deleted registrations
+	ByCountry map[string]int64 `json:"by_country"` // countries with few attendees are combined as "other"
+}
@@ -0,0 +1,22 @@
+package transfer
+
+// TransferDto is used by admins to move a registration to another account.
+type TransferDto struct {
+	Identity          string `json:"identity"`            // the subject of the new owner's account
+	Email             string `json:"email"`               // optional, replaces the email address unless empty, required if personal data is reset
+	ResetPersonalData bool   `json:"reset_personal_data"` // for a ticket resale, the new owner has to fill in their personal data
+}
+
+type TransferCodeRequestDto struct {
+	ResetPersonalData bool `json:"reset_personal_data"` // for a ticket resale, the new owner has to fill in their personal data
+}
+
+type TransferCodeDto struct {
+	Code    string `json:"code"`    // pass this on to the new owner
+	Expires string `json:"expires"` // the code can no longer be redeemed after this time
+}
+
+type RedeemTransferDto struct {
+	Code  string `json:"code"`
+	Email string `json:"email"` // optional, defaults to the email address of the logged in user if personal data is reset
+}
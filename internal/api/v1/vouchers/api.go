@@ -0,0 +1,18 @@
+package vouchers
+
+type VoucherListDto struct {
+	Vouchers []VoucherDto `json:"vouchers"`
+}
+
+// VoucherDto describes a voucher code. Exactly one of percent and amount must be set.
+type VoucherDto struct {
+	Id          string `json:"id"`   // read only
+	Code        string `json:"code"` // upper case letters, digits and dashes, cannot be changed
+	Description string `json:"description"`
+	Percent     int64  `json:"percent,omitempty"`  // discount on the applicable packages, 1 to 100
+	Amount      int64  `json:"amount,omitempty"`   // fixed discount in cents, at most the price of the applicable packages
+	Packages    string `json:"packages,omitempty"` // comma separated list of the packages the voucher applies to, empty means all
+	MaxUses     int64  `json:"max_uses,omitempty"` // how many registrations may redeem the voucher, 0 means unlimited
+	Expires     string `json:"expires,omitempty"`  // RFC 3339 timestamp after which the voucher can no longer be redeemed
+	Uses        int64  `json:"uses"`               // read only, the number of registrations that have redeemed the voucher
+}
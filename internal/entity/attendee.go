@@ -28,4 +28,10 @@ type Attendee struct {
 	Options      string `gorm:"type:varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci"`
 	UserComments string `gorm:"type:text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci" testdiff:"ignore"`
 	Identity     string `gorm:"type:varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci"`
+	// EmailVerified is reset whenever the email address changes
+	EmailVerified bool
+	Voucher       string `gorm:"type:varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;index:voucher_idx"` // the redeemed voucher code, if any
+	Language      string `gorm:"type:varchar(16) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci"`                   // for mails, empty means the default language
+	// Anonymised is set once the personal data has been overwritten, and is never reset
+	Anonymised bool `gorm:"index:anonymised_idx"`
 }
@@ -0,0 +1,21 @@
+package entity
+
+import "gorm.io/gorm"
+
+// configured sizes are for mysql, since version 5 mysql counts characters, not bytes
+
+// Group links registrations that want to be together, such as roommates, or a dealers' den table.
+type Group struct {
+	gorm.Model
+	Name           string `gorm:"type:varchar(80) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;NOT NULL"`
+	Type           string `gorm:"type:varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;NOT NULL;index:group_type_idx"`
+	OwnerId        uint   `gorm:"NOT NULL;index:group_owner_idx"` // badge number of the owner, who is always also a member
+	InviteCodeHash string `gorm:"type:varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci"`
+}
+
+// GroupMember is the membership of a registration in a group. Leaving a group soft deletes the membership.
+type GroupMember struct {
+	gorm.Model
+	GroupId    uint `gorm:"NOT NULL;index:group_member_group_idx"`
+	AttendeeId uint `gorm:"NOT NULL;index:group_member_attendee_idx"`
+}
@@ -0,0 +1,25 @@
+package entity
+
+// these are query results, not tables
+
+// AttendeeCountGroup is the number of attendees that share the same latest status, country, choices and t-shirt size.
+type AttendeeCountGroup struct {
+	Status     string
+	Country    string
+	Flags      string
+	Packages   string
+	TshirtSize string
+	Count      int64
+}
+
+// AttendeeWithStatus is an attendee together with their latest status.
+type AttendeeWithStatus struct {
+	Attendee `gorm:"embedded"`
+	Status   string
+}
+
+// DailyCount is the number of attendees whose registration was created on a given day (ISO date).
+type DailyCount struct {
+	Day   string
+	Count int64
+}
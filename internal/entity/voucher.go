@@ -0,0 +1,22 @@
+package entity
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// configured sizes are for mysql, since version 5 mysql counts characters, not bytes
+
+// Voucher is a discount code managed by admins. Attendees redeem it by entering the code in their registration.
+//
+// Exactly one of Percent or Amount is set.
+type Voucher struct {
+	gorm.Model
+	Code        string     `gorm:"type:varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci;NOT NULL;uniqueIndex:voucher_code_uidx"`
+	Description string     `gorm:"type:varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci"`
+	Percent     int64      // discount in percent of the package prices
+	Amount      int64      // fixed discount in cents, never more than the package prices
+	Packages    string     `gorm:"type:varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci"` // the voucher applies to these packages, empty means all
+	MaxUses     int64      // 0 means unlimited
+	Expires     *time.Time // the code can no longer be redeemed after this time, nil means never
+}
@@ -3,7 +3,8 @@
 package config
 
 import (
-	"crypto/rsa"
+	"crypto"
+	"crypto/subtle"
 	"fmt"
 	"strings"
 	"time"
@@ -52,13 +53,68 @@ func FixedApiToken() string {
 	return Configuration().Security.Fixed.Api
 }
 
+// ApiClientForToken finds the api client that uses the given token.
+//
+// security.fixed_token.api is reported as client FixedTokenClientName with all scopes.
+func ApiClientForToken(token string) (string, []string, bool) {
+	c := Configuration().Security
+	if token == "" {
+		return "", nil, false
+	}
+	if c.Fixed.Api != "" && subtle.ConstantTimeCompare([]byte(token), []byte(c.Fixed.Api)) == 1 {
+		return FixedTokenClientName, []string{ApiScopeAll}, true
+	}
+	for _, name := range sortedKeys(c.ApiClients) {
+		client := c.ApiClients[name]
+		if subtle.ConstantTimeCompare([]byte(token), []byte(client.Token)) == 1 {
+			return name, client.Scopes, true
+		}
+	}
+	return "", nil, false
+}
+
 func OidcTokenCookieName() string {
 	return Configuration().Security.Oidc.TokenCookieName
 }
 
-func OidcKeySet() []*rsa.PublicKey {
-	// TODO implement parsing during validation
-	return parsedKeySet
+func OidcKeySet() []crypto.PublicKey {
+	return Configuration().parsedKeySet
+}
+
+func OidcJwksUrl() string {
+	return Configuration().Security.Oidc.JwksUrl
+}
+
+func OidcJwksRefreshInterval() time.Duration {
+	return time.Minute * time.Duration(Configuration().Security.Oidc.JwksRefreshMinutes)
+}
+
+func OidcIssuer() string {
+	return Configuration().Security.Oidc.Issuer
+}
+
+func OidcAudience() string {
+	return Configuration().Security.Oidc.Audience
+}
+
+func OidcClockSkew() time.Duration {
+	return time.Second * time.Duration(Configuration().Security.Oidc.ClockSkewSeconds)
+}
+
+func OidcSubjectClaim() string {
+	return Configuration().Security.Oidc.Claims.Subject
+}
+
+func OidcEmailClaim() string {
+	return Configuration().Security.Oidc.Claims.Email
+}
+
+func OidcNameClaim() string {
+	return Configuration().Security.Oidc.Claims.Name
+}
+
+func OidcRolesClaim() string {
+	return Configuration().Security.Oidc.Claims.Roles
 }
 
 func OidcAdminRole() string {
@@ -111,6 +167,13 @@ func AllowedStatusValues() []string {
 	return []string{"new", "approved", "partially paid", "paid", "checked in", "cancelled", "deleted"}
 }
 
+// StatusProgression lists the statuses in the order an attendee normally goes through them.
+//
+// cancelled and deleted are not part of the progression.
+func StatusProgression() []string {
+	return statusProgression
+}
+
 func DefaultFlags() string {
 	return defaultChoiceStr(Configuration().Choices.Flags)
 }
@@ -164,6 +227,60 @@ func OptionsConfig() map[string]ChoiceConfig {
 	return Configuration().Choices.Options
 }
 
+// AvailableAt is true if the choice can be newly picked at the given time.
+//
+// Attendees who already have the choice can keep it outside the availability window.
+func (c ChoiceConfig) AvailableAt(t time.Time) bool {
+	if c.AvailableFrom != "" {
+		from, _ := time.Parse(StartTimeFormat, c.AvailableFrom)
+		if t.Before(from) {
+			return false
+		}
+	}
+	if c.AvailableUntil != "" {
+		until, _ := time.Parse(StartTimeFormat, c.AvailableUntil)
+		if !t.Before(until) {
+			return false
+		}
+	}
+	return true
+}
+
+// CurrentPrice is the price charged for the choice in a registration made now.
+func (c ChoiceConfig) CurrentPrice() float64 {
+	return c.PriceAt(time.Now())
+}
+
+// PriceAt is the price charged for the choice in a registration made at the given time.
+//
+// This is the early price, unless the late or at-con price applies from an earlier time, see the dues configuration.
+func (c ChoiceConfig) PriceAt(registered time.Time) float64 {
+	dues := Configuration().Dues
+	if dues.AtConPriceFromIsoDatetime != "" {
+		from, _ := time.Parse(StartTimeFormat, dues.AtConPriceFromIsoDatetime)
+		if !registered.Before(from) {
+			return c.PriceAtCon
+		}
+	}
+	if dues.LatePriceFromIsoDatetime != "" {
+		from, _ := time.Parse(StartTimeFormat, dues.LatePriceFromIsoDatetime)
+		if !registered.Before(from) {
+			return c.PriceLate
+		}
+	}
+	return c.PriceEarly
+}
+
+// FirstConDay returns the first day of the convention, and false if none is configured.
+func FirstConDay() (time.Time, bool) {
+	day := Configuration().Birthday.FirstConDay
+	if day == "" {
+		return time.Time{}, false
+	}
+	t, _ := time.Parse(IsoDateFormat, day)
+	return t, true
+}
+
 func EarliestBirthday() string {
 	return Configuration().Birthday.Earliest
 }
@@ -199,6 +316,21 @@ func RequireLoginForReg() bool {
 	return Configuration().Security.RequireLogin
 }
 
+func PaymentServiceToken() string {
+	return downstreamTokenOrFixed(Configuration().Downstream.PaymentServiceToken)
+}
+
+func MailServiceToken() string {
+	return downstreamTokenOrFixed(Configuration().Downstream.MailServiceToken)
+}
+
+func downstreamTokenOrFixed(token string) string {
+	if token == "" {
+		return FixedApiToken()
+	}
+	return token
+}
+
 func PaymentServiceBaseUrl() string {
 	return Configuration().Downstream.PaymentService
 }
@@ -206,3 +338,116 @@ func PaymentServiceBaseUrl() string {
 func MailServiceBaseUrl() string {
 	return Configuration().Downstream.MailService
 }
+
+// ConventionEndDate returns the configured end of the convention, and false if none is configured.
+func ConventionEndDate() (time.Time, bool) {
+	end := Configuration().Retention.ConventionEndIsoDate
+	if end == "" {
+		return time.Time{}, false
+	}
+	t, _ := time.Parse(IsoDateFormat, end)
+	return t, true
+}
+
+// AnonymiseAfter returns the retention period, or 0 if automatic anonymisation is switched off.
+func AnonymiseAfter() time.Duration {
+	return 24 * time.Hour * time.Duration(Configuration().Retention.AnonymiseAfterDays)
+}
+
+func RetentionCheckInterval() time.Duration {
+	return time.Minute * time.Duration(Configuration().Retention.CheckIntervalMinutes)
+}
+
+// PublicStatisticsMinCount is the minimum number of attendees a country needs to be listed separately in the public statistics.
+func PublicStatisticsMinCount() int {
+	return Configuration().Statistics.PublicMinCount
+}
+
+// RegdeskItems lists what can be handed out at check-in, e.g. badge, tshirt, bag.
+func RegdeskItems() []string {
+	return Configuration().Regdesk.Items
+}
+
+// RegdeskUndoWindow is how long after check-in the regdesk can still undo it.
+func RegdeskUndoWindow() time.Duration {
+	return time.Minute * time.Duration(Configuration().Regdesk.UndoWindowMinutes)
+}
+
+// BadgeSponsorPackages lists the packages that are sponsor tiers, highest tier first.
+func BadgeSponsorPackages() []string {
+	return Configuration().Badges.SponsorPackages
+}
+
+// BadgeMarkerFlags lists the flags that are printed on the badge as special markers.
+func BadgeMarkerFlags() []string {
+	return Configuration().Badges.MarkerFlags
+}
+
+// GroupRoomPackages lists the packages that book a room. All members of a room group must have the same one.
+func GroupRoomPackages() []string {
+	return Configuration().Groups.RoomPackages
+}
+
+// GroupMaxMembers is the most members a group can have, including its owner.
+func GroupMaxMembers() int {
+	return Configuration().Groups.MaxMembers
+}
+
+// EmailVerificationEnabled is true if attendees are sent a link to verify their email address.
+func EmailVerificationEnabled() bool {
+	return Configuration().EmailVerification.Enabled
+}
+
+// EmailVerificationSecret is the key used to sign email verification tokens.
+func EmailVerificationSecret() string {
+	return Configuration().EmailVerification.Secret
+}
+
+// EmailVerificationTokenValidity is how long an email verification token can be used.
+func EmailVerificationTokenValidity() time.Duration {
+	return time.Hour * time.Duration(Configuration().EmailVerification.TokenValidityHours)
+}
+
+// EmailVerificationRequiredForApproval is true if a registration can only be approved once its email address is verified.
+func EmailVerificationRequiredForApproval() bool {
+	return Configuration().EmailVerification.RequireForApproval
+}
+
+// MailLanguages lists the languages attendees can choose for their mails.
+func MailLanguages() []string {
+	return Configuration().Mail.Languages
+}
+
+// MailDefaultLanguage is sent to the mail service for attendees who have not chosen a language. May be empty.
+func MailDefaultLanguage() string {
+	return Configuration().Mail.DefaultLanguage
+}
+
+// MailRegistrationUrl is the link to the registration frontend, with {badge_number} as a placeholder. May be empty.
+func MailRegistrationUrl() string {
+	return Configuration().Mail.RegistrationUrl
+}
+
+// MailTemplate is the name of the mail template to send for an event.
+func MailTemplate(event string) string {
+	if template, ok := Configuration().Mail.Templates[event]; ok {
+		return template
+	}
+	return event
+}
+
+// MailSuppressedForTransition is true if no mail is sent for the status transition.
+func MailSuppressedForTransition(oldStatus string, newStatus string) bool {
+	for _, transition := range Configuration().Mail.SuppressTransitions {
+		from, to, _ := strings.Cut(transition, MailTransitionSeparator)
+		if (from == "*" || from == oldStatus) && (to == "*" || to == newStatus) {
+			return true
+		}
+	}
+	return false
+}
+
+// MailSuppressedForBulk is true if bulk imports send no status mails.
+func MailSuppressedForBulk() bool {
+	return Configuration().Mail.SuppressBulk
+}
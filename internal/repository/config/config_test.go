@@ -64,3 +64,19 @@ func TestMigrateDatabase(t *testing.T) {
 	dbMigrate = false
 	require.Equal(t, false, MigrateDatabase(), "unexpected return value")
 }
+
+func TestChoicePriceAt(t *testing.T) {
+	docs.Description("the price depends on when the registration was made, and the configured dates for late and at-con prices")
+	configurationData = &conf{Logging: loggingConfig{Severity: "DEBUG"}, Dues: duesConfig{
+		LatePriceFromIsoDatetime:  "2022-06-01T00:00:00+02:00",
+		AtConPriceFromIsoDatetime: "2022-08-24T00:00:00+02:00",
+	}}
+	choice := ChoiceConfig{PriceEarly: 100, PriceLate: 120, PriceAtCon: 150}
+	require.Equal(t, 100.0, choice.PriceAt(time.Date(2022, 5, 31, 21, 59, 59, 0, time.UTC)))
+	require.Equal(t, 120.0, choice.PriceAt(time.Date(2022, 5, 31, 22, 0, 0, 0, time.UTC)))
+	require.Equal(t, 150.0, choice.PriceAt(time.Date(2022, 8, 24, 12, 0, 0, 0, time.UTC)))
+	require.Equal(t, 150.0, choice.CurrentPrice())
+
+	configurationData = &conf{Logging: loggingConfig{Severity: "DEBUG"}}
+	require.Equal(t, 100.0, choice.CurrentPrice())
+}
@@ -0,0 +1,387 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ChoiceConstraint is a parsed constraint expression over the keys of one kind of choice (flags, packages, or options).
+//
+// Syntax, from lowest to highest precedence:
+//
+//	a or b      also a | b
+//	a and b     also a & b, and for compatibility with older configurations a,b
+//	not a       also !a
+//	(a)
+//	exactly-one-of(a, b, ...)
+//	at-most(n, a, b, ...)
+//	at-least(n, a, b, ...)
+//	key         true if the choice with this key is picked
+//
+// The arguments of the counting functions must be choice keys.
+type ChoiceConstraint interface {
+	// Holds evaluates the constraint for the given picked choices.
+	Holds(picked map[string]bool) bool
+	// Keys lists the choice keys referenced by the constraint.
+	Keys() []string
+	String() string
+}
+
+// ChoiceRule is a named constraint that must always hold, independent of which choices are picked.
+type ChoiceRule struct {
+	Name          string `yaml:"name"`
+	Constraint    string `yaml:"constraint"`
+	ConstraintMsg string `yaml:"constraint_msg"`
+
+	ParsedConstraint ChoiceConstraint `yaml:"-"` // set during configuration loading
+}
+
+var legacyConstraintPattern = regexp.MustCompile(`^!?[a-zA-Z0-9_-]+(,!?[a-zA-Z0-9_-]+)*$`)
+
+// IsLegacyConstraint is true for constraints in the original syntax, a comma separated list of key (requires)
+// and !key (excludes).
+func IsLegacyConstraint(constraint string) bool {
+	return legacyConstraintPattern.MatchString(constraint)
+}
+
+// ParseChoiceConstraint parses a constraint expression. It does not check that the referenced keys exist.
+func ParseChoiceConstraint(constraint string) (ChoiceConstraint, error) {
+	tokens, err := tokenizeConstraint(constraint)
+	if err != nil {
+		return nil, err
+	}
+	p := &constraintParser{tokens: tokens}
+	result, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected '%s' at end of constraint", p.tokens[p.pos])
+	}
+	return result, nil
+}
+
+// --- ast ---
+
+type keyConstraint struct {
+	key string
+}
+
+func (c *keyConstraint) Holds(picked map[string]bool) bool {
+	return picked[c.key]
+}
+
+func (c *keyConstraint) Keys() []string {
+	return []string{c.key}
+}
+
+func (c *keyConstraint) String() string {
+	return c.key
+}
+
+type notConstraint struct {
+	operand ChoiceConstraint
+}
+
+func (c *notConstraint) Holds(picked map[string]bool) bool {
+	return !c.operand.Holds(picked)
+}
+
+func (c *notConstraint) Keys() []string {
+	return c.operand.Keys()
+}
+
+func (c *notConstraint) String() string {
+	return "not " + operandString(c.operand)
+}
+
+type andConstraint struct {
+	operands []ChoiceConstraint
+}
+
+func (c *andConstraint) Holds(picked map[string]bool) bool {
+	for _, o := range c.operands {
+		if !o.Holds(picked) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *andConstraint) Keys() []string {
+	return keysOf(c.operands)
+}
+
+func (c *andConstraint) String() string {
+	return joinOperands(c.operands, " and ")
+}
+
+type orConstraint struct {
+	operands []ChoiceConstraint
+}
+
+func (c *orConstraint) Holds(picked map[string]bool) bool {
+	for _, o := range c.operands {
+		if o.Holds(picked) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *orConstraint) Keys() []string {
+	return keysOf(c.operands)
+}
+
+func (c *orConstraint) String() string {
+	return joinOperands(c.operands, " or ")
+}
+
+// countConstraint covers exactly-one-of, at-most and at-least
+type countConstraint struct {
+	function string
+	min      int
+	max      int
+	keys     []string
+}
+
+func (c *countConstraint) Holds(picked map[string]bool) bool {
+	count := 0
+	for _, k := range c.keys {
+		if picked[k] {
+			count++
+		}
+	}
+	return count >= c.min && count <= c.max
+}
+
+func (c *countConstraint) Keys() []string {
+	return c.keys
+}
+
+func (c *countConstraint) String() string {
+	switch c.function {
+	case "at-most":
+		return fmt.Sprintf("at-most(%d, %s)", c.max, strings.Join(c.keys, ", "))
+	case "at-least":
+		return fmt.Sprintf("at-least(%d, %s)", c.min, strings.Join(c.keys, ", "))
+	default:
+		return fmt.Sprintf("%s(%s)", c.function, strings.Join(c.keys, ", "))
+	}
+}
+
+func keysOf(operands []ChoiceConstraint) []string {
+	result := make([]string, 0)
+	for _, o := range operands {
+		result = append(result, o.Keys()...)
+	}
+	return result
+}
+
+func joinOperands(operands []ChoiceConstraint, separator string) string {
+	parts := make([]string, len(operands))
+	for i, o := range operands {
+		parts[i] = operandString(o)
+	}
+	return strings.Join(parts, separator)
+}
+
+// operandString puts compound operands in parentheses, so the result parses back to the same constraint.
+func operandString(operand ChoiceConstraint) string {
+	switch operand.(type) {
+	case *andConstraint, *orConstraint:
+		return "(" + operand.String() + ")"
+	default:
+		return operand.String()
+	}
+}
+
+// --- parser ---
+
+func isConstraintIdentRune(r rune) bool {
+	return r == '-' || r == '_' || (r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)))
+}
+
+func tokenizeConstraint(constraint string) ([]string, error) {
+	tokens := make([]string, 0)
+	runes := []rune(constraint)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("(),!&|", r):
+			tokens = append(tokens, string(r))
+			i++
+		case isConstraintIdentRune(r):
+			start := i
+			for i < len(runes) && isConstraintIdentRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		default:
+			return nil, fmt.Errorf("invalid character '%c' in constraint", r)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty constraint")
+	}
+	return tokens, nil
+}
+
+type constraintParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *constraintParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *constraintParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *constraintParser) expect(token string) error {
+	if t := p.next(); t != token {
+		if t == "" {
+			return fmt.Errorf("expected '%s' but constraint ended", token)
+		}
+		return fmt.Errorf("expected '%s' but found '%s'", token, t)
+	}
+	return nil
+}
+
+func (p *constraintParser) parseOr() (ChoiceConstraint, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	operands := []ChoiceConstraint{first}
+	for p.peek() == "or" || p.peek() == "|" {
+		p.next()
+		operand, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return &orConstraint{operands: operands}, nil
+}
+
+func (p *constraintParser) parseAnd() (ChoiceConstraint, error) {
+	first, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	operands := []ChoiceConstraint{first}
+	for p.peek() == "and" || p.peek() == "&" || p.peek() == "," {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return &andConstraint{operands: operands}, nil
+}
+
+func (p *constraintParser) parseUnary() (ChoiceConstraint, error) {
+	if p.peek() == "not" || p.peek() == "!" {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notConstraint{operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *constraintParser) parsePrimary() (ChoiceConstraint, error) {
+	t := p.next()
+	switch {
+	case t == "":
+		return nil, fmt.Errorf("constraint ended unexpectedly")
+	case t == "(":
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	case (t == "exactly-one-of" || t == "at-most" || t == "at-least") && p.peek() == "(":
+		return p.parseCount(t)
+	case t == "and" || t == "or" || t == "not" || !isConstraintIdent(t):
+		return nil, fmt.Errorf("unexpected '%s' in constraint", t)
+	default:
+		return &keyConstraint{key: t}, nil
+	}
+}
+
+func isConstraintIdent(token string) bool {
+	for _, r := range token {
+		if !isConstraintIdentRune(r) {
+			return false
+		}
+	}
+	return token != ""
+}
+
+func (p *constraintParser) parseCount(function string) (ChoiceConstraint, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	result := &countConstraint{function: function}
+
+	if function == "exactly-one-of" {
+		result.min, result.max = 1, 1
+	} else {
+		nStr := p.next()
+		n, err := strconv.Atoi(nStr)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%s needs a non-negative number as its first argument, found '%s'", function, nStr)
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		if function == "at-most" {
+			result.min, result.max = 0, n
+		} else {
+			result.min, result.max = n, int(^uint(0)>>1)
+		}
+	}
+
+	for {
+		k := p.next()
+		if !isConstraintIdent(k) || k == "and" || k == "or" || k == "not" {
+			return nil, fmt.Errorf("the arguments of %s must be choice keys, found '%s'", function, k)
+		}
+		result.keys = append(result.keys, k)
+		if p.peek() != "," {
+			break
+		}
+		p.next()
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	if function == "at-least" && result.min > len(result.keys) {
+		return nil, fmt.Errorf("at-least(%d, ...) can never hold with only %d keys", result.min, len(result.keys))
+	}
+	return result, nil
+}
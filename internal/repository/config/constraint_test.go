@@ -0,0 +1,89 @@
+package config
+
+import (
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func tstPicked(keys ...string) map[string]bool {
+	result := make(map[string]bool)
+	for _, k := range keys {
+		result[k] = true
+	}
+	return result
+}
+
+func TestParseChoiceConstraintLegacy(t *testing.T) {
+	docs.Description("the original comma separated syntax should still work and mean and")
+	parsed, err := ParseChoiceConstraint("!attendance,!stage")
+	require.Nil(t, err)
+	require.Equal(t, "not attendance and not stage", parsed.String())
+	require.True(t, parsed.Holds(tstPicked("day-thu")))
+	require.False(t, parsed.Holds(tstPicked("day-thu", "stage")))
+	require.True(t, IsLegacyConstraint("!attendance,!stage"))
+	require.False(t, IsLegacyConstraint("attendance or day-thu"))
+}
+
+func TestParseChoiceConstraintPrecedence(t *testing.T) {
+	docs.Description("and should bind more strongly than or, and not more strongly than and")
+	parsed, err := ParseChoiceConstraint("attendance or day-thu and not stage | (day-fri & day-sat)")
+	require.Nil(t, err)
+	require.Equal(t, "attendance or (day-thu and not stage) or (day-fri and day-sat)", parsed.String())
+	require.True(t, parsed.Holds(tstPicked("attendance", "stage")))
+	require.True(t, parsed.Holds(tstPicked("day-thu")))
+	require.False(t, parsed.Holds(tstPicked("day-thu", "stage")))
+	require.True(t, parsed.Holds(tstPicked("day-fri", "day-sat")))
+	require.False(t, parsed.Holds(tstPicked("day-fri")))
+}
+
+func TestParseChoiceConstraintNotCompound(t *testing.T) {
+	docs.Description("not applied to a compound expression should keep its parentheses when printed")
+	parsed, err := ParseChoiceConstraint("not (day-thu and day-fri) and !(stage | attendance)")
+	require.Nil(t, err)
+	require.Equal(t, "not (day-thu and day-fri) and not (stage or attendance)", parsed.String())
+	require.True(t, parsed.Holds(tstPicked("day-thu")))
+	require.False(t, parsed.Holds(tstPicked("day-thu", "day-fri")))
+	require.False(t, parsed.Holds(tstPicked("stage")))
+
+	reparsed, err := ParseChoiceConstraint(parsed.String())
+	require.Nil(t, err)
+	require.Equal(t, parsed.String(), reparsed.String())
+}
+
+func TestParseChoiceConstraintCounting(t *testing.T) {
+	docs.Description("the counting functions should count the picked keys among their arguments")
+	exactlyOne, err := ParseChoiceConstraint("exactly-one-of(room-none, room-single, room-double)")
+	require.Nil(t, err)
+	require.False(t, exactlyOne.Holds(tstPicked()))
+	require.True(t, exactlyOne.Holds(tstPicked("room-single")))
+	require.False(t, exactlyOne.Holds(tstPicked("room-single", "room-double")))
+	require.Equal(t, []string{"room-none", "room-single", "room-double"}, exactlyOne.Keys())
+
+	atMost, err := ParseChoiceConstraint("at-most(1, sponsor, sponsor2) and not at-least(2, art, anim, music)")
+	require.Nil(t, err)
+	require.Equal(t, "at-most(1, sponsor, sponsor2) and not at-least(2, art, anim, music)", atMost.String())
+	require.True(t, atMost.Holds(tstPicked("sponsor", "art")))
+	require.False(t, atMost.Holds(tstPicked("sponsor", "sponsor2")))
+	require.False(t, atMost.Holds(tstPicked("art", "music")))
+}
+
+func TestParseChoiceConstraintErrors(t *testing.T) {
+	docs.Description("syntax errors in constraints should be reported")
+	for constraint, expected := range map[string]string{
+		"":                          "empty constraint",
+		"a and":                     "constraint ended unexpectedly",
+		"(a or b":                   "expected ')' but constraint ended",
+		"a b":                       "unexpected 'b' at end of constraint",
+		"a + b":                     "invalid character '+' in constraint",
+		"at-most(x, a, b)":          "at-most needs a non-negative number as its first argument, found 'x'",
+		"exactly-one-of(a, not b)":  "the arguments of exactly-one-of must be choice keys, found 'not'",
+		"at-least(3, a, b)":         "at-least(3, ...) can never hold with only 2 keys",
+		"or a":                      "unexpected 'or' in constraint",
+		"exactly-one-of(a, (b, c))": "the arguments of exactly-one-of must be choice keys, found '('",
+	} {
+		_, err := ParseChoiceConstraint(constraint)
+		require.NotNil(t, err, constraint)
+		require.Equal(t, expected, err.Error(), constraint)
+	}
+}
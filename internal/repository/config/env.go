@@ -0,0 +1,150 @@
+package config
+
+import (
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix is the prefix of all environment variables that override configuration values.
+//
+// The variable name for a configuration key is the prefix followed by the upper-cased yaml path, with levels
+// separated by a double underscore, and dashes in map keys replaced by a single underscore. Examples:
+//
+//	security.fixed_token.api               -> REG_ATTENDEE_SECURITY__FIXED_TOKEN__API
+//	database.mysql.password                -> REG_ATTENDEE_DATABASE__MYSQL__PASSWORD
+//	choices.packages.room-none.price_early -> REG_ATTENDEE_CHOICES__PACKAGES__ROOM_NONE__PRICE_EARLY
+//
+// Appending EnvFileSuffix reads the value from the given file instead (for docker or kubernetes secrets).
+// Lists are given as comma separated values. Map entries must already exist in the configuration file,
+// and lists of structures (the choice rules) cannot be overridden.
+const EnvPrefix = "REG_ATTENDEE_"
+
+const EnvFileSuffix = "_FILE"
+
+const envLevelSeparator = "__"
+
+// applyEnvironmentOverrides sets all configuration values for which an environment variable is present.
+//
+// Values that cannot be read or converted are reported in errs under their yaml key.
+func applyEnvironmentOverrides(errs url.Values, c *conf) {
+	applyEnvironmentOverridesToValue(errs, reflect.ValueOf(c).Elem(), "", "")
+}
+
+func applyEnvironmentOverridesToValue(errs url.Values, value reflect.Value, key string, envName string) {
+	switch value.Kind() {
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			yamlName := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if field.PkgPath != "" || yamlName == "" || yamlName == "-" {
+				continue
+			}
+			applyEnvironmentOverridesToValue(errs, value.Field(i), joinKey(key, yamlName), joinEnvName(envName, yamlName))
+		}
+	case reflect.Map:
+		iter := value.MapRange()
+		for iter.Next() {
+			mapKey := iter.Key().String()
+			// map values are not addressable, so work on a copy and store it back
+			entry := reflect.New(iter.Value().Type()).Elem()
+			entry.Set(iter.Value())
+			applyEnvironmentOverridesToValue(errs, entry, joinKey(key, mapKey), joinEnvName(envName, mapKey))
+			value.SetMapIndex(iter.Key(), entry)
+		}
+	case reflect.Ptr:
+		if !value.IsNil() {
+			applyEnvironmentOverridesToValue(errs, value.Elem(), key, envName)
+		}
+	default:
+		raw, found, err := lookupEnvironmentValue(envName)
+		if err != nil {
+			errs.Add(key, err.Error())
+			return
+		}
+		if !found {
+			return
+		}
+		if err := setFromString(value, raw); err != nil {
+			errs.Add(key, fmt.Sprintf("value from environment variable %s is invalid: %s", envName, err.Error()))
+			return
+		}
+		aulogging.Logger.NoCtx().Info().Printf("configuration value %s overridden from environment", key)
+	}
+}
+
+// lookupEnvironmentValue prefers the variable itself over the _FILE variant.
+func lookupEnvironmentValue(envName string) (string, bool, error) {
+	if raw, ok := os.LookupEnv(envName); ok {
+		return raw, true, nil
+	}
+	filename, ok := os.LookupEnv(envName + EnvFileSuffix)
+	if !ok {
+		return "", false, nil
+	}
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read file given in environment variable %s: %s", envName+EnvFileSuffix, err.Error())
+	}
+	// secret files usually end in a newline that is not part of the secret
+	return strings.TrimRight(string(contents), "\r\n"), true, nil
+}
+
+func setFromString(value reflect.Value, raw string) error {
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		value.SetBool(parsed)
+	case reflect.Int:
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(parsed))
+	case reflect.Float64:
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		value.SetFloat(parsed)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("lists of this type cannot be set from the environment")
+		}
+		list := make([]string, 0)
+		if raw != "" {
+			for _, entry := range strings.Split(raw, ",") {
+				list = append(list, strings.TrimSpace(entry))
+			}
+		}
+		value.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("values of this type cannot be set from the environment")
+	}
+	return nil
+}
+
+func joinKey(key string, name string) string {
+	if key == "" {
+		return name
+	}
+	return key + "." + name
+}
+
+func joinEnvName(envName string, name string) string {
+	level := strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+	if envName == "" {
+		return EnvPrefix + level
+	}
+	return envName + envLevelSeparator + level
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const envTestYaml = `# yaml with minimal settings
+security:
+  fixed_token:
+    api: 'fixed-testing-token-abc'
+  oidc:
+    admin_role: 'admin'
+birthday:
+  earliest: '1851-01-01'
+  latest: '2048-01-01'
+go_live:
+  start_iso_datetime: '2019-11-28T20:00:00+01:00'
+choices:
+  packages:
+    room-none:
+      description: 'No Room'
+      help_url: 'help/room-none'
+      price_early: 0
+      vat_percent: 7
+`
+
+func TestEnvironmentOverrides(t *testing.T) {
+	docs.Description("check that environment variables override values from the configuration file")
+	t.Setenv("REG_ATTENDEE_SECURITY__FIXED_TOKEN__API", "fixed-token-from-env")
+	t.Setenv("REG_ATTENDEE_SECURITY__REQUIRE_LOGIN_FOR_REG", "true")
+	t.Setenv("REG_ATTENDEE_STATISTICS__PUBLIC_MIN_COUNT", "3")
+	t.Setenv("REG_ATTENDEE_COUNTRIES", "DE, AT")
+	t.Setenv("REG_ATTENDEE_CHOICES__PACKAGES__ROOM_NONE__PRICE_EARLY", "12.5")
+
+	c, errs, err := parseAndValidateConfig([]byte(envTestYaml))
+	require.Nil(t, err)
+	require.Empty(t, errs)
+	require.Equal(t, "fixed-token-from-env", c.Security.Fixed.Api)
+	require.True(t, c.Security.RequireLogin)
+	require.Equal(t, 3, c.Statistics.PublicMinCount)
+	require.Equal(t, []string{"DE", "AT"}, c.Countries)
+	require.Equal(t, 12.5, c.Choices.Packages["room-none"].PriceEarly)
+	require.Equal(t, "No Room", c.Choices.Packages["room-none"].Description)
+}
+
+func TestEnvironmentOverridesFromFile(t *testing.T) {
+	docs.Description("check that the _FILE variant reads the value from a file, without the trailing newline")
+	secretFile := filepath.Join(t.TempDir(), "mysql-password")
+	require.Nil(t, os.WriteFile(secretFile, []byte("secret-from-file\n"), 0600))
+	t.Setenv("REG_ATTENDEE_DATABASE__MYSQL__PASSWORD_FILE", secretFile)
+
+	c, _, err := parseAndValidateConfig([]byte(envTestYaml))
+	require.Nil(t, err)
+	require.Equal(t, "secret-from-file", c.Database.Mysql.Password)
+}
+
+func TestEnvironmentOverridesAreValidated(t *testing.T) {
+	docs.Description("check that values from the environment are validated like values from the configuration file")
+	t.Setenv("REG_ATTENDEE_SECURITY__FIXED_TOKEN__API", "short")
+	t.Setenv("REG_ATTENDEE_SERVER__READ_TIMEOUT_SECONDS", "soon")
+	missingFile := filepath.Join(t.TempDir(), "does-not-exist.pem")
+	t.Setenv("REG_ATTENDEE_SECURITY__OIDC__TOKEN_PUBLIC_KEYS_PEM_FILE", missingFile)
+
+	_, errs, err := parseAndValidateConfig([]byte(envTestYaml))
+	require.Equal(t, ErrorConfigInvalid, err)
+	expected := url.Values{
+		"security.fixed.api":                  []string{"security.fixed.api field must be at least 16 and at most 256 characters long"},
+		"server.read_timeout_seconds":         []string{`value from environment variable REG_ATTENDEE_SERVER__READ_TIMEOUT_SECONDS is invalid: strconv.Atoi: parsing "soon": invalid syntax`},
+		"security.oidc.token_public_keys_PEM": []string{"failed to read file given in environment variable REG_ATTENDEE_SECURITY__OIDC__TOKEN_PUBLIC_KEYS_PEM_FILE: open " + filepath.Join(filepath.Dir(os.Getenv("REG_ATTENDEE_SECURITY__OIDC__TOKEN_PUBLIC_KEYS_PEM_FILE")), "does-not-exist.pem") + ": no such file or directory"},
+	}
+	require.Equal(t, expected, errs)
+}
@@ -1,9 +1,9 @@
 package config
 
 import (
-	"crypto/rsa"
 	"errors"
 	"flag"
+	"fmt"
 	aulogging "github.com/StephanHCB/go-autumn-logging"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/system"
 	"gopkg.in/yaml.v2"
@@ -19,13 +19,12 @@ var (
 	configurationFilename string
 	dbMigrate             bool
 	ecsLogging            bool
-
-	parsedKeySet []*rsa.PublicKey
 )
 
 var (
 	ErrorConfigArgumentMissing = errors.New("configuration file argument missing. Please specify using -config argument. Aborting")
 	ErrorConfigFile            = errors.New("failed to read or parse configuration file. Aborting")
+	ErrorConfigInvalid         = errors.New("configuration validation error")
 )
 
 func init() {
@@ -43,17 +42,34 @@ func ParseCommandLineFlags() {
 }
 
 func parseAndOverwriteConfig(yamlFile []byte) error {
+	newConfigurationData, _, err := parseAndValidateConfig(yamlFile)
+	if err != nil {
+		return err
+	}
+
+	configurationLock.Lock()
+	defer configurationLock.Unlock()
+
+	configurationData = newConfigurationData
+	return nil
+}
+
+// parseAndValidateConfig does not touch the active configuration, so it can also be used for reloading.
+func parseAndValidateConfig(yamlFile []byte) (*conf, url.Values, error) {
 	newConfigurationData := &conf{}
 	err := yaml.UnmarshalStrict(yamlFile, newConfigurationData)
 	if err != nil {
 		// cannot use logging package here as this would create a circular dependency (logging needs config)
 		aulogging.Logger.NoCtx().Error().Printf("failed to parse configuration file '%s': %v", configurationFilename, err)
-		return err
+		return nil, url.Values{"yaml": {err.Error()}}, fmt.Errorf("%w: %s", ErrorConfigInvalid, err.Error())
 	}
 
+	// before validation, so the effective values are validated
+	errs := url.Values{}
+	applyEnvironmentOverrides(errs, newConfigurationData)
+
 	setConfigurationDefaults(newConfigurationData)
 
-	errs := url.Values{}
 	validateServerConfiguration(errs, newConfigurationData.Server)
 	validateLoggingConfiguration(errs, newConfigurationData.Logging)
 	validateSecurityConfiguration(errs, newConfigurationData.Security)
@@ -61,9 +77,21 @@ func parseAndOverwriteConfig(yamlFile []byte) error {
 	validateFlagsConfiguration(errs, newConfigurationData.Choices.Flags)
 	validatePackagesConfiguration(errs, newConfigurationData.Choices.Packages)
 	validateOptionsConfiguration(errs, newConfigurationData.Choices.Options)
+	validateChoiceRules(errs, newConfigurationData.Choices.Flags, "choices.flag_rules", newConfigurationData.Choices.FlagRules)
+	validateChoiceRules(errs, newConfigurationData.Choices.Packages, "choices.package_rules", newConfigurationData.Choices.PackageRules)
+	validateChoiceRules(errs, newConfigurationData.Choices.Options, "choices.option_rules", newConfigurationData.Choices.OptionRules)
 	validateBirthdayConfiguration(errs, newConfigurationData.Birthday)
+	validateEligibilityConfiguration(errs, newConfigurationData.Choices, newConfigurationData.Birthday)
 	validateRegistrationStartTime(errs, newConfigurationData.GoLive, newConfigurationData.Security)
-	validateDownstreamConfiguration(errs, newConfigurationData.Downstream)
+	validateDuesConfiguration(errs, newConfigurationData.Dues)
+	validateDownstreamConfiguration(errs, newConfigurationData.Downstream, newConfigurationData.Security.Fixed)
+	validateDataRetentionConfiguration(errs, newConfigurationData.Retention)
+	validateStatisticsConfiguration(errs, newConfigurationData.Statistics)
+	validateRegdeskConfiguration(errs, newConfigurationData.Regdesk)
+	validateBadgeConfiguration(errs, newConfigurationData.Badges, newConfigurationData.Choices)
+	validateGroupConfiguration(errs, newConfigurationData.Groups, newConfigurationData.Choices)
+	validateEmailVerificationConfiguration(errs, newConfigurationData.EmailVerification)
+	validateMailConfiguration(errs, newConfigurationData.Mail)
 
 	if len(errs) != 0 {
 		var keys []string
@@ -77,14 +105,13 @@ func parseAndOverwriteConfig(yamlFile []byte) error {
 			val := errs[k]
 			aulogging.Logger.NoCtx().Error().Printf("configuration error: %s: %s", key, val[0])
 		}
-		return errors.New("configuration validation error")
+		return nil, errs, ErrorConfigInvalid
 	}
 
-	configurationLock.Lock()
-	defer configurationLock.Unlock()
+	compileChoiceConstraints(&newConfigurationData.Choices)
+	compileSecurityConfiguration(newConfigurationData)
 
-	configurationData = newConfigurationData
-	return nil
+	return newConfigurationData, errs, nil
 }
 
 func loadConfiguration() error {
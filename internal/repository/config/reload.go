@@ -0,0 +1,161 @@
+package config
+
+import (
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	reloadLock      = &sync.Mutex{}
+	reloadListeners = make([]func(), 0)
+)
+
+// these only take effect after a restart, because they are used during startup
+var restartRequiredPrefixes = []string{"server.", "database.", "downstream.", "security.require_login_for_reg", "security.oidc.jwks_refresh_minutes", "data_retention.anonymise_after_days", "data_retention.check_interval_minutes"}
+
+// values of these keys are never logged
+var secretKeySuffixes = []string{".password", ".api", ".token", "_token", ".secret"}
+
+// OnReload registers a function that is called after every successful configuration reload.
+func OnReload(listener func()) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	reloadListeners = append(reloadListeners, listener)
+}
+
+// ReloadConfiguration reads the configuration file again, and atomically replaces the active configuration
+// if the new one is valid. An invalid configuration is rejected, and the old one stays active.
+//
+// Returns a human-readable list of changes, with secret values masked, or the validation errors.
+func ReloadConfiguration() ([]string, url.Values, error) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	yamlFile, err := ioutil.ReadFile(configurationFilename)
+	if err != nil {
+		aulogging.Logger.NoCtx().Error().Printf("failed to load configuration file '%s' for reload: %v", configurationFilename, err)
+		return nil, url.Values{}, err
+	}
+	newConfigurationData, errs, err := parseAndValidateConfig(yamlFile)
+	if err != nil {
+		aulogging.Logger.NoCtx().Error().Print("configuration reload rejected, keeping the active configuration")
+		return nil, errs, err
+	}
+
+	configurationLock.Lock()
+	oldConfigurationData := configurationData
+	configurationData = newConfigurationData
+	configurationLock.Unlock()
+
+	changes := configurationDiff(oldConfigurationData, newConfigurationData)
+	if len(changes) == 0 {
+		aulogging.Logger.NoCtx().Info().Print("configuration reloaded, no changes")
+	}
+	for _, change := range changes {
+		aulogging.Logger.NoCtx().Info().Printf("configuration reloaded: %s", change)
+		if restartRequired(change) {
+			aulogging.Logger.NoCtx().Warn().Printf("configuration change requires a restart to take effect: %s", change)
+		}
+	}
+
+	for _, listener := range reloadListeners {
+		listener()
+	}
+	return changes, errs, nil
+}
+
+func restartRequired(change string) bool {
+	for _, prefix := range restartRequiredPrefixes {
+		if strings.HasPrefix(change, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// configurationDiff lists the changed keys in dotted notation as in the yaml file, sorted by key.
+func configurationDiff(oldConf *conf, newConf *conf) []string {
+	oldValues := flattenConfiguration(oldConf)
+	newValues := flattenConfiguration(newConf)
+
+	keys := make([]string, 0)
+	for k := range oldValues {
+		keys = append(keys, k)
+	}
+	for k := range newValues {
+		if _, ok := oldValues[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	result := make([]string, 0)
+	for _, k := range keys {
+		oldValue, inOld := oldValues[k]
+		newValue, inNew := newValues[k]
+		if inOld && inNew && oldValue == newValue {
+			continue
+		}
+		if isSecretKey(k) {
+			result = append(result, k+": (secret changed)")
+		} else if !inOld {
+			result = append(result, fmt.Sprintf("%s: added %s", k, newValue))
+		} else if !inNew {
+			result = append(result, fmt.Sprintf("%s: removed %s", k, oldValue))
+		} else {
+			result = append(result, fmt.Sprintf("%s: %s -> %s", k, oldValue, newValue))
+		}
+	}
+	return result
+}
+
+func isSecretKey(key string) bool {
+	for _, suffix := range secretKeySuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func flattenConfiguration(c *conf) map[string]string {
+	result := make(map[string]string)
+	if c == nil {
+		return result
+	}
+	marshalled, err := yaml.Marshal(c)
+	if err != nil {
+		return result
+	}
+	var generic interface{}
+	if err := yaml.Unmarshal(marshalled, &generic); err != nil {
+		return result
+	}
+	flattenInto(result, "", generic)
+	return result
+}
+
+func flattenInto(result map[string]string, prefix string, value interface{}) {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		for k, child := range v {
+			key := fmt.Sprint(k)
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			flattenInto(result, key, child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			flattenInto(result, fmt.Sprintf("%s[%d]", prefix, i), child)
+		}
+	default:
+		result[prefix] = fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const reloadTestYaml = `# yaml with minimal settings
+security:
+  fixed_token:
+    api: 'fixed-testing-token-abc'
+  oidc:
+    admin_role: 'admin'
+birthday:
+  earliest: '1851-01-01'
+  latest: '2048-01-01'
+go_live:
+  start_iso_datetime: '2019-11-28T20:00:00+01:00'
+`
+
+func tstWriteReloadConfig(t *testing.T, contents string) {
+	configurationFilename = filepath.Join(t.TempDir(), "config.yaml")
+	require.Nil(t, os.WriteFile(configurationFilename, []byte(contents), 0600))
+}
+
+func TestReloadConfigurationChanged(t *testing.T) {
+	docs.Description("check that a valid changed configuration is activated, and the changes are listed with secrets masked")
+	tstWriteReloadConfig(t, reloadTestYaml)
+	require.Nil(t, StartupLoadConfiguration())
+
+	changed := `# changed
+security:
+  fixed_token:
+    api: 'fixed-testing-token-xyz'
+  oidc:
+    admin_role: 'admin'
+birthday:
+  earliest: '1851-01-01'
+  latest: '2048-01-01'
+go_live:
+  start_iso_datetime: '2019-12-01T20:00:00+01:00'
+logging:
+  severity: DEBUG
+`
+	require.Nil(t, os.WriteFile(configurationFilename, []byte(changed), 0600))
+
+	changes, _, err := ReloadConfiguration()
+	require.Nil(t, err)
+	require.Equal(t, []string{
+		"go_live.start_iso_datetime: 2019-11-28T20:00:00+01:00 -> 2019-12-01T20:00:00+01:00",
+		"logging.severity: INFO -> DEBUG",
+		"security.fixed_token.api: (secret changed)",
+	}, changes)
+	require.Equal(t, "DEBUG", LoggingSeverity())
+	require.Equal(t, "fixed-testing-token-xyz", FixedApiToken())
+}
+
+func TestReloadConfigurationInvalid(t *testing.T) {
+	docs.Description("check that an invalid configuration is rejected, and the active configuration is kept")
+	tstWriteReloadConfig(t, reloadTestYaml)
+	require.Nil(t, StartupLoadConfiguration())
+
+	require.Nil(t, os.WriteFile(configurationFilename, []byte(reloadTestYaml+`logging:
+  severity: FELINE
+`), 0600))
+
+	changes, errs, err := ReloadConfiguration()
+	require.ErrorIs(t, err, ErrorConfigInvalid)
+	require.Nil(t, changes)
+	require.Equal(t, []string{"must be one of DEBUG, INFO, WARN, ERROR"}, errs["logging.severity"])
+	require.Equal(t, "INFO", LoggingSeverity())
+}
+
+func TestReloadConfigurationListeners(t *testing.T) {
+	docs.Description("check that listeners are only called after a successful reload")
+	tstWriteReloadConfig(t, reloadTestYaml)
+	require.Nil(t, StartupLoadConfiguration())
+
+	called := 0
+	OnReload(func() { called++ })
+	defer func() { reloadListeners = make([]func(), 0) }()
+
+	_, _, err := ReloadConfiguration()
+	require.Nil(t, err)
+	require.Equal(t, 1, called)
+
+	require.Nil(t, os.WriteFile(configurationFilename, []byte("serval: 42\n"), 0600))
+	_, _, err = ReloadConfiguration()
+	require.NotNil(t, err)
+	require.Equal(t, 1, called)
+}
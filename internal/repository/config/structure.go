@@ -1,5 +1,7 @@
 package config
 
+import "crypto"
+
 type mysqlConfig struct {
 	Username   string   `yaml:"username"`
 	Password   string   `yaml:"password"`
@@ -21,8 +23,10 @@ type serverConfig struct {
 }
 
 type downstreamConfig struct {
-	PaymentService string `yaml:"payment_service"` // base url, usually http://localhost:nnnn, will use in-memory-mock if unset
-	MailService    string `yaml:"mail_service"`    // base url, usually http://localhost:nnnn, will use in-memory-mock if unset
+	PaymentService      string `yaml:"payment_service"`       // base url, usually http://localhost:nnnn, will use in-memory-mock if unset
+	PaymentServiceToken string `yaml:"payment_service_token"` // api token sent to the payment service, defaults to security.fixed_token.api
+	MailService         string `yaml:"mail_service"`          // base url, usually http://localhost:nnnn, will use in-memory-mock if unset
+	MailServiceToken    string `yaml:"mail_service_token"`    // api token sent to the mail service, defaults to security.fixed_token.api
 }
 
 type loggingConfig struct {
@@ -30,22 +34,55 @@ type loggingConfig struct {
 }
 
 type fixedTokenConfig struct {
-	Api string `yaml:"api"` // shared-secret for server-to-server backend authentication
+	Api string `yaml:"api"` // optional, shared-secret for server-to-server backend authentication, grants all scopes
+}
+
+// ApiClientConfig describes a backend service that may call us, see the ApiScope* constants for the scopes.
+type ApiClientConfig struct {
+	Token  string   `yaml:"token"`  // shared-secret the client sends in the X-Api-Key header
+	Scopes []string `yaml:"scopes"` // what the client may do
 }
 
+const (
+	ApiScopeAll      = "all"      // everything, like an admin
+	ApiScopeRead     = "read"     // read all registrations, their status, admin info and history, and the statistics
+	ApiScopeWrite    = "write"    // create and update registrations
+	ApiScopePayments = "payments" // change the status between approved, partially paid and paid, for the payment service
+	ApiScopeCheckin  = "checkin"  // check in paid attendees and undo recent check-ins, for a regdesk kiosk
+	ApiScopeBadges   = "badges"   // mark badges as printed
+)
+
+// FixedTokenClientName is the api client name used for security.fixed_token.api.
+const FixedTokenClientName = "api"
+
 type openIdConnectConfig struct {
-	TokenCookieName    string   `yaml:"token_cookie_name"`     // optional, if set, the jwt token is also read from this cookie (useful for mixed web application setups, see reg-auth-service)
-	TokenPublicKeysPEM []string `yaml:"token_public_keys_PEM"` // a list of public RSA keys in PEM format, see https://github.com/Jumpy-Squirrel/jwks2pem for obtaining PEM from openid keyset endpoint
-	AdminRole          string   `yaml:"admin_role"`            // the role/group claim that supplies admin rights
-	EarlyReg           string   `yaml:"early_reg_role"`        // optional, the role/group claim that turns on early staff registration
+	TokenCookieName    string       `yaml:"token_cookie_name"`     // optional, if set, the jwt token is also read from this cookie (useful for mixed web application setups, see reg-auth-service)
+	TokenPublicKeysPEM []string     `yaml:"token_public_keys_PEM"` // a list of public RSA or ECDSA (P-256) keys in PEM format, see https://github.com/Jumpy-Squirrel/jwks2pem for obtaining PEM from openid keyset endpoint
+	JwksUrl            string       `yaml:"jwks_url"`              // optional, the keys are also retrieved from this openid keyset endpoint and refreshed periodically
+	JwksRefreshMinutes int          `yaml:"jwks_refresh_minutes"`  // how often the keys are retrieved from jwks_url, defaults to 60
+	Issuer             string       `yaml:"issuer"`                // required if keys are configured, tokens must have exactly this iss claim
+	Audience           string       `yaml:"audience"`              // required if keys are configured, the aud claim of tokens must contain this
+	ClockSkewSeconds   int          `yaml:"clock_skew_seconds"`    // optional, tolerance when checking exp, nbf and iat
+	Claims             claimsConfig `yaml:"claims"`                // optional, where to find the user information in the token
+	AdminRole          string       `yaml:"admin_role"`            // the role/group claim that supplies admin rights
+	EarlyReg           string       `yaml:"early_reg_role"`        // optional, the role/group claim that turns on early staff registration
+}
+
+// claimsConfig gives the paths of the claims in the token, with nested levels separated by dots, e.g. realm_access.roles.
+type claimsConfig struct {
+	Subject string `yaml:"subject"` // defaults to sub
+	Email   string `yaml:"email"`   // defaults to global.email
+	Name    string `yaml:"name"`    // defaults to global.name
+	Roles   string `yaml:"roles"`   // defaults to global.roles, a list of strings or a single string, e.g. groups
 }
 
 type securityConfig struct {
-	Fixed           fixedTokenConfig    `yaml:"fixed_token"`
-	Oidc            openIdConnectConfig `yaml:"oidc"`
-	DisableCors     bool                `yaml:"disable_cors"`
-	CorsAllowOrigin string              `yaml:"cors_allow_origin"`
-	RequireLogin    bool                `yaml:"require_login_for_reg"`
+	Fixed           fixedTokenConfig           `yaml:"fixed_token"`
+	ApiClients      map[string]ApiClientConfig `yaml:"api_clients"` // optional, by client name
+	Oidc            openIdConnectConfig        `yaml:"oidc"`
+	DisableCors     bool                       `yaml:"disable_cors"`
+	CorsAllowOrigin string                     `yaml:"cors_allow_origin"`
+	RequireLogin    bool                       `yaml:"require_login_for_reg"`
 }
 
 type ChoiceConfig struct {
@@ -58,37 +95,130 @@ type ChoiceConfig struct {
 	Default       bool    `yaml:"default"`    // if set to true, is added to flags by default. Not available for admin only flags!
 	AdminOnly     bool    `yaml:"admin_only"` // this flag is kept under the adminInfo structure, so it is not visible to users
 	ReadOnly      bool    `yaml:"read_only"`  // this flag is kept under the normal flags, thus visible to end user, but only admin can change it
-	Constraint    string  `yaml:"constraint"`
+	Constraint    string  `yaml:"constraint"` // optional, must hold whenever this choice is picked, see ChoiceConstraint for the syntax
 	ConstraintMsg string  `yaml:"constraint_msg"`
+
+	Eligibility *ChoiceEligibility `yaml:"eligibility"` // optional, restricts who may pick this choice
+
+	AvailableFrom  string `yaml:"available_from"`  // optional, ISO date/time, can only be newly picked from this time
+	AvailableUntil string `yaml:"available_until"` // optional, ISO date/time, can only be newly picked before this time
+
+	RemovalPolicy       string `yaml:"removal_policy"`        // packages only, whether non-admins may remove the package after the first payment, see RemovalPolicy* constants
+	RemovalAllowedUntil string `yaml:"removal_allowed_until"` // ISO date, last day removal is allowed, only for removal_policy until
+
+	// set during configuration loading
+	ParsedConstraint ChoiceConstraint `yaml:"-"` // nil if there is no constraint
+	Rules            []*ChoiceRule    `yaml:"-"` // the choice rules that reference this choice
+}
+
+const (
+	RemovalPolicyNonRefundable = "non-refundable" // the default, only admins can remove the package after the first payment
+	RemovalPolicyRefundable    = "refundable"     // can always be removed, the dues are reduced accordingly
+	RemovalPolicyUntil         = "until"          // can be removed until RemovalAllowedUntil
+)
+
+// ChoiceEligibility restricts a choice to attendees that meet all the given conditions.
+type ChoiceEligibility struct {
+	MinAge       int      `yaml:"min_age"`       // optional, minimum age on the first day of the convention
+	MaxAge       int      `yaml:"max_age"`       // optional, maximum age on the first day of the convention
+	Countries    []string `yaml:"countries"`     // optional, only attendees whose address is in one of these countries
+	BeforeStatus string   `yaml:"before_status"` // optional, can only be newly picked while the status comes before this one
 }
 
 type flagsPkgOptConfig struct {
-	Flags    map[string]ChoiceConfig `yaml:"flags"`
-	Packages map[string]ChoiceConfig `yaml:"packages"`
-	Options  map[string]ChoiceConfig `yaml:"options"`
+	Flags        map[string]ChoiceConfig `yaml:"flags"`
+	Packages     map[string]ChoiceConfig `yaml:"packages"`
+	Options      map[string]ChoiceConfig `yaml:"options"`
+	FlagRules    []ChoiceRule            `yaml:"flag_rules"`    // optional, rules that must always hold for the flags
+	PackageRules []ChoiceRule            `yaml:"package_rules"` // optional, rules that must always hold for the packages
+	OptionRules  []ChoiceRule            `yaml:"option_rules"`  // optional, rules that must always hold for the options
 }
 
 type birthdayConfig struct {
-	Earliest string `yaml:"earliest"`
-	Latest   string `yaml:"latest"`
+	Earliest    string `yaml:"earliest"`
+	Latest      string `yaml:"latest"`
+	FirstConDay string `yaml:"first_con_day"` // optional, ISO date, needed for age based eligibility conditions
 }
 
 const StartTimeFormat = "2006-01-02T15:04:05-07:00"
 
+const IsoDateFormat = "2006-01-02"
+
 type goLiveConfig struct {
 	StartIsoDatetime         string `yaml:"start_iso_datetime"`
 	EarlyRegStartIsoDatetime string `yaml:"early_reg_start_iso_datetime"` // optional, only useful if you also set early_reg_role
 }
 
+// without any of these, price_early is always charged
+type duesConfig struct {
+	LatePriceFromIsoDatetime  string `yaml:"late_price_from_iso_datetime"`  // optional, registrations made from this time are charged price_late
+	AtConPriceFromIsoDatetime string `yaml:"atcon_price_from_iso_datetime"` // optional, registrations made from this time are charged price_atcon
+}
+
+type dataRetentionConfig struct {
+	ConventionEndIsoDate string `yaml:"convention_end_iso_date"` // optional, once this date has passed, all registrations may be anonymised
+	AnonymiseAfterDays   int    `yaml:"anonymise_after_days"`    // optional, if set, eligible registrations are automatically anonymised this many days after they became eligible
+	CheckIntervalMinutes int    `yaml:"check_interval_minutes"`  // how often the retention policy is applied, defaults to 60
+}
+
+type statisticsConfig struct {
+	PublicMinCount int `yaml:"public_min_count"` // in the public statistics, countries with fewer attendees are combined, defaults to 5
+}
+
+type regdeskConfig struct {
+	Items             []string `yaml:"items"`               // what can be handed out at check-in, defaults to badge, tshirt, bag
+	UndoWindowMinutes int      `yaml:"undo_window_minutes"` // how long after check-in the regdesk can still undo it, defaults to 15
+}
+
+type badgeConfig struct {
+	SponsorPackages []string `yaml:"sponsor_packages"` // packages that are sponsor tiers, highest tier first
+	MarkerFlags     []string `yaml:"marker_flags"`     // flags that are printed on the badge as special markers, may be admin only
+}
+
+type groupConfig struct {
+	RoomPackages []string `yaml:"room_packages"` // packages that book a room, all members of a room group must have the same one
+	MaxMembers   int      `yaml:"max_members"`   // the most members a group can have, including its owner, defaults to 10
+}
+
+type emailVerificationConfig struct {
+	Enabled            bool   `yaml:"enabled"`              // send a verification link on registration and whenever the email address changes
+	Secret             string `yaml:"secret"`               // required if enabled, signs the verification tokens
+	TokenValidityHours int    `yaml:"token_validity_hours"` // how long a verification token can be used, defaults to 72
+	RequireForApproval bool   `yaml:"require_for_approval"` // if set, a registration can only be approved once its email address is verified
+}
+
+// MailTransitionSeparator separates the old and new status in mail.suppress_transitions.
+const MailTransitionSeparator = "->"
+
+type mailConfig struct {
+	Languages           []string          `yaml:"languages"`            // the languages attendees can choose for their mails, empty means no choice
+	DefaultLanguage     string            `yaml:"default_language"`     // optional, sent for attendees who have not chosen a language
+	RegistrationUrl     string            `yaml:"registration_url"`     // optional, link to the registration frontend, {badge_number} is replaced
+	Templates           map[string]string `yaml:"templates"`            // event -> template name, events not listed use the event name
+	SuppressTransitions []string          `yaml:"suppress_transitions"` // status transitions that send no mail, e.g. "approved->partially paid", * matches any status
+	SuppressBulk        bool              `yaml:"suppress_bulk"`        // if set, bulk imports send no status mails
+}
+
 type conf struct {
-	Database    databaseConfig    `yaml:"database"`
-	Server      serverConfig      `yaml:"server"`
-	Choices     flagsPkgOptConfig `yaml:"choices"`
-	Logging     loggingConfig     `yaml:"logging"`
-	Security    securityConfig    `yaml:"security"`
-	TShirtSizes []string          `yaml:"tshirtsizes"`
-	Birthday    birthdayConfig    `yaml:"birthday"`
-	GoLive      goLiveConfig      `yaml:"go_live"`
-	Countries   []string          `yaml:"countries"`
-	Downstream  downstreamConfig  `yaml:"downstream"`
+	Database    databaseConfig      `yaml:"database"`
+	Server      serverConfig        `yaml:"server"`
+	Choices     flagsPkgOptConfig   `yaml:"choices"`
+	Logging     loggingConfig       `yaml:"logging"`
+	Security    securityConfig      `yaml:"security"`
+	TShirtSizes []string            `yaml:"tshirtsizes"`
+	Birthday    birthdayConfig      `yaml:"birthday"`
+	GoLive      goLiveConfig        `yaml:"go_live"`
+	Dues        duesConfig          `yaml:"dues"`
+	Countries   []string            `yaml:"countries"`
+	Downstream  downstreamConfig    `yaml:"downstream"`
+	Retention   dataRetentionConfig `yaml:"data_retention"`
+	Statistics  statisticsConfig    `yaml:"statistics"`
+	Regdesk     regdeskConfig       `yaml:"regdesk"`
+	Badges      badgeConfig         `yaml:"badges"`
+	Groups      groupConfig         `yaml:"groups"`
+
+	EmailVerification emailVerificationConfig `yaml:"email_verification"`
+	Mail              mailConfig              `yaml:"mail"`
+
+	parsedKeySet []crypto.PublicKey // set during configuration loading
 }
@@ -4,10 +4,10 @@ import (
 	"sort"
 )
 
-func sortedKeys(choiceMap map[string]ChoiceConfig) []string {
-	keys := make([]string, len(choiceMap))
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, len(m))
 	i := 0
-	for k := range choiceMap {
+	for k := range m {
 		keys[i] = k
 		i++
 	}
@@ -1,7 +1,7 @@
 package config
 
 import (
-	"crypto/rsa"
+	"crypto"
 	"fmt"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/validation"
 	"github.com/golang-jwt/jwt/v4"
@@ -32,6 +32,39 @@ func setConfigurationDefaults(c *conf) {
 	if c.Security.CorsAllowOrigin == "" {
 		c.Security.CorsAllowOrigin = "*"
 	}
+	if c.Security.Oidc.Claims.Subject == "" {
+		c.Security.Oidc.Claims.Subject = "sub"
+	}
+	if c.Security.Oidc.Claims.Email == "" {
+		c.Security.Oidc.Claims.Email = "global.email"
+	}
+	if c.Security.Oidc.Claims.Name == "" {
+		c.Security.Oidc.Claims.Name = "global.name"
+	}
+	if c.Security.Oidc.Claims.Roles == "" {
+		c.Security.Oidc.Claims.Roles = "global.roles"
+	}
+	if c.Security.Oidc.JwksRefreshMinutes <= 0 {
+		c.Security.Oidc.JwksRefreshMinutes = 60
+	}
+	if c.Retention.CheckIntervalMinutes <= 0 {
+		c.Retention.CheckIntervalMinutes = 60
+	}
+	if c.Statistics.PublicMinCount <= 0 {
+		c.Statistics.PublicMinCount = 5
+	}
+	if len(c.Regdesk.Items) == 0 {
+		c.Regdesk.Items = []string{"badge", "tshirt", "bag"}
+	}
+	if c.Regdesk.UndoWindowMinutes <= 0 {
+		c.Regdesk.UndoWindowMinutes = 15
+	}
+	if c.Groups.MaxMembers <= 0 {
+		c.Groups.MaxMembers = 10
+	}
+	if c.EmailVerification.TokenValidityHours <= 0 {
+		c.EmailVerification.TokenValidityHours = 72
+	}
 }
 
 const portPattern = "^[1-9][0-9]{0,4}$"
@@ -54,16 +87,93 @@ func validateLoggingConfiguration(errs url.Values, c loggingConfig) {
 }
 
 func validateSecurityConfiguration(errs url.Values, c securityConfig) {
-	validation.CheckLength(&errs, 16, 256, "security.fixed.api", c.Fixed.Api)
+	if c.Fixed.Api != "" {
+		validation.CheckLength(&errs, 16, 256, "security.fixed.api", c.Fixed.Api)
+	}
+	validateApiClients(errs, c)
 	validation.CheckLength(&errs, 1, 256, "security.oidc.admin_role", c.Oidc.AdminRole)
 
-	parsedKeySet = make([]*rsa.PublicKey, 0)
 	for i, keyStr := range c.Oidc.TokenPublicKeysPEM {
-		publicKeyPtr, err := jwt.ParseRSAPublicKeyFromPEM([]byte(keyStr))
-		if err != nil {
-			errs.Add(fmt.Sprintf("security.oidc.token_public_keys_PEM[%d]", i), fmt.Sprintf("failed to parse RSA public key in PEM format: %s", err.Error()))
-		} else {
-			parsedKeySet = append(parsedKeySet, publicKeyPtr)
+		if _, err := parsePublicKeyFromPEM(keyStr); err != nil {
+			errs.Add(fmt.Sprintf("security.oidc.token_public_keys_PEM[%d]", i), fmt.Sprintf("failed to parse RSA or ECDSA public key in PEM format: %s", err.Error()))
+		}
+	}
+	if validation.ViolatesPattern(jwksUrlPattern, c.Oidc.JwksUrl) {
+		errs.Add("security.oidc.jwks_url", "must be empty or start with http:// or https://")
+	}
+	validation.CheckIntValueRange(&errs, 1, 10080, "security.oidc.jwks_refresh_minutes", c.Oidc.JwksRefreshMinutes)
+
+	if len(c.Oidc.TokenPublicKeysPEM) > 0 || c.Oidc.JwksUrl != "" {
+		// otherwise any token signed by the identity provider would be accepted, even if it was issued for another service
+		validation.CheckLength(&errs, 1, 256, "security.oidc.issuer", c.Oidc.Issuer)
+		validation.CheckLength(&errs, 1, 256, "security.oidc.audience", c.Oidc.Audience)
+	}
+	validation.CheckIntValueRange(&errs, 0, 300, "security.oidc.clock_skew_seconds", c.Oidc.ClockSkewSeconds)
+	checkClaimPath(errs, "security.oidc.claims.subject", c.Oidc.Claims.Subject)
+	checkClaimPath(errs, "security.oidc.claims.email", c.Oidc.Claims.Email)
+	checkClaimPath(errs, "security.oidc.claims.name", c.Oidc.Claims.Name)
+	checkClaimPath(errs, "security.oidc.claims.roles", c.Oidc.Claims.Roles)
+}
+
+const apiClientNamePattern = "^[a-z0-9-]{1,64}$"
+
+var allowedApiScopes = []string{ApiScopeAll, ApiScopeRead, ApiScopeWrite, ApiScopePayments, ApiScopeCheckin, ApiScopeBadges}
+
+func validateApiClients(errs url.Values, c securityConfig) {
+	tokens := make(map[string]bool)
+	if c.Fixed.Api != "" {
+		tokens[c.Fixed.Api] = true
+	}
+	for _, name := range sortedKeys(c.ApiClients) {
+		client := c.ApiClients[name]
+		key := "security.api_clients." + name
+		if validation.ViolatesPattern(apiClientNamePattern, name) || name == FixedTokenClientName {
+			errs.Add(key, fmt.Sprintf("client name must consist of a-z, 0-9 and -, and cannot be '%s'", FixedTokenClientName))
+		}
+		validation.CheckLength(&errs, 16, 256, key+".token", client.Token)
+		if tokens[client.Token] {
+			errs.Add(key+".token", "each api client must have its own token")
+		}
+		tokens[client.Token] = true
+		if len(client.Scopes) == 0 {
+			errs.Add(key+".scopes", "must list at least one scope")
+		}
+		for _, scope := range client.Scopes {
+			if validation.NotInAllowedValues(allowedApiScopes, scope) {
+				errs.Add(key+".scopes", fmt.Sprintf("invalid scope %s, must be one of %s", scope, strings.Join(allowedApiScopes, ", ")))
+			}
+		}
+	}
+}
+
+const jwksUrlPattern = "^(|https?://.+)$"
+
+const claimPathPattern = "^[^.]+(\\.[^.]+)*$"
+
+func checkClaimPath(errs url.Values, key string, value string) {
+	if validation.ViolatesPattern(claimPathPattern, value) {
+		errs.Add(key, "must be a claim name, or several claim names separated by dots for nested claims")
+	}
+}
+
+// parsePublicKeyFromPEM accepts RSA and ECDSA keys.
+func parsePublicKeyFromPEM(keyStr string) (crypto.PublicKey, error) {
+	rsaKey, err := jwt.ParseRSAPublicKeyFromPEM([]byte(keyStr))
+	if err == nil {
+		return rsaKey, nil
+	}
+	if ecKey, ecErr := jwt.ParseECPublicKeyFromPEM([]byte(keyStr)); ecErr == nil {
+		return ecKey, nil
+	}
+	return nil, err
+}
+
+// compileSecurityConfiguration parses the public keys, which validation has already checked.
+func compileSecurityConfiguration(c *conf) {
+	c.parsedKeySet = make([]crypto.PublicKey, 0)
+	for _, keyStr := range c.Security.Oidc.TokenPublicKeysPEM {
+		if publicKey, err := parsePublicKeyFromPEM(keyStr); err == nil {
+			c.parsedKeySet = append(c.parsedKeySet, publicKey)
 		}
 	}
 }
@@ -90,6 +200,41 @@ func validateBirthdayConfiguration(errs url.Values, c birthdayConfig) {
 	}
 }
 
+// statuses in the order an attendee normally goes through them
+var statusProgression = []string{"new", "approved", "partially paid", "paid", "checked in"}
+
+func validateEligibilityConfiguration(errs url.Values, c flagsPkgOptConfig, b birthdayConfig) {
+	for keyPrefix, choices := range map[string]map[string]ChoiceConfig{"choices.flags": c.Flags, "choices.packages": c.Packages, "choices.options": c.Options} {
+		for k, v := range choices {
+			if v.Eligibility != nil {
+				checkEligibility(errs, keyPrefix+"."+k+".eligibility", v.Eligibility, b.FirstConDay != "")
+			}
+		}
+	}
+	if b.FirstConDay != "" && validation.InvalidISODate(b.FirstConDay) {
+		errs.Add("birthday.first_con_day", "invalid date, must be specified as an ISO Date, as in 2022-08-24")
+	}
+}
+
+func checkEligibility(errs url.Values, keyPrefix string, e *ChoiceEligibility, haveFirstConDay bool) {
+	validation.CheckIntValueRange(&errs, 0, 150, keyPrefix+".min_age", e.MinAge)
+	validation.CheckIntValueRange(&errs, 0, 150, keyPrefix+".max_age", e.MaxAge)
+	if e.MinAge > 0 && e.MaxAge > 0 && e.MinAge > e.MaxAge {
+		errs.Add(keyPrefix+".max_age", "max_age must not be less than min_age")
+	}
+	if (e.MinAge > 0 || e.MaxAge > 0) && !haveFirstConDay {
+		errs.Add(keyPrefix, "age conditions need birthday.first_con_day to be set")
+	}
+	for _, country := range e.Countries {
+		if validation.ViolatesPattern("^[A-Z]{2}$", country) {
+			errs.Add(keyPrefix+".countries", "invalid country "+country+", must be a 2 letter upper case ISO-3166-1 country code")
+		}
+	}
+	if e.BeforeStatus != "" && (validation.NotInAllowedValues(statusProgression, e.BeforeStatus) || e.BeforeStatus == "new") {
+		errs.Add(keyPrefix+".before_status", "before_status must be one of approved, partially paid, paid, checked in")
+	}
+}
+
 const keyPattern = "^[a-zA-Z0-9_-]+$"
 
 func validateFlagsConfiguration(errs url.Values, c map[string]ChoiceConfig) {
@@ -100,12 +245,16 @@ func validateFlagsConfiguration(errs url.Values, c map[string]ChoiceConfig) {
 		validation.CheckLength(&errs, 1, 256, "choices.flags."+k+".description", v.Description)
 		validation.CheckLength(&errs, 1, 256, "choices.flags."+k+".help_url", v.HelpUrl)
 		checkConstraints(errs, c, "choices.flags", k, v.Constraint, v.ConstraintMsg)
+		checkAvailability(errs, "choices.flags."+k, v)
 		if v.AdminOnly && v.ReadOnly {
 			errs.Add("choices.flags."+k+".admin", "a flag cannot both be admin_only and read_only")
 		}
 		if v.AdminOnly && v.Default {
 			errs.Add("choices.flags."+k+".default", "a flag cannot both be admin_only and default to on")
 		}
+		if v.RemovalPolicy != "" || v.RemovalAllowedUntil != "" {
+			errs.Add("choices.flags."+k+".removal_policy", "only packages can have a removal policy (they cost money)")
+		}
 	}
 }
 
@@ -117,9 +266,11 @@ func validatePackagesConfiguration(errs url.Values, c map[string]ChoiceConfig) {
 		validation.CheckLength(&errs, 1, 256, "choices.packages."+k+".description", v.Description)
 		validation.CheckLength(&errs, 1, 256, "choices.packages."+k+".help_url", v.HelpUrl)
 		checkConstraints(errs, c, "choices.packages", k, v.Constraint, v.ConstraintMsg)
+		checkAvailability(errs, "choices.packages."+k, v)
 		if v.AdminOnly {
 			errs.Add("choices.packages."+k+".admin", "packages cannot be admin_only (they cost money). Try read_only instead.")
 		}
+		checkRemovalPolicy(errs, "choices.packages."+k, v)
 	}
 }
 
@@ -131,34 +282,138 @@ func validateOptionsConfiguration(errs url.Values, c map[string]ChoiceConfig) {
 		validation.CheckLength(&errs, 1, 256, "choices.options."+k+".description", v.Description)
 		validation.CheckLength(&errs, 1, 256, "choices.options."+k+".help_url", v.HelpUrl)
 		checkConstraints(errs, c, "choices.options", k, v.Constraint, v.ConstraintMsg)
+		checkAvailability(errs, "choices.options."+k, v)
 		if v.AdminOnly {
 			errs.Add("choices.options."+k+".admin", "options cannot be admin_only (they represent user choices).")
 		}
 		if v.ReadOnly {
 			errs.Add("choices.options."+k+".readonly", "options cannot be read_only (they represent user choices).")
 		}
+		if v.RemovalPolicy != "" || v.RemovalAllowedUntil != "" {
+			errs.Add("choices.options."+k+".removal_policy", "only packages can have a removal policy (they cost money)")
+		}
+	}
+}
+
+func checkAvailability(errs url.Values, keyPrefix string, v ChoiceConfig) {
+	var from, until time.Time
+	var err error
+	if v.AvailableFrom != "" {
+		if from, err = time.Parse(StartTimeFormat, v.AvailableFrom); err != nil {
+			errs.Add(keyPrefix+".available_from", "invalid date/time format, use ISO with numeric timezone as in "+StartTimeFormat)
+		}
+	}
+	if v.AvailableUntil != "" {
+		if until, err = time.Parse(StartTimeFormat, v.AvailableUntil); err != nil {
+			errs.Add(keyPrefix+".available_until", "invalid date/time format, use ISO with numeric timezone as in "+StartTimeFormat)
+		}
+	}
+	if !from.IsZero() && !until.IsZero() && !from.Before(until) {
+		errs.Add(keyPrefix+".available_until", "if both are supplied, must be later than available_from")
+	}
+}
+
+func checkRemovalPolicy(errs url.Values, keyPrefix string, v ChoiceConfig) {
+	switch v.RemovalPolicy {
+	case "", RemovalPolicyNonRefundable, RemovalPolicyRefundable:
+		if v.RemovalAllowedUntil != "" {
+			errs.Add(keyPrefix+".removal_allowed_until", "removal_allowed_until can only be set for removal_policy until")
+		}
+	case RemovalPolicyUntil:
+		if validation.InvalidISODate(v.RemovalAllowedUntil) {
+			errs.Add(keyPrefix+".removal_allowed_until", "removal_policy until requires a valid removal_allowed_until, specified as an ISO Date, as in 2022-08-24")
+		}
+	default:
+		errs.Add(keyPrefix+".removal_policy", "removal_policy must be empty or one of non-refundable, refundable, until")
 	}
 }
 
 func checkConstraints(errs url.Values, c map[string]ChoiceConfig, keyPrefix string, key string, constraint string, constraintMsg string) {
 	if constraint != "" {
-		constraints := strings.Split(constraint, ",")
-		for _, cn := range constraints {
-			choiceKey := cn
-			if strings.HasPrefix(cn, "!") {
-				choiceKey = strings.TrimPrefix(cn, "!")
-			}
-			if _, ok := c[choiceKey]; !ok {
-				errs.Add(keyPrefix+"."+key+".constraint", "invalid key in constraint, references nonexistent entry")
-			} else {
-				if c[choiceKey].AdminOnly != c[key].AdminOnly {
-					errs.Add(keyPrefix+"."+key+".constraint", "invalid key in constraint, references across admin only and non-admin only")
+		parsed, err := ParseChoiceConstraint(constraint)
+		if err != nil {
+			errs.Add(keyPrefix+"."+key+".constraint", "invalid constraint: "+err.Error())
+		} else {
+			for _, choiceKey := range uniqueConstraintKeys(parsed) {
+				if _, ok := c[choiceKey]; !ok {
+					errs.Add(keyPrefix+"."+key+".constraint", "invalid key in constraint, references nonexistent entry")
+				} else {
+					if c[choiceKey].AdminOnly != c[key].AdminOnly {
+						errs.Add(keyPrefix+"."+key+".constraint", "invalid key in constraint, references across admin only and non-admin only")
+					}
+				}
+				if choiceKey == key {
+					errs.Add(keyPrefix+"."+key+".constraint", "invalid self referential constraint")
 				}
 			}
-			if choiceKey == key {
-				errs.Add(keyPrefix+"."+key+".constraint", "invalid self referential constraint")
+		}
+		validation.CheckLength(&errs, 1, 256, keyPrefix+"."+key+".constraint_msg", constraintMsg)
+	}
+}
+
+func validateChoiceRules(errs url.Values, c map[string]ChoiceConfig, keyPrefix string, rules []ChoiceRule) {
+	names := make(map[string]bool)
+	for i, rule := range rules {
+		rulePrefix := fmt.Sprintf("%s[%d]", keyPrefix, i)
+		if validation.ViolatesPattern(keyPattern, rule.Name) {
+			errs.Add(rulePrefix+".name", "invalid name, must consist of a-z A-Z 0-9 - _ only")
+		} else if names[rule.Name] {
+			errs.Add(rulePrefix+".name", "duplicate rule name "+rule.Name)
+		}
+		names[rule.Name] = true
+		validation.CheckLength(&errs, 1, 256, rulePrefix+".constraint_msg", rule.ConstraintMsg)
+
+		parsed, err := ParseChoiceConstraint(rule.Constraint)
+		if err != nil {
+			errs.Add(rulePrefix+".constraint", "invalid constraint: "+err.Error())
+			continue
+		}
+		keys := uniqueConstraintKeys(parsed)
+		for _, choiceKey := range keys {
+			if _, ok := c[choiceKey]; !ok {
+				errs.Add(rulePrefix+".constraint", "invalid key "+choiceKey+" in constraint, references nonexistent entry")
+			} else if c[choiceKey].AdminOnly != c[keys[0]].AdminOnly {
+				errs.Add(rulePrefix+".constraint", "invalid key "+choiceKey+" in constraint, references across admin only and non-admin only")
 			}
-			validation.CheckLength(&errs, 1, 256, keyPrefix+"."+key+".constraint_msg", constraintMsg)
+		}
+	}
+}
+
+func uniqueConstraintKeys(parsed ChoiceConstraint) []string {
+	result := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, k := range parsed.Keys() {
+		if !seen[k] {
+			seen[k] = true
+			result = append(result, k)
+		}
+	}
+	return result
+}
+
+// compileChoiceConstraints stores the parsed constraints and rules with the choices, so they are
+// not parsed again for every request. Only call this for a configuration that passed validation.
+func compileChoiceConstraints(c *flagsPkgOptConfig) {
+	compileChoices(c.Flags, c.FlagRules)
+	compileChoices(c.Packages, c.PackageRules)
+	compileChoices(c.Options, c.OptionRules)
+}
+
+func compileChoices(choices map[string]ChoiceConfig, rules []ChoiceRule) {
+	for k, v := range choices {
+		if v.Constraint != "" {
+			v.ParsedConstraint, _ = ParseChoiceConstraint(v.Constraint)
+		}
+		v.Rules = nil
+		choices[k] = v
+	}
+	for i := range rules {
+		rule := &rules[i]
+		rule.ParsedConstraint, _ = ParseChoiceConstraint(rule.Constraint)
+		for _, k := range uniqueConstraintKeys(rule.ParsedConstraint) {
+			v := choices[k]
+			v.Rules = append(v.Rules, rule)
+			choices[k] = v
 		}
 	}
 }
@@ -185,13 +440,149 @@ func validateRegistrationStartTime(errs url.Values, c goLiveConfig, s securityCo
 	}
 }
 
+func validateDuesConfiguration(errs url.Values, c duesConfig) {
+	var late, atCon time.Time
+	var err error
+	if c.LatePriceFromIsoDatetime != "" {
+		if late, err = time.Parse(StartTimeFormat, c.LatePriceFromIsoDatetime); err != nil {
+			errs.Add("dues.late_price_from_iso_datetime", "invalid date/time format, use ISO with numeric timezone as in "+StartTimeFormat)
+		}
+	}
+	if c.AtConPriceFromIsoDatetime != "" {
+		if atCon, err = time.Parse(StartTimeFormat, c.AtConPriceFromIsoDatetime); err != nil {
+			errs.Add("dues.atcon_price_from_iso_datetime", "invalid date/time format, use ISO with numeric timezone as in "+StartTimeFormat)
+		}
+	}
+	if !late.IsZero() && !atCon.IsZero() && atCon.Before(late) {
+		errs.Add("dues.atcon_price_from_iso_datetime", "if supplied together with dues.late_price_from_iso_datetime, must not be earlier")
+	}
+}
+
 const downstreamPattern = "^(|https?://.*[^/])$"
 
-func validateDownstreamConfiguration(errs url.Values, c downstreamConfig) {
+func validateDownstreamConfiguration(errs url.Values, c downstreamConfig, fixed fixedTokenConfig) {
 	if validation.ViolatesPattern(downstreamPattern, c.PaymentService) {
 		errs.Add("downstream.payment_service", "base url must be empty (enables in-memory simulator) or start with http:// or https:// and may not end in a /")
 	}
 	if validation.ViolatesPattern(downstreamPattern, c.MailService) {
 		errs.Add("downstream.payment_service", "base url must be empty (enables in-memory simulator) or start with http:// or https:// and may not end in a /")
 	}
+	checkDownstreamToken(errs, "downstream.payment_service_token", c.PaymentService, c.PaymentServiceToken, fixed)
+	checkDownstreamToken(errs, "downstream.mail_service_token", c.MailService, c.MailServiceToken, fixed)
+}
+
+// checkDownstreamToken allows falling back to security.fixed_token.api.
+func checkDownstreamToken(errs url.Values, key string, baseUrl string, token string, fixed fixedTokenConfig) {
+	if token != "" {
+		validation.CheckLength(&errs, 16, 256, key, token)
+	} else if baseUrl != "" && fixed.Api == "" {
+		errs.Add(key, "must be set if the service is configured, unless security.fixed_token.api is set")
+	}
+}
+
+func validateDataRetentionConfiguration(errs url.Values, c dataRetentionConfig) {
+	if c.ConventionEndIsoDate != "" && validation.InvalidISODate(c.ConventionEndIsoDate) {
+		errs.Add("data_retention.convention_end_iso_date", "invalid date, must be specified as an ISO Date, as in 2022-08-28")
+	}
+	validation.CheckIntValueRange(&errs, 0, 3650, "data_retention.anonymise_after_days", c.AnonymiseAfterDays)
+	validation.CheckIntValueRange(&errs, 1, 10080, "data_retention.check_interval_minutes", c.CheckIntervalMinutes)
+}
+
+func validateStatisticsConfiguration(errs url.Values, c statisticsConfig) {
+	validation.CheckIntValueRange(&errs, 1, 1000, "statistics.public_min_count", c.PublicMinCount)
+}
+
+const regdeskItemPattern = "^[a-z0-9_-]{1,32}$"
+
+func validateRegdeskConfiguration(errs url.Values, c regdeskConfig) {
+	seen := make(map[string]bool)
+	for _, item := range c.Items {
+		if validation.ViolatesPattern(regdeskItemPattern, item) {
+			errs.Add("regdesk.items", fmt.Sprintf("invalid item %s, must consist of 1 to 32 lowercase letters, digits, _ or -", item))
+		} else if seen[item] {
+			errs.Add("regdesk.items", fmt.Sprintf("duplicate item %s", item))
+		}
+		seen[item] = true
+	}
+	validation.CheckIntValueRange(&errs, 1, 1440, "regdesk.undo_window_minutes", c.UndoWindowMinutes)
+}
+
+func validateBadgeConfiguration(errs url.Values, c badgeConfig, choices flagsPkgOptConfig) {
+	checkChoiceList(errs, "badges.sponsor_packages", "package", c.SponsorPackages, choices.Packages)
+	checkChoiceList(errs, "badges.marker_flags", "flag", c.MarkerFlags, choices.Flags)
+}
+
+func validateGroupConfiguration(errs url.Values, c groupConfig, choices flagsPkgOptConfig) {
+	checkChoiceList(errs, "groups.room_packages", "package", c.RoomPackages, choices.Packages)
+	validation.CheckIntValueRange(&errs, 2, 100, "groups.max_members", c.MaxMembers)
+}
+
+func validateEmailVerificationConfiguration(errs url.Values, c emailVerificationConfig) {
+	if c.Enabled {
+		validation.CheckLength(&errs, 32, 256, "email_verification.secret", c.Secret)
+	} else if c.RequireForApproval {
+		errs.Add("email_verification.require_for_approval", "can only be set if email verification is enabled")
+	}
+	validation.CheckIntValueRange(&errs, 1, 8760, "email_verification.token_validity_hours", c.TokenValidityHours)
+}
+
+const languagePattern = "^[a-z]{2}(-[A-Z]{2})?$"
+
+// the mail events other than status changes, whose events are new-status-<status>
+var mailEvents = []string{"email-verification", "guest-invitation", "transfer-in", "transfer-out"}
+
+func validateMailConfiguration(errs url.Values, c mailConfig) {
+	seen := make(map[string]bool)
+	for _, language := range c.Languages {
+		if validation.ViolatesPattern(languagePattern, language) {
+			errs.Add("mail.languages", fmt.Sprintf("invalid language %s, must be a language code like en or en-US", language))
+		} else if seen[language] {
+			errs.Add("mail.languages", fmt.Sprintf("duplicate language %s", language))
+		}
+		seen[language] = true
+	}
+	if c.DefaultLanguage != "" {
+		if validation.ViolatesPattern(languagePattern, c.DefaultLanguage) {
+			errs.Add("mail.default_language", "must be a language code like en or en-US")
+		} else if len(c.Languages) > 0 && !seen[c.DefaultLanguage] {
+			errs.Add("mail.default_language", "must be one of the languages")
+		}
+	}
+
+	if validation.ViolatesPattern("^(|https?://.+)$", c.RegistrationUrl) {
+		errs.Add("mail.registration_url", "must be an http or https url")
+	}
+
+	events := make([]string, 0)
+	for _, status := range AllowedStatusValues() {
+		events = append(events, "new-status-"+status)
+	}
+	events = append(events, mailEvents...)
+	for event, template := range c.Templates {
+		if validation.NotInAllowedValues(events, event) {
+			errs.Add("mail.templates", fmt.Sprintf("invalid event %s, must be new-status-<status> or one of %s", event, strings.Join(mailEvents, ", ")))
+		} else if template == "" {
+			errs.Add("mail.templates", fmt.Sprintf("empty template name for event %s, use suppress_transitions to stop mails", event))
+		}
+	}
+
+	statuses := append([]string{"*"}, AllowedStatusValues()...)
+	for _, transition := range c.SuppressTransitions {
+		from, to, found := strings.Cut(transition, MailTransitionSeparator)
+		if !found || validation.NotInAllowedValues(statuses, from) || validation.NotInAllowedValues(statuses, to) {
+			errs.Add("mail.suppress_transitions", fmt.Sprintf("invalid transition %s, must be <old status>%s<new status>, where * matches any status", transition, MailTransitionSeparator))
+		}
+	}
+}
+
+func checkChoiceList(errs url.Values, key string, kind string, list []string, choices map[string]ChoiceConfig) {
+	seen := make(map[string]bool)
+	for _, entry := range list {
+		if _, ok := choices[entry]; !ok {
+			errs.Add(key, fmt.Sprintf("invalid %s %s, references nonexistent entry", kind, entry))
+		} else if seen[entry] {
+			errs.Add(key, fmt.Sprintf("duplicate %s %s", kind, entry))
+		}
+		seen[entry] = true
+	}
 }
@@ -84,3 +84,382 @@ func TestCheckOptions(t *testing.T) {
 		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
 	}
 }
+
+func TestValidateDataRetention(t *testing.T) {
+	c := dataRetentionConfig{ConventionEndIsoDate: "2022-13-01", AnonymiseAfterDays: -1, CheckIntervalMinutes: 60}
+
+	actualErrors := url.Values{}
+	validateDataRetentionConfiguration(actualErrors, c)
+	expectedErrors := url.Values{
+		"data_retention.convention_end_iso_date": []string{"invalid date, must be specified as an ISO Date, as in 2022-08-28"},
+		"data_retention.anonymise_after_days":    []string{"data_retention.anonymise_after_days field must be an integer at least 0 and at most 3650"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestValidateDues(t *testing.T) {
+	c := duesConfig{LatePriceFromIsoDatetime: "2022-06-01T00:00:00+02:00", AtConPriceFromIsoDatetime: "2022-05-01T00:00:00+02:00"}
+
+	actualErrors := url.Values{}
+	validateDuesConfiguration(actualErrors, c)
+	expectedErrors := url.Values{
+		"dues.atcon_price_from_iso_datetime": []string{"if supplied together with dues.late_price_from_iso_datetime, must not be earlier"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestValidateDuesFormat(t *testing.T) {
+	c := duesConfig{LatePriceFromIsoDatetime: "2022-06-01", AtConPriceFromIsoDatetime: "tomorrow"}
+
+	actualErrors := url.Values{}
+	validateDuesConfiguration(actualErrors, c)
+	expectedErrors := url.Values{
+		"dues.late_price_from_iso_datetime":  []string{"invalid date/time format, use ISO with numeric timezone as in 2006-01-02T15:04:05-07:00"},
+		"dues.atcon_price_from_iso_datetime": []string{"invalid date/time format, use ISO with numeric timezone as in 2006-01-02T15:04:05-07:00"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestValidateStatistics(t *testing.T) {
+	c := statisticsConfig{PublicMinCount: 1001}
+
+	actualErrors := url.Values{}
+	validateStatisticsConfiguration(actualErrors, c)
+	expectedErrors := url.Values{
+		"statistics.public_min_count": []string{"statistics.public_min_count field must be an integer at least 1 and at most 1000"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestValidateRegdesk(t *testing.T) {
+	c := regdeskConfig{Items: []string{"badge", "T-Shirt", "badge"}, UndoWindowMinutes: 1441}
+
+	actualErrors := url.Values{}
+	validateRegdeskConfiguration(actualErrors, c)
+	expectedErrors := url.Values{
+		"regdesk.items": []string{
+			"invalid item T-Shirt, must consist of 1 to 32 lowercase letters, digits, _ or -",
+			"duplicate item badge",
+		},
+		"regdesk.undo_window_minutes": []string{"regdesk.undo_window_minutes field must be an integer at least 1 and at most 1440"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestValidateBadges(t *testing.T) {
+	c := badgeConfig{SponsorPackages: []string{"sponsor2", "sponsor", "sponsor2"}, MarkerFlags: []string{"guest", "unicorn"}}
+	choices := flagsPkgOptConfig{
+		Flags:    map[string]ChoiceConfig{"guest": {AdminOnly: true}},
+		Packages: map[string]ChoiceConfig{"sponsor": {}, "sponsor2": {}},
+	}
+
+	actualErrors := url.Values{}
+	validateBadgeConfiguration(actualErrors, c, choices)
+	expectedErrors := url.Values{
+		"badges.sponsor_packages": []string{"duplicate package sponsor2"},
+		"badges.marker_flags":     []string{"invalid flag unicorn, references nonexistent entry"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestValidateGroups(t *testing.T) {
+	c := groupConfig{RoomPackages: []string{"room-single", "room-unicorn"}, MaxMembers: 1}
+	choices := flagsPkgOptConfig{
+		Packages: map[string]ChoiceConfig{"room-single": {}, "room-double": {}},
+	}
+
+	actualErrors := url.Values{}
+	validateGroupConfiguration(actualErrors, c, choices)
+	expectedErrors := url.Values{
+		"groups.room_packages": []string{"invalid package room-unicorn, references nonexistent entry"},
+		"groups.max_members":   []string{"groups.max_members field must be an integer at least 2 and at most 100"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestValidateEmailVerification(t *testing.T) {
+	c := emailVerificationConfig{Enabled: true, Secret: "too short", TokenValidityHours: 0}
+
+	actualErrors := url.Values{}
+	validateEmailVerificationConfiguration(actualErrors, c)
+	expectedErrors := url.Values{
+		"email_verification.secret":               []string{"email_verification.secret field must be at least 32 and at most 256 characters long"},
+		"email_verification.token_validity_hours": []string{"email_verification.token_validity_hours field must be an integer at least 1 and at most 8760"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestValidateEmailVerificationDisabled(t *testing.T) {
+	c := emailVerificationConfig{RequireForApproval: true, TokenValidityHours: 72}
+
+	actualErrors := url.Values{}
+	validateEmailVerificationConfiguration(actualErrors, c)
+	expectedErrors := url.Values{
+		"email_verification.require_for_approval": []string{"can only be set if email verification is enabled"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestValidateMail(t *testing.T) {
+	c := mailConfig{
+		Languages:       []string{"en-US", "de_DE", "en-US"},
+		DefaultLanguage: "fr-FR",
+		RegistrationUrl: "reg.example.com",
+		Templates: map[string]string{
+			"new-status-approved": "approval",
+			"new-status-unicorn":  "unicorn",
+		},
+		SuppressTransitions: []string{"approved->partially paid", "*->deleted", "paid", "new->unicorn"},
+	}
+
+	actualErrors := url.Values{}
+	validateMailConfiguration(actualErrors, c)
+	expectedErrors := url.Values{
+		"mail.languages": []string{
+			"invalid language de_DE, must be a language code like en or en-US",
+			"duplicate language en-US",
+		},
+		"mail.default_language": []string{"must be one of the languages"},
+		"mail.registration_url": []string{"must be an http or https url"},
+		"mail.templates":        []string{"invalid event new-status-unicorn, must be new-status-<status> or one of email-verification, guest-invitation, transfer-in, transfer-out"},
+		"mail.suppress_transitions": []string{
+			"invalid transition paid, must be <old status>-><new status>, where * matches any status",
+			"invalid transition new->unicorn, must be <old status>-><new status>, where * matches any status",
+		},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestValidateChoiceRules(t *testing.T) {
+	c := make(map[string]ChoiceConfig)
+	c["sponsor"] = ChoiceConfig{}
+	c["sponsor2"] = ChoiceConfig{}
+	c["guest"] = ChoiceConfig{AdminOnly: true}
+	rules := []ChoiceRule{
+		{Name: "one-tier", Constraint: "at-most(1, sponsor, sponsor2)", ConstraintMsg: "only one sponsor tier"},
+		{Name: "one-tier", Constraint: "sponsor or unicorn", ConstraintMsg: "duplicate name"},
+		{Name: "cross", Constraint: "not (guest and sponsor)"},
+		{Name: "bad syntax", Constraint: "sponsor and", ConstraintMsg: "syntax"},
+	}
+
+	actualErrors := url.Values{}
+	validateChoiceRules(actualErrors, c, "blah", rules)
+	expectedErrors := url.Values{
+		"blah[1].name":           []string{"duplicate rule name one-tier"},
+		"blah[1].constraint":     []string{"invalid key unicorn in constraint, references nonexistent entry"},
+		"blah[2].constraint":     []string{"invalid key sponsor in constraint, references across admin only and non-admin only"},
+		"blah[2].constraint_msg": []string{"blah[2].constraint_msg field must be at least 1 and at most 256 characters long"},
+		"blah[3].name":           []string{"invalid name, must consist of a-z A-Z 0-9 - _ only"},
+		"blah[3].constraint":     []string{"invalid constraint: constraint ended unexpectedly"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestValidateEligibility(t *testing.T) {
+	c := flagsPkgOptConfig{
+		Packages: map[string]ChoiceConfig{
+			"youth":    {Eligibility: &ChoiceEligibility{MinAge: 20, MaxAge: 18}},
+			"regional": {Eligibility: &ChoiceEligibility{Countries: []string{"DE", "de"}}},
+			"upgrade":  {Eligibility: &ChoiceEligibility{BeforeStatus: "new"}},
+		},
+	}
+	b := birthdayConfig{Earliest: "1901-01-01", Latest: "2001-08-14"}
+
+	actualErrors := url.Values{}
+	validateEligibilityConfiguration(actualErrors, c, b)
+	expectedErrors := url.Values{
+		"choices.packages.youth.eligibility.max_age":         []string{"max_age must not be less than min_age"},
+		"choices.packages.youth.eligibility":                 []string{"age conditions need birthday.first_con_day to be set"},
+		"choices.packages.regional.eligibility.countries":    []string{"invalid country de, must be a 2 letter upper case ISO-3166-1 country code"},
+		"choices.packages.upgrade.eligibility.before_status": []string{"before_status must be one of approved, partially paid, paid, checked in"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestValidateRemovalPolicy(t *testing.T) {
+	c := make(map[string]ChoiceConfig)
+	c["sponsor"] = ChoiceConfig{Description: "Sponsor", HelpUrl: "help/sponsor.html", RemovalPolicy: "until", RemovalAllowedUntil: "2022-13-01"}
+	c["sponsor2"] = ChoiceConfig{Description: "Supersponsor", HelpUrl: "help/sponsor2.html", RemovalPolicy: "sometimes"}
+	c["room"] = ChoiceConfig{Description: "Room", HelpUrl: "help/room.html", RemovalPolicy: "refundable", RemovalAllowedUntil: "2022-07-01"}
+
+	actualErrors := url.Values{}
+	validatePackagesConfiguration(actualErrors, c)
+	expectedErrors := url.Values{
+		"choices.packages.sponsor.removal_allowed_until": []string{"removal_policy until requires a valid removal_allowed_until, specified as an ISO Date, as in 2022-08-24"},
+		"choices.packages.sponsor2.removal_policy":       []string{"removal_policy must be empty or one of non-refundable, refundable, until"},
+		"choices.packages.room.removal_allowed_until":    []string{"removal_allowed_until can only be set for removal_policy until"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestValidateAvailability(t *testing.T) {
+	c := make(map[string]ChoiceConfig)
+	c["art"] = ChoiceConfig{Description: "Artist", HelpUrl: "help/art.html", AvailableFrom: "2022-01-01"}
+	c["anim"] = ChoiceConfig{Description: "Animator", HelpUrl: "help/anim.html", AvailableFrom: "2022-02-01T00:00:00+01:00", AvailableUntil: "2022-01-01T00:00:00+01:00"}
+	c["music"] = ChoiceConfig{Description: "Musician", HelpUrl: "help/music.html", AvailableFrom: "2022-01-01T00:00:00+01:00", AvailableUntil: "2022-02-01T00:00:00+01:00"}
+
+	actualErrors := url.Values{}
+	validateOptionsConfiguration(actualErrors, c)
+	expectedErrors := url.Values{
+		"choices.options.art.available_from":   []string{"invalid date/time format, use ISO with numeric timezone as in " + StartTimeFormat},
+		"choices.options.anim.available_until": []string{"if both are supplied, must be later than available_from"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestValidateSecurityJwks(t *testing.T) {
+	c := securityConfig{
+		Fixed: fixedTokenConfig{Api: "fixed-testing-token-abc"},
+		Oidc: openIdConnectConfig{
+			AdminRole:          "admin",
+			JwksUrl:            "ftp://idp.example.com/keys",
+			JwksRefreshMinutes: 0,
+			Issuer:             "https://idp.example.com",
+			Audience:           "reg-attendee-service",
+			Claims:             tstDefaultClaims(),
+		},
+	}
+
+	actualErrors := url.Values{}
+	validateSecurityConfiguration(actualErrors, c)
+	expectedErrors := url.Values{
+		"security.oidc.jwks_url":             []string{"must be empty or start with http:// or https://"},
+		"security.oidc.jwks_refresh_minutes": []string{"security.oidc.jwks_refresh_minutes field must be an integer at least 1 and at most 10080"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func tstDefaultClaims() claimsConfig {
+	return claimsConfig{Subject: "sub", Email: "global.email", Name: "global.name", Roles: "global.roles"}
+}
+
+func TestValidateSecurityClaims(t *testing.T) {
+	c := securityConfig{
+		Fixed: fixedTokenConfig{Api: "fixed-testing-token-abc"},
+		Oidc: openIdConnectConfig{
+			AdminRole:          "admin",
+			TokenPublicKeysPEM: []string{"not a key"},
+			JwksRefreshMinutes: 60,
+			ClockSkewSeconds:   301,
+			Claims:             claimsConfig{Subject: "sub", Email: "email", Name: "profile..name", Roles: "realm_access.roles"},
+		},
+	}
+
+	actualErrors := url.Values{}
+	validateSecurityConfiguration(actualErrors, c)
+	expectedErrors := url.Values{
+		"security.oidc.token_public_keys_PEM[0]": []string{"failed to parse RSA or ECDSA public key in PEM format: invalid key: Key must be a PEM encoded PKCS1 or PKCS8 key"},
+		"security.oidc.issuer":                   []string{"security.oidc.issuer field must be at least 1 and at most 256 characters long"},
+		"security.oidc.audience":                 []string{"security.oidc.audience field must be at least 1 and at most 256 characters long"},
+		"security.oidc.clock_skew_seconds":       []string{"security.oidc.clock_skew_seconds field must be an integer at least 0 and at most 300"},
+		"security.oidc.claims.name":              []string{"must be a claim name, or several claim names separated by dots for nested claims"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestValidateApiClients(t *testing.T) {
+	c := securityConfig{
+		Fixed: fixedTokenConfig{Api: "fixed-testing-token-abc"},
+		ApiClients: map[string]ApiClientConfig{
+			"api":             {Token: "token-for-the-api-client", Scopes: []string{"all"}},
+			"payment-service": {Token: "fixed-testing-token-abc", Scopes: []string{"status"}},
+			"regdesk-kiosk":   {Token: "short"},
+		},
+		Oidc: openIdConnectConfig{AdminRole: "admin", JwksRefreshMinutes: 60, Claims: tstDefaultClaims()},
+	}
+
+	actualErrors := url.Values{}
+	validateSecurityConfiguration(actualErrors, c)
+	expectedErrors := url.Values{
+		"security.api_clients.api":                    []string{"client name must consist of a-z, 0-9 and -, and cannot be 'api'"},
+		"security.api_clients.payment-service.token":  []string{"each api client must have its own token"},
+		"security.api_clients.payment-service.scopes": []string{"invalid scope status, must be one of all, read, write, payments, checkin, badges"},
+		"security.api_clients.regdesk-kiosk.token":    []string{"security.api_clients.regdesk-kiosk.token field must be at least 16 and at most 256 characters long"},
+		"security.api_clients.regdesk-kiosk.scopes":   []string{"must list at least one scope"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
+
+func TestValidateDownstreamTokens(t *testing.T) {
+	c := downstreamConfig{PaymentService: "http://localhost:9092", MailService: "http://localhost:9093", MailServiceToken: "short"}
+
+	actualErrors := url.Values{}
+	validateDownstreamConfiguration(actualErrors, c, fixedTokenConfig{})
+	expectedErrors := url.Values{
+		"downstream.payment_service_token": []string{"must be set if the service is configured, unless security.fixed_token.api is set"},
+		"downstream.mail_service_token":    []string{"downstream.mail_service_token field must be at least 16 and at most 256 characters long"},
+	}
+	prettyprintedActualErrors, _ := json.MarshalIndent(actualErrors, "", "  ")
+	prettyprintedExpectedErrors, _ := json.MarshalIndent(expectedErrors, "", "  ")
+	if !reflect.DeepEqual(actualErrors, expectedErrors) {
+		t.Errorf("Errors were not as expected.\nActual:\n%v\nExpected:\n%v\n", string(prettyprintedActualErrors), string(prettyprintedExpectedErrors))
+	}
+}
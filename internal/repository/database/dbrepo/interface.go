@@ -2,8 +2,10 @@ package dbrepo
 
 import (
 	"context"
+	"errors"
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"time"
 )
 
 type Repository interface {
@@ -16,9 +18,17 @@ type Repository interface {
 	GetAttendeeById(ctx context.Context, id uint) (*entity.Attendee, error)
 	CountAttendeesByNicknameZipEmail(ctx context.Context, nickname string, zip string, email string) (int64, error)
 	MaxAttendeeId(ctx context.Context) (uint, error)
+	// FindAnonymisationCandidates returns the ids of all attendees that have not been anonymised yet, and whose
+	// latest status is deleted since before deletedBefore. If includeAll is set, the status does not matter.
+	FindAnonymisationCandidates(ctx context.Context, deletedBefore time.Time, includeAll bool) ([]uint, error)
+	// FindAttendeesByStatus returns the attendees with ids from minId to maxId whose latest status is one of the given ones,
+	// together with that status, ordered by id.
+	FindAttendeesByStatus(ctx context.Context, statuses []string, minId uint, maxId uint) ([]*entity.AttendeeWithStatus, error)
 
 	GetAdminInfoByAttendeeId(ctx context.Context, attendeeId uint) (*entity.AdminInfo, error)
 	WriteAdminInfo(ctx context.Context, ai *entity.AdminInfo) error
+	// GetAdminInfosByAttendeeIds returns the admin info of the given attendees. Attendees without admin info are left out.
+	GetAdminInfosByAttendeeIds(ctx context.Context, attendeeIds []uint) ([]*entity.AdminInfo, error)
 
 	// GetLatestStatusChangeByAttendeeId returns the latest status change entry for the given attendee id.
 	//
@@ -30,13 +40,63 @@ type Repository interface {
 	FindAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria) ([]*entity.Attendee, error)
 	FindByIdentity(ctx context.Context, identity string) ([]*entity.Attendee, error)
 
+	// CountAttendeesByGroup counts attendees grouped by their latest status, country, flags, packages and t-shirt size.
+	CountAttendeesByGroup(ctx context.Context) ([]*entity.AttendeeCountGroup, error)
+	// CountRegistrationsPerDay counts attendees by the day their registration was created, oldest first.
+	CountRegistrationsPerDay(ctx context.Context) ([]*entity.DailyCount, error)
+
 	GetAllBans(ctx context.Context) ([]*entity.Ban, error)
 	GetBanById(ctx context.Context, id uint) (*entity.Ban, error)
 	AddBan(ctx context.Context, b *entity.Ban) (uint, error)
 	UpdateBan(ctx context.Context, b *entity.Ban) error
 
+	GetAllGroups(ctx context.Context) ([]*entity.Group, error)
+	GetGroupById(ctx context.Context, id uint) (*entity.Group, error)
+	AddGroup(ctx context.Context, g *entity.Group) (uint, error)
+	UpdateGroup(ctx context.Context, g *entity.Group) error
+	// DeleteGroup soft deletes a group. Its memberships must be deleted separately.
+	DeleteGroup(ctx context.Context, id uint) error
+	GetGroupMembersByGroupId(ctx context.Context, groupId uint) ([]*entity.GroupMember, error)
+	GetGroupMembersByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.GroupMember, error)
+	// GetGroupMembersByGroupType returns the memberships in all groups of the given type.
+	GetGroupMembersByGroupType(ctx context.Context, groupType string) ([]*entity.GroupMember, error)
+	AddGroupMember(ctx context.Context, m *entity.GroupMember) (uint, error)
+	DeleteGroupMember(ctx context.Context, id uint) error
+
+	GetAllVouchers(ctx context.Context) ([]*entity.Voucher, error)
+	GetVoucherById(ctx context.Context, id uint) (*entity.Voucher, error)
+	// GetVoucherByCode returns gorm.ErrRecordNotFound if there is no voucher with this code.
+	GetVoucherByCode(ctx context.Context, code string) (*entity.Voucher, error)
+	AddVoucher(ctx context.Context, v *entity.Voucher) (uint, error)
+	UpdateVoucher(ctx context.Context, v *entity.Voucher) error
+	// CountAttendeesByVoucher counts the active registrations (neither cancelled nor deleted) that have redeemed the voucher code.
+	CountAttendeesByVoucher(ctx context.Context, code string) (int64, error)
+	// AddAttendeeRedeemingVoucher works like AddAttendee, but fails with VoucherUsedUpError without writing anything
+	// if the voucher code of the attendee has already been redeemed by the maximum number of active registrations.
+	// The check and the write are atomic.
+	AddAttendeeRedeemingVoucher(ctx context.Context, a *entity.Attendee) (uint, error)
+	// UpdateAttendeeRedeemingVoucher is the same for UpdateAttendee. The attendee itself is not counted.
+	UpdateAttendeeRedeemingVoucher(ctx context.Context, a *entity.Attendee) error
+	// AddStatusChangeRedeemingVoucher is the same for AddStatusChange, for registrations that become active again.
+	// The attendee of the status change is not counted.
+	AddStatusChangeRedeemingVoucher(ctx context.Context, sc *entity.StatusChange, code string) error
+
 	GetAdditionalInfoFor(ctx context.Context, attendeeId uint, area string) (*entity.AdditionalInfo, error)
+	// GetAllAdditionalInfoFor returns the additional info entries for all areas that exist for an attendee.
+	GetAllAdditionalInfoFor(ctx context.Context, attendeeId uint) ([]*entity.AdditionalInfo, error)
+	// GetAdditionalInfoForAttendees returns the additional info entries in an area for the given attendees. Attendees without one are left out.
+	GetAdditionalInfoForAttendees(ctx context.Context, attendeeIds []uint, area string) ([]*entity.AdditionalInfo, error)
 	WriteAdditionalInfo(ctx context.Context, ad *entity.AdditionalInfo) error
 
 	RecordHistory(ctx context.Context, h *entity.History) error
+	// GetHistoryByEntity returns all history entries for the given entity, oldest first.
+	GetHistoryByEntity(ctx context.Context, entityName string, entityId uint) ([]*entity.History, error)
+	// GetHistoryByEntities returns all history entries for the given entities of one type, oldest first.
+	GetHistoryByEntities(ctx context.Context, entityName string, entityIds []uint) ([]*entity.History, error)
+	// ScrubHistory overwrites the diff of an existing history entry.
+	//
+	// This is only meant for removing personal data during anonymisation. History is otherwise append only.
+	ScrubHistory(ctx context.Context, h *entity.History) error
 }
+
+var VoucherUsedUpError = errors.New("voucher code has already been used the maximum number of times")
@@ -10,6 +10,7 @@ import (
 	"github.com/eurofurence/reg-attendee-service/internal/repository/database/dbrepo"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
 	"gorm.io/gorm"
+	"time"
 )
 
 type HistorizingRepository struct {
@@ -66,6 +67,14 @@ func (r *HistorizingRepository) MaxAttendeeId(ctx context.Context) (uint, error)
 	return r.wrappedRepository.MaxAttendeeId(ctx)
 }
 
+func (r *HistorizingRepository) FindAnonymisationCandidates(ctx context.Context, deletedBefore time.Time, includeAll bool) ([]uint, error) {
+	return r.wrappedRepository.FindAnonymisationCandidates(ctx, deletedBefore, includeAll)
+}
+
+func (r *HistorizingRepository) FindAttendeesByStatus(ctx context.Context, statuses []string, minId uint, maxId uint) ([]*entity.AttendeeWithStatus, error) {
+	return r.wrappedRepository.FindAttendeesByStatus(ctx, statuses, minId, maxId)
+}
+
 // --- attendee search ---
 
 func (r *HistorizingRepository) FindAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria) ([]*entity.Attendee, error) {
@@ -78,6 +87,10 @@ func (r *HistorizingRepository) GetAdminInfoByAttendeeId(ctx context.Context, at
 	return r.wrappedRepository.GetAdminInfoByAttendeeId(ctx, attendeeId)
 }
 
+func (r *HistorizingRepository) GetAdminInfosByAttendeeIds(ctx context.Context, attendeeIds []uint) ([]*entity.AdminInfo, error) {
+	return r.wrappedRepository.GetAdminInfosByAttendeeIds(ctx, attendeeIds)
+}
+
 func (r *HistorizingRepository) WriteAdminInfo(ctx context.Context, ai *entity.AdminInfo) error {
 	oldVersion, err := r.wrappedRepository.GetAdminInfoByAttendeeId(ctx, ai.ID)
 	if err != nil {
@@ -109,10 +122,24 @@ func (r *HistorizingRepository) AddStatusChange(ctx context.Context, sc *entity.
 	return r.wrappedRepository.AddStatusChange(ctx, sc)
 }
 
+func (r *HistorizingRepository) AddStatusChangeRedeemingVoucher(ctx context.Context, sc *entity.StatusChange, code string) error {
+	return r.wrappedRepository.AddStatusChangeRedeemingVoucher(ctx, sc, code)
+}
+
 func (r *HistorizingRepository) FindByIdentity(ctx context.Context, identity string) ([]*entity.Attendee, error) {
 	return r.wrappedRepository.FindByIdentity(ctx, identity)
 }
 
+// --- statistics ---
+
+func (r *HistorizingRepository) CountAttendeesByGroup(ctx context.Context) ([]*entity.AttendeeCountGroup, error) {
+	return r.wrappedRepository.CountAttendeesByGroup(ctx)
+}
+
+func (r *HistorizingRepository) CountRegistrationsPerDay(ctx context.Context) ([]*entity.DailyCount, error) {
+	return r.wrappedRepository.CountRegistrationsPerDay(ctx)
+}
+
 // --- bans ---
 
 func (r *HistorizingRepository) GetAllBans(ctx context.Context) ([]*entity.Ban, error) {
@@ -143,12 +170,135 @@ func (r *HistorizingRepository) UpdateBan(ctx context.Context, b *entity.Ban) er
 	return r.wrappedRepository.UpdateBan(ctx, b)
 }
 
+// --- groups ---
+
+func (r *HistorizingRepository) GetAllGroups(ctx context.Context) ([]*entity.Group, error) {
+	return r.wrappedRepository.GetAllGroups(ctx)
+}
+
+func (r *HistorizingRepository) GetGroupById(ctx context.Context, id uint) (*entity.Group, error) {
+	return r.wrappedRepository.GetGroupById(ctx, id)
+}
+
+func (r *HistorizingRepository) AddGroup(ctx context.Context, g *entity.Group) (uint, error) {
+	return r.wrappedRepository.AddGroup(ctx, g)
+}
+
+func (r *HistorizingRepository) UpdateGroup(ctx context.Context, g *entity.Group) error {
+	oldVersion, err := r.wrappedRepository.GetGroupById(ctx, g.ID)
+	if err != nil {
+		return err
+	}
+
+	histEntry := diffReverse(ctx, oldVersion, g, "Group", g.ID)
+
+	err = r.wrappedRepository.RecordHistory(ctx, histEntry)
+	if err != nil {
+		return err
+	}
+
+	return r.wrappedRepository.UpdateGroup(ctx, g)
+}
+
+func (r *HistorizingRepository) DeleteGroup(ctx context.Context, id uint) error {
+	// soft delete, the group is still in the database, so we don't need history
+	return r.wrappedRepository.DeleteGroup(ctx, id)
+}
+
+func (r *HistorizingRepository) GetGroupMembersByGroupId(ctx context.Context, groupId uint) ([]*entity.GroupMember, error) {
+	return r.wrappedRepository.GetGroupMembersByGroupId(ctx, groupId)
+}
+
+func (r *HistorizingRepository) GetGroupMembersByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.GroupMember, error) {
+	return r.wrappedRepository.GetGroupMembersByAttendeeId(ctx, attendeeId)
+}
+
+func (r *HistorizingRepository) GetGroupMembersByGroupType(ctx context.Context, groupType string) ([]*entity.GroupMember, error) {
+	return r.wrappedRepository.GetGroupMembersByGroupType(ctx, groupType)
+}
+
+func (r *HistorizingRepository) AddGroupMember(ctx context.Context, m *entity.GroupMember) (uint, error) {
+	// memberships are only added and soft deleted, so we don't need history
+	return r.wrappedRepository.AddGroupMember(ctx, m)
+}
+
+func (r *HistorizingRepository) DeleteGroupMember(ctx context.Context, id uint) error {
+	return r.wrappedRepository.DeleteGroupMember(ctx, id)
+}
+
+// --- vouchers ---
+
+func (r *HistorizingRepository) GetAllVouchers(ctx context.Context) ([]*entity.Voucher, error) {
+	return r.wrappedRepository.GetAllVouchers(ctx)
+}
+
+func (r *HistorizingRepository) GetVoucherById(ctx context.Context, id uint) (*entity.Voucher, error) {
+	return r.wrappedRepository.GetVoucherById(ctx, id)
+}
+
+func (r *HistorizingRepository) GetVoucherByCode(ctx context.Context, code string) (*entity.Voucher, error) {
+	return r.wrappedRepository.GetVoucherByCode(ctx, code)
+}
+
+func (r *HistorizingRepository) AddVoucher(ctx context.Context, v *entity.Voucher) (uint, error) {
+	return r.wrappedRepository.AddVoucher(ctx, v)
+}
+
+func (r *HistorizingRepository) UpdateVoucher(ctx context.Context, v *entity.Voucher) error {
+	oldVersion, err := r.wrappedRepository.GetVoucherById(ctx, v.ID)
+	if err != nil {
+		return err
+	}
+
+	histEntry := diffReverse(ctx, oldVersion, v, "Voucher", v.ID)
+
+	err = r.wrappedRepository.RecordHistory(ctx, histEntry)
+	if err != nil {
+		return err
+	}
+
+	return r.wrappedRepository.UpdateVoucher(ctx, v)
+}
+
+func (r *HistorizingRepository) CountAttendeesByVoucher(ctx context.Context, code string) (int64, error) {
+	return r.wrappedRepository.CountAttendeesByVoucher(ctx, code)
+}
+
+func (r *HistorizingRepository) AddAttendeeRedeemingVoucher(ctx context.Context, a *entity.Attendee) (uint, error) {
+	return r.wrappedRepository.AddAttendeeRedeemingVoucher(ctx, a)
+}
+
+func (r *HistorizingRepository) UpdateAttendeeRedeemingVoucher(ctx context.Context, a *entity.Attendee) error {
+	oldVersion, err := r.wrappedRepository.GetAttendeeById(ctx, a.ID)
+	if err != nil {
+		return err
+	}
+
+	histEntry := diffReverse(ctx, oldVersion, a, "Attendee", a.ID)
+
+	// the update may be refused, so only record history once it has happened
+	err = r.wrappedRepository.UpdateAttendeeRedeemingVoucher(ctx, a)
+	if err != nil {
+		return err
+	}
+
+	return r.wrappedRepository.RecordHistory(ctx, histEntry)
+}
+
 // --- additional info ---
 
 func (r *HistorizingRepository) GetAdditionalInfoFor(ctx context.Context, attendeeId uint, area string) (*entity.AdditionalInfo, error) {
 	return r.wrappedRepository.GetAdditionalInfoFor(ctx, attendeeId, area)
 }
 
+func (r *HistorizingRepository) GetAdditionalInfoForAttendees(ctx context.Context, attendeeIds []uint, area string) ([]*entity.AdditionalInfo, error) {
+	return r.wrappedRepository.GetAdditionalInfoForAttendees(ctx, attendeeIds, area)
+}
+
+func (r *HistorizingRepository) GetAllAdditionalInfoFor(ctx context.Context, attendeeId uint) ([]*entity.AdditionalInfo, error) {
+	return r.wrappedRepository.GetAllAdditionalInfoFor(ctx, attendeeId)
+}
+
 func (r *HistorizingRepository) WriteAdditionalInfo(ctx context.Context, ad *entity.AdditionalInfo) error {
 	oldVersion, err := r.wrappedRepository.GetAdditionalInfoFor(ctx, ad.AttendeeId, ad.Area)
 	if err != nil {
@@ -177,13 +327,26 @@ func (r *HistorizingRepository) RecordHistory(ctx context.Context, h *entity.His
 	return errors.New("not allowed to directly manipulate history")
 }
 
+func (r *HistorizingRepository) GetHistoryByEntity(ctx context.Context, entityName string, entityId uint) ([]*entity.History, error) {
+	return r.wrappedRepository.GetHistoryByEntity(ctx, entityName, entityId)
+}
+
+func (r *HistorizingRepository) GetHistoryByEntities(ctx context.Context, entityName string, entityIds []uint) ([]*entity.History, error) {
+	return r.wrappedRepository.GetHistoryByEntities(ctx, entityName, entityIds)
+}
+
+func (r *HistorizingRepository) ScrubHistory(ctx context.Context, h *entity.History) error {
+	// scrubbing must not itself leave a trace of the removed values, so no history for this
+	return r.wrappedRepository.ScrubHistory(ctx, h)
+}
+
 // we diff reverse so the OLD value is printed in the diffs. The new value is in the database now.
 func diffReverse[T any](ctx context.Context, oldVersion *T, newVersion *T, entityName string, entityID uint) *entity.History {
 	histEntry := &entity.History{
 		Entity:    entityName,
 		EntityId:  entityID,
 		RequestId: ctxvalues.RequestId(ctx),
-		UserId:    ctxvalues.Subject(ctx),
+		UserId:    ctxvalues.UserId(ctx),
 	}
 	diff, _ := messagediff.PrettyDiff(newVersion, oldVersion)
 	histEntry.Diff = diff
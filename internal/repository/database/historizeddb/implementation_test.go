@@ -5,6 +5,7 @@ import (
 	"github.com/eurofurence/reg-attendee-service/docs"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/database/inmemorydb"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
 	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
 	"testing"
@@ -80,6 +81,29 @@ func TestHistorizesUpdateCorrectly(t *testing.T) {
 	cut.Close()
 }
 
+func TestHistorizesApiClientAsUser(t *testing.T) {
+	docs.Description("check that changes made by an api client are recorded with the client name as the user")
+	cut := tstConstructCut()
+	cut.Open()
+	cut.Migrate()
+
+	a := tstBuildValidAttendee()
+	id, err := cut.AddAttendee(context.TODO(), a)
+	require.Nil(t, err, "unexpected error during add")
+
+	ctx := ctxvalues.CreateContextWithValueMap(context.TODO())
+	ctxvalues.SetApiClient(ctx, "payment-service", []string{"payments"})
+	a.Nickname = "WhiteCheetah"
+	err = cut.UpdateAttendee(ctx, a)
+	require.Nil(t, err, "unexpected error during update")
+
+	actualHistory, err := cut.wrappedRepository.(*inmemorydb.InMemoryRepository).GetHistoryById(context.TODO(), id+1)
+	require.Nil(t, err, "unexpected error during history access")
+	require.Equal(t, "api:payment-service", actualHistory.UserId)
+
+	cut.Close()
+}
+
 func TestHistorizesAdminInfoChangesCorrectly(t *testing.T) {
 	docs.Description("check that historizing admin info changes works as expected")
 	cut := tstConstructCut()
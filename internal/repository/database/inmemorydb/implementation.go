@@ -7,8 +7,10 @@ import (
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/database/dbrepo"
+	"gorm.io/gorm"
 	"sort"
 	"sync/atomic"
+	"time"
 )
 
 type InMemoryRepository struct {
@@ -16,6 +18,10 @@ type InMemoryRepository struct {
 	adminInfo     map[uint]*entity.AdminInfo
 	statusChanges map[uint][]entity.StatusChange
 	history       map[uint]*entity.History
+	addInfo       map[uint]map[string]*entity.AdditionalInfo
+	groups        map[uint]*entity.Group
+	groupMembers  map[uint]*entity.GroupMember
+	vouchers      map[uint]*entity.Voucher
 	idSequence    uint32
 }
 
@@ -28,6 +34,10 @@ func (r *InMemoryRepository) Open() error {
 	r.adminInfo = make(map[uint]*entity.AdminInfo)
 	r.statusChanges = make(map[uint][]entity.StatusChange)
 	r.history = make(map[uint]*entity.History)
+	r.addInfo = make(map[uint]map[string]*entity.AdditionalInfo)
+	r.groups = make(map[uint]*entity.Group)
+	r.groupMembers = make(map[uint]*entity.GroupMember)
+	r.vouchers = make(map[uint]*entity.Voucher)
 	return nil
 }
 
@@ -36,6 +46,10 @@ func (r *InMemoryRepository) Close() {
 	r.adminInfo = nil
 	r.statusChanges = nil
 	r.history = nil
+	r.addInfo = nil
+	r.groups = nil
+	r.groupMembers = nil
+	r.vouchers = nil
 }
 
 func (r *InMemoryRepository) Migrate() error {
@@ -48,6 +62,10 @@ func (r *InMemoryRepository) Migrate() error {
 func (r *InMemoryRepository) AddAttendee(ctx context.Context, a *entity.Attendee) (uint, error) {
 	newId := uint(atomic.AddUint32(&r.idSequence, 1))
 	a.ID = newId
+	if a.CreatedAt.IsZero() {
+		// gorm does this for the mysql implementation
+		a.CreatedAt = time.Now()
+	}
 
 	// copy the attendee, so later modifications won't also modify it in the simulated db
 	copiedAttendee := *a
@@ -72,7 +90,8 @@ func (r *InMemoryRepository) GetAttendeeById(ctx context.Context, id uint) (*ent
 		copiedAttendee := *att
 		return &copiedAttendee, nil
 	} else {
-		return &entity.Attendee{}, fmt.Errorf("cannot get attendee %d - not present", id)
+		// like gorm does for the mysql implementation, so callers can tell this apart from other errors
+		return &entity.Attendee{}, fmt.Errorf("cannot get attendee %d - not present: %w", id, gorm.ErrRecordNotFound)
 	}
 }
 
@@ -96,6 +115,39 @@ func (r *InMemoryRepository) MaxAttendeeId(ctx context.Context) (uint, error) {
 	return max, nil
 }
 
+func (r *InMemoryRepository) FindAnonymisationCandidates(ctx context.Context, deletedBefore time.Time, includeAll bool) ([]uint, error) {
+	result := make([]uint, 0)
+	for id, a := range r.attendees {
+		if a.Anonymised {
+			continue
+		}
+		latest, _ := r.GetLatestStatusChangeByAttendeeId(ctx, id)
+		if includeAll || latest.Status == "deleted" && latest.CreatedAt.Before(deletedBefore) {
+			result = append(result, id)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result, nil
+}
+
+func (r *InMemoryRepository) FindAttendeesByStatus(ctx context.Context, statuses []string, minId uint, maxId uint) ([]*entity.AttendeeWithStatus, error) {
+	result := make([]*entity.AttendeeWithStatus, 0)
+	for id := minId; id <= maxId; id++ {
+		a, ok := r.attendees[id]
+		if !ok {
+			continue
+		}
+		latest, _ := r.GetLatestStatusChangeByAttendeeId(ctx, id)
+		for _, s := range statuses {
+			if latest.Status == s {
+				result = append(result, &entity.AttendeeWithStatus{Attendee: *a, Status: latest.Status})
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
 // --- attendee search ---
 
 func (r *InMemoryRepository) FindAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria) ([]*entity.Attendee, error) {
@@ -192,6 +244,17 @@ func (r *InMemoryRepository) WriteAdminInfo(ctx context.Context, ai *entity.Admi
 	return nil
 }
 
+func (r *InMemoryRepository) GetAdminInfosByAttendeeIds(ctx context.Context, attendeeIds []uint) ([]*entity.AdminInfo, error) {
+	result := make([]*entity.AdminInfo, 0)
+	for _, id := range attendeeIds {
+		if ai, ok := r.adminInfo[id]; ok {
+			copiedAdminInfo := *ai
+			result = append(result, &copiedAdminInfo)
+		}
+	}
+	return result, nil
+}
+
 // --- status changes ---
 
 func (r *InMemoryRepository) GetLatestStatusChangeByAttendeeId(ctx context.Context, attendeeId uint) (*entity.StatusChange, error) {
@@ -245,6 +308,45 @@ func (r *InMemoryRepository) FindByIdentity(ctx context.Context, identity string
 	return result, nil
 }
 
+// --- statistics ---
+
+func (r *InMemoryRepository) CountAttendeesByGroup(ctx context.Context) ([]*entity.AttendeeCountGroup, error) {
+	groups := make(map[entity.AttendeeCountGroup]int64)
+	for id, a := range r.attendees {
+		latest, _ := r.GetLatestStatusChangeByAttendeeId(ctx, id)
+		key := entity.AttendeeCountGroup{
+			Status:     latest.Status,
+			Country:    a.Country,
+			Flags:      a.Flags,
+			Packages:   a.Packages,
+			TshirtSize: a.TshirtSize,
+		}
+		groups[key]++
+	}
+
+	result := make([]*entity.AttendeeCountGroup, 0, len(groups))
+	for key, count := range groups {
+		group := key
+		group.Count = count
+		result = append(result, &group)
+	}
+	return result, nil
+}
+
+func (r *InMemoryRepository) CountRegistrationsPerDay(ctx context.Context) ([]*entity.DailyCount, error) {
+	days := make(map[string]int64)
+	for _, a := range r.attendees {
+		days[a.CreatedAt.Format("2006-01-02")]++
+	}
+
+	result := make([]*entity.DailyCount, 0, len(days))
+	for day, count := range days {
+		result = append(result, &entity.DailyCount{Day: day, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Day < result[j].Day })
+	return result, nil
+}
+
 // --- bans ---
 
 func (r *InMemoryRepository) GetAllBans(ctx context.Context) ([]*entity.Ban, error) {
@@ -263,14 +365,275 @@ func (r *InMemoryRepository) UpdateBan(ctx context.Context, b *entity.Ban) error
 	return errors.New("TODO - not implemented")
 }
 
+// --- groups ---
+
+func (r *InMemoryRepository) GetAllGroups(ctx context.Context) ([]*entity.Group, error) {
+	result := make([]*entity.Group, 0, len(r.groups))
+	for _, g := range r.groups {
+		copiedGroup := *g
+		result = append(result, &copiedGroup)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+func (r *InMemoryRepository) GetGroupById(ctx context.Context, id uint) (*entity.Group, error) {
+	if g, ok := r.groups[id]; ok {
+		// copy the group, so later modifications won't also modify it in the simulated db
+		copiedGroup := *g
+		return &copiedGroup, nil
+	} else {
+		return &entity.Group{}, fmt.Errorf("cannot get group %d - not present: %w", id, gorm.ErrRecordNotFound)
+	}
+}
+
+func (r *InMemoryRepository) AddGroup(ctx context.Context, g *entity.Group) (uint, error) {
+	newId := uint(atomic.AddUint32(&r.idSequence, 1))
+	g.ID = newId
+	if g.CreatedAt.IsZero() {
+		g.CreatedAt = time.Now()
+	}
+
+	copiedGroup := *g
+	r.groups[newId] = &copiedGroup
+	return newId, nil
+}
+
+func (r *InMemoryRepository) UpdateGroup(ctx context.Context, g *entity.Group) error {
+	if _, ok := r.groups[g.ID]; ok {
+		copiedGroup := *g
+		r.groups[g.ID] = &copiedGroup
+		return nil
+	} else {
+		return fmt.Errorf("cannot update group %d - not present", g.ID)
+	}
+}
+
+func (r *InMemoryRepository) DeleteGroup(ctx context.Context, id uint) error {
+	if _, ok := r.groups[id]; ok {
+		delete(r.groups, id)
+		return nil
+	} else {
+		return fmt.Errorf("cannot delete group %d - not present", id)
+	}
+}
+
+func (r *InMemoryRepository) GetGroupMembersByGroupId(ctx context.Context, groupId uint) ([]*entity.GroupMember, error) {
+	return r.findGroupMembers(func(m *entity.GroupMember) bool { return m.GroupId == groupId }), nil
+}
+
+func (r *InMemoryRepository) GetGroupMembersByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.GroupMember, error) {
+	return r.findGroupMembers(func(m *entity.GroupMember) bool { return m.AttendeeId == attendeeId }), nil
+}
+
+func (r *InMemoryRepository) GetGroupMembersByGroupType(ctx context.Context, groupType string) ([]*entity.GroupMember, error) {
+	return r.findGroupMembers(func(m *entity.GroupMember) bool {
+		g, ok := r.groups[m.GroupId]
+		return ok && g.Type == groupType
+	}), nil
+}
+
+func (r *InMemoryRepository) findGroupMembers(matches func(m *entity.GroupMember) bool) []*entity.GroupMember {
+	result := make([]*entity.GroupMember, 0)
+	for _, m := range r.groupMembers {
+		if matches(m) {
+			copiedMember := *m
+			result = append(result, &copiedMember)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+func (r *InMemoryRepository) AddGroupMember(ctx context.Context, m *entity.GroupMember) (uint, error) {
+	if m.GroupId == 0 || m.AttendeeId == 0 {
+		return 0, fmt.Errorf("cannot save group member without group or attendee ID")
+	}
+	newId := uint(atomic.AddUint32(&r.idSequence, 1))
+	m.ID = newId
+
+	copiedMember := *m
+	r.groupMembers[newId] = &copiedMember
+	return newId, nil
+}
+
+func (r *InMemoryRepository) DeleteGroupMember(ctx context.Context, id uint) error {
+	if _, ok := r.groupMembers[id]; ok {
+		delete(r.groupMembers, id)
+		return nil
+	} else {
+		return fmt.Errorf("cannot delete group member %d - not present", id)
+	}
+}
+
+// --- vouchers ---
+
+func (r *InMemoryRepository) GetAllVouchers(ctx context.Context) ([]*entity.Voucher, error) {
+	result := make([]*entity.Voucher, 0, len(r.vouchers))
+	for _, v := range r.vouchers {
+		copiedVoucher := *v
+		result = append(result, &copiedVoucher)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+func (r *InMemoryRepository) GetVoucherById(ctx context.Context, id uint) (*entity.Voucher, error) {
+	if v, ok := r.vouchers[id]; ok {
+		// copy the voucher, so later modifications won't also modify it in the simulated db
+		copiedVoucher := *v
+		return &copiedVoucher, nil
+	} else {
+		return &entity.Voucher{}, fmt.Errorf("cannot get voucher %d - not present: %w", id, gorm.ErrRecordNotFound)
+	}
+}
+
+func (r *InMemoryRepository) GetVoucherByCode(ctx context.Context, code string) (*entity.Voucher, error) {
+	for _, v := range r.vouchers {
+		if v.Code == code {
+			copiedVoucher := *v
+			return &copiedVoucher, nil
+		}
+	}
+	return &entity.Voucher{}, fmt.Errorf("cannot get voucher %s - not present: %w", code, gorm.ErrRecordNotFound)
+}
+
+func (r *InMemoryRepository) AddVoucher(ctx context.Context, v *entity.Voucher) (uint, error) {
+	for _, existing := range r.vouchers {
+		if existing.Code == v.Code {
+			return 0, fmt.Errorf("cannot add voucher %s - duplicate code", v.Code)
+		}
+	}
+	newId := uint(atomic.AddUint32(&r.idSequence, 1))
+	v.ID = newId
+	if v.CreatedAt.IsZero() {
+		v.CreatedAt = time.Now()
+	}
+
+	copiedVoucher := *v
+	r.vouchers[newId] = &copiedVoucher
+	return newId, nil
+}
+
+func (r *InMemoryRepository) UpdateVoucher(ctx context.Context, v *entity.Voucher) error {
+	if _, ok := r.vouchers[v.ID]; ok {
+		copiedVoucher := *v
+		r.vouchers[v.ID] = &copiedVoucher
+		return nil
+	} else {
+		return fmt.Errorf("cannot update voucher %d - not present", v.ID)
+	}
+}
+
+func (r *InMemoryRepository) CountAttendeesByVoucher(ctx context.Context, code string) (int64, error) {
+	return r.countActiveAttendeesByVoucher(ctx, code, 0), nil
+}
+
+func (r *InMemoryRepository) AddAttendeeRedeemingVoucher(ctx context.Context, a *entity.Attendee) (uint, error) {
+	if r.voucherUsedUp(ctx, a.Voucher, 0) {
+		return 0, dbrepo.VoucherUsedUpError
+	}
+	return r.AddAttendee(ctx, a)
+}
+
+func (r *InMemoryRepository) UpdateAttendeeRedeemingVoucher(ctx context.Context, a *entity.Attendee) error {
+	if r.voucherUsedUp(ctx, a.Voucher, a.ID) {
+		return dbrepo.VoucherUsedUpError
+	}
+	return r.UpdateAttendee(ctx, a)
+}
+
+func (r *InMemoryRepository) AddStatusChangeRedeemingVoucher(ctx context.Context, sc *entity.StatusChange, code string) error {
+	if r.voucherUsedUp(ctx, code, sc.AttendeeId) {
+		return dbrepo.VoucherUsedUpError
+	}
+	return r.AddStatusChange(ctx, sc)
+}
+
+func (r *InMemoryRepository) voucherUsedUp(ctx context.Context, code string, excludeId uint) bool {
+	for _, v := range r.vouchers {
+		if v.Code == code {
+			return v.MaxUses > 0 && r.countActiveAttendeesByVoucher(ctx, code, excludeId) >= v.MaxUses
+		}
+	}
+	return false
+}
+
+func (r *InMemoryRepository) countActiveAttendeesByVoucher(ctx context.Context, code string, excludeId uint) int64 {
+	var count int64
+	for id, a := range r.attendees {
+		if a.Voucher != code || id == excludeId {
+			continue
+		}
+		latest, _ := r.GetLatestStatusChangeByAttendeeId(ctx, id)
+		if latest.Status != "cancelled" && latest.Status != "deleted" {
+			count++
+		}
+	}
+	return count
+}
+
 // --- additional info ---
 
 func (r *InMemoryRepository) GetAdditionalInfoFor(ctx context.Context, attendeeId uint, area string) (*entity.AdditionalInfo, error) {
-	return &entity.AdditionalInfo{}, errors.New("TODO - not implemented")
+	if areas, ok := r.addInfo[attendeeId]; ok {
+		if ad, ok := areas[area]; ok {
+			// copy the info, so later modifications won't also modify it in the simulated db
+			copiedAddInfo := *ad
+			return &copiedAddInfo, nil
+		}
+	}
+	// same behaviour as the mysql implementation, the historizing repository relies on this
+	return &entity.AdditionalInfo{AttendeeId: attendeeId, Area: area}, gorm.ErrRecordNotFound
+}
+
+func (r *InMemoryRepository) GetAllAdditionalInfoFor(ctx context.Context, attendeeId uint) ([]*entity.AdditionalInfo, error) {
+	result := make([]*entity.AdditionalInfo, 0)
+	if areas, ok := r.addInfo[attendeeId]; ok {
+		for _, ad := range areas {
+			copiedAddInfo := *ad
+			result = append(result, &copiedAddInfo)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Area < result[j].Area
+	})
+	return result, nil
+}
+
+func (r *InMemoryRepository) GetAdditionalInfoForAttendees(ctx context.Context, attendeeIds []uint, area string) ([]*entity.AdditionalInfo, error) {
+	result := make([]*entity.AdditionalInfo, 0)
+	for _, id := range attendeeIds {
+		if ad, ok := r.addInfo[id][area]; ok {
+			copiedAddInfo := *ad
+			result = append(result, &copiedAddInfo)
+		}
+	}
+	return result, nil
 }
 
 func (r *InMemoryRepository) WriteAdditionalInfo(ctx context.Context, ad *entity.AdditionalInfo) error {
-	return errors.New("TODO - not implemented")
+	if ad.AttendeeId == 0 {
+		return fmt.Errorf("cannot save additional info for attendee ID 0")
+	}
+	if ad.ID == 0 {
+		ad.ID = uint(atomic.AddUint32(&r.idSequence, 1))
+	}
+
+	areas, ok := r.addInfo[ad.AttendeeId]
+	if !ok {
+		areas = make(map[string]*entity.AdditionalInfo)
+		r.addInfo[ad.AttendeeId] = areas
+	}
+	copiedAddInfo := *ad
+	areas[ad.Area] = &copiedAddInfo
+	return nil
 }
 
 // --- history ---
@@ -282,6 +645,47 @@ func (r *InMemoryRepository) RecordHistory(ctx context.Context, h *entity.Histor
 	return nil
 }
 
+func (r *InMemoryRepository) GetHistoryByEntity(ctx context.Context, entityName string, entityId uint) ([]*entity.History, error) {
+	result := make([]*entity.History, 0)
+	for _, h := range r.history {
+		if h.Entity == entityName && h.EntityId == entityId {
+			copiedHistory := *h
+			result = append(result, &copiedHistory)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+func (r *InMemoryRepository) GetHistoryByEntities(ctx context.Context, entityName string, entityIds []uint) ([]*entity.History, error) {
+	ids := make(map[uint]bool)
+	for _, id := range entityIds {
+		ids[id] = true
+	}
+	result := make([]*entity.History, 0)
+	for _, h := range r.history {
+		if h.Entity == entityName && ids[h.EntityId] {
+			copiedHistory := *h
+			result = append(result, &copiedHistory)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+func (r *InMemoryRepository) ScrubHistory(ctx context.Context, h *entity.History) error {
+	if existing, ok := r.history[h.ID]; ok {
+		existing.Diff = h.Diff
+		return nil
+	} else {
+		return fmt.Errorf("cannot scrub history entry %d - not present", h.ID)
+	}
+}
+
 // only offered for testing, and only on the in memory db
 func (r *InMemoryRepository) GetHistoryById(ctx context.Context, id uint) (*entity.History, error) {
 	if h, ok := r.history[id]; ok {
@@ -4,7 +4,9 @@ import (
 	"context"
 	"github.com/eurofurence/reg-attendee-service/docs"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database/dbrepo"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 	"os"
 	"testing"
 )
@@ -45,7 +47,8 @@ func TestGetAttendeeNotFound(t *testing.T) {
 	docs.Description("retrieving a nonexistent attendee should fail")
 	att, err := cut.GetAttendeeById(context.TODO(), 0)
 	require.NotNil(t, err, "no error occurred, although it should have")
-	require.Equal(t, "cannot get attendee 0 - not present", err.Error(), "unexpected error message")
+	require.Equal(t, "cannot get attendee 0 - not present: record not found", err.Error(), "unexpected error message")
+	require.ErrorIs(t, err, gorm.ErrRecordNotFound)
 	require.Equal(t, uint(0), att.ID, "ID should still be at its initial value")
 }
 
@@ -74,3 +77,175 @@ func TestUpdateAttendeeNotFound(t *testing.T) {
 	require.Equal(t, "cannot update attendee 0 - not present", err.Error(), "unexpected error message")
 	require.Equal(t, uint(0), att.ID, "ID should still be at its initial value")
 }
+
+func TestAdditionalInfoNotFound(t *testing.T) {
+	docs.Description("retrieving nonexistent additional info should fail with record not found")
+	_, err := cut.GetAdditionalInfoFor(context.TODO(), 4711, "regdesk")
+	require.ErrorIs(t, err, gorm.ErrRecordNotFound, "unexpected error")
+}
+
+func TestWriteAndReadAdditionalInfo(t *testing.T) {
+	docs.Description("it should be possible to write additional info for several areas and then retrieve it again")
+	err := cut.WriteAdditionalInfo(context.TODO(), &entity.AdditionalInfo{AttendeeId: 4712, Area: "sponsordesk", JsonValue: `{"a":1}`})
+	require.Nil(t, err, "unexpected error during write")
+	err = cut.WriteAdditionalInfo(context.TODO(), &entity.AdditionalInfo{AttendeeId: 4712, Area: "regdesk", JsonValue: `{"b":2}`})
+	require.Nil(t, err, "unexpected error during write")
+
+	ad, err := cut.GetAdditionalInfoFor(context.TODO(), 4712, "regdesk")
+	require.Nil(t, err, "unexpected error during get")
+	require.Equal(t, `{"b":2}`, ad.JsonValue)
+
+	all, err := cut.GetAllAdditionalInfoFor(context.TODO(), 4712)
+	require.Nil(t, err, "unexpected error during get all")
+	require.Equal(t, 2, len(all))
+	require.Equal(t, "regdesk", all[0].Area)
+	require.Equal(t, "sponsordesk", all[1].Area)
+}
+
+func TestCountAttendeesByGroup(t *testing.T) {
+	docs.Description("attendees should be counted grouped by their latest status and choices")
+	cut2 := &InMemoryRepository{}
+	cut2.Open()
+	defer cut2.Close()
+
+	id1, _ := cut2.AddAttendee(context.TODO(), &entity.Attendee{Country: "DE", Packages: "attendance"})
+	_, _ = cut2.AddAttendee(context.TODO(), &entity.Attendee{Country: "DE", Packages: "attendance"})
+	_ = cut2.AddStatusChange(context.TODO(), &entity.StatusChange{AttendeeId: id1, Status: "approved"})
+	_ = cut2.AddStatusChange(context.TODO(), &entity.StatusChange{AttendeeId: id1, Status: "paid"})
+
+	groups, err := cut2.CountAttendeesByGroup(context.TODO())
+	require.Nil(t, err)
+	require.Equal(t, 2, len(groups))
+	counts := make(map[string]int64)
+	for _, g := range groups {
+		require.Equal(t, "DE", g.Country)
+		counts[g.Status] = g.Count
+	}
+	require.Equal(t, map[string]int64{"new": 1, "paid": 1}, counts)
+
+	days, err := cut2.CountRegistrationsPerDay(context.TODO())
+	require.Nil(t, err)
+	require.Equal(t, 1, len(days))
+	require.Equal(t, int64(2), days[0].Count)
+}
+
+func TestFindAttendeesByStatus(t *testing.T) {
+	docs.Description("attendees should be found by their latest status within a range of ids")
+	cut2 := &InMemoryRepository{}
+	cut2.Open()
+	defer cut2.Close()
+
+	id1, _ := cut2.AddAttendee(context.TODO(), &entity.Attendee{Nickname: "one"})
+	id2, _ := cut2.AddAttendee(context.TODO(), &entity.Attendee{Nickname: "two"})
+	id3, _ := cut2.AddAttendee(context.TODO(), &entity.Attendee{Nickname: "three"})
+	_ = cut2.AddStatusChange(context.TODO(), &entity.StatusChange{AttendeeId: id1, Status: "paid"})
+	_ = cut2.AddStatusChange(context.TODO(), &entity.StatusChange{AttendeeId: id3, Status: "checked in"})
+
+	found, err := cut2.FindAttendeesByStatus(context.TODO(), []string{"paid", "checked in"}, id1, id3)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(found))
+	require.Equal(t, "one", found[0].Nickname)
+	require.Equal(t, "paid", found[0].Status)
+	require.Equal(t, "three", found[1].Nickname)
+	require.Equal(t, "checked in", found[1].Status)
+
+	found, err = cut2.FindAttendeesByStatus(context.TODO(), []string{"new"}, id1, id2)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(found))
+	require.Equal(t, id2, found[0].ID)
+}
+
+func TestGroupsAndMembers(t *testing.T) {
+	docs.Description("it should be possible to add groups and members, find the members by group, group type and attendee, and delete them")
+	cut2 := &InMemoryRepository{}
+	cut2.Open()
+	defer cut2.Close()
+
+	groupId, err := cut2.AddGroup(context.TODO(), &entity.Group{Name: "Den", Type: "room", OwnerId: 1})
+	require.Nil(t, err, "unexpected error during add")
+	_, err = cut2.AddGroupMember(context.TODO(), &entity.GroupMember{GroupId: groupId, AttendeeId: 1})
+	require.Nil(t, err, "unexpected error during add member")
+	memberId, err := cut2.AddGroupMember(context.TODO(), &entity.GroupMember{GroupId: groupId, AttendeeId: 2})
+	require.Nil(t, err, "unexpected error during add member")
+
+	members, err := cut2.GetGroupMembersByGroupId(context.TODO(), groupId)
+	require.Nil(t, err, "unexpected error during get members")
+	require.Equal(t, 2, len(members))
+	require.Equal(t, uint(1), members[0].AttendeeId)
+
+	dealersId, _ := cut2.AddGroup(context.TODO(), &entity.Group{Name: "Table", Type: "dealers", OwnerId: 3})
+	_, _ = cut2.AddGroupMember(context.TODO(), &entity.GroupMember{GroupId: dealersId, AttendeeId: 3})
+	roomMembers, err := cut2.GetGroupMembersByGroupType(context.TODO(), "room")
+	require.Nil(t, err, "unexpected error during get members by type")
+	require.Equal(t, 2, len(roomMembers))
+	require.Equal(t, uint(2), roomMembers[1].AttendeeId)
+
+	require.Nil(t, cut2.DeleteGroupMember(context.TODO(), memberId))
+	memberships, err := cut2.GetGroupMembersByAttendeeId(context.TODO(), 2)
+	require.Nil(t, err, "unexpected error during get memberships")
+	require.Equal(t, 0, len(memberships))
+
+	require.Nil(t, cut2.DeleteGroup(context.TODO(), groupId))
+	_, err = cut2.GetGroupById(context.TODO(), groupId)
+	require.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestVouchers(t *testing.T) {
+	docs.Description("it should be possible to add vouchers, find them by code, and count the attendees who redeemed them")
+	cut2 := &InMemoryRepository{}
+	cut2.Open()
+	defer cut2.Close()
+
+	voucherId, err := cut2.AddVoucher(context.TODO(), &entity.Voucher{Code: "VOLUNTEER", Percent: 50})
+	require.Nil(t, err, "unexpected error during add")
+	_, err = cut2.AddVoucher(context.TODO(), &entity.Voucher{Code: "VOLUNTEER", Amount: 1000})
+	require.NotNil(t, err, "duplicate code was accepted")
+
+	voucher, err := cut2.GetVoucherByCode(context.TODO(), "VOLUNTEER")
+	require.Nil(t, err, "unexpected error during get")
+	require.Equal(t, voucherId, voucher.ID)
+	_, err = cut2.GetVoucherByCode(context.TODO(), "UNKNOWN")
+	require.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	_, err = cut2.AddAttendee(context.TODO(), &entity.Attendee{Nickname: "one", Voucher: "VOLUNTEER"})
+	require.Nil(t, err, "unexpected error during add attendee")
+	_, err = cut2.AddAttendee(context.TODO(), &entity.Attendee{Nickname: "two"})
+	require.Nil(t, err, "unexpected error during add attendee")
+	count, err := cut2.CountAttendeesByVoucher(context.TODO(), "VOLUNTEER")
+	require.Nil(t, err, "unexpected error during count")
+	require.Equal(t, int64(1), count)
+}
+
+func TestVoucherMaxUses(t *testing.T) {
+	docs.Description("a voucher should not be redeemed more often than allowed, not counting cancelled registrations")
+	cut2 := &InMemoryRepository{}
+	cut2.Open()
+	defer cut2.Close()
+
+	_, err := cut2.AddVoucher(context.TODO(), &entity.Voucher{Code: "TWICE", Percent: 10, MaxUses: 2})
+	require.Nil(t, err, "unexpected error during add")
+
+	firstId, err := cut2.AddAttendeeRedeemingVoucher(context.TODO(), &entity.Attendee{Nickname: "one", Voucher: "TWICE"})
+	require.Nil(t, err, "unexpected error during add attendee")
+	secondId, err := cut2.AddAttendeeRedeemingVoucher(context.TODO(), &entity.Attendee{Nickname: "two", Voucher: "TWICE"})
+	require.Nil(t, err, "unexpected error during add attendee")
+	_, err = cut2.AddAttendeeRedeemingVoucher(context.TODO(), &entity.Attendee{Nickname: "three", Voucher: "TWICE"})
+	require.ErrorIs(t, err, dbrepo.VoucherUsedUpError)
+
+	// keeping the voucher is possible, the attendee itself is not counted
+	err = cut2.UpdateAttendeeRedeemingVoucher(context.TODO(), &entity.Attendee{Model: gorm.Model{ID: secondId}, Nickname: "two-changed", Voucher: "TWICE"})
+	require.Nil(t, err, "unexpected error during update attendee")
+
+	err = cut2.AddStatusChange(context.TODO(), &entity.StatusChange{AttendeeId: firstId, Status: "cancelled"})
+	require.Nil(t, err, "unexpected error during status change")
+	count, err := cut2.CountAttendeesByVoucher(context.TODO(), "TWICE")
+	require.Nil(t, err, "unexpected error during count")
+	require.Equal(t, int64(1), count)
+
+	_, err = cut2.AddAttendeeRedeemingVoucher(context.TODO(), &entity.Attendee{Nickname: "three", Voucher: "TWICE"})
+	require.Nil(t, err, "cancelled registration was counted")
+
+	// the cancelled registration cannot become active again, now that the voucher is used up
+	err = cut2.AddStatusChangeRedeemingVoucher(context.TODO(), &entity.StatusChange{AttendeeId: firstId, Status: "approved"}, "TWICE")
+	require.ErrorIs(t, err, dbrepo.VoucherUsedUpError)
+}
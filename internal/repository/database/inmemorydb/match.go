@@ -3,6 +3,8 @@ package inmemorydb
 import (
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"regexp"
+	"strings"
 )
 
 func matchesCriteria(conds *attendee.AttendeeSearchCriteria, a *entity.Attendee) bool {
@@ -22,7 +24,77 @@ func matchesCriteria(conds *attendee.AttendeeSearchCriteria, a *entity.Attendee)
 	return false
 }
 
+// matches implements the same semantics as the mysql search query
 func matches(cond *attendee.AttendeeSearchSingleCriterion, a *entity.Attendee) bool {
-	// TODO
+	if len(cond.Ids) > 0 && !containsId(cond.Ids, a.ID) {
+		return false
+	}
+	if cond.Nickname != "" && !fullstringMatch(a.Nickname, cond.Nickname) {
+		return false
+	}
+	if cond.Name != "" && !fullstringMatch(a.FirstName+" "+a.LastName, cond.Name) {
+		return false
+	}
+	if cond.Address != "" && !substringMatch(a.Street+" "+a.Zip+" "+a.City+" "+a.State, cond.Address) {
+		return false
+	}
+	if cond.Country != "" && !strings.EqualFold(a.Country, cond.Country) {
+		return false
+	}
+	if cond.CountryBadge != "" && !strings.EqualFold(a.CountryBadge, cond.CountryBadge) {
+		return false
+	}
+	if cond.Email != "" && !substringMatch(a.Email, cond.Email) {
+		return false
+	}
+	if cond.Telegram != "" && !substringMatch(a.Telegram, cond.Telegram) {
+		return false
+	}
+	if !choiceMatch(a.Flags, cond.Flags) || !choiceMatch(a.Options, cond.Options) || !choiceMatch(a.Packages, cond.Packages) {
+		return false
+	}
+	if cond.UserComments != "" && !substringMatch(a.UserComments, cond.UserComments) {
+		return false
+	}
+	return true
+}
+
+func containsId(ids []uint, id uint) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
 	return false
 }
+
+func substringMatch(value string, condition string) bool {
+	return fullstringMatch(value, "*"+condition+"*")
+}
+
+// fullstringMatch is a case insensitive match, where * is a wildcard.
+func fullstringMatch(value string, condition string) bool {
+	parts := strings.Split(condition, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	pattern := "(?is)^" + strings.Join(parts, ".*") + "$"
+	matched, _ := regexp.MatchString(pattern, value)
+	return matched
+}
+
+func choiceMatch(choiceStr string, condition map[string]int8) bool {
+	picked := make(map[string]bool)
+	for _, k := range strings.Split(choiceStr, ",") {
+		picked[k] = true
+	}
+	for k, v := range condition {
+		if v == 1 && !picked[k] {
+			return false
+		}
+		if v == 0 && picked[k] {
+			return false
+		}
+	}
+	return true
+}
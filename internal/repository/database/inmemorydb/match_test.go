@@ -0,0 +1,51 @@
+package inmemorydb
+
+import (
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func tstMatchAttendee() *entity.Attendee {
+	return &entity.Attendee{
+		Nickname:  "BlackCheetah",
+		FirstName: "Hans",
+		LastName:  "Mustermann",
+		Street:    "Teststraße 24",
+		Zip:       "12345",
+		City:      "Berlin",
+		Country:   "DE",
+		Email:     "jsquirrel_github_9a6d@packetloss.de",
+		Flags:     "anon,ev",
+		Packages:  "room-none,attendance",
+	}
+}
+
+func TestMatchEmptyCriterion(t *testing.T) {
+	docs.Description("an empty criterion matches everyone, no criteria match no one")
+	a := tstMatchAttendee()
+	require.True(t, matchesCriteria(&attendee.AttendeeSearchCriteria{MatchAny: []attendee.AttendeeSearchSingleCriterion{{}}}, a))
+	require.False(t, matchesCriteria(&attendee.AttendeeSearchCriteria{}, a))
+}
+
+func TestMatchWildcards(t *testing.T) {
+	docs.Description("string criteria are case insensitive and support * as a wildcard")
+	a := tstMatchAttendee()
+	require.True(t, matches(&attendee.AttendeeSearchSingleCriterion{Nickname: "*chee*"}, a))
+	require.False(t, matches(&attendee.AttendeeSearchSingleCriterion{Nickname: "chee"}, a))
+	require.True(t, matches(&attendee.AttendeeSearchSingleCriterion{Name: "hans m*n"}, a))
+	require.True(t, matches(&attendee.AttendeeSearchSingleCriterion{Address: "straße*berlin"}, a))
+	require.True(t, matches(&attendee.AttendeeSearchSingleCriterion{Email: "packetloss"}, a))
+	require.True(t, matches(&attendee.AttendeeSearchSingleCriterion{Country: "de"}, a))
+	require.False(t, matches(&attendee.AttendeeSearchSingleCriterion{Country: "D"}, a))
+}
+
+func TestMatchChoices(t *testing.T) {
+	docs.Description("choice criteria require presence or absence of a choice, and all criteria must match")
+	a := tstMatchAttendee()
+	require.True(t, matches(&attendee.AttendeeSearchSingleCriterion{Flags: map[string]int8{"anon": 1, "hc": 0}}, a))
+	require.False(t, matches(&attendee.AttendeeSearchSingleCriterion{Flags: map[string]int8{"ev": 0}}, a))
+	require.False(t, matches(&attendee.AttendeeSearchSingleCriterion{Packages: map[string]int8{"attendance": 1}, Ids: []uint{42}}, a))
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/eurofurence/reg-attendee-service/internal/repository/database/dbrepo"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"io"
 	"time"
 )
 
@@ -56,8 +58,11 @@ func (r *MysqlRepository) Migrate() error {
 		&entity.AdminInfo{},
 		&entity.Attendee{},
 		&entity.Ban{},
+		&entity.Group{},
+		&entity.GroupMember{},
 		&entity.History{},
 		&entity.StatusChange{},
+		&entity.Voucher{},
 	)
 	if err != nil {
 		aulogging.Logger.NoCtx().Error().WithErr(err).Printf("failed to migrate mysql db: %s", err.Error())
@@ -69,7 +74,7 @@ func (r *MysqlRepository) Migrate() error {
 // --- attendee ---
 
 func (r *MysqlRepository) AddAttendee(ctx context.Context, a *entity.Attendee) (uint, error) {
-	err := r.db.Create(a).Error
+	err := r.db.WithContext(ctx).Create(a).Error
 	if err != nil {
 		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during attendee insert: %s", err.Error())
 	}
@@ -77,7 +82,7 @@ func (r *MysqlRepository) AddAttendee(ctx context.Context, a *entity.Attendee) (
 }
 
 func (r *MysqlRepository) UpdateAttendee(ctx context.Context, a *entity.Attendee) error {
-	err := r.db.Save(a).Error
+	err := r.db.WithContext(ctx).Save(a).Error
 	if err != nil {
 		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during attendee update: %s", err.Error())
 	}
@@ -86,7 +91,7 @@ func (r *MysqlRepository) UpdateAttendee(ctx context.Context, a *entity.Attendee
 
 func (r *MysqlRepository) GetAttendeeById(ctx context.Context, id uint) (*entity.Attendee, error) {
 	var a entity.Attendee
-	err := r.db.First(&a, id).Error
+	err := r.db.WithContext(ctx).First(&a, id).Error
 	if err != nil {
 		aulogging.Logger.Ctx(ctx).Info().WithErr(err).Printf("mysql error during attendee select - might be ok: %s", err.Error())
 	}
@@ -95,7 +100,7 @@ func (r *MysqlRepository) GetAttendeeById(ctx context.Context, id uint) (*entity
 
 func (r *MysqlRepository) CountAttendeesByNicknameZipEmail(ctx context.Context, nickname string, zip string, email string) (int64, error) {
 	var count int64
-	err := r.db.Model(&entity.Attendee{}).Where(&entity.Attendee{Nickname: nickname, Zip: zip, Email: email}).Count(&count).Error
+	err := r.db.WithContext(ctx).Model(&entity.Attendee{}).Where(&entity.Attendee{Nickname: nickname, Zip: zip, Email: email}).Count(&count).Error
 	if err != nil {
 		return -1, err
 	}
@@ -104,7 +109,7 @@ func (r *MysqlRepository) CountAttendeesByNicknameZipEmail(ctx context.Context,
 
 func (r *MysqlRepository) MaxAttendeeId(ctx context.Context) (uint, error) {
 	var max uint
-	rows, err := r.db.Model(&entity.Attendee{}).Select("ifnull(max(id),0) AS max_id").Rows()
+	rows, err := r.db.WithContext(ctx).Model(&entity.Attendee{}).Select("ifnull(max(id),0) AS max_id").Rows()
 	if err != nil {
 		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error querying for max attendee id: %s", err.Error())
 		return 0, err
@@ -123,6 +128,55 @@ func (r *MysqlRepository) MaxAttendeeId(ctx context.Context) (uint, error) {
 	return max, err
 }
 
+// the latest status change is the one with the highest id, consistent with GetLatestStatusChangeByAttendeeId
+const findAnonymisationCandidatesQuery = `SELECT a.id
+FROM attendees a
+LEFT JOIN status_changes s ON s.id = (
+  SELECT MAX(s2.id) FROM status_changes s2 WHERE s2.attendee_id = a.id AND s2.deleted_at IS NULL
+)
+WHERE a.deleted_at IS NULL AND a.anonymised = 0
+AND (@include_all OR (s.status = 'deleted' AND s.created_at < @deleted_before))
+ORDER BY a.id`
+
+func (r *MysqlRepository) FindAnonymisationCandidates(ctx context.Context, deletedBefore time.Time, includeAll bool) ([]uint, error) {
+	params := map[string]interface{}{
+		"include_all":    includeAll,
+		"deleted_before": deletedBefore,
+	}
+	result := make([]uint, 0)
+	err := r.db.WithContext(ctx).Raw(findAnonymisationCandidatesQuery, params).Scan(&result).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("mysql error during anonymisation candidate select: %s", err.Error())
+	}
+	return result, err
+}
+
+const findAttendeesByStatusQuery = `SELECT a.*, COALESCE(s.status, 'new') AS status
+FROM attendees a
+LEFT JOIN status_changes s ON s.id = (
+  SELECT MAX(s2.id) FROM status_changes s2 WHERE s2.attendee_id = a.id AND s2.deleted_at IS NULL
+)
+WHERE a.deleted_at IS NULL AND a.id >= @min_id AND a.id <= @max_id
+AND COALESCE(s.status, 'new') IN @statuses
+ORDER BY a.id`
+
+func (r *MysqlRepository) FindAttendeesByStatus(ctx context.Context, statuses []string, minId uint, maxId uint) ([]*entity.AttendeeWithStatus, error) {
+	result := make([]*entity.AttendeeWithStatus, 0)
+	if len(statuses) == 0 {
+		return result, nil
+	}
+	params := map[string]interface{}{
+		"statuses": statuses,
+		"min_id":   minId,
+		"max_id":   maxId,
+	}
+	err := r.db.WithContext(ctx).Raw(findAttendeesByStatusQuery, params).Scan(&result).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("mysql error during attendee by status select: %s", err.Error())
+	}
+	return result, err
+}
+
 // --- attendee search ---
 
 func (r *MysqlRepository) FindAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria) ([]*entity.Attendee, error) {
@@ -132,7 +186,7 @@ func (r *MysqlRepository) FindAttendees(ctx context.Context, criteria *attendee.
 	result := make([]*entity.Attendee, 0)
 	attendeeBuffer := entity.Attendee{}
 
-	rows, err := r.db.Raw(query, params).Find(&attendeeBuffer).Rows()
+	rows, err := r.db.WithContext(ctx).Raw(query, params).Find(&attendeeBuffer).Rows()
 	if err != nil {
 		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("error finding attendees: %s", err.Error())
 		return result, err
@@ -161,7 +215,7 @@ func (r *MysqlRepository) FindAttendees(ctx context.Context, criteria *attendee.
 
 func (r *MysqlRepository) GetAdminInfoByAttendeeId(ctx context.Context, attendeeId uint) (*entity.AdminInfo, error) {
 	var ai entity.AdminInfo
-	err := r.db.First(&ai, attendeeId).Error
+	err := r.db.WithContext(ctx).First(&ai, attendeeId).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			ai.ID = attendeeId
@@ -175,18 +229,31 @@ func (r *MysqlRepository) GetAdminInfoByAttendeeId(ctx context.Context, attendee
 }
 
 func (r *MysqlRepository) WriteAdminInfo(ctx context.Context, ai *entity.AdminInfo) error {
-	err := r.db.Save(ai).Error
+	err := r.db.WithContext(ctx).Save(ai).Error
 	if err != nil {
 		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during admin info save: %s", err.Error())
 	}
 	return err
 }
 
+func (r *MysqlRepository) GetAdminInfosByAttendeeIds(ctx context.Context, attendeeIds []uint) ([]*entity.AdminInfo, error) {
+	result := make([]*entity.AdminInfo, 0)
+	if len(attendeeIds) == 0 {
+		return result, nil
+	}
+	err := r.db.WithContext(ctx).Model(&entity.AdminInfo{}).Where("id IN ?", attendeeIds).Find(&result).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during admin info select: %s", err.Error())
+		return make([]*entity.AdminInfo, 0), err
+	}
+	return result, nil
+}
+
 // --- status changes ---
 
 func (r *MysqlRepository) GetLatestStatusChangeByAttendeeId(ctx context.Context, attendeeId uint) (*entity.StatusChange, error) {
 	var sc entity.StatusChange
-	err := r.db.Model(&entity.StatusChange{}).Where(&entity.StatusChange{AttendeeId: attendeeId}).Last(&sc).Error
+	err := r.db.WithContext(ctx).Model(&entity.StatusChange{}).Where(&entity.StatusChange{AttendeeId: attendeeId}).Last(&sc).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			sc = entity.StatusChange{
@@ -201,15 +268,12 @@ func (r *MysqlRepository) GetLatestStatusChangeByAttendeeId(ctx context.Context,
 }
 
 func (r *MysqlRepository) GetStatusChangesByAttendeeId(ctx context.Context, attendeeId uint) ([]entity.StatusChange, error) {
-	rows, err := r.db.Model(&entity.StatusChange{}).Where(&entity.StatusChange{AttendeeId: attendeeId}).Rows()
+	rows, err := r.db.WithContext(ctx).Model(&entity.StatusChange{}).Where(&entity.StatusChange{AttendeeId: attendeeId}).Rows()
 	if err != nil {
 		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during status change select: %s", err.Error())
 		return make([]entity.StatusChange, 0), err
 	}
-	defer func() {
-		err := rows.Close()
-		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during status change result set close: %s", err.Error())
-	}()
+	defer closeResultSet(ctx, rows, "status change")
 
 	result := make([]entity.StatusChange, 0)
 	for rows.Next() {
@@ -227,7 +291,7 @@ func (r *MysqlRepository) GetStatusChangesByAttendeeId(ctx context.Context, atte
 }
 
 func (r *MysqlRepository) AddStatusChange(ctx context.Context, sc *entity.StatusChange) error {
-	err := r.db.Create(sc).Error
+	err := r.db.WithContext(ctx).Create(sc).Error
 	if err != nil {
 		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during status change insert: %s", err.Error())
 	}
@@ -236,15 +300,12 @@ func (r *MysqlRepository) AddStatusChange(ctx context.Context, sc *entity.Status
 
 func (r *MysqlRepository) FindByIdentity(ctx context.Context, identity string) ([]*entity.Attendee, error) {
 	result := make([]*entity.Attendee, 0)
-	rows, err := r.db.Model(&entity.Attendee{}).Where(&entity.Attendee{Identity: identity}).Rows()
+	rows, err := r.db.WithContext(ctx).Model(&entity.Attendee{}).Where(&entity.Attendee{Identity: identity}).Rows()
 	if err != nil {
 		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during identity select: %s", err.Error())
 		return result, err
 	}
-	defer func() {
-		err := rows.Close()
-		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during attendee by identity result set close: %s", err.Error())
-	}()
+	defer closeResultSet(ctx, rows, "attendee by identity")
 
 	for rows.Next() {
 		var a entity.Attendee
@@ -260,6 +321,41 @@ func (r *MysqlRepository) FindByIdentity(ctx context.Context, identity string) (
 	return result, nil
 }
 
+// --- statistics ---
+
+// the latest status change is the one with the highest id, consistent with GetLatestStatusChangeByAttendeeId
+const countAttendeesByGroupQuery = `SELECT IFNULL(s.status, 'new') AS status, a.country, a.flags, a.packages, a.tshirt_size, COUNT(*) AS count
+FROM attendees a
+LEFT JOIN status_changes s ON s.id = (
+  SELECT MAX(s2.id) FROM status_changes s2 WHERE s2.attendee_id = a.id AND s2.deleted_at IS NULL
+)
+WHERE a.deleted_at IS NULL
+GROUP BY IFNULL(s.status, 'new'), a.country, a.flags, a.packages, a.tshirt_size`
+
+const countRegistrationsPerDayQuery = `SELECT DATE_FORMAT(a.created_at, '%Y-%m-%d') AS day, COUNT(*) AS count
+FROM attendees a
+WHERE a.deleted_at IS NULL
+GROUP BY DATE_FORMAT(a.created_at, '%Y-%m-%d')
+ORDER BY day`
+
+func (r *MysqlRepository) CountAttendeesByGroup(ctx context.Context) ([]*entity.AttendeeCountGroup, error) {
+	result := make([]*entity.AttendeeCountGroup, 0)
+	err := r.db.WithContext(ctx).Raw(countAttendeesByGroupQuery).Scan(&result).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("mysql error during attendee count by group: %s", err.Error())
+	}
+	return result, err
+}
+
+func (r *MysqlRepository) CountRegistrationsPerDay(ctx context.Context) ([]*entity.DailyCount, error) {
+	result := make([]*entity.DailyCount, 0)
+	err := r.db.WithContext(ctx).Raw(countRegistrationsPerDayQuery).Scan(&result).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("mysql error during registration count per day: %s", err.Error())
+	}
+	return result, err
+}
+
 // --- bans ---
 
 func (r *MysqlRepository) GetAllBans(ctx context.Context) ([]*entity.Ban, error) {
@@ -278,22 +374,327 @@ func (r *MysqlRepository) UpdateBan(ctx context.Context, b *entity.Ban) error {
 	return errors.New("TODO - not implemented")
 }
 
+// --- groups ---
+
+func (r *MysqlRepository) GetAllGroups(ctx context.Context) ([]*entity.Group, error) {
+	result := make([]*entity.Group, 0)
+	err := r.db.WithContext(ctx).Model(&entity.Group{}).Order("id").Find(&result).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during group select: %s", err.Error())
+		return make([]*entity.Group, 0), err
+	}
+	return result, nil
+}
+
+func (r *MysqlRepository) GetGroupById(ctx context.Context, id uint) (*entity.Group, error) {
+	var g entity.Group
+	err := r.db.WithContext(ctx).First(&g, id).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Info().WithErr(err).Printf("mysql error during group select - might be ok: %s", err.Error())
+	}
+	return &g, err
+}
+
+func (r *MysqlRepository) AddGroup(ctx context.Context, g *entity.Group) (uint, error) {
+	err := r.db.WithContext(ctx).Create(g).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during group insert: %s", err.Error())
+	}
+	return g.ID, err
+}
+
+func (r *MysqlRepository) UpdateGroup(ctx context.Context, g *entity.Group) error {
+	err := r.db.WithContext(ctx).Save(g).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during group update: %s", err.Error())
+	}
+	return err
+}
+
+func (r *MysqlRepository) DeleteGroup(ctx context.Context, id uint) error {
+	err := r.db.WithContext(ctx).Delete(&entity.Group{}, id).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during group delete: %s", err.Error())
+	}
+	return err
+}
+
+func (r *MysqlRepository) GetGroupMembersByGroupId(ctx context.Context, groupId uint) ([]*entity.GroupMember, error) {
+	result := make([]*entity.GroupMember, 0)
+	err := r.db.WithContext(ctx).Model(&entity.GroupMember{}).Where(&entity.GroupMember{GroupId: groupId}).Order("id").Find(&result).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during group member select: %s", err.Error())
+		return make([]*entity.GroupMember, 0), err
+	}
+	return result, nil
+}
+
+func (r *MysqlRepository) GetGroupMembersByAttendeeId(ctx context.Context, attendeeId uint) ([]*entity.GroupMember, error) {
+	result := make([]*entity.GroupMember, 0)
+	err := r.db.WithContext(ctx).Model(&entity.GroupMember{}).Where(&entity.GroupMember{AttendeeId: attendeeId}).Order("id").Find(&result).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during group member by attendee select: %s", err.Error())
+		return make([]*entity.GroupMember, 0), err
+	}
+	return result, nil
+}
+
+func (r *MysqlRepository) GetGroupMembersByGroupType(ctx context.Context, groupType string) ([]*entity.GroupMember, error) {
+	result := make([]*entity.GroupMember, 0)
+	err := r.db.WithContext(ctx).Model(&entity.GroupMember{}).
+		Joins("JOIN `groups` g ON g.id = group_members.group_id AND g.deleted_at IS NULL").
+		Where("g.type = ?", groupType).Order("group_members.id").Find(&result).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during group member by group type select: %s", err.Error())
+		return make([]*entity.GroupMember, 0), err
+	}
+	return result, nil
+}
+
+func (r *MysqlRepository) AddGroupMember(ctx context.Context, m *entity.GroupMember) (uint, error) {
+	err := r.db.WithContext(ctx).Create(m).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during group member insert: %s", err.Error())
+	}
+	return m.ID, err
+}
+
+func (r *MysqlRepository) DeleteGroupMember(ctx context.Context, id uint) error {
+	err := r.db.WithContext(ctx).Delete(&entity.GroupMember{}, id).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during group member delete: %s", err.Error())
+	}
+	return err
+}
+
+// --- vouchers ---
+
+func (r *MysqlRepository) GetAllVouchers(ctx context.Context) ([]*entity.Voucher, error) {
+	result := make([]*entity.Voucher, 0)
+	err := r.db.WithContext(ctx).Model(&entity.Voucher{}).Order("id").Find(&result).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during voucher select: %s", err.Error())
+		return make([]*entity.Voucher, 0), err
+	}
+	return result, nil
+}
+
+func (r *MysqlRepository) GetVoucherById(ctx context.Context, id uint) (*entity.Voucher, error) {
+	var v entity.Voucher
+	err := r.db.WithContext(ctx).First(&v, id).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Info().WithErr(err).Printf("mysql error during voucher select - might be ok: %s", err.Error())
+	}
+	return &v, err
+}
+
+func (r *MysqlRepository) GetVoucherByCode(ctx context.Context, code string) (*entity.Voucher, error) {
+	var v entity.Voucher
+	err := r.db.WithContext(ctx).Where(&entity.Voucher{Code: code}).First(&v).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Info().WithErr(err).Printf("mysql error during voucher select - might be ok: %s", err.Error())
+	}
+	return &v, err
+}
+
+func (r *MysqlRepository) AddVoucher(ctx context.Context, v *entity.Voucher) (uint, error) {
+	err := r.db.WithContext(ctx).Create(v).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during voucher insert: %s", err.Error())
+	}
+	return v.ID, err
+}
+
+func (r *MysqlRepository) UpdateVoucher(ctx context.Context, v *entity.Voucher) error {
+	err := r.db.WithContext(ctx).Save(v).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during voucher update: %s", err.Error())
+	}
+	return err
+}
+
+// the latest status change is the one with the highest id, consistent with GetLatestStatusChangeByAttendeeId
+const countActiveAttendeesByVoucherQuery = `SELECT COUNT(*)
+FROM attendees a
+LEFT JOIN status_changes s ON s.id = (
+  SELECT MAX(s2.id) FROM status_changes s2 WHERE s2.attendee_id = a.id AND s2.deleted_at IS NULL
+)
+WHERE a.deleted_at IS NULL AND a.voucher = @code AND a.id <> @exclude_id
+AND COALESCE(s.status, 'new') NOT IN ('cancelled', 'deleted')`
+
+func (r *MysqlRepository) CountAttendeesByVoucher(ctx context.Context, code string) (int64, error) {
+	return countActiveAttendeesByVoucher(r.db, code, 0)
+}
+
+func countActiveAttendeesByVoucher(db *gorm.DB, code string, excludeId uint) (int64, error) {
+	params := map[string]interface{}{
+		"code":       code,
+		"exclude_id": excludeId,
+	}
+	var count int64
+	err := db.Raw(countActiveAttendeesByVoucherQuery, params).Scan(&count).Error
+	if err != nil {
+		return -1, err
+	}
+	return count, nil
+}
+
+func (r *MysqlRepository) AddAttendeeRedeemingVoucher(ctx context.Context, a *entity.Attendee) (uint, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkVoucherNotUsedUp(tx, a.Voucher, 0); err != nil {
+			return err
+		}
+		return tx.Create(a).Error
+	})
+	if err != nil && !errors.Is(err, dbrepo.VoucherUsedUpError) {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during attendee insert with voucher: %s", err.Error())
+	}
+	return a.ID, err
+}
+
+func (r *MysqlRepository) UpdateAttendeeRedeemingVoucher(ctx context.Context, a *entity.Attendee) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkVoucherNotUsedUp(tx, a.Voucher, a.ID); err != nil {
+			return err
+		}
+		return tx.Save(a).Error
+	})
+	if err != nil && !errors.Is(err, dbrepo.VoucherUsedUpError) {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during attendee update with voucher: %s", err.Error())
+	}
+	return err
+}
+
+func (r *MysqlRepository) AddStatusChangeRedeemingVoucher(ctx context.Context, sc *entity.StatusChange, code string) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkVoucherNotUsedUp(tx, code, sc.AttendeeId); err != nil {
+			return err
+		}
+		return tx.Create(sc).Error
+	})
+	if err != nil && !errors.Is(err, dbrepo.VoucherUsedUpError) {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during status change insert with voucher: %s", err.Error())
+	}
+	return err
+}
+
+// checkVoucherNotUsedUp locks the voucher row until the end of the transaction, so concurrent redemptions
+// of the same voucher are counted one after the other.
+//
+// The count is the first consistent read of the transaction, so it sees everything committed before the lock was granted.
+func checkVoucherNotUsedUp(tx *gorm.DB, code string, excludeId uint) error {
+	var voucher entity.Voucher
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(&entity.Voucher{Code: code}).First(&voucher).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// not a limited voucher, CanRedeemVoucher has already rejected unknown codes
+			return nil
+		}
+		return err
+	}
+	if voucher.MaxUses <= 0 {
+		return nil
+	}
+	uses, err := countActiveAttendeesByVoucher(tx, code, excludeId)
+	if err != nil {
+		return err
+	}
+	if uses >= voucher.MaxUses {
+		return dbrepo.VoucherUsedUpError
+	}
+	return nil
+}
+
 // --- additional info ---
 
 func (r *MysqlRepository) GetAdditionalInfoFor(ctx context.Context, attendeeId uint, area string) (*entity.AdditionalInfo, error) {
-	return &entity.AdditionalInfo{}, errors.New("TODO - not implemented")
+	var ad entity.AdditionalInfo
+	err := r.db.WithContext(ctx).Model(&entity.AdditionalInfo{}).Where(&entity.AdditionalInfo{AttendeeId: attendeeId, Area: area}).First(&ad).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during additional info select - not record not found: %s", err.Error())
+		}
+		ad.AttendeeId = attendeeId
+		ad.Area = area
+	}
+	return &ad, err
+}
+
+func (r *MysqlRepository) GetAllAdditionalInfoFor(ctx context.Context, attendeeId uint) ([]*entity.AdditionalInfo, error) {
+	result := make([]*entity.AdditionalInfo, 0)
+	err := r.db.WithContext(ctx).Model(&entity.AdditionalInfo{}).Where(&entity.AdditionalInfo{AttendeeId: attendeeId}).Order("area").Find(&result).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during additional info select: %s", err.Error())
+		return make([]*entity.AdditionalInfo, 0), err
+	}
+	return result, nil
+}
+
+func (r *MysqlRepository) GetAdditionalInfoForAttendees(ctx context.Context, attendeeIds []uint, area string) ([]*entity.AdditionalInfo, error) {
+	result := make([]*entity.AdditionalInfo, 0)
+	if len(attendeeIds) == 0 {
+		return result, nil
+	}
+	err := r.db.WithContext(ctx).Model(&entity.AdditionalInfo{}).Where("attendee_id IN ? AND area = ?", attendeeIds, area).Find(&result).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during additional info select: %s", err.Error())
+		return make([]*entity.AdditionalInfo, 0), err
+	}
+	return result, nil
 }
 
 func (r *MysqlRepository) WriteAdditionalInfo(ctx context.Context, ad *entity.AdditionalInfo) error {
-	return errors.New("TODO - not implemented")
+	err := r.db.WithContext(ctx).Save(ad).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during additional info save: %s", err.Error())
+	}
+	return err
 }
 
 // --- history ---
 
 func (r *MysqlRepository) RecordHistory(ctx context.Context, h *entity.History) error {
-	err := r.db.Create(h).Error
+	err := r.db.WithContext(ctx).Create(h).Error
 	if err != nil {
 		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during history entry insert: %s", err.Error())
 	}
 	return err
 }
+
+func (r *MysqlRepository) GetHistoryByEntity(ctx context.Context, entityName string, entityId uint) ([]*entity.History, error) {
+	result := make([]*entity.History, 0)
+	err := r.db.WithContext(ctx).Model(&entity.History{}).Where(&entity.History{Entity: entityName, EntityId: entityId}).Order("id").Find(&result).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during history select: %s", err.Error())
+		return make([]*entity.History, 0), err
+	}
+	return result, nil
+}
+
+func (r *MysqlRepository) GetHistoryByEntities(ctx context.Context, entityName string, entityIds []uint) ([]*entity.History, error) {
+	result := make([]*entity.History, 0)
+	if len(entityIds) == 0 {
+		return result, nil
+	}
+	err := r.db.WithContext(ctx).Model(&entity.History{}).Where("entity = ? AND entity_id IN ?", entityName, entityIds).Order("id").Find(&result).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during history select: %s", err.Error())
+		return make([]*entity.History, 0), err
+	}
+	return result, nil
+}
+
+func (r *MysqlRepository) ScrubHistory(ctx context.Context, h *entity.History) error {
+	err := r.db.WithContext(ctx).Model(&entity.History{}).Where("id = ?", h.ID).Update("diff", h.Diff).Error
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during history entry scrub: %s", err.Error())
+	}
+	return err
+}
+
+// closeResultSet logs if closing the result set fails. There is nothing else to be done about it at this point.
+func closeResultSet(ctx context.Context, rows io.Closer, what string) {
+	if err := rows.Close(); err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("mysql error during %s result set close: %s", what, err.Error())
+	}
+}
@@ -0,0 +1,28 @@
+package mysqldb
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type tstCloser struct {
+	err    error
+	closed bool
+}
+
+func (c *tstCloser) Close() error {
+	c.closed = true
+	return c.err
+}
+
+func TestCloseResultSet(t *testing.T) {
+	for _, closeErr := range []error{nil, errors.New("connection lost")} {
+		closer := &tstCloser{err: closeErr}
+		require.NotPanics(t, func() {
+			closeResultSet(context.TODO(), closer, "test")
+		})
+		require.True(t, closer.closed)
+	}
+}
@@ -0,0 +1,216 @@
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const requestTimeout = 10 * time.Second
+
+// an unknown key id triggers a refresh, because the identity provider may have rotated its keys,
+// but not more often than this, so tokens with made up key ids cannot be used to flood the endpoint
+const unknownKidRefreshInterval = time.Minute
+
+type Impl struct {
+	mu                    sync.RWMutex
+	keys                  map[string]crypto.PublicKey
+	lastUnknownKidRefresh time.Time
+
+	url        func() string
+	httpClient *http.Client
+}
+
+func newImpl() *Impl {
+	return &Impl{
+		keys:       make(map[string]crypto.PublicKey),
+		url:        config.OidcJwksUrl,
+		httpClient: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+func (i *Impl) KeysFor(kid string) []crypto.PublicKey {
+	if kid != "" && i.url() != "" {
+		if key, ok := i.lookup(kid); ok {
+			return []crypto.PublicKey{key}
+		}
+		if i.unknownKidRefreshDue() {
+			aulogging.Logger.NoCtx().Info().Printf("token signed with unknown key id %s, refreshing keys", kid)
+			_ = i.Refresh(context.Background())
+			if key, ok := i.lookup(kid); ok {
+				return []crypto.PublicKey{key}
+			}
+		}
+	}
+	return i.all()
+}
+
+func (i *Impl) Refresh(ctx context.Context) error {
+	url := i.url()
+	if url == "" {
+		i.mu.Lock()
+		i.keys = make(map[string]crypto.PublicKey)
+		i.mu.Unlock()
+		return nil
+	}
+
+	keys, err := i.fetch(ctx, url)
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("failed to retrieve keys from %s, keeping the last good keys: %s", url, err.Error())
+		return err
+	}
+
+	i.mu.Lock()
+	i.keys = keys
+	i.mu.Unlock()
+	aulogging.Logger.Ctx(ctx).Debug().Printf("retrieved %d keys from %s", len(keys), url)
+	return nil
+}
+
+func (i *Impl) lookup(kid string) (crypto.PublicKey, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	key, ok := i.keys[kid]
+	return key, ok
+}
+
+// all returns the keys in the order of their key ids, so validation is deterministic.
+func (i *Impl) all() []crypto.PublicKey {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	kids := make([]string, 0)
+	for kid := range i.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+	result := make([]crypto.PublicKey, 0)
+	for _, kid := range kids {
+		result = append(result, i.keys[kid])
+	}
+	return result
+}
+
+func (i *Impl) unknownKidRefreshDue() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if time.Since(i.lastUnknownKidRefresh) < unknownKidRefreshInterval {
+		return false
+	}
+	i.lastUnknownKidRefresh = time.Now()
+	return true
+}
+
+func (i *Impl) fetch(ctx context.Context, url string) (map[string]crypto.PublicKey, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := i.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d", DownstreamError, response.StatusCode)
+	}
+
+	keySet := jsonWebKeySet{}
+	if err := json.NewDecoder(response.Body).Decode(&keySet); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]crypto.PublicKey)
+	for index, jwk := range keySet.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		var key crypto.PublicKey
+		var err error
+		switch jwk.Kty {
+		case "RSA":
+			key, err = parseRSAPublicKey(jwk)
+		case "EC":
+			key, err = parseECPublicKey(jwk)
+		default:
+			continue
+		}
+		if err != nil {
+			aulogging.Logger.Ctx(ctx).Warn().Printf("skipping key %d (kid %s) from %s: %s", index, jwk.Kid, url, err.Error())
+			continue
+		}
+		kid := jwk.Kid
+		if kid == "" {
+			// never matches a token key id, but is still tried for tokens without a key id
+			kid = fmt.Sprintf("#%d", index)
+		}
+		result[kid] = key
+	}
+	if len(result) == 0 {
+		return nil, EmptyKeySetError
+	}
+	return result, nil
+}
+
+func parseRSAPublicKey(jwk jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(jwk.N, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus: %s", err.Error())
+	}
+	e, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(jwk.E, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid exponent: %s", err.Error())
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("invalid key parameters")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+// parseECPublicKey only supports P-256, which is the curve used by ES256.
+func parseECPublicKey(jwk jsonWebKey) (*ecdsa.PublicKey, error) {
+	if jwk.Crv != "P-256" {
+		return nil, fmt.Errorf("unsupported curve %s", jwk.Crv)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(jwk.X, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid x coordinate: %s", err.Error())
+	}
+	y, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(jwk.Y, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid y coordinate: %s", err.Error())
+	}
+	key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	if !key.Curve.IsOnCurve(key.X, key.Y) {
+		return nil, fmt.Errorf("point is not on the curve")
+	}
+	return key, nil
+}
@@ -0,0 +1,136 @@
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type tstKeyServer struct {
+	mu       sync.Mutex
+	keys     map[string]crypto.PublicKey
+	failing  bool
+	requests int
+}
+
+func (s *tstKeyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if s.failing {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	keySet := jsonWebKeySet{Keys: []jsonWebKey{{Kty: "oct", Kid: "ignored"}}}
+	for kid, key := range s.keys {
+		switch k := key.(type) {
+		case *rsa.PublicKey:
+			keySet.Keys = append(keySet.Keys, jsonWebKey{
+				Kty: "RSA",
+				Use: "sig",
+				Kid: kid,
+				N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+			})
+		case *ecdsa.PublicKey:
+			keySet.Keys = append(keySet.Keys, jsonWebKey{
+				Kty: "EC",
+				Kid: kid,
+				Crv: "P-256",
+				X:   base64.RawURLEncoding.EncodeToString(k.X.Bytes()),
+				Y:   base64.RawURLEncoding.EncodeToString(k.Y.Bytes()),
+			})
+		}
+	}
+	_ = json.NewEncoder(w).Encode(keySet)
+}
+
+func (s *tstKeyServer) set(keys map[string]crypto.PublicKey, failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+	s.failing = failing
+}
+
+func tstGenerateECKey(t *testing.T) *ecdsa.PublicKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	return &key.PublicKey
+}
+
+func tstGenerateKey(t *testing.T) *rsa.PublicKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	return &key.PublicKey
+}
+
+func tstSetupKeySet(t *testing.T, keys map[string]crypto.PublicKey) (*Impl, *tstKeyServer) {
+	keyServer := &tstKeyServer{keys: keys}
+	server := httptest.NewServer(keyServer)
+	t.Cleanup(server.Close)
+
+	impl := newImpl()
+	impl.url = func() string { return server.URL }
+	return impl, keyServer
+}
+
+func TestRefreshAndSelectByKid(t *testing.T) {
+	docs.Description("check that the keys are retrieved, and a known key id selects only that key")
+	key1 := tstGenerateKey(t)
+	key2 := tstGenerateECKey(t)
+	impl, _ := tstSetupKeySet(t, map[string]crypto.PublicKey{"k1": key1, "k2": key2})
+
+	require.Nil(t, impl.Refresh(context.Background()))
+	require.Equal(t, []crypto.PublicKey{key2}, impl.KeysFor("k2"))
+	require.Equal(t, []crypto.PublicKey{key1, key2}, impl.KeysFor(""))
+}
+
+func TestRefreshFailureKeepsLastGoodKeys(t *testing.T) {
+	docs.Description("check that the last good keys are kept when the endpoint fails")
+	key1 := tstGenerateKey(t)
+	impl, keyServer := tstSetupKeySet(t, map[string]crypto.PublicKey{"k1": key1})
+	require.Nil(t, impl.Refresh(context.Background()))
+
+	keyServer.set(nil, true)
+	require.NotNil(t, impl.Refresh(context.Background()))
+	require.Equal(t, []crypto.PublicKey{key1}, impl.KeysFor("k1"))
+
+	keyServer.set(map[string]crypto.PublicKey{}, false)
+	require.Equal(t, EmptyKeySetError, impl.Refresh(context.Background()))
+	require.Equal(t, []crypto.PublicKey{key1}, impl.KeysFor("k1"))
+}
+
+func TestUnknownKidTriggersRateLimitedRefresh(t *testing.T) {
+	docs.Description("check that a rotated key is picked up on first use, but unknown key ids do not flood the endpoint")
+	key1 := tstGenerateKey(t)
+	key2 := tstGenerateKey(t)
+	impl, keyServer := tstSetupKeySet(t, map[string]crypto.PublicKey{"k1": key1})
+	require.Nil(t, impl.Refresh(context.Background()))
+
+	keyServer.set(map[string]crypto.PublicKey{"k1": key1, "k2": key2}, false)
+	require.Equal(t, []crypto.PublicKey{key2}, impl.KeysFor("k2"))
+	require.Equal(t, 2, keyServer.requests)
+
+	require.Equal(t, []crypto.PublicKey{key1, key2}, impl.KeysFor("made-up"))
+	require.Equal(t, 2, keyServer.requests)
+}
+
+func TestNoUrlConfigured(t *testing.T) {
+	docs.Description("check that no keys are returned if no keyset endpoint is configured")
+	impl := newImpl()
+	impl.url = func() string { return "" }
+	require.Nil(t, impl.Refresh(context.Background()))
+	require.Empty(t, impl.KeysFor("k1"))
+}
@@ -0,0 +1,48 @@
+package jwks
+
+import (
+	"context"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"time"
+)
+
+var activeInstance KeySet = newImpl()
+
+// Create retrieves the keys, and starts refreshing them in the background until the context is cancelled.
+//
+// A failure to retrieve the keys is not fatal, it is logged and retried on the next refresh.
+func Create(ctx context.Context) {
+	instance := newImpl()
+	activeInstance = instance
+
+	if config.OidcJwksUrl() == "" {
+		aulogging.Logger.NoCtx().Info().Print("security.oidc.jwks_url not configured, only using token_public_keys_PEM")
+	} else {
+		_ = instance.Refresh(ctx)
+	}
+
+	// the url may have changed
+	config.OnReload(func() {
+		go func() {
+			_ = instance.Refresh(ctx)
+		}()
+	})
+
+	go func() {
+		ticker := time.NewTicker(config.OidcJwksRefreshInterval())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_ = instance.Refresh(ctx)
+			}
+		}
+	}()
+}
+
+func Get() KeySet {
+	return activeInstance
+}
@@ -0,0 +1,23 @@
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"errors"
+)
+
+// KeySet holds the public keys retrieved from the openid keyset endpoint configured as security.oidc.jwks_url.
+type KeySet interface {
+	// KeysFor returns the candidate keys for a token signed with the given key id.
+	//
+	// If the key id is known, only that key is returned. Otherwise, all keys are returned.
+	KeysFor(kid string) []crypto.PublicKey
+
+	// Refresh retrieves the keys again. On failure, the last good keys are kept.
+	Refresh(ctx context.Context) error
+}
+
+var (
+	DownstreamError  = errors.New("keyset endpoint unavailable - see log for details")
+	EmptyKeySetError = errors.New("keyset endpoint returned no usable RSA or ECDSA signing keys")
+)
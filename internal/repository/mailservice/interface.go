@@ -17,4 +17,5 @@ type TemplateRequestDto struct {
 	Name      string            `json:"name"`
 	Variables map[string]string `json:"variables"`
 	Email     string            `json:"email"`
+	Lang      string            `json:"lang,omitempty"` // empty means the default language of the mail service
 }
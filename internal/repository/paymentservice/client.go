@@ -20,7 +20,7 @@ type Impl struct {
 
 func requestManipulator(ctx context.Context, r *http.Request) {
 	// TODO do we ever need to pass on the user token instead?
-	r.Header.Add(media.HeaderXApiKey, config.FixedApiToken())
+	r.Header.Add(media.HeaderXApiKey, config.PaymentServiceToken())
 }
 
 func newClient() (PaymentService, error) {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/service/authsrv"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
 )
 
@@ -40,3 +41,9 @@ func (s *AttendeeServiceImplData) UpdateAdminInfo(ctx context.Context, attendee
 
 	return nil
 }
+
+// isAdmin is true for the admin role, api clients with full access, and owners of a registration
+// with the admin permission.
+func isAdmin(ctx context.Context) (bool, error) {
+	return authorizationService.HasPermission(ctx, authsrv.PermissionAdmin)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+	"sort"
 	"strings"
 	"time"
 )
@@ -24,14 +25,22 @@ func (s *AttendeeServiceImplData) RegisterNewAttendee(ctx context.Context, atten
 	}
 	if alreadyExists {
 		aulogging.Logger.Ctx(ctx).Warn().Printf("received new registration duplicate - nick %s zip %s email %s", attendee.Nickname, attendee.Zip, attendee.Email)
-		return 0, errors.New("duplicate attendee data - you are already registered")
+		return 0, DuplicateAttendeeError
 	}
 
 	// record which user owns this attendee
 	attendee.Identity = ctxvalues.Subject(ctx)
+	attendee.EmailVerified = false
 
-	id, err := database.GetRepository().AddAttendee(ctx, attendee)
-	return id, err
+	id, err := addAttendee(ctx, attendee)
+	if err != nil {
+		return id, err
+	}
+
+	if config.EmailVerificationEnabled() {
+		sendEmailVerificationBestEffort(ctx, attendee)
+	}
+	return id, nil
 }
 
 func (s *AttendeeServiceImplData) GetAttendee(ctx context.Context, id uint) (*entity.Attendee, error) {
@@ -40,21 +49,36 @@ func (s *AttendeeServiceImplData) GetAttendee(ctx context.Context, id uint) (*en
 }
 
 func (s *AttendeeServiceImplData) UpdateAttendee(ctx context.Context, attendee *entity.Attendee) error {
-	alreadyExists, err := isDuplicateAttendee(ctx, attendee.Nickname, attendee.Zip, attendee.Email, 1)
+	previous, err := database.GetRepository().GetAttendeeById(ctx, attendee.ID)
+	if err != nil {
+		return err
+	}
+
+	alreadyExists, err := isDuplicateUpdate(ctx, previous, attendee)
 	if err != nil {
 		return err
 	}
 	if alreadyExists {
 		aulogging.Logger.Ctx(ctx).Warn().Printf("received update with registration duplicate - nick %s zip %s email %s", attendee.Nickname, attendee.Zip, attendee.Email)
-		return errors.New("your changes would lead to duplicate attendee data - same nickname, zip, email")
+		return fmt.Errorf("%w: your changes would lead to duplicate attendee data - same nickname, zip, email", DuplicateAttendeeError)
+	}
+
+	if err := s.checkNoForbiddenPackageRemoval(ctx, attendee); err != nil {
+		return err
+	}
+	if err := s.checkNoForbiddenVoucherChange(ctx, previous, attendee); err != nil {
+		return err
 	}
 
-	// TODO: verify permissions - after first payment, only admins can remove packages
+	sendVerification := markEmailUnverifiedIfChanged(previous.Email, attendee)
 
-	err = database.GetRepository().UpdateAttendee(ctx, attendee)
+	err = updateAttendee(ctx, previous, attendee)
 	if err != nil {
 		return err
 	}
+	if sendVerification {
+		sendEmailVerificationBestEffort(ctx, attendee)
+	}
 
 	statusHistory, err := s.GetFullStatusHistory(ctx, attendee)
 	if err != nil {
@@ -84,11 +108,14 @@ func (s *AttendeeServiceImplData) CanChangeChoiceTo(ctx context.Context, origina
 		if err := checkNoForbiddenChanges(ctx, k, v, originalChoices, newChoices); err != nil {
 			return err
 		}
+		if err := checkNoUnavailableChoice(ctx, k, v, originalChoices, newChoices); err != nil {
+			return err
+		}
 		if err := checkNoConstraintViolation(k, v, newChoices); err != nil {
 			return err
 		}
 	}
-	return nil
+	return checkNoRuleViolation(configuration, newChoices)
 }
 
 func (s *AttendeeServiceImplData) CanRegisterAtThisTime(ctx context.Context) error {
@@ -122,10 +149,24 @@ func isDuplicateAttendee(ctx context.Context, nickname string, zip string, email
 	return count != expectedCount, nil
 }
 
+// isDuplicateUpdate checks an update for duplicates. The attendee itself is only found if nickname, zip and email
+// are unchanged, otherwise every change to one of them would count as a duplicate of the attendee itself.
+func isDuplicateUpdate(ctx context.Context, previous *entity.Attendee, attendee *entity.Attendee) (bool, error) {
+	var expectedCount int64 = 0
+	if previous.Nickname == attendee.Nickname && previous.Zip == attendee.Zip && previous.Email == attendee.Email {
+		expectedCount = 1
+	}
+	return isDuplicateAttendee(ctx, attendee.Nickname, attendee.Zip, attendee.Email, expectedCount)
+}
+
 func checkNoForbiddenChanges(ctx context.Context, key string, choiceConfig config.ChoiceConfig, originalChoices map[string]bool, newChoices map[string]bool) error {
 	if choiceConfig.AdminOnly || choiceConfig.ReadOnly {
 		if originalChoices[key] != newChoices[key] {
-			if !ctxvalues.HasApiToken(ctx) && !ctxvalues.IsAuthorizedAsRole(ctx, config.OidcAdminRole()) {
+			admin, err := isAdmin(ctx)
+			if err != nil {
+				return err
+			}
+			if !admin {
 				return errors.New("forbidden change in state of choice key " + key + " - only an admin can do that")
 			}
 		}
@@ -133,26 +174,91 @@ func checkNoForbiddenChanges(ctx context.Context, key string, choiceConfig confi
 	return nil
 }
 
+// checkNoUnavailableChoice rejects newly picking a choice outside its availability window. Attendees who already
+// have the choice can keep it.
+func checkNoUnavailableChoice(ctx context.Context, key string, choiceConfig config.ChoiceConfig, originalChoices map[string]bool, newChoices map[string]bool) error {
+	if newChoices[key] && !originalChoices[key] && !choiceConfig.AvailableAt(time.Now()) {
+		admin, err := isAdmin(ctx)
+		if err != nil {
+			return err
+		}
+		if !admin {
+			return errors.New("choice key " + key + " is not available at this time")
+		}
+	}
+	return nil
+}
+
 func checkNoConstraintViolation(key string, choiceConfig config.ChoiceConfig, newChoices map[string]bool) error {
-	if choiceConfig.Constraint != "" {
-		constraints := strings.Split(choiceConfig.Constraint, ",")
-		for _, cn := range constraints {
-			constraintK := cn
-			if strings.HasPrefix(cn, "!") {
-				constraintK = strings.TrimPrefix(cn, "!")
-				if newChoices[key] && newChoices[constraintK] {
-					return errors.New("cannot pick both " + key + " and " + constraintK + " - constraint violated")
-				}
-			} else {
-				if newChoices[key] && !newChoices[constraintK] {
-					return errors.New("when picking " + key + ", must also pick " + constraintK + " - constraint violated")
-				}
+	if choiceConfig.Constraint == "" {
+		return nil
+	}
+	if !config.IsLegacyConstraint(choiceConfig.Constraint) {
+		if !newChoices[key] {
+			return nil
+		}
+		parsed, err := parsedConstraint(choiceConfig.ParsedConstraint, choiceConfig.Constraint)
+		if err != nil {
+			return err
+		}
+		if !parsed.Holds(newChoices) {
+			return errors.New("constraint of " + key + " violated: " + choiceConfig.ConstraintMsg)
+		}
+		return nil
+	}
+
+	constraints := strings.Split(choiceConfig.Constraint, ",")
+	for _, cn := range constraints {
+		constraintK := cn
+		if strings.HasPrefix(cn, "!") {
+			constraintK = strings.TrimPrefix(cn, "!")
+			if newChoices[key] && newChoices[constraintK] {
+				return errors.New("cannot pick both " + key + " and " + constraintK + " - constraint violated")
+			}
+		} else {
+			if newChoices[key] && !newChoices[constraintK] {
+				return errors.New("when picking " + key + ", must also pick " + constraintK + " - constraint violated")
+			}
+		}
+	}
+	return nil
+}
+
+// checkNoRuleViolation checks the choice rules that reference any of the given choices.
+func checkNoRuleViolation(configuration map[string]config.ChoiceConfig, newChoices map[string]bool) error {
+	seen := make(map[*config.ChoiceRule]bool)
+	rules := make([]*config.ChoiceRule, 0)
+	for _, v := range configuration {
+		for _, rule := range v.Rules {
+			if !seen[rule] {
+				seen[rule] = true
+				rules = append(rules, rule)
 			}
 		}
 	}
+	// report violations in a stable order
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+
+	for _, rule := range rules {
+		parsed, err := parsedConstraint(rule.ParsedConstraint, rule.Constraint)
+		if err != nil {
+			return err
+		}
+		if !parsed.Holds(newChoices) {
+			return errors.New("rule " + rule.Name + " violated: " + rule.ConstraintMsg)
+		}
+	}
 	return nil
 }
 
+// parsedConstraint avoids parsing again if configuration loading already did it.
+func parsedConstraint(parsed config.ChoiceConstraint, constraint string) (config.ChoiceConstraint, error) {
+	if parsed != nil {
+		return parsed, nil
+	}
+	return config.ParseChoiceConstraint(constraint)
+}
+
 func choiceStrToMap(choiceStr string) map[string]bool {
 	result := make(map[string]bool)
 	if choiceStr != "" {
@@ -0,0 +1,363 @@
+package attendeesrv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BadgeAddInfoArea is the additional info area in which badge prints are recorded.
+const BadgeAddInfoArea = "badge"
+
+const (
+	BadgeFilterAll        = "all"         // every attendee in status paid or checked in
+	BadgeFilterNotPrinted = "not_printed" // badge was never printed
+	BadgeFilterChanged    = "changed"     // badge was printed, but the data on it has changed since
+	BadgeFilterQueue      = "queue"       // not printed or changed, that is, a badge needs to be printed
+)
+
+// only attendees in these statuses get a badge
+var badgeStatuses = []string{"paid", "checked in"}
+
+// the fields that end up on the badge, by history entity name
+var badgeRelevantFields = map[string][]string{
+	"Attendee":  {"Nickname", "CountryBadge", "Packages", "Flags"},
+	"AdminInfo": {"Flags"},
+}
+
+// BadgeInfo is an attendee together with what is printed on their badge and its print state.
+type BadgeInfo struct {
+	Attendee          *entity.Attendee
+	Status            string
+	SponsorTier       string   // the first of the configured sponsor packages the attendee has, or empty
+	Markers           []string // the configured marker flags the attendee has, including admin only flags
+	Prints            []BadgePrint
+	ChangedSincePrint bool
+}
+
+// BadgeRecord is stored in the badge additional info area.
+type BadgeRecord struct {
+	Prints []BadgePrint `json:"prints"`
+}
+
+// BadgePrint records a single print of a badge.
+type BadgePrint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Operator  string    `json:"operator"`
+	Reason    string    `json:"reason,omitempty"` // required for reprints
+	// the id of the latest history entry of the attendee and their admin info at the time of the print,
+	// later entries may change what is on the badge
+	HistoryMark uint `json:"history_mark"`
+}
+
+// badgeValues is what is actually printed on a badge.
+type badgeValues struct {
+	Nickname     string
+	CountryBadge string
+	SponsorTier  string
+	Markers      string
+}
+
+func (s *AttendeeServiceImplData) ExportBadges(ctx context.Context, filter string, pageTimeout time.Duration, consume func(entries []*BadgeInfo) error) error {
+	// controller checks permissions and validates the filter
+
+	maxId, err := database.GetRepository().MaxAttendeeId(ctx)
+	if err != nil {
+		return err
+	}
+
+	for pageStart := uint(1); pageStart <= maxId; pageStart += exportPageSize {
+		entries, err := badgePage(ctx, filter, pageStart, pageStart+exportPageSize-1, pageTimeout)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			if err := consume(entries); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// badgePage reads the badges for a range of badge numbers, with a fixed number of queries however many attendees there are.
+func badgePage(ctx context.Context, filter string, minId uint, maxId uint, pageTimeout time.Duration) ([]*BadgeInfo, error) {
+	pageCtx, cancel := context.WithTimeout(ctx, pageTimeout)
+	defer cancel()
+
+	attendees, err := database.GetRepository().FindAttendeesByStatus(pageCtx, badgeStatuses, minId, maxId)
+	if err != nil || len(attendees) == 0 {
+		return nil, err
+	}
+	ids := make([]uint, len(attendees))
+	for i, a := range attendees {
+		ids[i] = a.ID
+	}
+
+	adminInfos, err := database.GetRepository().GetAdminInfosByAttendeeIds(pageCtx, ids)
+	if err != nil {
+		return nil, err
+	}
+	adminFlags := make(map[uint]string)
+	for _, ai := range adminInfos {
+		adminFlags[ai.ID] = ai.Flags
+	}
+
+	addInfos, err := database.GetRepository().GetAdditionalInfoForAttendees(pageCtx, ids, BadgeAddInfoArea)
+	if err != nil {
+		return nil, err
+	}
+	records := make(map[uint]*BadgeRecord)
+	printedIds := make([]uint, 0)
+	for _, addInfo := range addInfos {
+		record, err := parseBadgeRecord(addInfo)
+		if err != nil {
+			return nil, err
+		}
+		records[addInfo.AttendeeId] = record
+		if len(record.Prints) > 0 {
+			printedIds = append(printedIds, addInfo.AttendeeId)
+		}
+	}
+
+	// only printed badges need their history, to find out what was printed
+	history := make(map[string]map[uint][]*entity.History)
+	for entityName := range badgeRelevantFields {
+		entries, err := database.GetRepository().GetHistoryByEntities(pageCtx, entityName, printedIds)
+		if err != nil {
+			return nil, err
+		}
+		history[entityName] = make(map[uint][]*entity.History)
+		for _, h := range entries {
+			history[entityName][h.EntityId] = append(history[entityName][h.EntityId], h)
+		}
+	}
+
+	result := make([]*BadgeInfo, 0, len(attendees))
+	for _, a := range attendees {
+		record, ok := records[a.ID]
+		if !ok {
+			record = &BadgeRecord{Prints: make([]BadgePrint, 0)}
+		}
+		attendeeHistory := map[string][]*entity.History{}
+		for entityName := range badgeRelevantFields {
+			attendeeHistory[entityName] = history[entityName][a.ID]
+		}
+		info := badgeInfo(&a.Attendee, a.Status, adminFlags[a.ID], record, attendeeHistory)
+		if badgeMatchesFilter(info, filter) {
+			result = append(result, info)
+		}
+	}
+	return result, nil
+}
+
+func (s *AttendeeServiceImplData) MarkBadgesPrinted(ctx context.Context, attendees []*entity.Attendee, reason string) error {
+	// controller checks permissions and validates the reason
+
+	// check everything first, so we either record all prints or none
+	mark := make(map[uint]uint)
+	for _, a := range attendees {
+		latest, err := database.GetRepository().GetLatestStatusChangeByAttendeeId(ctx, a.ID)
+		if err != nil {
+			return err
+		}
+		if !containsString(badgeStatuses, latest.Status) {
+			return fmt.Errorf("%w: attendee %d is in status %s", BadgeNotPrintableError, a.ID, latest.Status)
+		}
+		record, _, err := readBadgeRecord(ctx, a.ID)
+		if err != nil {
+			return err
+		}
+		if len(record.Prints) > 0 && reason == "" {
+			return fmt.Errorf("%w: attendee %d", ReprintReasonMissingError, a.ID)
+		}
+		mark[a.ID], err = latestBadgeHistoryId(ctx, a.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	operator := ctxvalues.UserId(ctx)
+	for _, a := range attendees {
+		record, addInfo, err := readBadgeRecord(ctx, a.ID)
+		if err != nil {
+			return err
+		}
+		record.Prints = append(record.Prints, BadgePrint{
+			Timestamp:   time.Now().UTC(),
+			Operator:    operator,
+			Reason:      reason,
+			HistoryMark: mark[a.ID],
+		})
+		if err := writeBadgeRecord(ctx, addInfo, record); err != nil {
+			return err
+		}
+		aulogging.Logger.Ctx(ctx).Info().Printf("badge of attendee %d marked as printed (print %d) by %s", a.ID, len(record.Prints), operator)
+	}
+	return nil
+}
+
+// badgeInfo needs the history entries of the attendee and their admin info, by entity name, only if the badge was printed.
+func badgeInfo(a *entity.Attendee, status string, adminFlags string, record *BadgeRecord, history map[string][]*entity.History) *BadgeInfo {
+	current := currentBadgeValues(a, adminFlags)
+
+	info := &BadgeInfo{
+		Attendee:    a,
+		Status:      status,
+		SponsorTier: current.SponsorTier,
+		Markers:     make([]string, 0),
+		Prints:      record.Prints,
+	}
+	if current.Markers != "" {
+		info.Markers = strings.Split(current.Markers, ",")
+	}
+	if len(record.Prints) > 0 {
+		lastPrint := record.Prints[len(record.Prints)-1]
+		info.ChangedSincePrint = printedBadgeValues(a, adminFlags, lastPrint.HistoryMark, history) != current
+	}
+	return info
+}
+
+func badgeMatchesFilter(info *BadgeInfo, filter string) bool {
+	switch filter {
+	case BadgeFilterNotPrinted:
+		return len(info.Prints) == 0
+	case BadgeFilterChanged:
+		return info.ChangedSincePrint
+	case BadgeFilterQueue:
+		return len(info.Prints) == 0 || info.ChangedSincePrint
+	default:
+		return true
+	}
+}
+
+func currentBadgeValues(a *entity.Attendee, adminFlags string) badgeValues {
+	return badgeValuesFrom(badgeFields(a, adminFlags))
+}
+
+// badgeFields returns the current values of the badge relevant fields, keyed by entity name and field name.
+func badgeFields(a *entity.Attendee, adminFlags string) map[string]string {
+	return map[string]string{
+		"Attendee.Nickname":     a.Nickname,
+		"Attendee.CountryBadge": a.CountryBadge,
+		"Attendee.Packages":     a.Packages,
+		"Attendee.Flags":        a.Flags,
+		"AdminInfo.Flags":       adminFlags,
+	}
+}
+
+// printedBadgeValues reconstructs what was on the badge at the time of the print from the history entries, by entity name.
+//
+// History entries contain the old values, so for each field, the first entry after the print has its value at the time.
+func printedBadgeValues(a *entity.Attendee, adminFlags string, historyMark uint, history map[string][]*entity.History) badgeValues {
+	fields := badgeFields(a, adminFlags)
+	for entityName, relevant := range badgeRelevantFields {
+		seen := make(map[string]bool)
+		for _, h := range history[entityName] {
+			if h.ID <= historyMark {
+				continue
+			}
+			for _, line := range strings.Split(h.Diff, "\n") {
+				matches := historyDiffLineRegex.FindStringSubmatch(line)
+				if matches == nil || !containsString(relevant, matches[2]) || seen[matches[2]] {
+					continue
+				}
+				seen[matches[2]] = true
+				rawValue := strings.TrimPrefix(line, matches[1]+": ."+matches[2]+" = ")
+				oldValue, err := strconv.Unquote(rawValue)
+				if err != nil {
+					// not a quoted string, e.g. scrubbed, compare as is, which will almost certainly count as changed
+					oldValue = rawValue
+				}
+				fields[entityName+"."+matches[2]] = oldValue
+			}
+		}
+	}
+	return badgeValuesFrom(fields)
+}
+
+func badgeValuesFrom(fields map[string]string) badgeValues {
+	result := badgeValues{
+		Nickname:     fields["Attendee.Nickname"],
+		CountryBadge: fields["Attendee.CountryBadge"],
+	}
+	packages := choiceStrToMap(fields["Attendee.Packages"])
+	for _, p := range config.BadgeSponsorPackages() {
+		if packages[p] {
+			result.SponsorTier = p
+			break
+		}
+	}
+	flags := choiceStrToMap(fields["Attendee.Flags"])
+	for k, v := range choiceStrToMap(fields["AdminInfo.Flags"]) {
+		flags[k] = flags[k] || v
+	}
+	markers := make([]string, 0)
+	for _, f := range config.BadgeMarkerFlags() {
+		if flags[f] {
+			markers = append(markers, f)
+		}
+	}
+	result.Markers = strings.Join(markers, ",")
+	return result
+}
+
+// latestBadgeHistoryId returns the id of the latest history entry of the attendee and their admin info, or 0 if there is none.
+func latestBadgeHistoryId(ctx context.Context, attendeeId uint) (uint, error) {
+	latest := uint(0)
+	for entityName := range badgeRelevantFields {
+		entries, err := database.GetRepository().GetHistoryByEntity(ctx, entityName, attendeeId)
+		if err != nil {
+			return 0, err
+		}
+		if len(entries) > 0 && entries[len(entries)-1].ID > latest {
+			latest = entries[len(entries)-1].ID
+		}
+	}
+	return latest, nil
+}
+
+// readBadgeRecord returns an empty record if the badge was never printed.
+func readBadgeRecord(ctx context.Context, attendeeId uint) (*BadgeRecord, *entity.AdditionalInfo, error) {
+	addInfo, err := database.GetRepository().GetAdditionalInfoFor(ctx, attendeeId, BadgeAddInfoArea)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &BadgeRecord{Prints: make([]BadgePrint, 0)}, addInfo, nil
+		}
+		return nil, nil, err
+	}
+	record, err := parseBadgeRecord(addInfo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return record, addInfo, nil
+}
+
+func parseBadgeRecord(addInfo *entity.AdditionalInfo) (*BadgeRecord, error) {
+	record := &BadgeRecord{Prints: make([]BadgePrint, 0)}
+	if addInfo.JsonValue != "" {
+		if err := json.Unmarshal([]byte(addInfo.JsonValue), record); err != nil {
+			return nil, fmt.Errorf("badge additional info of attendee %d is not a badge record: %s", addInfo.AttendeeId, err.Error())
+		}
+	}
+	return record, nil
+}
+
+func writeBadgeRecord(ctx context.Context, addInfo *entity.AdditionalInfo, record *BadgeRecord) error {
+	jsonValue, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	addInfo.JsonValue = string(jsonValue)
+	return database.GetRepository().WriteAdditionalInfo(ctx, addInfo)
+}
@@ -0,0 +1,145 @@
+package attendeesrv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+	"gorm.io/gorm"
+	"time"
+)
+
+// guests may not read their mail every day, so invitations are valid for longer than transfer codes
+const invitationCodeValidity = 30 * 24 * time.Hour
+
+// CompAddInfoArea is the additional info area in which the comp of a registration created by an admin is stored.
+const CompAddInfoArea = "comp"
+
+// Comp makes a registration complimentary.
+type Comp struct {
+	Reason string
+	// Discount in cents. Zero means the package dues are waived completely.
+	Discount int64
+}
+
+// CompRecord is what is stored in the comp additional info area.
+//
+// It is read whenever the dues are adjusted, so a full comp also covers packages selected later on.
+type CompRecord struct {
+	Reason    string `json:"reason"`
+	Discount  int64  `json:"discount"`
+	CreatedBy string `json:"created_by"`
+}
+
+func (s *AttendeeServiceImplData) CreateAttendeeFor(ctx context.Context, attendee *entity.Attendee, adminFlags string, initialStatus string, comp *Comp) (uint, string, time.Time, error) {
+	// controller checks permissions and validates the attendee, admin flags, status and comp
+
+	alreadyExists, err := isDuplicateAttendee(ctx, attendee.Nickname, attendee.Zip, attendee.Email, 0)
+	if err != nil {
+		return 0, "", time.Time{}, err
+	}
+	if alreadyExists {
+		return 0, "", time.Time{}, DuplicateAttendeeError
+	}
+
+	// nobody owns the registration until the invitation is redeemed
+	attendee.Identity = ""
+	attendee.EmailVerified = false
+
+	id, err := database.GetRepository().AddAttendee(ctx, attendee)
+	if err != nil {
+		return 0, "", time.Time{}, err
+	}
+	subject := ctxvalues.Subject(ctx)
+
+	if adminFlags != "" {
+		adminInfo, err := database.GetRepository().GetAdminInfoByAttendeeId(ctx, id)
+		if err != nil {
+			return id, "", time.Time{}, err
+		}
+		adminInfo.Flags = adminFlags
+		if err := database.GetRepository().WriteAdminInfo(ctx, adminInfo); err != nil {
+			return id, "", time.Time{}, err
+		}
+	}
+
+	if comp != nil {
+		if err := writeCompRecord(ctx, id, &CompRecord{
+			Reason:    comp.Reason,
+			Discount:  comp.Discount,
+			CreatedBy: ctxvalues.UserId(ctx),
+		}); err != nil {
+			return id, "", time.Time{}, err
+		}
+	}
+
+	if initialStatus != "" && initialStatus != "new" {
+		if err := s.StatusChangePossible(ctx, attendee, "new", initialStatus); err != nil {
+			return id, "", time.Time{}, err
+		}
+		if err := s.UpdateDuesAndDoStatusChangeIfNeeded(ctx, attendee, "new", initialStatus, fmt.Sprintf("registration created by %s", subject)); err != nil {
+			return id, "", time.Time{}, err
+		}
+	}
+
+	code, err := newSecretCode(id)
+	if err != nil {
+		return id, "", time.Time{}, err
+	}
+	record := &TransferRecord{
+		CodeHash:   hashSecretCode(code),
+		Expires:    time.Now().UTC().Add(invitationCodeValidity),
+		CreatedBy:  ctxvalues.UserId(ctx),
+		Invitation: true,
+	}
+	if err := writeTransferRecord(ctx, id, record); err != nil {
+		return id, "", time.Time{}, err
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("attendee %d created by %s with status %s (comp: %t)", id, record.CreatedBy, initialStatus, comp != nil)
+
+	sendInvitationBestEffort(ctx, attendee, code)
+	return id, code, record.Expires, nil
+}
+
+// sendInvitationBestEffort does not fail the creation if the mail cannot be sent, the admin
+// can pass on the code manually.
+func sendInvitationBestEffort(ctx context.Context, attendee *entity.Attendee, code string) {
+	if err := sendMail(ctx, mailEventGuestInvitation, attendee, map[string]string{"code": code}); err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("failed to send invitation email to attendee %d: %s", attendee.ID, err.Error())
+	}
+}
+
+// readCompRecord returns nil if the registration has no comp.
+func readCompRecord(ctx context.Context, attendeeId uint) (*CompRecord, error) {
+	addInfo, err := database.GetRepository().GetAdditionalInfoFor(ctx, attendeeId, CompAddInfoArea)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if addInfo.JsonValue == "" || addInfo.JsonValue == "{}" {
+		return nil, nil
+	}
+	record := &CompRecord{}
+	if err := json.Unmarshal([]byte(addInfo.JsonValue), record); err != nil {
+		return nil, fmt.Errorf("comp additional info of attendee %d is not a comp record: %s", attendeeId, err.Error())
+	}
+	return record, nil
+}
+
+func writeCompRecord(ctx context.Context, attendeeId uint, record *CompRecord) error {
+	jsonValue, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return database.GetRepository().WriteAdditionalInfo(ctx, &entity.AdditionalInfo{
+		AttendeeId: attendeeId,
+		Area:       CompAddInfoArea,
+		JsonValue:  string(jsonValue),
+	})
+}
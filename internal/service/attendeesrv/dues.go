@@ -7,7 +7,9 @@ import (
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -36,8 +38,9 @@ func (s *AttendeeServiceImplData) UpdateDues(ctx context.Context, attendee *enti
 		if newStatus == "approved" || newStatus == "partially paid" || newStatus == "paid" {
 			// we do not adjust status back once checked in
 
+			// no transactions at all if nothing was ever due, e.g. for guests
 			updatedTransactionHistory, err := paymentservice.Get().GetTransactions(ctx, attendee.ID)
-			if err != nil {
+			if err != nil && !errors.Is(err, paymentservice.NoSuchDebitor404Error) {
 				return newStatus, err
 			}
 
@@ -65,21 +68,42 @@ func (s *AttendeeServiceImplData) UpdateDues(ctx context.Context, attendee *enti
 }
 
 func (s *AttendeeServiceImplData) adjustDuesAccordingToSelectedPackages(ctx context.Context, attendee *entity.Attendee, transactionHistory []paymentservice.Transaction) error {
-	oldDuesByVAT := s.oldDuesByVAT(transactionHistory)
+	packageTransactions, voucherTransactions := splitVoucherTransactions(transactionHistory)
 	packageDuesByVAT := s.packageDuesByVAT(attendee)
 
-	// add missing keys to packageDuesByVAT, so we can just iterate over it and not miss any tax rates
+	comp, err := readCompRecord(ctx, attendee.ID)
+	if err != nil {
+		return err
+	}
+	applyComp(packageDuesByVAT, comp)
+
+	err = s.bookDuesDifference(ctx, attendee, s.oldDuesByVAT(packageTransactions), packageDuesByVAT, "dues adjustment due to change in status or selected packages")
+	if err != nil {
+		return err
+	}
+
+	// voucher discounts are booked separately, so they show up as such
+	voucherDuesByVAT, err := s.voucherDuesByVAT(ctx, attendee, packageDuesByVAT)
+	if err != nil {
+		return err
+	}
+	return s.bookDuesDifference(ctx, attendee, s.oldDuesByVAT(voucherTransactions), voucherDuesByVAT, voucherComment(attendee.Voucher))
+}
+
+// bookDuesDifference books a dues transaction for each VAT rate where the desired balance differs from the old one.
+func (s *AttendeeServiceImplData) bookDuesDifference(ctx context.Context, attendee *entity.Attendee, oldDuesByVAT map[string]int64, desiredDuesByVAT map[string]int64, comment string) error {
+	// add missing keys to desiredDuesByVAT, so we can just iterate over it and not miss any tax rates
 	for vatStr, _ := range oldDuesByVAT {
-		_, ok := packageDuesByVAT[vatStr]
+		_, ok := desiredDuesByVAT[vatStr]
 		if !ok {
-			packageDuesByVAT[vatStr] = 0
+			desiredDuesByVAT[vatStr] = 0
 		}
 	}
 
-	for vatStr, desiredBalance := range packageDuesByVAT {
+	for vatStr, desiredBalance := range desiredDuesByVAT {
 		currentBalance, _ := oldDuesByVAT[vatStr]
 		if currentBalance != desiredBalance {
-			diffTx := s.duesTransactionForAttendee(attendee, desiredBalance-currentBalance, vatStr, "dues adjustment due to change in status or selected packages")
+			diffTx := s.duesTransactionForAttendee(attendee, desiredBalance-currentBalance, vatStr, comment)
 			err := paymentservice.Get().AddTransaction(ctx, diffTx)
 			if err != nil {
 				return err
@@ -90,6 +114,18 @@ func (s *AttendeeServiceImplData) adjustDuesAccordingToSelectedPackages(ctx cont
 	return nil
 }
 
+// splitVoucherTransactions separates the voucher discounts from all other transactions.
+func splitVoucherTransactions(transactionHistory []paymentservice.Transaction) (others []paymentservice.Transaction, vouchers []paymentservice.Transaction) {
+	for _, tx := range transactionHistory {
+		if tx.Type == paymentservice.Due && strings.HasPrefix(tx.Comment, voucherDuesComment) {
+			vouchers = append(vouchers, tx)
+		} else {
+			others = append(others, tx)
+		}
+	}
+	return
+}
+
 func (s *AttendeeServiceImplData) packageDuesByVAT(attendee *entity.Attendee) map[string]int64 {
 	result := make(map[string]int64)
 	packageConfigs := config.Configuration().Choices.Packages
@@ -101,8 +137,8 @@ func (s *AttendeeServiceImplData) packageDuesByVAT(attendee *entity.Attendee) ma
 			} else {
 				vatStr := fmt.Sprintf("%.6f", packageConfig.VatPercent)
 
-				// TODO IMPORTANT determine whether to use early, late, or atcon dues rate, based on time constraints in config
-				price := int64(packageConfig.PriceEarly * 100)
+				// priced by registration time, so early registrations keep the early price
+				price := int64(packageConfig.PriceAt(attendee.CreatedAt) * 100)
 
 				previous, _ := result[vatStr]
 				result[vatStr] = previous + price
@@ -112,6 +148,47 @@ func (s *AttendeeServiceImplData) packageDuesByVAT(attendee *entity.Attendee) ma
 	return result
 }
 
+// applyComp reduces the package dues by the comp of a registration created by an admin, if any.
+//
+// A full comp waives the dues for all selected packages. A discount reduces the highest VAT rates first,
+// but never below zero.
+func applyComp(duesByVAT map[string]int64, comp *CompRecord) {
+	if comp == nil {
+		return
+	}
+	if comp.Discount == 0 {
+		for vatStr := range duesByVAT {
+			duesByVAT[vatStr] = 0
+		}
+		return
+	}
+
+	remaining := comp.Discount
+	for _, vatStr := range vatRatesDescending(duesByVAT) {
+		reduction := duesByVAT[vatStr]
+		if reduction > remaining {
+			reduction = remaining
+		}
+		if reduction > 0 {
+			duesByVAT[vatStr] -= reduction
+			remaining -= reduction
+		}
+	}
+}
+
+func vatRatesDescending(duesByVAT map[string]int64) []string {
+	vatStrs := make([]string, 0, len(duesByVAT))
+	for vatStr := range duesByVAT {
+		vatStrs = append(vatStrs, vatStr)
+	}
+	sort.Slice(vatStrs, func(i, j int) bool {
+		vatI, _ := strconv.ParseFloat(vatStrs[i], 64)
+		vatJ, _ := strconv.ParseFloat(vatStrs[j], 64)
+		return vatI > vatJ
+	})
+	return vatStrs
+}
+
 func (s *AttendeeServiceImplData) compensateAllDues(ctx context.Context, attendee *entity.Attendee, newStatus string, transactionHistory []paymentservice.Transaction) error {
 	oldDuesByVAT := s.oldDuesByVAT(transactionHistory)
 
@@ -0,0 +1,129 @@
+package attendeesrv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"sort"
+	"strings"
+	"time"
+)
+
+func (s *AttendeeServiceImplData) IneligibleChoices(ctx context.Context, attendee *entity.Attendee, originalChoiceStr string, configuration map[string]config.ChoiceConfig) (map[string]string, error) {
+	result := make(map[string]string)
+
+	currentStatus := ""
+	originalChoices := choiceStrToMap(originalChoiceStr)
+	for k, v := range configuration {
+		if v.Eligibility == nil {
+			continue
+		}
+		if v.Eligibility.BeforeStatus != "" && currentStatus == "" {
+			var err error
+			currentStatus, err = statusForEligibility(ctx, attendee)
+			if err != nil {
+				return result, err
+			}
+		}
+		if reason := ineligibilityReason(v.Eligibility, attendee, currentStatus, originalChoices[k]); reason != "" {
+			result[k] = reason
+		}
+	}
+	return result, nil
+}
+
+func (s *AttendeeServiceImplData) CheckChoiceEligibility(ctx context.Context, attendee *entity.Attendee, originalChoiceStr string, newChoiceStr string, configuration map[string]config.ChoiceConfig) error {
+	if admin, err := isAdmin(ctx); err != nil || admin {
+		return err
+	}
+
+	ineligible, err := s.IneligibleChoices(ctx, attendee, originalChoiceStr, configuration)
+	if err != nil {
+		return err
+	}
+
+	newChoices := choiceStrToMap(newChoiceStr)
+	keys := make([]string, 0)
+	for k := range ineligible {
+		if newChoices[k] {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) > 0 {
+		// report in a stable order
+		sort.Strings(keys)
+		return errors.New("cannot pick " + keys[0] + " - " + ineligible[keys[0]])
+	}
+	return nil
+}
+
+// statusForEligibility determines the current status, which is "new" for attendees that have not been saved yet.
+func statusForEligibility(ctx context.Context, attendee *entity.Attendee) (string, error) {
+	if attendee.ID == 0 {
+		return "new", nil
+	}
+	latest, err := database.GetRepository().GetLatestStatusChangeByAttendeeId(ctx, attendee.ID)
+	if err != nil {
+		return "", err
+	}
+	return latest.Status, nil
+}
+
+// ineligibilityReason returns an explanation why the attendee may not pick the choice, or "" if they may.
+//
+// The status condition only applies when the choice is newly picked, so attendees keep what they already have.
+func ineligibilityReason(eligibility *config.ChoiceEligibility, attendee *entity.Attendee, currentStatus string, alreadyPicked bool) string {
+	if eligibility.MinAge > 0 || eligibility.MaxAge > 0 {
+		if age, ok := ageOnFirstConDay(attendee.Birthday); ok {
+			if eligibility.MinAge > 0 && age < eligibility.MinAge {
+				return fmt.Sprintf("only available for attendees at least %d years old on the first day of the convention", eligibility.MinAge)
+			}
+			if eligibility.MaxAge > 0 && age > eligibility.MaxAge {
+				return fmt.Sprintf("only available for attendees at most %d years old on the first day of the convention", eligibility.MaxAge)
+			}
+		}
+	}
+	if len(eligibility.Countries) > 0 && !containsString(eligibility.Countries, attendee.Country) {
+		return "only available for attendees from " + strings.Join(eligibility.Countries, ", ")
+	}
+	if eligibility.BeforeStatus != "" && !alreadyPicked && !statusBefore(currentStatus, eligibility.BeforeStatus) {
+		return "can no longer be picked once the status is " + eligibility.BeforeStatus
+	}
+	return ""
+}
+
+// ageOnFirstConDay is false if the birthday is invalid, or no first con day is configured.
+func ageOnFirstConDay(birthday string) (int, bool) {
+	firstDay, ok := config.FirstConDay()
+	if !ok {
+		return 0, false
+	}
+	born, err := time.Parse(config.IsoDateFormat, birthday)
+	if err != nil {
+		return 0, false
+	}
+	age := firstDay.Year() - born.Year()
+	if firstDay.Month() < born.Month() || (firstDay.Month() == born.Month() && firstDay.Day() < born.Day()) {
+		// birthday not yet reached in that year
+		age--
+	}
+	return age, true
+}
+
+// statusBefore is true if status comes before limit in the status progression.
+//
+// cancelled and deleted never come before anything.
+func statusBefore(status string, limit string) bool {
+	for _, s := range config.StatusProgression() {
+		if s == limit {
+			return false
+		}
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
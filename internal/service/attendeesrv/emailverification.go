@@ -0,0 +1,135 @@
+package attendeesrv
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (s *AttendeeServiceImplData) SendEmailVerification(ctx context.Context, attendee *entity.Attendee) error {
+	// controller checks permissions
+
+	if !config.EmailVerificationEnabled() {
+		return EmailVerificationDisabledError
+	}
+	if attendee.EmailVerified {
+		return EmailAlreadyVerifiedError
+	}
+	return sendEmailVerification(ctx, attendee)
+}
+
+func (s *AttendeeServiceImplData) VerifyEmail(ctx context.Context, token string) (*entity.Attendee, error) {
+	// no permission check, the token is proof enough
+
+	if !config.EmailVerificationEnabled() {
+		return nil, EmailVerificationDisabledError
+	}
+
+	attendee, err := attendeeForEmailVerificationToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if attendee.EmailVerified {
+		// links do get clicked twice
+		return attendee, nil
+	}
+
+	attendee.EmailVerified = true
+	if err := database.GetRepository().UpdateAttendee(ctx, attendee); err != nil {
+		return nil, err
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("email address of attendee %d verified", attendee.ID)
+	return attendee, nil
+}
+
+// markEmailUnverifiedIfChanged must be called before saving an attendee whose email address may have changed.
+//
+// Returns true if a verification email should be sent once the attendee has been saved.
+func markEmailUnverifiedIfChanged(previousEmail string, attendee *entity.Attendee) bool {
+	if attendee.Email == previousEmail {
+		return false
+	}
+	attendee.EmailVerified = false
+	return config.EmailVerificationEnabled()
+}
+
+// sendEmailVerificationBestEffort does not fail the surrounding operation if the mail cannot be sent,
+// the attendee can request another verification email.
+func sendEmailVerificationBestEffort(ctx context.Context, attendee *entity.Attendee) {
+	if err := sendEmailVerification(ctx, attendee); err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("failed to send verification email to attendee %d: %s", attendee.ID, err.Error())
+	}
+}
+
+func sendEmailVerification(ctx context.Context, attendee *entity.Attendee) error {
+	expires := time.Now().Add(config.EmailVerificationTokenValidity())
+	return sendMail(ctx, mailEventEmailVerification, attendee, map[string]string{
+		"token": emailVerificationToken(attendee, expires),
+	})
+}
+
+// emailVerificationToken is <badge number>.<expiry as unix time>.<signature>.
+//
+// The signature also covers the email address, so the token stops working if the email address is changed.
+func emailVerificationToken(attendee *entity.Attendee, expires time.Time) string {
+	payload := fmt.Sprintf("%d.%d", attendee.ID, expires.Unix())
+	return payload + "." + emailVerificationSignature(payload, attendee.Email)
+}
+
+func emailVerificationSignature(payload string, email string) string {
+	mac := hmac.New(sha256.New, []byte(config.EmailVerificationSecret()))
+	mac.Write([]byte(payload + "." + email))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// attendeeForEmailVerificationToken returns EmailVerificationTokenInvalidError for anything that is wrong with the token.
+func attendeeForEmailVerificationToken(ctx context.Context, token string) (*entity.Attendee, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, EmailVerificationTokenInvalidError
+	}
+	id, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil || id == 0 {
+		return nil, EmailVerificationTokenInvalidError
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return nil, EmailVerificationTokenInvalidError
+	}
+
+	attendee, err := database.GetRepository().GetAttendeeById(ctx, uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, EmailVerificationTokenInvalidError
+		}
+		return nil, err
+	}
+
+	expected := emailVerificationSignature(parts[0]+"."+parts[1], attendee.Email)
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, EmailVerificationTokenInvalidError
+	}
+	return attendee, nil
+}
+
+func checkEmailVerifiedForApproval(attendee *entity.Attendee, oldStatus string) error {
+	if !config.EmailVerificationRequiredForApproval() || attendee.EmailVerified {
+		return nil
+	}
+	// later changes back to approved, e.g. after a refund, are not blocked
+	if oldStatus == "new" || oldStatus == "cancelled" || oldStatus == "deleted" {
+		return EmailNotVerifiedError
+	}
+	return nil
+}
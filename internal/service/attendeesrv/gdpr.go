@@ -0,0 +1,259 @@
+package attendeesrv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// PersonalDataExport holds everything we store about an attendee, or about them in other services.
+type PersonalDataExport struct {
+	Attendee       *entity.Attendee
+	StatusHistory  []entity.StatusChange
+	AdminFlags     string // only filled for admins, the attendee does not get to see admin-only flags
+	AdditionalInfo []*entity.AdditionalInfo
+	Transactions   []paymentservice.Transaction
+}
+
+func (s *AttendeeServiceImplData) GetPersonalDataExport(ctx context.Context, attendee *entity.Attendee) (*PersonalDataExport, error) {
+	// controller checks permissions (self, admin, or api token)
+
+	result := &PersonalDataExport{
+		Attendee:       attendee,
+		AdditionalInfo: make([]*entity.AdditionalInfo, 0),
+		Transactions:   make([]paymentservice.Transaction, 0),
+	}
+
+	statusHistory, err := s.GetFullStatusHistory(ctx, attendee)
+	if err != nil {
+		return result, err
+	}
+	result.StatusHistory = statusHistory
+
+	admin, err := isAdmin(ctx)
+	if err != nil {
+		return result, err
+	}
+	if admin {
+		adminInfo, err := database.GetRepository().GetAdminInfoByAttendeeId(ctx, attendee.ID)
+		if err != nil {
+			return result, err
+		}
+		result.AdminFlags = adminInfo.Flags
+	}
+
+	additionalInfo, err := database.GetRepository().GetAllAdditionalInfoFor(ctx, attendee.ID)
+	if err != nil {
+		return result, err
+	}
+	for _, ad := range additionalInfo {
+		visible, err := canSeeAdditionalInfoArea(ctx, ad.Area)
+		if err != nil {
+			return result, err
+		}
+		if visible {
+			result.AdditionalInfo = append(result.AdditionalInfo, ad)
+		}
+	}
+
+	transactions, err := paymentservice.Get().GetTransactions(ctx, attendee.ID)
+	if err != nil && !errors.Is(err, paymentservice.NoSuchDebitor404Error) {
+		return result, err
+	}
+	result.Transactions = append(result.Transactions, transactions...)
+
+	return result, nil
+}
+
+// canSeeAdditionalInfoArea checks whether the currently logged in user may see additional info for the given area.
+//
+// Admins and api token users may see all areas, anyone else needs the permission named after the area.
+func canSeeAdditionalInfoArea(ctx context.Context, area string) (bool, error) {
+	return authorizationService.HasPermission(ctx, area)
+}
+
+const (
+	anonymisedPlaceholder = "anonymised"
+	anonymisedEmail       = "anonymised@anonymised.invalid"
+	anonymisedBirthday    = "1900-01-01"
+)
+
+// personal data fields that are overwritten during anonymisation, and scrubbed from the history diffs
+var anonymisedFields = map[string][]string{
+	"Attendee":       {"FirstName", "LastName", "Street", "Zip", "City", "State", "Email", "Phone", "Telegram", "Partner", "Birthday", "UserComments", "Identity"},
+	"AdminInfo":      {"AdminComments"},
+	"AdditionalInfo": {"JsonValue"},
+}
+
+var historyDiffLineRegex = regexp.MustCompile(`^(\w+): \.(\w+) = .*$`)
+
+func (s *AttendeeServiceImplData) AnonymiseAttendee(ctx context.Context, attendee *entity.Attendee) error {
+	// controller checks permissions
+
+	eligible, since, err := anonymisationEligibility(ctx, attendee)
+	if err != nil {
+		return err
+	}
+	if !eligible || since.After(time.Now()) {
+		return NotEligibleForAnonymisationError
+	}
+
+	return anonymise(ctx, attendee)
+}
+
+func (s *AttendeeServiceImplData) ApplyRetentionPolicy(ctx context.Context) (int, error) {
+	retention := config.AnonymiseAfter()
+	if retention <= 0 {
+		return 0, nil
+	}
+
+	// same rules as anonymisationEligibility, but evaluated in the database
+	cutoff := time.Now().Add(-retention)
+	conventionOver := false
+	if conEnd, ok := config.ConventionEndDate(); ok {
+		conventionOver = !conEnd.AddDate(0, 0, 1).After(cutoff)
+	}
+	candidates, err := database.GetRepository().FindAnonymisationCandidates(ctx, cutoff, conventionOver)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, id := range candidates {
+		attendee, err := database.GetRepository().GetAttendeeById(ctx, id)
+		if err != nil {
+			return count, err
+		}
+
+		if err := anonymise(ctx, attendee); err != nil {
+			return count, err
+		}
+		aulogging.Logger.Ctx(ctx).Info().Printf("retention policy anonymised attendee %d", attendee.ID)
+		count++
+	}
+	return count, nil
+}
+
+// anonymisationEligibility determines whether the attendee may be anonymised at all, and from which time.
+//
+// Deleted attendees are eligible from the time of their deletion, all others once the convention is over.
+func anonymisationEligibility(ctx context.Context, attendee *entity.Attendee) (bool, time.Time, error) {
+	latest, err := database.GetRepository().GetLatestStatusChangeByAttendeeId(ctx, attendee.ID)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	if latest.Status == "deleted" {
+		return true, latest.CreatedAt, nil
+	}
+
+	if conEnd, ok := config.ConventionEndDate(); ok {
+		// the end date is the last day of the convention
+		return true, conEnd.AddDate(0, 0, 1), nil
+	}
+	return false, time.Time{}, nil
+}
+
+// anonymise overwrites all personal data of an attendee and removes it from the history.
+//
+// The badge number, status history, admin flags and dues are kept, because we need them for legal retention
+// of the financial records.
+func anonymise(ctx context.Context, attendee *entity.Attendee) error {
+	attendee.FirstName = anonymisedPlaceholder
+	attendee.LastName = anonymisedPlaceholder
+	attendee.Street = anonymisedPlaceholder
+	attendee.Zip = anonymisedPlaceholder
+	attendee.City = anonymisedPlaceholder
+	attendee.State = ""
+	attendee.Email = anonymisedEmail
+	attendee.Phone = anonymisedPlaceholder
+	attendee.Telegram = ""
+	attendee.Partner = ""
+	attendee.Birthday = anonymisedBirthday
+	attendee.UserComments = ""
+	attendee.Identity = ""
+	attendee.Anonymised = true
+	if err := database.GetRepository().UpdateAttendee(ctx, attendee); err != nil {
+		return err
+	}
+	if err := scrubHistoryFor(ctx, "Attendee", attendee.ID); err != nil {
+		return err
+	}
+
+	adminInfo, err := database.GetRepository().GetAdminInfoByAttendeeId(ctx, attendee.ID)
+	if err != nil {
+		return err
+	}
+	if adminInfo.AdminComments != "" {
+		adminInfo.AdminComments = ""
+		if err := database.GetRepository().WriteAdminInfo(ctx, adminInfo); err != nil {
+			return err
+		}
+	}
+	if err := scrubHistoryFor(ctx, "AdminInfo", attendee.ID); err != nil {
+		return err
+	}
+
+	additionalInfo, err := database.GetRepository().GetAllAdditionalInfoFor(ctx, attendee.ID)
+	if err != nil {
+		return err
+	}
+	for _, ad := range additionalInfo {
+		if ad.JsonValue != "{}" {
+			ad.JsonValue = "{}"
+			if err := database.GetRepository().WriteAdditionalInfo(ctx, ad); err != nil {
+				return err
+			}
+		}
+		if err := scrubHistoryFor(ctx, "AdditionalInfo", ad.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func scrubHistoryFor(ctx context.Context, entityName string, entityId uint) error {
+	entries, err := database.GetRepository().GetHistoryByEntity(ctx, entityName, entityId)
+	if err != nil {
+		return err
+	}
+	for _, h := range entries {
+		scrubbed := scrubDiff(h.Diff, anonymisedFields[entityName])
+		if scrubbed != h.Diff {
+			h.Diff = scrubbed
+			if err := database.GetRepository().ScrubHistory(ctx, h); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// scrubDiff replaces the values of the given fields in a diff as produced by the historizing repository.
+func scrubDiff(diff string, fields []string) string {
+	lines := strings.Split(diff, "\n")
+	for i, line := range lines {
+		matches := historyDiffLineRegex.FindStringSubmatch(line)
+		if matches != nil && containsString(fields, matches[2]) {
+			lines[i] = fmt.Sprintf("%s: .%s = %q", matches[1], matches[2], anonymisedPlaceholder)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,460 @@
+package attendeesrv
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	GroupTypeRoom    = "room"    // roommates, all members must have the same room package
+	GroupTypeDealers = "dealers" // sharing a dealers' den table
+	GroupTypeFursuit = "fursuit" // fursuit groups
+	GroupTypeOther   = "other"
+)
+
+var GroupTypes = []string{GroupTypeRoom, GroupTypeDealers, GroupTypeFursuit, GroupTypeOther}
+
+// registrations in these statuses cannot create or join groups, and are reported as a problem if they are members
+var inactiveStatuses = []string{"cancelled", "deleted"}
+
+// GroupInfo is a group together with its members, and anything that is inconsistent about it.
+type GroupInfo struct {
+	Group    *entity.Group
+	Members  []*GroupMemberInfo
+	Problems []string
+}
+
+type GroupMemberInfo struct {
+	Attendee    *entity.Attendee
+	Status      string
+	RoomPackage string // the first of the configured room packages the attendee has, or empty
+}
+
+func (s *AttendeeServiceImplData) CreateGroup(ctx context.Context, owner *entity.Attendee, name string, groupType string) (*entity.Group, error) {
+	// controller checks permissions and validates the values
+
+	if err := checkCanJoinGroup(ctx, owner, groupType); err != nil {
+		return nil, err
+	}
+
+	group := &entity.Group{
+		Name:    name,
+		Type:    groupType,
+		OwnerId: owner.ID,
+	}
+	id, err := database.GetRepository().AddGroup(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	group.ID = id
+	if _, err := database.GetRepository().AddGroupMember(ctx, &entity.GroupMember{GroupId: id, AttendeeId: owner.ID}); err != nil {
+		return nil, err
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("%s group %d created for attendee %d by %s", groupType, id, owner.ID, ctxvalues.UserId(ctx))
+	return group, nil
+}
+
+func (s *AttendeeServiceImplData) GetGroup(ctx context.Context, id uint) (*GroupInfo, error) {
+	group, err := database.GetRepository().GetGroupById(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, GroupNotFoundError
+		}
+		return nil, err
+	}
+	return groupInfo(ctx, group)
+}
+
+func (s *AttendeeServiceImplData) GetAllGroups(ctx context.Context) ([]*GroupInfo, error) {
+	groups, err := database.GetRepository().GetAllGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*GroupInfo, 0, len(groups))
+	for _, g := range groups {
+		info, err := groupInfo(ctx, g)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+func (s *AttendeeServiceImplData) GetGroupsForAttendees(ctx context.Context, attendees []*entity.Attendee) ([]*GroupInfo, error) {
+	seen := make(map[uint]bool)
+	result := make([]*GroupInfo, 0)
+	for _, a := range attendees {
+		memberships, err := database.GetRepository().GetGroupMembersByAttendeeId(ctx, a.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range memberships {
+			if seen[m.GroupId] {
+				continue
+			}
+			seen[m.GroupId] = true
+			info, err := s.GetGroup(ctx, m.GroupId)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, info)
+		}
+	}
+	return result, nil
+}
+
+func (s *AttendeeServiceImplData) UpdateGroup(ctx context.Context, group *entity.Group) error {
+	// controller checks permissions and validates the values
+
+	member, err := findMembership(ctx, group.ID, group.OwnerId)
+	if err != nil {
+		return err
+	}
+	if member == nil {
+		return fmt.Errorf("%w: attendee %d", GroupOwnerNotMemberError, group.OwnerId)
+	}
+	return database.GetRepository().UpdateGroup(ctx, group)
+}
+
+func (s *AttendeeServiceImplData) DeleteGroup(ctx context.Context, group *entity.Group) error {
+	// controller checks permissions
+
+	members, err := database.GetRepository().GetGroupMembersByGroupId(ctx, group.ID)
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		if err := database.GetRepository().DeleteGroupMember(ctx, m.ID); err != nil {
+			return err
+		}
+	}
+	if err := database.GetRepository().DeleteGroup(ctx, group.ID); err != nil {
+		return err
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("group %d deleted by %s", group.ID, ctxvalues.UserId(ctx))
+	return nil
+}
+
+func (s *AttendeeServiceImplData) CreateGroupInviteCode(ctx context.Context, group *entity.Group) (string, error) {
+	// controller checks permissions
+
+	code, err := newSecretCode(group.ID)
+	if err != nil {
+		return "", err
+	}
+
+	group.InviteCodeHash = hashSecretCode(code)
+	if err := database.GetRepository().UpdateGroup(ctx, group); err != nil {
+		return "", err
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("invite code for group %d created by %s", group.ID, ctxvalues.UserId(ctx))
+	return code, nil
+}
+
+func (s *AttendeeServiceImplData) JoinGroup(ctx context.Context, code string, attendee *entity.Attendee) (*entity.Group, error) {
+	// controller checks that the attendee belongs to the logged in user
+
+	group, err := findInviteCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if err := addGroupMember(ctx, group, attendee); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (s *AttendeeServiceImplData) AddGroupMember(ctx context.Context, group *entity.Group, attendee *entity.Attendee) error {
+	// controller checks permissions
+
+	return addGroupMember(ctx, group, attendee)
+}
+
+func (s *AttendeeServiceImplData) RemoveGroupMember(ctx context.Context, group *entity.Group, attendee *entity.Attendee) error {
+	// controller checks permissions
+
+	if group.OwnerId == attendee.ID {
+		return GroupOwnerCannotLeaveError
+	}
+	member, err := findMembership(ctx, group.ID, attendee.ID)
+	if err != nil {
+		return err
+	}
+	if member == nil {
+		return fmt.Errorf("%w: attendee %d", GroupMemberNotFoundError, attendee.ID)
+	}
+	if err := database.GetRepository().DeleteGroupMember(ctx, member.ID); err != nil {
+		return err
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("attendee %d removed from group %d by %s", attendee.ID, group.ID, ctxvalues.UserId(ctx))
+	return nil
+}
+
+func (s *AttendeeServiceImplData) UnmatchedPartnerRequests(ctx context.Context, pageTimeout time.Duration) ([]*AttendeeListEntry, error) {
+	// controller checks permissions
+
+	roomMemberships, err := database.GetRepository().GetGroupMembersByGroupType(ctx, GroupTypeRoom)
+	if err != nil {
+		return nil, err
+	}
+	inRoomGroup := make(map[uint]bool)
+	for _, m := range roomMemberships {
+		inRoomGroup[m.AttendeeId] = true
+	}
+
+	result := make([]*AttendeeListEntry, 0)
+	err = s.ExportAttendees(ctx, &attendee.AttendeeSearchCriteria{}, false, pageTimeout, func(entries []*AttendeeListEntry) error {
+		for _, e := range entries {
+			if strings.TrimSpace(e.Attendee.Partner) == "" || containsString(inactiveStatuses, e.Status) {
+				continue
+			}
+			if !inRoomGroup[e.Attendee.ID] {
+				result = append(result, e)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// --- helpers ---
+
+// leaveAllGroups removes the attendee from all groups.
+//
+// Ownership of a group passes to the member who joined first, and the pending invite code stops working.
+// A group without other members is deleted.
+func leaveAllGroups(ctx context.Context, attendee *entity.Attendee) error {
+	memberships, err := database.GetRepository().GetGroupMembersByAttendeeId(ctx, attendee.ID)
+	if err != nil {
+		return err
+	}
+	for _, m := range memberships {
+		group, err := database.GetRepository().GetGroupById(ctx, m.GroupId)
+		if err != nil {
+			return err
+		}
+		if group.OwnerId == attendee.ID {
+			if err := handOverGroup(ctx, group, attendee.ID); err != nil {
+				return err
+			}
+		}
+		if err := database.GetRepository().DeleteGroupMember(ctx, m.ID); err != nil {
+			return err
+		}
+		aulogging.Logger.Ctx(ctx).Info().Printf("attendee %d removed from group %d by %s", attendee.ID, group.ID, ctxvalues.UserId(ctx))
+	}
+	return nil
+}
+
+func handOverGroup(ctx context.Context, group *entity.Group, previousOwnerId uint) error {
+	members, err := database.GetRepository().GetGroupMembersByGroupId(ctx, group.ID)
+	if err != nil {
+		return err
+	}
+	var newOwner *entity.GroupMember
+	for _, m := range members {
+		if m.AttendeeId != previousOwnerId && (newOwner == nil || m.ID < newOwner.ID) {
+			newOwner = m
+		}
+	}
+	if newOwner == nil {
+		if err := database.GetRepository().DeleteGroup(ctx, group.ID); err != nil {
+			return err
+		}
+		aulogging.Logger.Ctx(ctx).Info().Printf("group %d deleted because its only member %d left", group.ID, previousOwnerId)
+		return nil
+	}
+
+	group.OwnerId = newOwner.AttendeeId
+	group.InviteCodeHash = ""
+	if err := database.GetRepository().UpdateGroup(ctx, group); err != nil {
+		return err
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("ownership of group %d passed from attendee %d to %d", group.ID, previousOwnerId, newOwner.AttendeeId)
+	return nil
+}
+
+func addGroupMember(ctx context.Context, group *entity.Group, attendee *entity.Attendee) error {
+	if err := checkCanJoinGroup(ctx, attendee, group.Type); err != nil {
+		return err
+	}
+
+	members, err := database.GetRepository().GetGroupMembersByGroupId(ctx, group.ID)
+	if err != nil {
+		return err
+	}
+	if len(members) >= config.GroupMaxMembers() {
+		return fmt.Errorf("%w: at most %d members", GroupFullError, config.GroupMaxMembers())
+	}
+
+	if group.Type == GroupTypeRoom {
+		owner, err := database.GetRepository().GetAttendeeById(ctx, group.OwnerId)
+		if err != nil {
+			return err
+		}
+		if roomPackage(owner) != roomPackage(attendee) {
+			return fmt.Errorf("%w: the owner has %s, attendee %d has %s", GroupRoomPackageMismatchError,
+				roomPackageOrNone(roomPackage(owner)), attendee.ID, roomPackageOrNone(roomPackage(attendee)))
+		}
+	}
+
+	if _, err := database.GetRepository().AddGroupMember(ctx, &entity.GroupMember{GroupId: group.ID, AttendeeId: attendee.ID}); err != nil {
+		return err
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("attendee %d added to group %d by %s", attendee.ID, group.ID, ctxvalues.UserId(ctx))
+	return nil
+}
+
+// checkCanJoinGroup ensures a registration is active, and in at most one group of each type.
+func checkCanJoinGroup(ctx context.Context, attendee *entity.Attendee, groupType string) error {
+	latest, err := database.GetRepository().GetLatestStatusChangeByAttendeeId(ctx, attendee.ID)
+	if err != nil {
+		return err
+	}
+	if containsString(inactiveStatuses, latest.Status) {
+		return fmt.Errorf("%w: attendee %d is in status %s", GroupMemberInactiveError, attendee.ID, latest.Status)
+	}
+
+	inGroup, err := isInGroupOfType(ctx, attendee.ID, groupType)
+	if err != nil {
+		return err
+	}
+	if inGroup {
+		return fmt.Errorf("%w: attendee %d is already in a %s group", GroupMembershipExistsError, attendee.ID, groupType)
+	}
+	return nil
+}
+
+func isInGroupOfType(ctx context.Context, attendeeId uint, groupType string) (bool, error) {
+	memberships, err := database.GetRepository().GetGroupMembersByAttendeeId(ctx, attendeeId)
+	if err != nil {
+		return false, err
+	}
+	for _, m := range memberships {
+		group, err := database.GetRepository().GetGroupById(ctx, m.GroupId)
+		if err != nil {
+			return false, err
+		}
+		if group.Type == groupType {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// findMembership returns nil if the attendee is not a member of the group.
+func findMembership(ctx context.Context, groupId uint, attendeeId uint) (*entity.GroupMember, error) {
+	members, err := database.GetRepository().GetGroupMembersByGroupId(ctx, groupId)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		if m.AttendeeId == attendeeId {
+			return m, nil
+		}
+	}
+	return nil, nil
+}
+
+// groupInfo reads the members of a group and determines its consistency problems.
+//
+// Members can change their packages or status after they joined, so this is checked every time.
+func groupInfo(ctx context.Context, group *entity.Group) (*GroupInfo, error) {
+	members, err := database.GetRepository().GetGroupMembersByGroupId(ctx, group.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &GroupInfo{
+		Group:    group,
+		Members:  make([]*GroupMemberInfo, 0, len(members)),
+		Problems: make([]string, 0),
+	}
+	var ownerRoomPackage string
+	for _, m := range members {
+		a, err := database.GetRepository().GetAttendeeById(ctx, m.AttendeeId)
+		if err != nil {
+			return nil, err
+		}
+		latest, err := database.GetRepository().GetLatestStatusChangeByAttendeeId(ctx, m.AttendeeId)
+		if err != nil {
+			return nil, err
+		}
+		member := &GroupMemberInfo{
+			Attendee:    a,
+			Status:      latest.Status,
+			RoomPackage: roomPackage(a),
+		}
+		if a.ID == group.OwnerId {
+			ownerRoomPackage = member.RoomPackage
+		}
+		if containsString(inactiveStatuses, member.Status) {
+			info.Problems = append(info.Problems, fmt.Sprintf("attendee %d is in status %s", a.ID, member.Status))
+		}
+		info.Members = append(info.Members, member)
+	}
+
+	if group.Type == GroupTypeRoom {
+		for _, m := range info.Members {
+			if m.RoomPackage != ownerRoomPackage {
+				info.Problems = append(info.Problems, fmt.Sprintf("attendee %d has %s, but the owner has %s",
+					m.Attendee.ID, roomPackageOrNone(m.RoomPackage), roomPackageOrNone(ownerRoomPackage)))
+			}
+		}
+	}
+	return info, nil
+}
+
+func roomPackage(a *entity.Attendee) string {
+	packages := choiceStrToMap(a.Packages)
+	for _, p := range config.GroupRoomPackages() {
+		if packages[p] {
+			return p
+		}
+	}
+	return ""
+}
+
+func roomPackageOrNone(p string) string {
+	if p == "" {
+		return "no room package"
+	}
+	return p
+}
+
+// findInviteCode returns GroupInviteCodeInvalidError for anything that is wrong with the code, so codes cannot be probed.
+func findInviteCode(ctx context.Context, code string) (*entity.Group, error) {
+	idStr, _, found := strings.Cut(code, "-")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if !found || err != nil || id == 0 {
+		return nil, GroupInviteCodeInvalidError
+	}
+	group, err := database.GetRepository().GetGroupById(ctx, uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, GroupInviteCodeInvalidError
+		}
+		return nil, err
+	}
+	if group.InviteCodeHash == "" ||
+		subtle.ConstantTimeCompare([]byte(group.InviteCodeHash), []byte(hashSecretCode(code))) != 1 {
+		return nil, GroupInviteCodeInvalidError
+	}
+	return group, nil
+}
@@ -1,6 +1,12 @@
 package attendeesrv
 
+import (
+	"github.com/eurofurence/reg-attendee-service/internal/service/authsrv"
+)
+
 type AttendeeServiceImplData struct {
 }
 
 var _ AttendeeService = (*AttendeeServiceImplData)(nil)
+
+var authorizationService authsrv.AuthorizationService = &authsrv.AuthorizationServiceImplData{}
@@ -0,0 +1,54 @@
+package attendeesrv
+
+import (
+	"context"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+)
+
+func (s *AttendeeServiceImplData) ImportAttendee(ctx context.Context, attendee *entity.Attendee, adminFlags string, initialStatus string, dryRun bool) (uint, error) {
+	// controller checks permissions and validates the attendee, admin flags and status
+
+	alreadyExists, err := isDuplicateAttendee(ctx, attendee.Nickname, attendee.Zip, attendee.Email, 0)
+	if err != nil {
+		return 0, err
+	}
+	if alreadyExists {
+		return 0, DuplicateAttendeeError
+	}
+	if dryRun {
+		return 0, nil
+	}
+
+	id, err := database.GetRepository().AddAttendee(ctx, attendee)
+	if err != nil {
+		return 0, err
+	}
+
+	if adminFlags != "" {
+		adminInfo, err := database.GetRepository().GetAdminInfoByAttendeeId(ctx, id)
+		if err != nil {
+			return id, err
+		}
+		adminInfo.Flags = adminFlags
+		if err := database.GetRepository().WriteAdminInfo(ctx, adminInfo); err != nil {
+			return id, err
+		}
+	}
+
+	if initialStatus != "" && initialStatus != "new" {
+		if err := s.StatusChangePossible(ctx, attendee, "new", initialStatus); err != nil {
+			return id, err
+		}
+		subject := ctxvalues.Subject(ctx)
+		if err := s.updateDuesAndDoStatusChangeIfNeeded(ctx, attendee, "new", initialStatus, fmt.Sprintf("bulk import by %s", subject), true); err != nil {
+			return id, err
+		}
+	}
+
+	aulogging.Logger.Ctx(ctx).Info().Printf("imported attendee %d with status %s", id, initialStatus)
+	return id, nil
+}
@@ -3,8 +3,10 @@ package attendeesrv
 import (
 	"context"
 	"errors"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"time"
 )
 
 type AttendeeService interface {
@@ -16,6 +18,10 @@ type AttendeeService interface {
 	// RegisterNewAttendee saves a previously unsaved attendee, assigning them a badge number.
 	RegisterNewAttendee(ctx context.Context, attendee *entity.Attendee) (uint, error)
 	GetAttendee(ctx context.Context, id uint) (*entity.Attendee, error)
+	// UpdateAttendee saves changes to an existing attendee, and adjusts the dues.
+	//
+	// Once a payment has been made, non-admins can only remove packages as allowed by their removal policy,
+	// otherwise an error wrapping PackageRemovalNotAllowedError is returned.
 	UpdateAttendee(ctx context.Context, attendee *entity.Attendee) error
 
 	// GetAttendeeMaxId returns the highest assigned badge number.
@@ -25,6 +31,16 @@ type AttendeeService interface {
 
 	CanChangeChoiceTo(ctx context.Context, originalChoiceStr string, newChoiceStr string, configuration map[string]config.ChoiceConfig) error
 
+	// IneligibleChoices lists the choices the attendee is not eligible for, with the reason why.
+	//
+	// Eligibility depends on the age on the first day of the convention, the country, and the current status.
+	// Choices that are already picked remain eligible regardless of the current status.
+	IneligibleChoices(ctx context.Context, attendee *entity.Attendee, originalChoiceStr string, configuration map[string]config.ChoiceConfig) (map[string]string, error)
+	// CheckChoiceEligibility fails if any of the newly chosen choices is in IneligibleChoices.
+	//
+	// Admins and api token callers are exempt.
+	CheckChoiceEligibility(ctx context.Context, attendee *entity.Attendee, originalChoiceStr string, newChoiceStr string, configuration map[string]config.ChoiceConfig) error
+
 	GetAdminInfo(ctx context.Context, attendeeId uint) (*entity.AdminInfo, error)
 	UpdateAdminInfo(ctx context.Context, attendee *entity.Attendee, adminInfo *entity.AdminInfo) error
 
@@ -44,6 +60,148 @@ type AttendeeService interface {
 	// Unless an admin has made changes to the database, this essentially means their registration was made
 	// using this account.
 	IsOwnerFor(ctx context.Context) ([]*entity.Attendee, error)
+
+	// GetPersonalDataExport collects all data we hold on an attendee, including their payment transactions
+	// from the payment service, suitable for answering a subject access request.
+	//
+	// Additional info is only included for areas the currently logged in user is allowed to see.
+	GetPersonalDataExport(ctx context.Context, attendee *entity.Attendee) (*PersonalDataExport, error)
+
+	// AnonymiseAttendee overwrites the personal data of an attendee with placeholders, and scrubs it from
+	// the history and additional info.
+	//
+	// Only possible for deleted attendees, or once the convention is over. The badge number and
+	// everything needed for the financial records is kept.
+	AnonymiseAttendee(ctx context.Context, attendee *entity.Attendee) error
+	// ApplyRetentionPolicy anonymises all attendees whose configured retention period has expired.
+	//
+	// Returns the number of attendees that were anonymised.
+	ApplyRetentionPolicy(ctx context.Context) (int, error)
+
+	// ExportAttendees finds all attendees matching the criteria, and passes them to consume in pages
+	// ordered by badge number, so the caller can stream the results.
+	//
+	// Each page is read with its own timeout, so exports of many attendees are possible. Dues and payments
+	// are only obtained from the payment service if withPayments is set, because this is expensive.
+	ExportAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria, withPayments bool, pageTimeout time.Duration, consume func(entries []*AttendeeListEntry) error) error
+
+	// ImportAttendee saves a previously unsaved attendee from a bulk import, assigning them a badge number.
+	//
+	// Unlike RegisterNewAttendee, the registration is not owned by the logged in user. Optionally sets admin flags
+	// and changes the status to initialStatus. In a dry run, only the duplicate check is performed.
+	ImportAttendee(ctx context.Context, attendee *entity.Attendee, adminFlags string, initialStatus string, dryRun bool) (uint, error)
+
+	// CreateAttendeeFor lets an admin create a registration on behalf of someone else, e.g. a guest of honour.
+	//
+	// The registration is not owned by anyone. An invitation code is mailed to its email address, with which
+	// the guest can take it over using RedeemTransferCode. Returns the badge number, the code and its expiry time.
+	// Optionally sets admin flags, stores a comp, and changes the status to initialStatus.
+	CreateAttendeeFor(ctx context.Context, attendee *entity.Attendee, adminFlags string, initialStatus string, comp *Comp) (uint, string, time.Time, error)
+
+	// GetStatistics aggregates registration numbers by status, country, package, flag, t-shirt size and day.
+	GetStatistics(ctx context.Context) (*Statistics, error)
+
+	// GetPublicStatistics returns the subset of the statistics that is safe to publish.
+	//
+	// Only totals, status and country counts are included, and countries with few attendees are combined.
+	GetPublicStatistics(ctx context.Context) (*Statistics, error)
+
+	// RegdeskLookup finds attendees by badge number, or if that is 0, by part of their nickname.
+	//
+	// The nickname lookup returns at most 20 attendees.
+	RegdeskLookup(ctx context.Context, badgeNumber uint, nickname string) ([]*RegdeskInfo, error)
+	// CheckIn changes the status of a fully paid attendee to checked in, and records the desk, the operator
+	// and the items handed out in the regdesk additional info area.
+	CheckIn(ctx context.Context, attendee *entity.Attendee, desk string, items []string, comment string) error
+	// UndoCheckIn reverts a check-in, which is only possible within the configured undo window.
+	//
+	// No dues are changed and no email is sent.
+	UndoCheckIn(ctx context.Context, attendee *entity.Attendee) error
+
+	// ExportBadges passes the badge data of all attendees in status paid or checked in that match the filter
+	// (one of the BadgeFilter* constants) to consume, one page at a time, in the order of their badge numbers.
+	//
+	// Whether a badge has changed since its last print is determined from the history.
+	ExportBadges(ctx context.Context, filter string, pageTimeout time.Duration, consume func(entries []*BadgeInfo) error) error
+	// MarkBadgesPrinted records a print for each of the attendees, which must all be in status paid or checked in.
+	//
+	// Reprints need a reason. Either all prints are recorded, or none.
+	MarkBadgesPrinted(ctx context.Context, attendees []*entity.Attendee, reason string) error
+
+	// CreateTransferCode creates a code with which another logged in user can take over the registration,
+	// replacing any previous code. Only the owner or an admin should do this.
+	//
+	// The code expires after 72 hours. If resetPersonalData is set, the personal data is cleared on transfer,
+	// which is what you want for a ticket resale.
+	CreateTransferCode(ctx context.Context, attendee *entity.Attendee, resetPersonalData bool) (string, time.Time, error)
+	// CancelTransferCode removes the pending transfer code, returns NoTransferCodeError if there is none.
+	CancelTransferCode(ctx context.Context, attendee *entity.Attendee) error
+	// RedeemTransferCode transfers the registration the code was created for to the logged in user.
+	//
+	// If email is empty, the email address of the logged in user is used when personal data is reset,
+	// otherwise the email address of the registration is kept.
+	RedeemTransferCode(ctx context.Context, code string, email string) (*entity.Attendee, error)
+	// TransferAttendee changes the owner of a registration without a transfer code, for admins.
+	//
+	// Both the old and the new owner are informed by email. The email address is only changed if newEmail is set.
+	TransferAttendee(ctx context.Context, attendee *entity.Attendee, newIdentity string, newEmail string, resetPersonalData bool) error
+
+	// SendEmailVerification mails a new verification token to the attendee.
+	//
+	// This happens automatically on registration and whenever the email address changes, so this is only
+	// needed if the email was lost or the token has expired.
+	SendEmailVerification(ctx context.Context, attendee *entity.Attendee) error
+	// VerifyEmail marks the email address of the attendee the token was issued for as verified.
+	//
+	// Tokens stop working once they expire, or if the email address has been changed since they were issued.
+	VerifyEmail(ctx context.Context, token string) (*entity.Attendee, error)
+
+	// CreateGroup creates a group of one of the GroupTypes, with the owner as its first member.
+	//
+	// A registration can be in at most one group of each type.
+	CreateGroup(ctx context.Context, owner *entity.Attendee, name string, groupType string) (*entity.Group, error)
+	// GetGroup returns the group with its members and consistency problems, or GroupNotFoundError.
+	GetGroup(ctx context.Context, id uint) (*GroupInfo, error)
+	// GetAllGroups returns all groups with their members and consistency problems, for admins.
+	GetAllGroups(ctx context.Context) ([]*GroupInfo, error)
+	// GetGroupsForAttendees returns the groups any of the attendees is a member of.
+	GetGroupsForAttendees(ctx context.Context, attendees []*entity.Attendee) ([]*GroupInfo, error)
+	// UpdateGroup saves a changed name or owner. The new owner must already be a member.
+	UpdateGroup(ctx context.Context, group *entity.Group) error
+	// DeleteGroup removes the group and all its memberships.
+	DeleteGroup(ctx context.Context, group *entity.Group) error
+	// CreateGroupInviteCode creates a code with which other registrations can join the group,
+	// replacing any previous code.
+	CreateGroupInviteCode(ctx context.Context, group *entity.Group) (string, error)
+	// JoinGroup adds the attendee to the group the invite code was created for.
+	//
+	// Members of room groups must have the same room package as the owner.
+	JoinGroup(ctx context.Context, code string, attendee *entity.Attendee) (*entity.Group, error)
+	// AddGroupMember adds the attendee to the group without an invite code, for admins.
+	//
+	// The same checks as for JoinGroup apply.
+	AddGroupMember(ctx context.Context, group *entity.Group, attendee *entity.Attendee) error
+	// RemoveGroupMember removes an attendee other than the owner from the group.
+	RemoveGroupMember(ctx context.Context, group *entity.Group, attendee *entity.Attendee) error
+	// UnmatchedPartnerRequests lists the active attendees who have filled in the partner field,
+	// but are not in a room group.
+	UnmatchedPartnerRequests(ctx context.Context, pageTimeout time.Duration) ([]*AttendeeListEntry, error)
+
+	// GetAllVouchers returns all vouchers with the number of times each has been redeemed, for admins.
+	GetAllVouchers(ctx context.Context) ([]*VoucherInfo, error)
+	// GetVoucher returns the voucher with the number of times it has been redeemed, or VoucherNotFoundError.
+	GetVoucher(ctx context.Context, id uint) (*VoucherInfo, error)
+	// CreateVoucher adds a voucher. Voucher codes must be unique, otherwise VoucherCodeExistsError is returned.
+	CreateVoucher(ctx context.Context, voucher *entity.Voucher) (uint, error)
+	// UpdateVoucher saves changes to a voucher. The code cannot be changed.
+	//
+	// Dues of attendees who have already redeemed the voucher are only adjusted on their next update.
+	UpdateVoucher(ctx context.Context, voucher *entity.Voucher) error
+	// CanRedeemVoucher checks that an attendee may switch from the original voucher code to the new one.
+	//
+	// Keeping or removing a voucher is always possible, a new code must exist, must not have expired or been used up,
+	// and must apply to at least one of the selected packages.
+	CanRedeemVoucher(ctx context.Context, originalCode string, newCode string, packages string) error
 }
 
 var (
@@ -53,4 +211,45 @@ var (
 	CannotDeleteError        = errors.New("cannot delete attendee for legal reasons (there were payments or invoices)")
 	GoToApprovedFirst        = errors.New("please change status to approved, this will automatically advance to (partially) paid as appropriate")
 	UnknownStatusError       = errors.New("unknown status value - this is a programming error")
+
+	DuplicateAttendeeError           = errors.New("duplicate attendee data - you are already registered")
+	PackageRemovalNotAllowedError    = errors.New("packages cannot be removed after the first payment, please contact the registration team")
+	NotEligibleForAnonymisationError = errors.New("attendee can only be anonymised after deletion or once the convention is over")
+
+	AlreadyCheckedInError   = errors.New("attendee is already checked in")
+	NotPaidError            = errors.New("only attendees in status paid can be checked in")
+	NotCheckedInError       = errors.New("attendee is not checked in")
+	UndoWindowExpiredError  = errors.New("the check in can no longer be undone at the regdesk, please contact an admin")
+	CheckInNotRecordedError = errors.New("the check in could not be recorded, please try again")
+
+	BadgeNotPrintableError    = errors.New("only attendees in status paid or checked in get a badge")
+	ReprintReasonMissingError = errors.New("badge was already printed, a reprint needs a reason")
+
+	TransferNotAllowedError   = errors.New("registrations can only be transferred in status new, approved, partially paid or paid")
+	TransferCodeInvalidError  = errors.New("transfer code is invalid or has expired")
+	NoTransferCodeError       = errors.New("there is no pending transfer code")
+	TransferToSameOwnerError  = errors.New("the registration already belongs to this account")
+	TransferEmailMissingError = errors.New("the personal data is reset on this transfer, so an email address is required")
+
+	EmailVerificationDisabledError     = errors.New("email verification is not enabled")
+	EmailAlreadyVerifiedError          = errors.New("the email address is already verified")
+	EmailVerificationTokenInvalidError = errors.New("email verification token is invalid or has expired")
+	EmailNotVerifiedError              = errors.New("the email address must be verified before the registration can be approved")
+
+	GroupNotFoundError            = errors.New("group not found")
+	GroupInviteCodeInvalidError   = errors.New("invite code is invalid")
+	GroupMemberInactiveError      = errors.New("cancelled or deleted registrations cannot be in a group")
+	GroupMembershipExistsError    = errors.New("a registration can only be in one group of each type")
+	GroupFullError                = errors.New("the group is full")
+	GroupRoomPackageMismatchError = errors.New("all members of a room group must have the same room package")
+	GroupOwnerCannotLeaveError    = errors.New("the owner cannot leave the group, please delete it or choose another owner")
+	GroupOwnerNotMemberError      = errors.New("the owner must be a member of the group")
+	GroupMemberNotFoundError      = errors.New("attendee is not a member of the group")
+
+	VoucherNotFoundError         = errors.New("voucher not found")
+	VoucherCodeExistsError       = errors.New("there is already a voucher with this code")
+	VoucherInvalidError          = errors.New("voucher code is invalid or has expired")
+	VoucherUsedUpError           = errors.New("voucher code has already been used the maximum number of times")
+	VoucherNotApplicableError    = errors.New("voucher code does not apply to any of the selected packages")
+	VoucherChangeNotAllowedError = errors.New("the voucher cannot be changed after the first payment, please contact the registration team")
 )
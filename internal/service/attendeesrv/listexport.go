@@ -0,0 +1,117 @@
+package attendeesrv
+
+import (
+	"context"
+	"errors"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+	"time"
+)
+
+// AttendeeListEntry is an attendee together with the derived values that can be included in an attendee list.
+type AttendeeListEntry struct {
+	Attendee       *entity.Attendee
+	Status         string
+	TotalDues      int64
+	PaymentBalance int64
+}
+
+// the number of badge numbers covered by each page of an export.
+//
+// With payments, every attendee on a page needs a call to the payment service, which must all fit into
+// the page timeout, so those pages are much smaller.
+const (
+	exportPageSize        = 200
+	exportPaymentPageSize = 20
+)
+
+func (s *AttendeeServiceImplData) ExportAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria, withPayments bool, pageTimeout time.Duration, consume func(entries []*AttendeeListEntry) error) error {
+	// controller checks permissions
+
+	maxId, err := database.GetRepository().MaxAttendeeId(ctx)
+	if err != nil {
+		return err
+	}
+
+	first := uint(1)
+	if criteria.MinId > first {
+		first = criteria.MinId
+	}
+	last := maxId
+	if criteria.MaxId > 0 && criteria.MaxId < last {
+		last = criteria.MaxId
+	}
+
+	pageCriteria := *criteria
+	if len(pageCriteria.MatchAny) == 0 {
+		// an empty criterion matches everyone
+		pageCriteria.MatchAny = []attendee.AttendeeSearchSingleCriterion{{}}
+	}
+	pageCriteria.SortBy = "id"
+	pageCriteria.SortOrder = "ascending"
+	pageCriteria.NumResults = 0
+
+	pageSize := uint(exportPageSize)
+	if withPayments {
+		pageSize = exportPaymentPageSize
+	}
+
+	remaining := int(criteria.NumResults)
+	for pageStart := first; pageStart <= last; pageStart += pageSize {
+		pageCriteria.MinId = pageStart
+		pageCriteria.MaxId = pageStart + pageSize - 1
+
+		entries, err := s.exportPage(ctx, &pageCriteria, withPayments, pageTimeout)
+		if err != nil {
+			return err
+		}
+
+		if criteria.NumResults > 0 {
+			if len(entries) >= remaining {
+				return consume(entries[:remaining])
+			}
+			remaining -= len(entries)
+		}
+		if len(entries) > 0 {
+			if err := consume(entries); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// exportPage reads one page of an export. Every page gets its own timeout, so large exports are possible.
+func (s *AttendeeServiceImplData) exportPage(ctx context.Context, criteria *attendee.AttendeeSearchCriteria, withPayments bool, pageTimeout time.Duration) ([]*AttendeeListEntry, error) {
+	pageCtx, cancel := context.WithTimeout(ctx, pageTimeout)
+	defer cancel()
+
+	attendees, err := database.GetRepository().FindAttendees(pageCtx, criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*AttendeeListEntry, 0, len(attendees))
+	for _, a := range attendees {
+		entry := &AttendeeListEntry{Attendee: a}
+
+		latest, err := database.GetRepository().GetLatestStatusChangeByAttendeeId(pageCtx, a.ID)
+		if err != nil {
+			return nil, err
+		}
+		entry.Status = latest.Status
+
+		if withPayments {
+			transactions, err := paymentservice.Get().GetTransactions(pageCtx, a.ID)
+			if err != nil && !errors.Is(err, paymentservice.NoSuchDebitor404Error) {
+				return nil, err
+			}
+			entry.TotalDues, entry.PaymentBalance = s.balances(transactions)
+		}
+
+		result = append(result, entry)
+	}
+	return result, nil
+}
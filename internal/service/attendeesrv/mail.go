@@ -0,0 +1,128 @@
+package attendeesrv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/mailservice"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the mail events other than status changes. The event name is also the default template name, see config.MailTemplate.
+const (
+	mailEventEmailVerification = "email-verification"
+	mailEventGuestInvitation   = "guest-invitation"
+	mailEventTransferIn        = "transfer-in"
+	mailEventTransferOut       = "transfer-out"
+)
+
+func statusMailEvent(status string) string {
+	return "new-status-" + status
+}
+
+// sendMail sends the template configured for the event to the attendee, in their language.
+//
+// Every mail gets the variables nickname, badge_number and, if configured, registration_link.
+// The given variables are added and take precedence.
+func sendMail(ctx context.Context, event string, attendee *entity.Attendee, variables map[string]string) error {
+	badgeNumber := strconv.Itoa(int(attendee.ID))
+	allVariables := map[string]string{
+		"nickname":     attendee.Nickname,
+		"badge_number": badgeNumber,
+	}
+	if registrationUrl := config.MailRegistrationUrl(); registrationUrl != "" {
+		allVariables["registration_link"] = strings.ReplaceAll(registrationUrl, "{badge_number}", badgeNumber)
+	}
+	for key, value := range variables {
+		allVariables[key] = value
+	}
+
+	return mailservice.Get().SendEmail(ctx, mailservice.TemplateRequestDto{
+		Name:      config.MailTemplate(event),
+		Variables: allVariables,
+		Email:     attendee.Email,
+		Lang:      mailLanguage(attendee),
+	})
+}
+
+// sendStatusMail informs the attendee of a status change, unless mails are suppressed for the transition,
+// or for bulk operations.
+//
+// In addition to the common variables, status mails get the new status, the selected packages, and the
+// dues, remaining dues and due date as booked in the payment service. Amounts are formatted like 123.45.
+func (s *AttendeeServiceImplData) sendStatusMail(ctx context.Context, attendee *entity.Attendee, oldStatus string, newStatus string, bulk bool) error {
+	if config.MailSuppressedForTransition(oldStatus, newStatus) || bulk && config.MailSuppressedForBulk() {
+		aulogging.Logger.Ctx(ctx).Info().Printf("status mail %s -> %s for attendee %d suppressed by configuration (bulk: %t)", oldStatus, newStatus, attendee.ID, bulk)
+		return nil
+	}
+
+	transactionHistory, err := paymentservice.Get().GetTransactions(ctx, attendee.ID)
+	if err != nil && !errors.Is(err, paymentservice.NoSuchDebitor404Error) {
+		return err
+	}
+	dues, paid := s.balances(transactionHistory)
+
+	return sendMail(ctx, statusMailEvent(newStatus), attendee, map[string]string{
+		"status":         newStatus,
+		"packages":       packageDescriptions(attendee.Packages),
+		"total_dues":     formatCents(dues),
+		"remaining_dues": formatCents(dues - paid),
+		"due_date":       latestDueDate(transactionHistory),
+	})
+}
+
+func mailLanguage(attendee *entity.Attendee) string {
+	if attendee.Language != "" {
+		return attendee.Language
+	}
+	return config.MailDefaultLanguage()
+}
+
+// packageDescriptions lists the descriptions of the selected packages, ordered by package key.
+func packageDescriptions(packages string) string {
+	keys := make([]string, 0)
+	for key, selected := range choiceStrToMap(packages) {
+		if selected {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	descriptions := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if packageConfig, ok := config.Configuration().Choices.Packages[key]; ok {
+			descriptions = append(descriptions, packageConfig.Description)
+		}
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+func formatCents(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// latestDueDate is the due date of the last valid dues that have one, or empty.
+func latestDueDate(transactionHistory []paymentservice.Transaction) string {
+	latest := time.Time{}
+	for _, tx := range transactionHistory {
+		if tx.Status == paymentservice.Valid && tx.Type == paymentservice.Due && tx.Amount.GrossCent > 0 && tx.DueDate.After(latest) {
+			latest = tx.DueDate
+		}
+	}
+	if latest.IsZero() {
+		return ""
+	}
+	return latest.Format(config.IsoDateFormat)
+}
@@ -0,0 +1,83 @@
+package attendeesrv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+	"sort"
+	"time"
+)
+
+// checkNoForbiddenPackageRemoval compares the packages with the stored attendee, and applies the removal
+// policy of each removed package if a payment has been made.
+//
+// Admins and api token callers can always remove packages.
+func (s *AttendeeServiceImplData) checkNoForbiddenPackageRemoval(ctx context.Context, attendee *entity.Attendee) error {
+	if admin, err := isAdmin(ctx); err != nil || admin {
+		return err
+	}
+
+	stored, err := database.GetRepository().GetAttendeeById(ctx, attendee.ID)
+	if err != nil {
+		return err
+	}
+	removed := removedChoices(stored.Packages, attendee.Packages)
+	if len(removed) == 0 {
+		return nil
+	}
+
+	paid, err := hasValidPayment(ctx, attendee.ID)
+	if err != nil || !paid {
+		return err
+	}
+
+	today := time.Now().Format(config.IsoDateFormat)
+	packages := config.PackagesConfig()
+	for _, key := range removed {
+		policy := packages[key]
+		switch policy.RemovalPolicy {
+		case config.RemovalPolicyRefundable:
+			// always allowed
+		case config.RemovalPolicyUntil:
+			if today > policy.RemovalAllowedUntil {
+				return fmt.Errorf("cannot remove package %s after %s: %w", key, policy.RemovalAllowedUntil, PackageRemovalNotAllowedError)
+			}
+		default:
+			return fmt.Errorf("cannot remove package %s: %w", key, PackageRemovalNotAllowedError)
+		}
+	}
+	return nil
+}
+
+// removedChoices lists the keys that are in originalChoiceStr but not in newChoiceStr, sorted.
+func removedChoices(originalChoiceStr string, newChoiceStr string) []string {
+	newChoices := choiceStrToMap(newChoiceStr)
+	result := make([]string, 0)
+	for k, picked := range choiceStrToMap(originalChoiceStr) {
+		if picked && !newChoices[k] {
+			result = append(result, k)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func hasValidPayment(ctx context.Context, attendeeId uint) (bool, error) {
+	transactionHistory, err := paymentservice.Get().GetTransactions(ctx, attendeeId)
+	if err != nil {
+		if errors.Is(err, paymentservice.NoSuchDebitor404Error) {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, tx := range transactionHistory {
+		if tx.Status == paymentservice.Valid && tx.Type == paymentservice.Payment && tx.Amount.GrossCent > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,263 @@
+package attendeesrv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+	"gorm.io/gorm"
+	"time"
+)
+
+// RegdeskAddInfoArea is the additional info area in which check-ins are recorded.
+const RegdeskAddInfoArea = "regdesk"
+
+// the key of the check-in record in the regdesk additional info, other keys are left alone
+const checkInKey = "check_in"
+
+// the most attendees a nickname lookup returns
+const regdeskLookupLimit = 20
+
+// RegdeskInfo is an attendee together with what the regdesk needs to know at check-in.
+type RegdeskInfo struct {
+	Attendee       *entity.Attendee
+	Status         string
+	TotalDues      int64
+	PaymentBalance int64
+	AdminFlags     string
+	CheckIn        *CheckInRecord // nil unless checked in
+}
+
+// CheckInRecord is stored in the regdesk additional info area.
+type CheckInRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	Desk      string    `json:"desk"`
+	Operator  string    `json:"operator"`
+	Items     []string  `json:"items"`
+}
+
+func (s *AttendeeServiceImplData) RegdeskLookup(ctx context.Context, badgeNumber uint, nickname string) ([]*RegdeskInfo, error) {
+	// controller checks permissions
+
+	attendees := make([]*entity.Attendee, 0)
+	if badgeNumber != 0 {
+		a, err := database.GetRepository().GetAttendeeById(ctx, badgeNumber)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return make([]*RegdeskInfo, 0), nil
+			}
+			return nil, err
+		}
+		attendees = append(attendees, a)
+	} else {
+		criteria := &attendee.AttendeeSearchCriteria{
+			MatchAny:   []attendee.AttendeeSearchSingleCriterion{{Nickname: "*" + nickname + "*"}},
+			NumResults: regdeskLookupLimit,
+			SortBy:     "nickname",
+			SortOrder:  "ascending",
+		}
+		found, err := database.GetRepository().FindAttendees(ctx, criteria)
+		if err != nil {
+			return nil, err
+		}
+		attendees = found
+	}
+
+	result := make([]*RegdeskInfo, 0, len(attendees))
+	for _, a := range attendees {
+		info, err := s.regdeskInfo(ctx, a)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+func (s *AttendeeServiceImplData) CheckIn(ctx context.Context, attendee *entity.Attendee, desk string, items []string, comment string) error {
+	// controller checks permissions and validates the values
+
+	latest, err := database.GetRepository().GetLatestStatusChangeByAttendeeId(ctx, attendee.ID)
+	if err != nil {
+		return err
+	}
+	if latest.Status == "checked in" {
+		return AlreadyCheckedInError
+	}
+	if latest.Status != "paid" {
+		return NotPaidError
+	}
+	if err := s.StatusChangePossible(ctx, attendee, latest.Status, "checked in"); err != nil {
+		return err
+	}
+
+	operator := ctxvalues.UserId(ctx)
+	comments := fmt.Sprintf("checked in at %s by %s", desk, operator)
+	if comment != "" {
+		comments += ": " + comment
+	}
+
+	// the record is only read once the attendee is checked in, so writing it first means
+	// a failure at any point leaves nothing half done, and the check in can simply be retried
+	record := &CheckInRecord{
+		Timestamp: time.Now().UTC(),
+		Desk:      desk,
+		Operator:  operator,
+		Items:     items,
+	}
+	if err := writeCheckInRecord(ctx, attendee.ID, record); err != nil {
+		return fmt.Errorf("%w: %w", CheckInNotRecordedError, err)
+	}
+
+	if err := s.UpdateDuesAndDoStatusChangeIfNeeded(ctx, attendee, latest.Status, "checked in", comments); err != nil {
+		current, err2 := database.GetRepository().GetLatestStatusChangeByAttendeeId(ctx, attendee.ID)
+		if err2 == nil && current.Status == "checked in" {
+			// checked in, but something after the status change failed, e.g. the mail
+			return err
+		}
+		if err2 := writeCheckInRecord(ctx, attendee.ID, nil); err2 != nil {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err2).Printf("failed to remove check in record of attendee %d after failed check in: %s", attendee.ID, err2.Error())
+		}
+		return fmt.Errorf("%w: %w", CheckInNotRecordedError, err)
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("attendee %d checked in at %s by %s", attendee.ID, desk, operator)
+	return nil
+}
+
+func (s *AttendeeServiceImplData) UndoCheckIn(ctx context.Context, attendee *entity.Attendee) error {
+	// controller checks permissions
+
+	history, err := s.GetFullStatusHistory(ctx, attendee)
+	if err != nil {
+		return err
+	}
+	latest := history[len(history)-1]
+	if latest.Status != "checked in" || len(history) < 2 {
+		return NotCheckedInError
+	}
+	// check-ins by plain status change have no record, those can only be undone by an admin
+	record, err := readCheckInRecord(ctx, attendee.ID)
+	if err != nil {
+		return err
+	}
+	if record == nil || time.Since(record.Timestamp) > config.RegdeskUndoWindow() {
+		return UndoWindowExpiredError
+	}
+
+	// no dues change and no email, this is a correction of a mistake at the regdesk
+	previous := history[len(history)-2]
+	operator := ctxvalues.UserId(ctx)
+	change := entity.StatusChange{
+		AttendeeId: attendee.ID,
+		Status:     previous.Status,
+		Comments:   fmt.Sprintf("check in undone by %s", operator),
+	}
+	if err := database.GetRepository().AddStatusChange(ctx, &change); err != nil {
+		return err
+	}
+
+	if err := writeCheckInRecord(ctx, attendee.ID, nil); err != nil {
+		return err
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("check in of attendee %d undone by %s", attendee.ID, operator)
+	return nil
+}
+
+func (s *AttendeeServiceImplData) regdeskInfo(ctx context.Context, a *entity.Attendee) (*RegdeskInfo, error) {
+	info := &RegdeskInfo{Attendee: a}
+
+	latest, err := database.GetRepository().GetLatestStatusChangeByAttendeeId(ctx, a.ID)
+	if err != nil {
+		return nil, err
+	}
+	info.Status = latest.Status
+
+	adminInfo, err := database.GetRepository().GetAdminInfoByAttendeeId(ctx, a.ID)
+	if err != nil {
+		return nil, err
+	}
+	info.AdminFlags = adminInfo.Flags
+
+	transactions, err := paymentservice.Get().GetTransactions(ctx, a.ID)
+	if err != nil && !errors.Is(err, paymentservice.NoSuchDebitor404Error) {
+		return nil, err
+	}
+	info.TotalDues, info.PaymentBalance = s.balances(transactions)
+
+	if info.Status == "checked in" {
+		record, err := readCheckInRecord(ctx, a.ID)
+		if err != nil {
+			return nil, err
+		}
+		info.CheckIn = record
+	}
+	return info, nil
+}
+
+// readCheckInRecord returns nil if there is no record, e.g. because the attendee was checked in using a plain status change.
+func readCheckInRecord(ctx context.Context, attendeeId uint) (*CheckInRecord, error) {
+	values, _, err := readRegdeskAddInfo(ctx, attendeeId)
+	if err != nil {
+		return nil, err
+	}
+	raw, ok := values[checkInKey]
+	if !ok || string(raw) == "null" {
+		return nil, nil
+	}
+	record := &CheckInRecord{}
+	if err := json.Unmarshal(raw, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// writeCheckInRecord replaces the check-in record, or removes it if record is nil.
+func writeCheckInRecord(ctx context.Context, attendeeId uint, record *CheckInRecord) error {
+	values, addInfo, err := readRegdeskAddInfo(ctx, attendeeId)
+	if err != nil {
+		return err
+	}
+	if record != nil {
+		raw, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		values[checkInKey] = raw
+	} else {
+		if _, ok := values[checkInKey]; !ok {
+			return nil
+		}
+		delete(values, checkInKey)
+	}
+
+	jsonValue, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	addInfo.JsonValue = string(jsonValue)
+	return database.GetRepository().WriteAdditionalInfo(ctx, addInfo)
+}
+
+func readRegdeskAddInfo(ctx context.Context, attendeeId uint) (map[string]json.RawMessage, *entity.AdditionalInfo, error) {
+	values := make(map[string]json.RawMessage)
+	addInfo, err := database.GetRepository().GetAdditionalInfoFor(ctx, attendeeId, RegdeskAddInfoArea)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return values, addInfo, nil
+		}
+		return nil, nil, err
+	}
+	if addInfo.JsonValue != "" {
+		if err := json.Unmarshal([]byte(addInfo.JsonValue), &values); err != nil {
+			return nil, nil, fmt.Errorf("regdesk additional info of attendee %d is not a json object: %s", attendeeId, err.Error())
+		}
+	}
+	return values, addInfo, nil
+}
@@ -0,0 +1,28 @@
+package attendeesrv
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+)
+
+// the number of random bytes in a secret code, such as a transfer or group invite code
+const secretCodeRandomBytes = 10
+
+// newSecretCode generates a random code. The id of what it belongs to is added in front so the code can be looked up.
+//
+// Only the hash of the code is stored, see hashSecretCode.
+func newSecretCode(id uint) (string, error) {
+	random := make([]byte, secretCodeRandomBytes)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-%s", id, base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(random)), nil
+}
+
+func hashSecretCode(code string) string {
+	hash := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(hash[:])
+}
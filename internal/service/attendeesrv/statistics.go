@@ -0,0 +1,100 @@
+package attendeesrv
+
+import (
+	"context"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+)
+
+// Statistics holds aggregated registration numbers.
+//
+// Deleted registrations only show up in ByStatus and PerDay.
+type Statistics struct {
+	Total        int64
+	ByStatus     map[string]int64
+	ByCountry    map[string]int64
+	ByPackage    map[string]int64
+	ByFlag       map[string]int64
+	ByTshirtSize map[string]int64
+	PerDay       []*entity.DailyCount
+}
+
+const (
+	noTshirtSizeKey = "none"
+	otherCountryKey = "other"
+)
+
+func (s *AttendeeServiceImplData) GetStatistics(ctx context.Context) (*Statistics, error) {
+	// controller checks permissions
+
+	groups, err := database.GetRepository().CountAttendeesByGroup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Statistics{
+		ByStatus:     make(map[string]int64),
+		ByCountry:    make(map[string]int64),
+		ByPackage:    make(map[string]int64),
+		ByFlag:       make(map[string]int64),
+		ByTshirtSize: make(map[string]int64),
+	}
+	for _, g := range groups {
+		result.ByStatus[g.Status] += g.Count
+		if g.Status == "deleted" {
+			continue
+		}
+
+		result.Total += g.Count
+		result.ByCountry[g.Country] += g.Count
+		for k := range choiceStrToMap(g.Packages) {
+			result.ByPackage[k] += g.Count
+		}
+		for k := range choiceStrToMap(g.Flags) {
+			result.ByFlag[k] += g.Count
+		}
+		if g.TshirtSize == "" {
+			result.ByTshirtSize[noTshirtSizeKey] += g.Count
+		} else {
+			result.ByTshirtSize[g.TshirtSize] += g.Count
+		}
+	}
+
+	result.PerDay, err = database.GetRepository().CountRegistrationsPerDay(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *AttendeeServiceImplData) GetPublicStatistics(ctx context.Context) (*Statistics, error) {
+	full, err := s.GetStatistics(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Statistics{
+		Total:     full.Total,
+		ByStatus:  make(map[string]int64),
+		ByCountry: make(map[string]int64),
+	}
+	for k, v := range full.ByStatus {
+		if k != "deleted" {
+			result.ByStatus[k] = v
+		}
+	}
+
+	// small countries could allow identifying individual attendees
+	minCount := int64(config.PublicStatisticsMinCount())
+	for k, v := range full.ByCountry {
+		if v >= minCount {
+			result.ByCountry[k] = v
+		} else {
+			result.ByCountry[otherCountryKey] += v
+		}
+	}
+
+	return result, nil
+}
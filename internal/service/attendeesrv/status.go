@@ -7,8 +7,8 @@ import (
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
-	"github.com/eurofurence/reg-attendee-service/internal/repository/mailservice"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+	"github.com/eurofurence/reg-attendee-service/internal/service/authsrv"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
 	"gorm.io/gorm"
 )
@@ -44,6 +44,11 @@ func (s *AttendeeServiceImplData) GetFullStatusHistory(ctx context.Context, atte
 }
 
 func (s *AttendeeServiceImplData) UpdateDuesAndDoStatusChangeIfNeeded(ctx context.Context, attendee *entity.Attendee, oldStatus string, newStatus string, comments string) error {
+	return s.updateDuesAndDoStatusChangeIfNeeded(ctx, attendee, oldStatus, newStatus, comments, false)
+}
+
+// updateDuesAndDoStatusChangeIfNeeded is UpdateDuesAndDoStatusChangeIfNeeded, bulk operations may have their status mails suppressed.
+func (s *AttendeeServiceImplData) updateDuesAndDoStatusChangeIfNeeded(ctx context.Context, attendee *entity.Attendee, oldStatus string, newStatus string, comments string, bulk bool) error {
 	var err error
 	// controller checks value validity
 	// controller checks permission via StatusChangeAllowed
@@ -61,18 +66,12 @@ func (s *AttendeeServiceImplData) UpdateDuesAndDoStatusChangeIfNeeded(ctx contex
 			Status:     newStatus,
 			Comments:   comments,
 		}
-		err = database.GetRepository().AddStatusChange(ctx, &change)
+		err = addStatusChange(ctx, attendee, oldStatus, &change)
 		if err != nil {
 			return err
 		}
 
-		err = mailservice.Get().SendEmail(ctx, mailservice.TemplateRequestDto{
-			Name: "new-status-" + newStatus,
-			Variables: map[string]string{
-				"nickname": attendee.Nickname,
-			},
-			Email: attendee.Email,
-		})
+		err = s.sendStatusMail(ctx, attendee, oldStatus, newStatus, bulk)
 		if err != nil {
 			return err
 		}
@@ -82,9 +81,20 @@ func (s *AttendeeServiceImplData) UpdateDuesAndDoStatusChangeIfNeeded(ctx contex
 }
 
 func (s *AttendeeServiceImplData) StatusChangeAllowed(ctx context.Context, attendee *entity.Attendee, oldStatus string, newStatus string) error {
-	if ctxvalues.HasApiToken(ctx) || ctxvalues.IsAuthorizedAsRole(ctx, config.OidcAdminRole()) {
-		// api or admin
-		return nil
+	if client := ctxvalues.ApiClient(ctx); client != "" && !ctxvalues.HasApiScope(ctx, config.ApiScopeAll) {
+		// api clients with limited scopes, each scope only allows the transitions it is meant for
+		if ctxvalues.HasApiScope(ctx, config.ApiScopePayments) && isPaymentStatus(oldStatus) && isPaymentStatus(newStatus) {
+			return nil
+		}
+		if ctxvalues.HasApiScope(ctx, config.ApiScopeCheckin) && oldStatus == "paid" && newStatus == "checked in" {
+			return nil
+		}
+
+		aulogging.Logger.Ctx(ctx).Warn().Printf("forbidden status change attempt %s -> %s for attendee %d by api client %s", oldStatus, newStatus, attendee.ID, client)
+		return errors.New("you are not allowed to make this status transition - the attempt has been logged")
+	}
+	if admin, err := isAdmin(ctx); err != nil || admin {
+		return err
 	}
 
 	subject := ctxvalues.Subject(ctx)
@@ -106,25 +116,25 @@ func (s *AttendeeServiceImplData) StatusChangeAllowed(ctx context.Context, atten
 
 	// others
 
-	if oldStatus == "paid" && newStatus == "checked in" {
-		// TODO - this is kind of ugly
+	if isPaymentStatus(oldStatus) && isPaymentStatus(newStatus) {
+		allowed, err := authorizationService.HasPermission(ctx, authsrv.PermissionPayments)
+		if err != nil {
+			return err
+		}
+		if allowed {
+			aulogging.Logger.Ctx(ctx).Info().Printf("payment status change %s -> %s for attendee %d by %s", oldStatus, newStatus, attendee.ID, subject)
+			return nil
+		}
+	}
 
-		// check that any of the registrations owned by subject have the regdesk permission
-		ownedAttendees, err := database.GetRepository().FindByIdentity(ctx, subject)
+	if oldStatus == "paid" && newStatus == "checked in" {
+		allowed, err := authorizationService.HasPermission(ctx, authsrv.PermissionRegdesk)
 		if err != nil {
 			return err
 		}
-		for _, oa := range ownedAttendees {
-			adminInfo, err := database.GetRepository().GetAdminInfoByAttendeeId(ctx, oa.ID)
-			if err != nil {
-				return err
-			}
-			permissions := choiceStrToMap(adminInfo.Permissions)
-			allowed, _ := permissions["regdesk"]
-			if allowed {
-				aulogging.Logger.Ctx(ctx).Info().Printf("regdesk check in for attendee %d by %s", attendee.ID, subject)
-				return nil
-			}
+		if allowed {
+			aulogging.Logger.Ctx(ctx).Info().Printf("regdesk check in for attendee %d by %s", attendee.ID, subject)
+			return nil
 		}
 	}
 
@@ -132,10 +142,18 @@ func (s *AttendeeServiceImplData) StatusChangeAllowed(ctx context.Context, atten
 	return errors.New("you are not allowed to make this status transition - the attempt has been logged")
 }
 
+// isPaymentStatus is true for the status values that the payment service switches between as payments come in or are refunded.
+func isPaymentStatus(status string) bool {
+	return status == "approved" || status == "partially paid" || status == "paid"
+}
+
 func (s *AttendeeServiceImplData) StatusChangePossible(ctx context.Context, attendee *entity.Attendee, oldStatus string, newStatus string) error {
 	if oldStatus == newStatus {
 		return SameStatusError
 	}
+	if err := checkVoucherNotUsedUpOnReactivation(ctx, attendee, oldStatus, newStatus); err != nil {
+		return err
+	}
 
 	transactionHistory, err := paymentservice.Get().GetTransactions(ctx, attendee.ID)
 	if err != nil && !errors.Is(err, paymentservice.NoSuchDebitor404Error) {
@@ -146,6 +164,9 @@ func (s *AttendeeServiceImplData) StatusChangePossible(ctx context.Context, atte
 	case "new":
 		return s.checkZeroOrNegativePaymentBalance(ctx, attendee, transactionHistory)
 	case "approved":
+		if err := checkEmailVerifiedForApproval(attendee, oldStatus); err != nil {
+			return err
+		}
 		return s.checkZeroOrNegativePaymentBalance(ctx, attendee, transactionHistory)
 	case "partially paid":
 		if oldStatus == "new" || oldStatus == "cancelled" || oldStatus == "deleted" {
@@ -0,0 +1,280 @@
+package attendeesrv
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TransferAddInfoArea is the additional info area in which a pending transfer code is stored.
+const TransferAddInfoArea = "transfer"
+
+// how long a transfer code can be redeemed
+const transferCodeValidity = 72 * time.Hour
+
+// registrations in these statuses can be transferred by their owner, admins can transfer anything but deleted ones
+var userTransferableStatuses = []string{"new", "approved", "partially paid", "paid"}
+
+// TransferRecord is stored in the transfer additional info area. Only a hash of the code is kept.
+type TransferRecord struct {
+	CodeHash          string    `json:"code_hash"`
+	Expires           time.Time `json:"expires"`
+	ResetPersonalData bool      `json:"reset_personal_data"`
+	CreatedBy         string    `json:"created_by"`
+	Invitation        bool      `json:"invitation,omitempty"` // the code was mailed to the email address of the registration
+}
+
+func (s *AttendeeServiceImplData) CreateTransferCode(ctx context.Context, attendee *entity.Attendee, resetPersonalData bool) (string, time.Time, error) {
+	// controller checks permissions
+
+	if err := checkTransferable(ctx, attendee, false); err != nil {
+		return "", time.Time{}, err
+	}
+
+	code, err := newSecretCode(attendee.ID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	record := &TransferRecord{
+		CodeHash:          hashSecretCode(code),
+		Expires:           time.Now().UTC().Add(transferCodeValidity),
+		ResetPersonalData: resetPersonalData,
+		CreatedBy:         ctxvalues.UserId(ctx),
+	}
+	if err := writeTransferRecord(ctx, attendee.ID, record); err != nil {
+		return "", time.Time{}, err
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("transfer code for attendee %d created by %s (reset personal data: %t)", attendee.ID, record.CreatedBy, resetPersonalData)
+	return code, record.Expires, nil
+}
+
+func (s *AttendeeServiceImplData) CancelTransferCode(ctx context.Context, attendee *entity.Attendee) error {
+	// controller checks permissions
+
+	record, err := readTransferRecord(ctx, attendee.ID)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return NoTransferCodeError
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("transfer code for attendee %d cancelled by %s", attendee.ID, ctxvalues.UserId(ctx))
+	return writeTransferRecord(ctx, attendee.ID, nil)
+}
+
+func (s *AttendeeServiceImplData) RedeemTransferCode(ctx context.Context, code string, email string) (*entity.Attendee, error) {
+	// controller checks that there is a logged in user
+
+	attendee, record, err := findTransferCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTransferable(ctx, attendee, false); err != nil {
+		return nil, err
+	}
+	if email == "" && record.ResetPersonalData {
+		email = ctxvalues.Email(ctx)
+	}
+	if email == "" && record.Invitation {
+		// the code was sent to this address, so whoever redeems it has access to it
+		attendee.EmailVerified = true
+	}
+
+	if err := transfer(ctx, attendee, ctxvalues.Subject(ctx), email, record.ResetPersonalData); err != nil {
+		return nil, err
+	}
+	return attendee, nil
+}
+
+func (s *AttendeeServiceImplData) TransferAttendee(ctx context.Context, attendee *entity.Attendee, newIdentity string, newEmail string, resetPersonalData bool) error {
+	// controller checks permissions and validates the values
+
+	if err := checkTransferable(ctx, attendee, true); err != nil {
+		return err
+	}
+	return transfer(ctx, attendee, newIdentity, newEmail, resetPersonalData)
+}
+
+// transfer changes the owner of a registration, removes any pending transfer code, and informs both parties by email.
+// Registrations created by an admin on behalf of someone else have no previous owner to inform.
+//
+// Permissions and group memberships belong to the previous owner, so they are not handed over.
+//
+// The change of the identity is recorded in the history like any other change.
+func transfer(ctx context.Context, attendee *entity.Attendee, newIdentity string, newEmail string, resetPersonalData bool) error {
+	if attendee.Identity == newIdentity {
+		return TransferToSameOwnerError
+	}
+	if resetPersonalData && newEmail == "" {
+		return TransferEmailMissingError
+	}
+
+	// the previous owner is informed in their own language, with the personal data before a reset
+	previous := *attendee
+
+	attendee.Identity = newIdentity
+	if resetPersonalData {
+		resetForResale(attendee)
+	}
+	if newEmail != "" {
+		attendee.Email = newEmail
+	}
+	sendVerification := markEmailUnverifiedIfChanged(previous.Email, attendee)
+	if err := database.GetRepository().UpdateAttendee(ctx, attendee); err != nil {
+		return err
+	}
+	if err := writeTransferRecord(ctx, attendee.ID, nil); err != nil {
+		return err
+	}
+	if err := clearPermissions(ctx, attendee.ID); err != nil {
+		return err
+	}
+	if err := leaveAllGroups(ctx, attendee); err != nil {
+		return err
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("attendee %d transferred from %s to %s by %s (reset personal data: %t)", attendee.ID, previous.Identity, newIdentity, ctxvalues.UserId(ctx), resetPersonalData)
+
+	if previous.Identity != "" {
+		if err := sendMail(ctx, mailEventTransferOut, &previous, nil); err != nil {
+			return err
+		}
+	}
+	if err := sendMail(ctx, mailEventTransferIn, attendee, nil); err != nil {
+		return err
+	}
+
+	if sendVerification {
+		sendEmailVerificationBestEffort(ctx, attendee)
+	}
+	return nil
+}
+
+// resetForResale clears the personal data of the previous owner. The new owner has to fill it in again.
+//
+// Packages are kept, because that is what was paid for. Admin only flags are also kept.
+func resetForResale(attendee *entity.Attendee) {
+	attendee.Nickname = ""
+	attendee.FirstName = ""
+	attendee.LastName = ""
+	attendee.Street = ""
+	attendee.Zip = ""
+	attendee.City = ""
+	attendee.Country = ""
+	attendee.CountryBadge = ""
+	attendee.State = ""
+	attendee.Email = ""
+	attendee.Phone = ""
+	attendee.Telegram = ""
+	attendee.Partner = ""
+	attendee.Birthday = ""
+	attendee.Gender = ""
+	attendee.Pronouns = ""
+	attendee.TshirtSize = ""
+	attendee.Flags = config.DefaultFlags()
+	attendee.Options = config.DefaultOptions()
+	attendee.UserComments = ""
+	attendee.Language = ""
+}
+
+func checkTransferable(ctx context.Context, attendee *entity.Attendee, byAdmin bool) error {
+	latest, err := database.GetRepository().GetLatestStatusChangeByAttendeeId(ctx, attendee.ID)
+	if err != nil {
+		return err
+	}
+	if byAdmin && latest.Status != "deleted" || containsString(userTransferableStatuses, latest.Status) {
+		return nil
+	}
+	return fmt.Errorf("%w: attendee %d is in status %s", TransferNotAllowedError, attendee.ID, latest.Status)
+}
+
+// findTransferCode returns TransferCodeInvalidError for anything that is wrong with the code, so codes cannot be probed.
+func findTransferCode(ctx context.Context, code string) (*entity.Attendee, *TransferRecord, error) {
+	idStr, _, found := strings.Cut(code, "-")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if !found || err != nil || id == 0 {
+		return nil, nil, TransferCodeInvalidError
+	}
+	attendee, err := database.GetRepository().GetAttendeeById(ctx, uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, TransferCodeInvalidError
+		}
+		return nil, nil, err
+	}
+	record, err := readTransferRecord(ctx, attendee.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if record == nil || time.Now().After(record.Expires) ||
+		subtle.ConstantTimeCompare([]byte(record.CodeHash), []byte(hashSecretCode(code))) != 1 {
+		return nil, nil, TransferCodeInvalidError
+	}
+	return attendee, record, nil
+}
+
+// readTransferRecord returns nil if there is no pending transfer code.
+func readTransferRecord(ctx context.Context, attendeeId uint) (*TransferRecord, error) {
+	addInfo, err := database.GetRepository().GetAdditionalInfoFor(ctx, attendeeId, TransferAddInfoArea)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if addInfo.JsonValue == "" || addInfo.JsonValue == "{}" {
+		return nil, nil
+	}
+	record := &TransferRecord{}
+	if err := json.Unmarshal([]byte(addInfo.JsonValue), record); err != nil {
+		return nil, fmt.Errorf("transfer additional info of attendee %d is not a transfer record: %s", attendeeId, err.Error())
+	}
+	return record, nil
+}
+
+// writeTransferRecord replaces the pending transfer code, or removes it if record is nil.
+func writeTransferRecord(ctx context.Context, attendeeId uint, record *TransferRecord) error {
+	addInfo, err := database.GetRepository().GetAdditionalInfoFor(ctx, attendeeId, TransferAddInfoArea)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if record == nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || addInfo.JsonValue == "{}" {
+			return nil
+		}
+		addInfo.JsonValue = "{}"
+		return database.GetRepository().WriteAdditionalInfo(ctx, addInfo)
+	}
+
+	jsonValue, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	addInfo.JsonValue = string(jsonValue)
+	return database.GetRepository().WriteAdditionalInfo(ctx, addInfo)
+}
+
+// clearPermissions removes the permissions from the admin info, because they are granted to whoever owns the registration.
+func clearPermissions(ctx context.Context, attendeeId uint) error {
+	adminInfo, err := database.GetRepository().GetAdminInfoByAttendeeId(ctx, attendeeId)
+	if err != nil {
+		return err
+	}
+	if adminInfo.Permissions == "" {
+		return nil
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("permissions %s of attendee %d removed due to transfer", adminInfo.Permissions, attendeeId)
+	adminInfo.Permissions = ""
+	return database.GetRepository().WriteAdminInfo(ctx, adminInfo)
+}
@@ -0,0 +1,271 @@
+package attendeesrv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database/dbrepo"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+	"gorm.io/gorm"
+	"time"
+)
+
+// voucher discounts are booked as separate dues transactions, recognizable by this comment prefix
+const voucherDuesComment = "voucher discount"
+
+// VoucherInfo is a voucher together with the number of attendees who have redeemed it.
+type VoucherInfo struct {
+	Voucher *entity.Voucher
+	Uses    int64
+}
+
+func (s *AttendeeServiceImplData) GetAllVouchers(ctx context.Context) ([]*VoucherInfo, error) {
+	// controller checks permissions
+
+	vouchers, err := database.GetRepository().GetAllVouchers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*VoucherInfo, 0, len(vouchers))
+	for _, voucher := range vouchers {
+		info, err := voucherInfo(ctx, voucher)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+func (s *AttendeeServiceImplData) GetVoucher(ctx context.Context, id uint) (*VoucherInfo, error) {
+	// controller checks permissions
+
+	voucher, err := database.GetRepository().GetVoucherById(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: voucher %d", VoucherNotFoundError, id)
+		}
+		return nil, err
+	}
+	return voucherInfo(ctx, voucher)
+}
+
+func (s *AttendeeServiceImplData) CreateVoucher(ctx context.Context, voucher *entity.Voucher) (uint, error) {
+	// controller checks permissions and validates the voucher
+
+	_, err := database.GetRepository().GetVoucherByCode(ctx, voucher.Code)
+	if err == nil {
+		return 0, fmt.Errorf("%w: %s", VoucherCodeExistsError, voucher.Code)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+
+	id, err := database.GetRepository().AddVoucher(ctx, voucher)
+	if err != nil {
+		return 0, err
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("voucher %d with code %s created by %s", id, voucher.Code, ctxvalues.UserId(ctx))
+	return id, nil
+}
+
+func (s *AttendeeServiceImplData) UpdateVoucher(ctx context.Context, voucher *entity.Voucher) error {
+	// controller checks permissions and validates the voucher, including that the code is unchanged
+
+	return database.GetRepository().UpdateVoucher(ctx, voucher)
+}
+
+func (s *AttendeeServiceImplData) CanRedeemVoucher(ctx context.Context, originalCode string, newCode string, packages string) error {
+	if newCode == "" || newCode == originalCode {
+		// keeping a voucher is always possible, even if it has expired or been used up since
+		return nil
+	}
+
+	voucher, err := database.GetRepository().GetVoucherByCode(ctx, newCode)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return VoucherInvalidError
+		}
+		return err
+	}
+	if voucher.Expires != nil && time.Now().After(*voucher.Expires) {
+		return VoucherInvalidError
+	}
+	if voucher.MaxUses > 0 {
+		uses, err := database.GetRepository().CountAttendeesByVoucher(ctx, voucher.Code)
+		if err != nil {
+			return err
+		}
+		if uses >= voucher.MaxUses {
+			return VoucherUsedUpError
+		}
+	}
+	if len(applicablePackages(voucher, packages)) == 0 {
+		return VoucherNotApplicableError
+	}
+	return nil
+}
+
+// addAttendee enforces the maximum uses of the voucher in the database, because CanRedeemVoucher
+// cannot prevent concurrent registrations from redeeming the last use.
+func addAttendee(ctx context.Context, attendee *entity.Attendee) (uint, error) {
+	if attendee.Voucher == "" {
+		return database.GetRepository().AddAttendee(ctx, attendee)
+	}
+	id, err := database.GetRepository().AddAttendeeRedeemingVoucher(ctx, attendee)
+	if errors.Is(err, dbrepo.VoucherUsedUpError) {
+		return id, VoucherUsedUpError
+	}
+	return id, err
+}
+
+// updateAttendee is the same for updates. Keeping a voucher is always possible.
+func updateAttendee(ctx context.Context, previous *entity.Attendee, attendee *entity.Attendee) error {
+	if attendee.Voucher == "" || attendee.Voucher == previous.Voucher {
+		return database.GetRepository().UpdateAttendee(ctx, attendee)
+	}
+	err := database.GetRepository().UpdateAttendeeRedeemingVoucher(ctx, attendee)
+	if errors.Is(err, dbrepo.VoucherUsedUpError) {
+		return VoucherUsedUpError
+	}
+	return err
+}
+
+// addStatusChange is the same for a registration that becomes active again, because cancelled and deleted
+// registrations do not count as uses of their voucher.
+func addStatusChange(ctx context.Context, attendee *entity.Attendee, oldStatus string, change *entity.StatusChange) error {
+	if !isVoucherReactivation(attendee, oldStatus, change.Status) {
+		return database.GetRepository().AddStatusChange(ctx, change)
+	}
+	err := database.GetRepository().AddStatusChangeRedeemingVoucher(ctx, change, attendee.Voucher)
+	if errors.Is(err, dbrepo.VoucherUsedUpError) {
+		return VoucherUsedUpError
+	}
+	return err
+}
+
+// checkVoucherNotUsedUpOnReactivation lets StatusChangePossible reject the status change before any dues are booked.
+func checkVoucherNotUsedUpOnReactivation(ctx context.Context, attendee *entity.Attendee, oldStatus string, newStatus string) error {
+	if !isVoucherReactivation(attendee, oldStatus, newStatus) {
+		return nil
+	}
+	voucher, err := database.GetRepository().GetVoucherByCode(ctx, attendee.Voucher)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if voucher.MaxUses > 0 {
+		uses, err := database.GetRepository().CountAttendeesByVoucher(ctx, voucher.Code)
+		if err != nil {
+			return err
+		}
+		if uses >= voucher.MaxUses {
+			return VoucherUsedUpError
+		}
+	}
+	return nil
+}
+
+func isVoucherReactivation(attendee *entity.Attendee, oldStatus string, newStatus string) bool {
+	return attendee.Voucher != "" && containsString(inactiveStatuses, oldStatus) && !containsString(inactiveStatuses, newStatus)
+}
+
+// checkNoForbiddenVoucherChange makes sure the voucher is not changed after the first payment, because
+// that would lead to a refund or an unexpected payment request.
+//
+// Admins and api token callers can always change the voucher.
+func (s *AttendeeServiceImplData) checkNoForbiddenVoucherChange(ctx context.Context, previous *entity.Attendee, attendee *entity.Attendee) error {
+	if previous.Voucher == attendee.Voucher {
+		return nil
+	}
+	if admin, err := isAdmin(ctx); err != nil || admin {
+		return err
+	}
+
+	paid, err := hasValidPayment(ctx, attendee.ID)
+	if err != nil {
+		return err
+	}
+	if paid {
+		return VoucherChangeNotAllowedError
+	}
+	return nil
+}
+
+// voucherDuesByVAT calculates the (negative) discount by VAT rate for the voucher the attendee has redeemed.
+//
+// The discount for a VAT rate never exceeds the dues for that rate, which already include any comp.
+// A fixed amount is applied to the highest VAT rates first.
+func (s *AttendeeServiceImplData) voucherDuesByVAT(ctx context.Context, attendee *entity.Attendee, duesByVAT map[string]int64) (map[string]int64, error) {
+	result := make(map[string]int64)
+	if attendee.Voucher == "" {
+		return result, nil
+	}
+	voucher, err := database.GetRepository().GetVoucherByCode(ctx, attendee.Voucher)
+	if err != nil {
+		return result, err
+	}
+
+	applicableByVAT := make(map[string]int64)
+	packageConfigs := config.Configuration().Choices.Packages
+	for _, key := range applicablePackages(voucher, attendee.Packages) {
+		if packageConfig, ok := packageConfigs[key]; ok {
+			vatStr := fmt.Sprintf("%.6f", packageConfig.VatPercent)
+			applicableByVAT[vatStr] += int64(packageConfig.PriceAt(attendee.CreatedAt) * 100)
+		}
+	}
+
+	remainingAmount := voucher.Amount
+	for _, vatStr := range vatRatesDescending(applicableByVAT) {
+		discount := applicableByVAT[vatStr] * voucher.Percent / 100
+		if voucher.Amount > 0 {
+			discount = min64(applicableByVAT[vatStr], remainingAmount)
+			remainingAmount -= discount
+		}
+		discount = min64(discount, duesByVAT[vatStr])
+		if discount > 0 {
+			result[vatStr] = -discount
+		}
+	}
+	return result, nil
+}
+
+func voucherComment(code string) string {
+	if code == "" {
+		return voucherDuesComment + " removed"
+	}
+	return voucherDuesComment + " " + code
+}
+
+// applicablePackages lists the selected packages the voucher applies to.
+func applicablePackages(voucher *entity.Voucher, packages string) []string {
+	voucherPackages := choiceStrToMap(voucher.Packages)
+	result := make([]string, 0)
+	for key, selected := range choiceStrToMap(packages) {
+		if selected && (voucher.Packages == "" || voucherPackages[key]) {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+func voucherInfo(ctx context.Context, voucher *entity.Voucher) (*VoucherInfo, error) {
+	uses, err := database.GetRepository().CountAttendeesByVoucher(ctx, voucher.Code)
+	if err != nil {
+		return nil, err
+	}
+	return &VoucherInfo{Voucher: voucher, Uses: uses}, nil
+}
+
+func min64(a int64, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,73 @@
+package authsrv
+
+import (
+	"context"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+	"sort"
+	"strings"
+)
+
+func (s *AuthorizationServiceImplData) EffectivePermissions(ctx context.Context) (map[string]bool, error) {
+	permissions, ok := ctxvalues.Permissions(ctx)
+	if !ok {
+		var err error
+		permissions, err = s.resolvePermissions(ctx)
+		if err != nil {
+			// not cached, so a later check in the same request tries again
+			return nil, err
+		}
+		ctxvalues.SetPermissions(ctx, permissions)
+	}
+
+	result := make(map[string]bool)
+	for _, p := range permissions {
+		result[p] = true
+	}
+	return result, nil
+}
+
+func (s *AuthorizationServiceImplData) HasPermission(ctx context.Context, permission string) (bool, error) {
+	permissions, err := s.EffectivePermissions(ctx)
+	if err != nil {
+		return false, err
+	}
+	return permissions[PermissionAdmin] || permissions[permission], nil
+}
+
+func (s *AuthorizationServiceImplData) resolvePermissions(ctx context.Context) ([]string, error) {
+	if ctxvalues.HasApiToken(ctx) || ctxvalues.IsAuthorizedAsRole(ctx, config.OidcAdminRole()) {
+		return []string{PermissionAdmin}, nil
+	}
+
+	// api clients with limited scopes have no permissions, they are checked by scope
+	subject := ctxvalues.Subject(ctx)
+	if subject == "" || ctxvalues.ApiClient(ctx) != "" {
+		return []string{}, nil
+	}
+
+	ownedAttendees, err := database.GetRepository().FindByIdentity(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+	collected := make(map[string]bool)
+	for _, oa := range ownedAttendees {
+		adminInfo, err := database.GetRepository().GetAdminInfoByAttendeeId(ctx, oa.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range strings.Split(adminInfo.Permissions, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				collected[p] = true
+			}
+		}
+	}
+
+	result := make([]string, 0)
+	for p := range collected {
+		result = append(result, p)
+	}
+	sort.Strings(result)
+	return result, nil
+}
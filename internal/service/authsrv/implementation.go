@@ -0,0 +1,6 @@
+package authsrv
+
+type AuthorizationServiceImplData struct {
+}
+
+var _ AuthorizationService = (*AuthorizationServiceImplData)(nil)
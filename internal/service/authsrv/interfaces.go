@@ -0,0 +1,33 @@
+package authsrv
+
+import (
+	"context"
+)
+
+const (
+	// PermissionAdmin grants all other permissions.
+	PermissionAdmin    = "admin"
+	PermissionReadAll  = "read_all"
+	PermissionPayments = "payments"
+	PermissionRegdesk  = "regdesk"
+)
+
+// AllowedPermissions lists the values that may be stored in AdminInfo.Permissions.
+//
+// bans, announcements and the last few are only used by regsys classic, they carry no meaning in this service.
+func AllowedPermissions() []string {
+	return []string{PermissionAdmin, PermissionReadAll, PermissionPayments, "bans", "announcements", PermissionRegdesk,
+		"sponsordesk", "view", "stats", "announce", "export_conbook"}
+}
+
+type AuthorizationService interface {
+	// EffectivePermissions resolves the permissions of the caller.
+	//
+	// Admins (by role) and api clients with full access have the admin permission. Logged in users get the union
+	// of the permissions in the admin info of all registrations they own. The result is cached in the request
+	// context, so the database is read at most once per request.
+	EffectivePermissions(ctx context.Context) (map[string]bool, error)
+
+	// HasPermission checks the effective permissions of the caller. The admin permission implies all others.
+	HasPermission(ctx context.Context, permission string) (bool, error)
+}
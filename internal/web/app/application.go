@@ -1,8 +1,10 @@
 package app
 
 import (
+	"context"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/jwks"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/mailservice"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
 )
@@ -41,6 +43,18 @@ func (i *Impl) Run() int {
 		return 1
 	}
 
+	jwksCtx, cancelJwks := context.WithCancel(context.Background())
+	defer cancelJwks()
+	jwks.Create(jwksCtx)
+
+	reloadCtx, cancelReload := context.WithCancel(context.Background())
+	defer cancelReload()
+	startReloadOnSignal(reloadCtx)
+
+	retentionCtx, cancelRetention := context.WithCancel(context.Background())
+	defer cancelRetention()
+	startRetentionPolicyJob(retentionCtx)
+
 	if err := runServerWithGracefulShutdown(); err != nil {
 		return 2
 	}
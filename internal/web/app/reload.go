@@ -0,0 +1,32 @@
+package app
+
+import (
+	"context"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// startReloadOnSignal reloads the configuration whenever the process receives SIGHUP, until the context is cancelled.
+func startReloadOnSignal(ctx context.Context) {
+	config.OnReload(func() {
+		setLoglevel(config.LoggingSeverity())
+	})
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(sig)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sig:
+				aulogging.Logger.NoCtx().Info().Print("received SIGHUP, reloading configuration")
+				_, _, _ = config.ReloadConfiguration()
+			}
+		}
+	}()
+}
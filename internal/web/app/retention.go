@@ -0,0 +1,52 @@
+package app
+
+import (
+	"context"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+	"time"
+)
+
+const retentionJobSubject = "retention-policy"
+
+// startRetentionPolicyJob periodically anonymises registrations whose retention period has expired,
+// until the context is cancelled.
+//
+// Does nothing unless data_retention.anonymise_after_days is configured.
+func startRetentionPolicyJob(ctx context.Context) {
+	if config.AnonymiseAfter() <= 0 {
+		aulogging.Logger.NoCtx().Info().Print("automatic anonymisation is switched off. Configure data_retention.anonymise_after_days to enable.")
+		return
+	}
+
+	service := &attendeesrv.AttendeeServiceImplData{}
+	go func() {
+		ticker := time.NewTicker(config.RetentionCheckInterval())
+		defer ticker.Stop()
+		for {
+			applyRetentionPolicy(ctx, service)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func applyRetentionPolicy(ctx context.Context, service attendeesrv.AttendeeService) {
+	jobCtx := ctxvalues.CreateContextWithValueMap(ctx)
+	// recorded as the user in the history
+	ctxvalues.SetSubject(jobCtx, retentionJobSubject)
+
+	count, err := service.ApplyRetentionPolicy(jobCtx)
+	if err != nil {
+		aulogging.Logger.Ctx(jobCtx).Error().WithErr(err).Printf("failed to apply retention policy after anonymising %d attendees: %s", count, err.Error())
+		return
+	}
+	if count > 0 {
+		aulogging.Logger.Ctx(jobCtx).Info().Printf("retention policy anonymised %d attendees", count)
+	}
+}
@@ -8,10 +8,18 @@ import (
 	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
 	"github.com/eurofurence/reg-attendee-service/internal/web/controller/adminctl"
 	"github.com/eurofurence/reg-attendee-service/internal/web/controller/attendeectl"
+	"github.com/eurofurence/reg-attendee-service/internal/web/controller/badgectl"
+	"github.com/eurofurence/reg-attendee-service/internal/web/controller/choicectl"
+	"github.com/eurofurence/reg-attendee-service/internal/web/controller/configctl"
 	"github.com/eurofurence/reg-attendee-service/internal/web/controller/countdownctl"
 	"github.com/eurofurence/reg-attendee-service/internal/web/controller/fallbackctl"
+	"github.com/eurofurence/reg-attendee-service/internal/web/controller/groupctl"
 	"github.com/eurofurence/reg-attendee-service/internal/web/controller/infoctl"
+	"github.com/eurofurence/reg-attendee-service/internal/web/controller/regdeskctl"
+	"github.com/eurofurence/reg-attendee-service/internal/web/controller/statsctl"
 	"github.com/eurofurence/reg-attendee-service/internal/web/controller/statusctl"
+	"github.com/eurofurence/reg-attendee-service/internal/web/controller/transferctl"
+	"github.com/eurofurence/reg-attendee-service/internal/web/controller/voucherctl"
 	"github.com/eurofurence/reg-attendee-service/internal/web/middleware"
 	"github.com/go-chi/chi/v5"
 	"net"
@@ -38,6 +46,14 @@ func CreateRouter(ctx context.Context) chi.Router {
 	adminctl.Create(server)
 	statusctl.Create(server)
 	infoctl.Create(server)
+	statsctl.Create(server)
+	choicectl.Create(server)
+	configctl.Create(server)
+	regdeskctl.Create(server)
+	badgectl.Create(server)
+	transferctl.Create(server)
+	groupctl.Create(server)
+	voucherctl.Create(server)
 
 	fallbackctl.Create(server)
 	return server
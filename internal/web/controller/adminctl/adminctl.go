@@ -3,11 +3,13 @@ package adminctl
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	aulogging "github.com/StephanHCB/go-autumn-logging"
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/admin"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
 	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/service/authsrv"
 	"github.com/eurofurence/reg-attendee-service/internal/web/filter"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
@@ -31,8 +33,9 @@ func OverrideAttendeeService(overrideAttendeeServiceForTesting attendeesrv.Atten
 }
 
 func Create(server chi.Router) {
-	server.Get("/api/rest/v1/attendees/{id}/admin", filter.HasRoleOrApiToken(config.OidcAdminRole(), filter.WithTimeout(3*time.Second, getAdminInfoHandler)))
-	server.Put("/api/rest/v1/attendees/{id}/admin", filter.HasRoleOrApiToken(config.OidcAdminRole(), filter.WithTimeout(3*time.Second, writeAdminInfoHandler)))
+	server.Get("/api/rest/v1/attendees/{id}/admin", filter.HasPermission(authsrv.PermissionReadAll, config.ApiScopeRead, filter.WithTimeout(3*time.Second, getAdminInfoHandler)))
+	server.Put("/api/rest/v1/attendees/{id}/admin", filter.HasPermission(authsrv.PermissionAdmin, config.ApiScopeAll, filter.WithTimeout(3*time.Second, writeAdminInfoHandler)))
+	server.Post("/api/rest/v1/attendees/{id}/anonymise", filter.HasPermission(authsrv.PermissionAdmin, config.ApiScopeAll, filter.WithTimeout(3*time.Second, anonymiseAttendeeHandler)))
 }
 
 // --- handlers ---
@@ -94,6 +97,27 @@ func writeAdminInfoHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func anonymiseAttendeeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	attendee, err := attendeeByIdMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	err = attendeeService.AnonymiseAttendee(ctx, attendee)
+	if err != nil {
+		if errors.Is(err, attendeesrv.NotEligibleForAnonymisationError) {
+			anonymiseNotEligibleErrorHandler(ctx, w, r, err)
+		} else {
+			anonymiseErrorHandler(ctx, w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // --- helpers ---
 
 func attendeeByIdMustReturnOnError(ctx context.Context, w http.ResponseWriter, r *http.Request) (*entity.Attendee, error) {
@@ -140,3 +164,13 @@ func adminInfoValidationErrorHandler(ctx context.Context, w http.ResponseWriter,
 	aulogging.Logger.Ctx(ctx).Warn().Printf("received adminInfo data with validation errors: %v", errs)
 	ctlutil.ErrorHandler(ctx, w, r, "admin.data.invalid", http.StatusBadRequest, errs)
 }
+
+func anonymiseNotEligibleErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("attendee not eligible for anonymisation: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "attendee.anonymise.notallowed", http.StatusConflict, url.Values{"details": []string{err.Error()}})
+}
+
+func anonymiseErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("failed to anonymise attendee: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "attendee.anonymise.error", http.StatusInternalServerError, url.Values{})
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/admin"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/service/authsrv"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/validation"
 	"net/url"
 )
@@ -18,7 +19,7 @@ func validate(ctx context.Context, a *admin.AdminInfoDto, trustedOriginalState *
 		errs.Add("id", "id field must be empty or correctly assigned for incoming requests")
 	}
 
-	validation.CheckCombinationOfAllowedValues(&errs, []string{"admin", "regdesk", "sponsordesk", "view", "stats", "announce", "export_conbook"}, "permissions", a.Permissions)
+	validation.CheckCombinationOfAllowedValues(&errs, authsrv.AllowedPermissions(), "permissions", a.Permissions)
 
 	validation.CheckCombinationOfAllowedValues(&errs, config.AllowedFlagsAdminOnly(), "flags", a.Flags)
 	if err := attendeeService.CanChangeChoiceTo(ctx, trustedOriginalState.Flags, a.Flags, config.FlagsConfigAdminOnly()); err != nil {
@@ -3,12 +3,14 @@ package attendeectl
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	aulogging "github.com/StephanHCB/go-autumn-logging"
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
 	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/service/authsrv"
 	"github.com/eurofurence/reg-attendee-service/internal/web/filter"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
@@ -33,13 +35,25 @@ func OverrideAttendeeService(overrideAttendeeServiceForTesting attendeesrv.Atten
 
 func Create(server chi.Router) {
 	if config.RequireLoginForReg() {
-		server.Post("/api/rest/v1/attendees", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, newAttendeeHandler)))
+		server.Post("/api/rest/v1/attendees", filter.LoggedInOrApiScope(config.ApiScopeWrite, filter.WithTimeout(3*time.Second, newAttendeeHandler)))
+		server.Post("/api/rest/v1/attendees/eligibility", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, choiceEligibilityHandler)))
 	} else {
 		server.Post("/api/rest/v1/attendees", filter.WithTimeout(3*time.Second, newAttendeeHandler))
+		server.Post("/api/rest/v1/attendees/eligibility", filter.WithTimeout(3*time.Second, choiceEligibilityHandler))
 	}
+	server.Get("/api/rest/v1/attendees", filter.LoggedIn(filter.WithTimeout(3*time.Second, listMyRegistrationsHandler)))
+	server.Get("/api/rest/v1/attendees/mine", filter.LoggedIn(filter.WithTimeout(3*time.Second, getMyRegistrationsHandler)))
 	server.Get("/api/rest/v1/attendees/max-id", filter.WithTimeout(3*time.Second, getAttendeeMaxIdHandler))
-	server.Get("/api/rest/v1/attendees/{id}", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, getAttendeeHandler)))
-	server.Put("/api/rest/v1/attendees/{id}", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, updateAttendeeHandler)))
+	// no overall timeout for exports, each page of the export has its own timeout instead
+	server.Post("/api/rest/v1/attendees/export", filter.HasPermission(authsrv.PermissionReadAll, config.ApiScopeRead, exportAttendeeListHandler))
+	server.Post("/api/rest/v1/attendees/import", filter.HasPermission(authsrv.PermissionAdmin, config.ApiScopeAll, importAttendeesHandler))
+	server.Post("/api/rest/v1/attendees/create-for", filter.HasPermission(authsrv.PermissionAdmin, config.ApiScopeAll, filter.WithTimeout(3*time.Second, createAttendeeForHandler)))
+	// the token is proof enough, no login needed
+	server.Post("/api/rest/v1/attendees/email-verification", filter.WithTimeout(3*time.Second, verifyEmailHandler))
+	server.Get("/api/rest/v1/attendees/{id}", filter.LoggedInOrApiScope(config.ApiScopeRead, filter.WithTimeout(3*time.Second, getAttendeeHandler)))
+	server.Put("/api/rest/v1/attendees/{id}", filter.LoggedInOrApiScope(config.ApiScopeWrite, filter.WithTimeout(3*time.Second, updateAttendeeHandler)))
+	server.Get("/api/rest/v1/attendees/{id}/export", filter.LoggedInOrApiScope(config.ApiScopeRead, filter.WithTimeout(3*time.Second, getPersonalDataExportHandler)))
+	server.Post("/api/rest/v1/attendees/{id}/email-verification", filter.LoggedInOrApiScope(config.ApiScopeWrite, filter.WithTimeout(3*time.Second, resendEmailVerificationHandler)))
 }
 
 func newAttendeeHandler(w http.ResponseWriter, r *http.Request) {
@@ -80,7 +94,7 @@ func getAttendeeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := filter.IsSubjectOrRoleOrApiToken(w, r, existingAttendee.Identity, config.OidcAdminRole()); err != nil {
+	if err := filter.IsSubjectOrPermissionOrApiScope(w, r, existingAttendee.Identity, authsrv.PermissionReadAll, config.ApiScopeRead); err != nil {
 		return
 	}
 
@@ -107,7 +121,7 @@ func updateAttendeeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := filter.IsSubjectOrRoleOrApiToken(w, r, attd.Identity, config.OidcAdminRole()); err != nil {
+	if err := filter.IsSubjectOrPermissionOrApiScope(w, r, attd.Identity, authsrv.PermissionAdmin, config.ApiScopeWrite); err != nil {
 		return
 	}
 
@@ -170,8 +184,12 @@ func attendeeParseErrorHandler(ctx context.Context, w http.ResponseWriter, r *ht
 
 func attendeeWriteErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
 	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("attendee could not be written: %s", err.Error())
-	if err.Error() == "duplicate attendee data - you are already registered" {
+	if errors.Is(err, attendeesrv.DuplicateAttendeeError) {
 		ctlutil.ErrorHandler(ctx, w, r, "attendee.data.duplicate", http.StatusConflict, url.Values{"attendee": {"there is already an attendee with this information (looking at nickname, email, and zip code)"}})
+	} else if errors.Is(err, attendeesrv.PackageRemovalNotAllowedError) {
+		ctlutil.ErrorHandler(ctx, w, r, "attendee.data.invalid", http.StatusBadRequest, url.Values{"packages": {err.Error()}})
+	} else if errors.Is(err, attendeesrv.VoucherChangeNotAllowedError) || errors.Is(err, attendeesrv.VoucherUsedUpError) {
+		ctlutil.ErrorHandler(ctx, w, r, "attendee.data.invalid", http.StatusBadRequest, url.Values{"voucher": {err.Error()}})
 	} else {
 		ctlutil.ErrorHandler(ctx, w, r, "attendee.write.error", http.StatusInternalServerError, url.Values{})
 	}
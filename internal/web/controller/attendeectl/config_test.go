@@ -3,12 +3,14 @@ package attendeectl
 import (
 	"context"
 	"errors"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
 	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
 	"github.com/stretchr/testify/mock"
 	"os"
 	"testing"
+	"time"
 )
 
 // placing these here because they are package global
@@ -63,6 +65,14 @@ func (s *MockAttendeeService) CanChangeChoiceTo(ctx context.Context, originalCho
 	return nil
 }
 
+func (s *MockAttendeeService) IneligibleChoices(ctx context.Context, attendee *entity.Attendee, originalChoiceStr string, configuration map[string]config.ChoiceConfig) (map[string]string, error) {
+	return make(map[string]string), nil
+}
+
+func (s *MockAttendeeService) CheckChoiceEligibility(ctx context.Context, attendee *entity.Attendee, originalChoiceStr string, newChoiceStr string, configuration map[string]config.ChoiceConfig) error {
+	return nil
+}
+
 func (s *MockAttendeeService) CanRegisterAtThisTime(ctx context.Context) error {
 	return nil
 }
@@ -95,6 +105,146 @@ func (s *MockAttendeeService) IsOwnerFor(ctx context.Context) ([]*entity.Attende
 	return make([]*entity.Attendee, 0), nil
 }
 
+func (s *MockAttendeeService) GetPersonalDataExport(ctx context.Context, attendee *entity.Attendee) (*attendeesrv.PersonalDataExport, error) {
+	return &attendeesrv.PersonalDataExport{}, nil
+}
+
+func (s *MockAttendeeService) AnonymiseAttendee(ctx context.Context, attendee *entity.Attendee) error {
+	return nil
+}
+
+func (s *MockAttendeeService) ApplyRetentionPolicy(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+func (s *MockAttendeeService) ExportAttendees(ctx context.Context, criteria *attendee.AttendeeSearchCriteria, withPayments bool, pageTimeout time.Duration, consume func(entries []*attendeesrv.AttendeeListEntry) error) error {
+	return nil
+}
+
+func (s *MockAttendeeService) ImportAttendee(ctx context.Context, attendee *entity.Attendee, adminFlags string, initialStatus string, dryRun bool) (uint, error) {
+	return 0, nil
+}
+
+func (s *MockAttendeeService) CreateAttendeeFor(ctx context.Context, attendee *entity.Attendee, adminFlags string, initialStatus string, comp *attendeesrv.Comp) (uint, string, time.Time, error) {
+	return 0, "", time.Time{}, nil
+}
+
+func (s *MockAttendeeService) GetStatistics(ctx context.Context) (*attendeesrv.Statistics, error) {
+	return &attendeesrv.Statistics{}, nil
+}
+
+func (s *MockAttendeeService) GetPublicStatistics(ctx context.Context) (*attendeesrv.Statistics, error) {
+	return &attendeesrv.Statistics{}, nil
+}
+
+func (s *MockAttendeeService) RegdeskLookup(ctx context.Context, badgeNumber uint, nickname string) ([]*attendeesrv.RegdeskInfo, error) {
+	return make([]*attendeesrv.RegdeskInfo, 0), nil
+}
+
+func (s *MockAttendeeService) CheckIn(ctx context.Context, attendee *entity.Attendee, desk string, items []string, comment string) error {
+	return nil
+}
+
+func (s *MockAttendeeService) UndoCheckIn(ctx context.Context, attendee *entity.Attendee) error {
+	return nil
+}
+
+func (s *MockAttendeeService) ExportBadges(ctx context.Context, filter string, pageTimeout time.Duration, consume func(entries []*attendeesrv.BadgeInfo) error) error {
+	return nil
+}
+
+func (s *MockAttendeeService) MarkBadgesPrinted(ctx context.Context, attendees []*entity.Attendee, reason string) error {
+	return nil
+}
+
+func (s *MockAttendeeService) CreateTransferCode(ctx context.Context, attendee *entity.Attendee, resetPersonalData bool) (string, time.Time, error) {
+	return "", time.Time{}, nil
+}
+
+func (s *MockAttendeeService) CancelTransferCode(ctx context.Context, attendee *entity.Attendee) error {
+	return nil
+}
+
+func (s *MockAttendeeService) RedeemTransferCode(ctx context.Context, code string, email string) (*entity.Attendee, error) {
+	return nil, nil
+}
+
+func (s *MockAttendeeService) TransferAttendee(ctx context.Context, attendee *entity.Attendee, newIdentity string, newEmail string, resetPersonalData bool) error {
+	return nil
+}
+
+func (s *MockAttendeeService) SendEmailVerification(ctx context.Context, attendee *entity.Attendee) error {
+	return nil
+}
+
+func (s *MockAttendeeService) VerifyEmail(ctx context.Context, token string) (*entity.Attendee, error) {
+	return nil, nil
+}
+
+func (s *MockAttendeeService) CreateGroup(ctx context.Context, owner *entity.Attendee, name string, groupType string) (*entity.Group, error) {
+	return nil, nil
+}
+
+func (s *MockAttendeeService) GetGroup(ctx context.Context, id uint) (*attendeesrv.GroupInfo, error) {
+	return nil, nil
+}
+
+func (s *MockAttendeeService) GetAllGroups(ctx context.Context) ([]*attendeesrv.GroupInfo, error) {
+	return nil, nil
+}
+
+func (s *MockAttendeeService) GetGroupsForAttendees(ctx context.Context, attendees []*entity.Attendee) ([]*attendeesrv.GroupInfo, error) {
+	return nil, nil
+}
+
+func (s *MockAttendeeService) UpdateGroup(ctx context.Context, group *entity.Group) error {
+	return nil
+}
+
+func (s *MockAttendeeService) DeleteGroup(ctx context.Context, group *entity.Group) error {
+	return nil
+}
+
+func (s *MockAttendeeService) CreateGroupInviteCode(ctx context.Context, group *entity.Group) (string, error) {
+	return "", nil
+}
+
+func (s *MockAttendeeService) JoinGroup(ctx context.Context, code string, attendee *entity.Attendee) (*entity.Group, error) {
+	return nil, nil
+}
+
+func (s *MockAttendeeService) AddGroupMember(ctx context.Context, group *entity.Group, attendee *entity.Attendee) error {
+	return nil
+}
+
+func (s *MockAttendeeService) RemoveGroupMember(ctx context.Context, group *entity.Group, attendee *entity.Attendee) error {
+	return nil
+}
+
+func (s *MockAttendeeService) UnmatchedPartnerRequests(ctx context.Context, pageTimeout time.Duration) ([]*attendeesrv.AttendeeListEntry, error) {
+	return nil, nil
+}
+
+func (s *MockAttendeeService) GetAllVouchers(ctx context.Context) ([]*attendeesrv.VoucherInfo, error) {
+	return nil, nil
+}
+
+func (s *MockAttendeeService) GetVoucher(ctx context.Context, id uint) (*attendeesrv.VoucherInfo, error) {
+	return nil, nil
+}
+
+func (s *MockAttendeeService) CreateVoucher(ctx context.Context, voucher *entity.Voucher) (uint, error) {
+	return 0, nil
+}
+
+func (s *MockAttendeeService) UpdateVoucher(ctx context.Context, voucher *entity.Voucher) error {
+	return nil
+}
+
+func (s *MockAttendeeService) CanRedeemVoucher(ctx context.Context, originalCode string, newCode string, packages string) error {
+	return nil
+}
+
 func tstSetupServiceMocks() {
 	attendeeService = &MockAttendeeService{}
 }
@@ -0,0 +1,99 @@
+package attendeectl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/validation"
+	"github.com/go-http-utils/headers"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// the statuses a registration created by an admin may start out in
+var allowedCreateForStatuses = []string{"new", "approved"}
+
+// createAttendeeForHandler lets an admin create a registration on behalf of someone else, who is
+// invited by email to claim it with their own login.
+//
+// The Location header points to the new registration, the body contains the invitation code in case
+// the email gets lost.
+func createAttendeeForHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	dto, err := parseBodyToAttendeeCreateForDto(ctx, w, r)
+	if err != nil {
+		return
+	}
+	validationErrs := validateCreateFor(ctx, dto)
+	if len(validationErrs) != 0 {
+		attendeeValidationErrorHandler(ctx, w, r, validationErrs)
+		return
+	}
+
+	var comp *attendeesrv.Comp
+	if dto.CompReason != "" {
+		comp = &attendeesrv.Comp{
+			Reason:   dto.CompReason,
+			Discount: dto.CompDiscount,
+		}
+	}
+
+	newAttendee := attendeeService.NewAttendee(ctx)
+	mapDtoToAttendee(&dto.Attendee, newAttendee)
+	id, code, expires, err := attendeeService.CreateAttendeeFor(ctx, newAttendee, dto.AdminFlags, dto.Status, comp)
+	if err != nil {
+		attendeeWriteErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	w.Header().Set(headers.Location, fmt.Sprintf("/api/rest/v1/attendees/%d", id))
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	w.WriteHeader(http.StatusCreated)
+	ctlutil.WriteJson(ctx, w, attendee.InvitationDto{
+		Code:    code,
+		Expires: expires.Format(time.RFC3339),
+	})
+}
+
+func validateCreateFor(ctx context.Context, dto *attendee.AttendeeCreateForDto) url.Values {
+	errs := validate(ctx, &dto.Attendee, &entity.Attendee{Flags: config.DefaultFlags(), Packages: config.DefaultPackages(), Options: config.DefaultOptions()})
+	// this is an admin operation, so the registration start time does not apply
+	delete(errs, "timing")
+
+	validation.CheckCombinationOfAllowedValues(&errs, config.AllowedFlagsAdminOnly(), "admin_flags", dto.AdminFlags)
+	if err := attendeeService.CanChangeChoiceTo(ctx, "", dto.AdminFlags, config.FlagsConfigAdminOnly()); err != nil {
+		errs.Add("admin_flags", err.Error())
+	}
+	if dto.Status != "" && validation.NotInAllowedValues(allowedCreateForStatuses, dto.Status) {
+		errs.Add("status", "optional status field must be one of "+strings.Join(allowedCreateForStatuses, ", ")+" or it can be left blank, which means new")
+	} else if dto.Status == "approved" && config.EmailVerificationRequiredForApproval() {
+		errs.Add("status", "the email address must be verified before approval, it counts as verified once the invitation has been redeemed")
+	}
+
+	validation.CheckLength(&errs, 0, 80, "comp_reason", dto.CompReason)
+	if dto.CompDiscount < 0 {
+		errs.Add("comp_discount", "comp_discount field must not be negative")
+	} else if dto.CompDiscount > 0 && dto.CompReason == "" {
+		errs.Add("comp_reason", "comp_reason field is required when giving a discount")
+	}
+	return errs
+}
+
+func parseBodyToAttendeeCreateForDto(ctx context.Context, w http.ResponseWriter, r *http.Request) (*attendee.AttendeeCreateForDto, error) {
+	decoder := json.NewDecoder(r.Body)
+	dto := &attendee.AttendeeCreateForDto{}
+	err := decoder.Decode(dto)
+	if err != nil {
+		attendeeParseErrorHandler(ctx, w, r, err)
+	}
+	return dto, err
+}
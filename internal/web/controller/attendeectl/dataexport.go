@@ -0,0 +1,118 @@
+package attendeectl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/dataexport"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/service/authsrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/filter"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/go-http-utils/headers"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+func getPersonalDataExportHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := ctlutil.AttendeeIdFromVars(ctx, w, r)
+	if err != nil {
+		return
+	}
+	existingAttendee, err := attendeeService.GetAttendee(ctx, id)
+	if err != nil {
+		ctlutil.AttendeeNotFoundErrorHandler(ctx, w, r, id)
+		return
+	}
+
+	if err := filter.IsSubjectOrPermissionOrApiScope(w, r, existingAttendee.Identity, authsrv.PermissionReadAll, config.ApiScopeRead); err != nil {
+		return
+	}
+
+	export, err := attendeeService.GetPersonalDataExport(ctx, existingAttendee)
+	if err != nil {
+		exportReadErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	aulogging.Logger.Ctx(ctx).Info().Printf("personal data export for attendee %d", existingAttendee.ID)
+
+	dto := dataexport.PersonalDataExportDto{}
+	mapPersonalDataExportToDto(export, &dto)
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, dto)
+}
+
+func mapPersonalDataExportToDto(export *attendeesrv.PersonalDataExport, dto *dataexport.PersonalDataExportDto) {
+	mapAttendeeToDto(export.Attendee, &dto.Attendee)
+
+	dto.StatusHistory = make([]status.StatusChangeDto, 0)
+	for _, h := range export.StatusHistory {
+		dto.StatusHistory = append(dto.StatusHistory, status.StatusChangeDto{
+			Timestamp: h.CreatedAt.Format(time.RFC3339),
+			Status:    h.Status,
+			Comment:   h.Comments,
+		})
+	}
+
+	dto.AdminFlags = export.AdminFlags
+
+	dto.AdditionalInfo = make(map[string]interface{})
+	for _, ad := range export.AdditionalInfo {
+		var value interface{}
+		if err := json.Unmarshal([]byte(ad.JsonValue), &value); err != nil {
+			// still export it, we just cannot represent it as json
+			value = ad.JsonValue
+		}
+		dto.AdditionalInfo[ad.Area] = value
+	}
+
+	dto.Transactions = make([]dataexport.TransactionDto, 0)
+	for _, tx := range export.Transactions {
+		dto.Transactions = append(dto.Transactions, mapTransactionToDto(tx))
+	}
+}
+
+var (
+	transactionTypeNames   = map[paymentservice.TransactionType]string{paymentservice.Due: "due", paymentservice.Payment: "payment"}
+	paymentMethodNames     = map[paymentservice.PaymentMethod]string{paymentservice.Credit: "credit", paymentservice.Paypal: "paypal", paymentservice.Transfer: "transfer", paymentservice.Internal: "internal", paymentservice.Gift: "gift"}
+	transactionStatusNames = map[paymentservice.TransactionStatus]string{paymentservice.Pending: "pending", paymentservice.Tentative: "tentative", paymentservice.Valid: "valid", paymentservice.Deleted: "deleted"}
+)
+
+func mapTransactionToDto(tx paymentservice.Transaction) dataexport.TransactionDto {
+	dueDate := ""
+	if !tx.DueDate.IsZero() {
+		dueDate = tx.DueDate.Format(config.IsoDateFormat)
+	}
+	return dataexport.TransactionDto{
+		Id:     tx.ID,
+		Type:   transactionTypeNames[tx.Type],
+		Method: paymentMethodNames[tx.Method],
+		Amount: dataexport.AmountDto{
+			Currency:  tx.Amount.Currency,
+			GrossCent: tx.Amount.GrossCent,
+			VatRate:   tx.Amount.VatRate,
+		},
+		Comment:       tx.Comment,
+		Status:        transactionStatusNames[tx.Status],
+		EffectiveDate: tx.EffectiveDate,
+		DueDate:       dueDate,
+	}
+}
+
+func exportReadErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("could not collect personal data export: %s", err.Error())
+	if errors.Is(err, paymentservice.DownstreamError) {
+		ctlutil.ErrorHandler(ctx, w, r, "export.payment.error", http.StatusBadGateway, url.Values{})
+	} else {
+		ctlutil.ErrorHandler(ctx, w, r, "export.read.error", http.StatusInternalServerError, url.Values{})
+	}
+}
@@ -0,0 +1,72 @@
+package attendeectl
+
+import (
+	"context"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/service/authsrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/filter"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/go-http-utils/headers"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// choiceEligibilityHandler tells the frontend which choices the attendee described by the body is not eligible for,
+// so it can grey them out.
+//
+// For an existing registration, the id must be set, and the current status and choices are taken into account.
+func choiceEligibilityHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	dto, err := parseBodyToAttendeeDto(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	original := &entity.Attendee{Flags: config.DefaultFlags(), Packages: config.DefaultPackages(), Options: config.DefaultOptions()}
+	if dto.Id != "" {
+		id, err := strconv.ParseUint(dto.Id, 10, 32)
+		if err != nil {
+			ctlutil.InvalidAttendeeIdErrorHandler(ctx, w, r, dto.Id)
+			return
+		}
+		original, err = attendeeService.GetAttendee(ctx, uint(id))
+		if err != nil {
+			ctlutil.AttendeeNotFoundErrorHandler(ctx, w, r, uint(id))
+			return
+		}
+		if err := filter.IsSubjectOrPermissionOrApiScope(w, r, original.Identity, authsrv.PermissionReadAll, config.ApiScopeAll); err != nil {
+			return
+		}
+	}
+
+	eligibilityState := &entity.Attendee{Birthday: dto.Birthday, Country: dto.Country}
+	eligibilityState.ID = original.ID
+
+	result := attendee.ChoiceEligibilityDto{}
+	if result.Flags, err = attendeeService.IneligibleChoices(ctx, eligibilityState, original.Flags, config.FlagsConfigNoAdmin()); err != nil {
+		eligibilityErrorHandler(ctx, w, r, err)
+		return
+	}
+	if result.Packages, err = attendeeService.IneligibleChoices(ctx, eligibilityState, original.Packages, config.PackagesConfig()); err != nil {
+		eligibilityErrorHandler(ctx, w, r, err)
+		return
+	}
+	if result.Options, err = attendeeService.IneligibleChoices(ctx, eligibilityState, original.Options, config.OptionsConfig()); err != nil {
+		eligibilityErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, result)
+}
+
+func eligibilityErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("could not determine choice eligibility: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "attendee.eligibility.error", http.StatusInternalServerError, url.Values{})
+}
@@ -0,0 +1,100 @@
+package attendeectl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/mailservice"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/service/authsrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/filter"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/validation"
+	"github.com/go-http-utils/headers"
+	"net/http"
+	"net/url"
+)
+
+// verifyEmailHandler confirms an email address using the token from the verification email.
+//
+// The Location header points to the registration.
+func verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	dto := &attendee.EmailVerificationDto{}
+	if err := json.NewDecoder(r.Body).Decode(dto); err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("email verification body could not be parsed: %s", err.Error())
+		ctlutil.ErrorHandler(ctx, w, r, "verification.parse.error", http.StatusBadRequest, url.Values{})
+		return
+	}
+	errs := url.Values{}
+	validation.CheckLength(&errs, 1, 256, "token", dto.Token)
+	if len(errs) != 0 {
+		aulogging.Logger.Ctx(ctx).Warn().Printf("received email verification with validation errors: %v", errs)
+		ctlutil.ErrorHandler(ctx, w, r, "verification.data.invalid", http.StatusBadRequest, errs)
+		return
+	}
+
+	att, err := attendeeService.VerifyEmail(ctx, dto.Token)
+	if err != nil {
+		emailVerificationErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.Header().Set(headers.Location, fmt.Sprintf("/api/rest/v1/attendees/%d", att.ID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resendEmailVerificationHandler sends another verification email, e.g. because the token has expired.
+func resendEmailVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := idFromVars(ctx, w, r)
+	if err != nil {
+		return
+	}
+	att, err := attendeeService.GetAttendee(ctx, id)
+	if err != nil {
+		ctlutil.AttendeeNotFoundErrorHandler(ctx, w, r, id)
+		return
+	}
+	if err := filter.IsSubjectOrPermissionOrApiScope(w, r, att.Identity, authsrv.PermissionAdmin, config.ApiScopeWrite); err != nil {
+		return
+	}
+
+	if err := attendeeService.SendEmailVerification(ctx, att); err != nil {
+		emailVerificationErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func emailVerificationErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, attendeesrv.EmailVerificationDisabledError) {
+		aulogging.Logger.Ctx(ctx).Warn().Printf("email verification attempted but not enabled")
+		ctlutil.ErrorHandler(ctx, w, r, "verification.disabled", http.StatusNotFound, url.Values{})
+		return
+	}
+	if errors.Is(err, attendeesrv.EmailVerificationTokenInvalidError) {
+		// no details, so tokens cannot be probed
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("invalid email verification token: %s", err.Error())
+		ctlutil.ErrorHandler(ctx, w, r, "verification.token.invalid", http.StatusBadRequest, url.Values{})
+		return
+	}
+	if errors.Is(err, attendeesrv.EmailAlreadyVerifiedError) {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("no need to resend verification email: %s", err.Error())
+		ctlutil.ErrorHandler(ctx, w, r, "verification.already.done", http.StatusConflict, url.Values{"details": []string{err.Error()}})
+		return
+	}
+	if errors.Is(err, mailservice.DownstreamError) {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("could not send verification email: %s", err.Error())
+		ctlutil.ErrorHandler(ctx, w, r, "verification.mail.error", http.StatusBadGateway, url.Values{"details": []string{err.Error()}})
+		return
+	}
+
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("could not write email verification: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "verification.write.error", http.StatusInternalServerError, url.Values{})
+}
@@ -0,0 +1,299 @@
+package attendeectl
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/mailservice"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/validation"
+	"github.com/go-http-utils/headers"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// each row of an import must complete within this time, the import as a whole within importTimeout
+const (
+	importRowTimeout = 3 * time.Second
+	importTimeout    = 5 * time.Minute
+)
+
+// about 10000 rows, import larger files in several parts
+const importMaxBodyBytes = 2 << 20
+
+// the statuses an imported registration may start out in
+var allowedImportStatuses = []string{"new", "approved", "cancelled"}
+
+// importRow is a parsed row of an import file together with the extra columns not present in the AttendeeDto
+type importRow struct {
+	row        int
+	dto        attendee.AttendeeDto
+	status     string
+	adminFlags string
+}
+
+func importAttendeesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), importTimeout)
+	defer cancel()
+	// the server write timeout only fits normal requests
+	ctlutil.ExtendWriteDeadline(ctx, w, importTimeout+10*time.Second)
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	rows, err := parseBodyToImportRows(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	validationErrs := validateImportRows(ctx, rows)
+	if len(validationErrs) != 0 {
+		importValidationErrorHandler(ctx, w, r, validationErrs)
+		return
+	}
+
+	result := attendee.AttendeeImportResultDto{
+		DryRun: dryRun,
+		Rows:   make([]attendee.AttendeeImportRowResult, 0),
+	}
+	if !dryRun {
+		for _, row := range rows {
+			id, err := importSingleRow(ctx, row)
+			if err != nil {
+				importWriteErrorHandler(ctx, w, r, row, result, err)
+				return
+			}
+			result.Rows = append(result.Rows, attendee.AttendeeImportRowResult{Row: row.row, Id: fmt.Sprint(id)})
+		}
+	} else {
+		for _, row := range rows {
+			result.Rows = append(result.Rows, attendee.AttendeeImportRowResult{Row: row.row})
+		}
+	}
+	result.Count = len(result.Rows)
+
+	aulogging.Logger.Ctx(ctx).Info().Printf("import of %d attendees successful (dry run: %t)", result.Count, dryRun)
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, result)
+}
+
+func importSingleRow(ctx context.Context, row *importRow) (uint, error) {
+	rowCtx, cancel := context.WithTimeout(ctx, importRowTimeout)
+	defer cancel()
+
+	newAttendee := attendeeService.NewAttendee(rowCtx)
+	mapDtoToAttendee(&row.dto, newAttendee)
+	return attendeeService.ImportAttendee(rowCtx, newAttendee, row.adminFlags, row.status, false)
+}
+
+// validateImportRows checks all rows before anything is written, so an import either fails up front or
+// (barring technical errors) succeeds as a whole.
+//
+// Error keys are prefixed with the row number, e.g. row-3.email.
+func validateImportRows(ctx context.Context, rows []*importRow) url.Values {
+	errs := url.Values{}
+	seen := make(map[string]int)
+
+	for _, row := range rows {
+		prefix := fmt.Sprintf("row-%d.", row.row)
+
+		rowErrs := validate(ctx, &row.dto, &entity.Attendee{Flags: config.DefaultFlags(), Packages: config.DefaultPackages(), Options: config.DefaultOptions()})
+		// imports are an admin operation, so the registration start time does not apply
+		delete(rowErrs, "timing")
+
+		validation.CheckCombinationOfAllowedValues(&rowErrs, config.AllowedFlagsAdminOnly(), "admin_flags", row.adminFlags)
+		if err := attendeeService.CanChangeChoiceTo(ctx, "", row.adminFlags, config.FlagsConfigAdminOnly()); err != nil {
+			rowErrs.Add("admin_flags", err.Error())
+		}
+		if row.status != "" && validation.NotInAllowedValues(allowedImportStatuses, row.status) {
+			rowErrs.Add("status", "optional status field must be one of "+strings.Join(allowedImportStatuses, ", ")+" or it can be left blank, which means new")
+		} else if row.status == "approved" && config.EmailVerificationRequiredForApproval() {
+			// otherwise the import would fail halfway through when setting the status
+			rowErrs.Add("status", "the email address must be verified before approval, which is not possible for imported registrations")
+		}
+
+		key := strings.Join([]string{row.dto.Nickname, row.dto.Zip, row.dto.Email}, "\n")
+		if otherRow, ok := seen[key]; ok {
+			rowErrs.Add("attendee", fmt.Sprintf("same nickname, zip code and email as row %d", otherRow))
+		} else {
+			seen[key] = row.row
+			if err := checkImportDuplicate(ctx, row); err != nil {
+				if errors.Is(err, attendeesrv.DuplicateAttendeeError) {
+					rowErrs.Add("attendee", "there is already an attendee with this information (looking at nickname, email, and zip code)")
+				} else {
+					rowErrs.Add("attendee", "could not check for duplicates: "+err.Error())
+				}
+			}
+		}
+
+		for k, v := range rowErrs {
+			errs[prefix+k] = v
+		}
+	}
+	return errs
+}
+
+func checkImportDuplicate(ctx context.Context, row *importRow) error {
+	rowCtx, cancel := context.WithTimeout(ctx, importRowTimeout)
+	defer cancel()
+
+	a := &entity.Attendee{}
+	mapDtoToAttendee(&row.dto, a)
+	_, err := attendeeService.ImportAttendee(rowCtx, a, row.adminFlags, row.status, true)
+	return err
+}
+
+func parseBodyToImportRows(ctx context.Context, w http.ResponseWriter, r *http.Request) ([]*importRow, error) {
+	rows, err := parseImportCsv(http.MaxBytesReader(w, r.Body, importMaxBodyBytes))
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("import body could not be parsed: %s", err.Error())
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			ctlutil.ErrorHandler(ctx, w, r, "import.parse.error", http.StatusRequestEntityTooLarge, url.Values{"details": {fmt.Sprintf("import file must not be larger than %d bytes", importMaxBodyBytes)}})
+		} else {
+			ctlutil.ErrorHandler(ctx, w, r, "import.parse.error", http.StatusBadRequest, url.Values{"details": {err.Error()}})
+		}
+	}
+	return rows, err
+}
+
+// parseImportCsv reads a csv file whose header row contains the json field names of AttendeeDto,
+// plus the optional columns status and admin_flags.
+//
+// If the flags, packages or options columns are missing, the configured defaults are used.
+func parseImportCsv(body io.Reader) ([]*importRow, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, errors.New("import file is empty")
+		}
+		return nil, err
+	}
+	for i, column := range header {
+		header[i] = strings.TrimSpace(column)
+		if validation.NotInAllowedValues(allowedImportColumns, header[i]) {
+			return nil, fmt.Errorf("invalid column %s, must be one of %s", header[i], strings.Join(allowedImportColumns, ", "))
+		}
+	}
+
+	result := make([]*importRow, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		row := &importRow{
+			row: len(result) + 2,
+			dto: attendee.AttendeeDto{
+				Flags:    config.DefaultFlags(),
+				Packages: config.DefaultPackages(),
+				Options:  config.DefaultOptions(),
+			},
+		}
+		for i, value := range record {
+			setImportField(row, header[i], value)
+		}
+		result = append(result, row)
+	}
+	if len(result) == 0 {
+		return nil, errors.New("import file contains no attendees")
+	}
+	return result, nil
+}
+
+var allowedImportColumns = []string{
+	"nickname", "first_name", "last_name", "street", "zip", "city", "country", "country_badge", "state",
+	"email", "phone", "telegram", "partner", "birthday", "gender", "pronouns", "tshirt_size",
+	"flags", "options", "packages", "user_comments",
+	"status", "admin_flags",
+}
+
+func setImportField(row *importRow, column string, value string) {
+	dto := &row.dto
+	switch column {
+	case "nickname":
+		dto.Nickname = value
+	case "first_name":
+		dto.FirstName = value
+	case "last_name":
+		dto.LastName = value
+	case "street":
+		dto.Street = value
+	case "zip":
+		dto.Zip = value
+	case "city":
+		dto.City = value
+	case "country":
+		dto.Country = value
+	case "country_badge":
+		dto.CountryBadge = value
+	case "state":
+		dto.State = value
+	case "email":
+		dto.Email = value
+	case "phone":
+		dto.Phone = value
+	case "telegram":
+		dto.Telegram = value
+	case "partner":
+		dto.Partner = value
+	case "birthday":
+		dto.Birthday = value
+	case "gender":
+		dto.Gender = value
+	case "pronouns":
+		dto.Pronouns = value
+	case "tshirt_size":
+		dto.TshirtSize = value
+	case "flags":
+		dto.Flags = value
+	case "options":
+		dto.Options = value
+	case "packages":
+		dto.Packages = value
+	case "user_comments":
+		dto.UserComments = value
+	case "status":
+		row.status = value
+	case "admin_flags":
+		row.adminFlags = value
+	}
+}
+
+func importValidationErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, errs url.Values) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received import with validation errors: %v", errs)
+	ctlutil.ErrorHandler(ctx, w, r, "import.data.invalid", http.StatusBadRequest, errs)
+}
+
+func importWriteErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, row *importRow, partial attendee.AttendeeImportResultDto, err error) {
+	aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("import failed at row %d after %d attendees: %s", row.row, len(partial.Rows), err.Error())
+
+	// tell the caller which rows already made it, so they can fix the file and retry with the rest
+	details := url.Values{}
+	details.Add("failed_row", fmt.Sprint(row.row))
+	for _, done := range partial.Rows {
+		details.Add("imported", fmt.Sprintf("row-%d:%s", done.Row, done.Id))
+	}
+
+	if errors.Is(err, paymentservice.DownstreamError) || errors.Is(err, mailservice.DownstreamError) {
+		ctlutil.ErrorHandler(ctx, w, r, "import.downstream.error", http.StatusBadGateway, details)
+	} else {
+		ctlutil.ErrorHandler(ctx, w, r, "import.write.error", http.StatusInternalServerError, details)
+	}
+}
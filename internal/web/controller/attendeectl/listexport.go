@@ -0,0 +1,234 @@
+package attendeectl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/spreadsheet"
+	"github.com/go-http-utils/headers"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// each page of an export must be read within this time, the export as a whole may take longer.
+//
+// With payment columns, every attendee on a page needs a call to the payment service, so the pages
+// are smaller (see attendeesrv), and they get more time.
+const (
+	exportPageTimeout        = 3 * time.Second
+	exportPaymentPageTimeout = 30 * time.Second
+)
+
+// the time allowed for writing a page to the client, in addition to the time for reading it
+const exportPageWriteTimeout = 10 * time.Second
+
+var defaultExportColumns = []string{"id", "nickname", "first_name", "last_name", "email", "country", "flags", "packages", "options", "status"}
+
+// the columns that need the payment service
+var paymentExportColumns = []string{"total_dues", "payment_balance", "current_dues"}
+
+func exportAttendeeListHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	dto, err := parseBodyToListExportRequest(ctx, w, r)
+	if err != nil {
+		return
+	}
+	if dto.Format == "" {
+		dto.Format = spreadsheet.FormatCsv
+	}
+	if len(dto.Columns) == 0 {
+		dto.Columns = defaultExportColumns
+	}
+
+	validationErrs := validateListExportRequest(ctx, dto)
+	if len(validationErrs) != 0 {
+		listExportValidationErrorHandler(ctx, w, r, validationErrs)
+		return
+	}
+
+	withPayments := false
+	for _, c := range dto.Columns {
+		withPayments = withPayments || contains(paymentExportColumns, c)
+	}
+	pageTimeout := exportPageTimeout
+	if withPayments {
+		pageTimeout = exportPaymentPageTimeout
+	}
+
+	// the server write timeout only fits normal requests, so each page extends the deadline for the next one
+	ctlutil.ExtendWriteDeadline(ctx, w, pageTimeout+exportPageWriteTimeout)
+
+	// we only start writing the response once the first page was read successfully,
+	// so we can still send a proper error response for the most common failures
+	var sw spreadsheet.Writer
+	start := func() error {
+		w.Header().Set(headers.ContentType, spreadsheet.ContentType(dto.Format))
+		w.Header().Set(headers.ContentDisposition, `attachment; filename="attendees.`+dto.Format+`"`)
+		w.WriteHeader(http.StatusOK)
+		sw, err = spreadsheet.New(dto.Format, w)
+		if err != nil {
+			return err
+		}
+		return writeExportRow(w, sw, stringsToCells(dto.Columns))
+	}
+
+	count := 0
+	err = attendeeService.ExportAttendees(ctx, &dto.Criteria, withPayments, pageTimeout, func(entries []*attendeesrv.AttendeeListEntry) error {
+		if sw == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		for _, e := range entries {
+			if err := writeExportRow(w, sw, exportCells(dto.Columns, e)); err != nil {
+				return err
+			}
+		}
+		count += len(entries)
+		ctlutil.ExtendWriteDeadline(ctx, w, pageTimeout+exportPageWriteTimeout)
+		return nil
+	})
+	if err != nil {
+		if sw == nil {
+			exportReadErrorHandler(ctx, w, r, err)
+		} else {
+			// too late for an error response, the client will receive an incomplete file
+			aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("attendee list export aborted after %d attendees: %s", count, err.Error())
+		}
+		return
+	}
+
+	if sw == nil {
+		// no matches, still send the header row
+		if err := start(); err != nil {
+			aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("failed to write empty attendee list export: %s", err.Error())
+			return
+		}
+	}
+	if err := sw.Close(); err != nil {
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("failed to complete attendee list export: %s", err.Error())
+		return
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("exported %d attendees as %s", count, dto.Format)
+}
+
+func writeExportRow(w http.ResponseWriter, sw spreadsheet.Writer, cells []interface{}) error {
+	if err := sw.WriteRow(cells); err != nil {
+		return err
+	}
+	if err := http.NewResponseController(w).Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+func stringsToCells(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, v := range values {
+		result[i] = v
+	}
+	return result
+}
+
+func exportCells(columns []string, e *attendeesrv.AttendeeListEntry) []interface{} {
+	result := make([]interface{}, len(columns))
+	for i, c := range columns {
+		result[i] = exportCell(c, e)
+	}
+	return result
+}
+
+func exportCell(column string, e *attendeesrv.AttendeeListEntry) interface{} {
+	a := e.Attendee
+	switch column {
+	case "id":
+		return int64(a.ID)
+	case "nickname":
+		return a.Nickname
+	case "first_name":
+		return a.FirstName
+	case "last_name":
+		return a.LastName
+	case "street":
+		return a.Street
+	case "zip":
+		return a.Zip
+	case "city":
+		return a.City
+	case "country":
+		return a.Country
+	case "country_badge":
+		return a.CountryBadge
+	case "state":
+		return a.State
+	case "email":
+		return a.Email
+	case "phone":
+		return a.Phone
+	case "telegram":
+		return a.Telegram
+	case "partner":
+		return a.Partner
+	case "birthday":
+		return a.Birthday
+	case "gender":
+		return a.Gender
+	case "pronouns":
+		return a.Pronouns
+	case "tshirt_size":
+		return a.TshirtSize
+	case "flags":
+		return a.Flags
+	case "options":
+		return a.Options
+	case "packages":
+		return a.Packages
+	case "user_comments":
+		return a.UserComments
+	case "status":
+		return e.Status
+	case "total_dues":
+		return centsToAmount(e.TotalDues)
+	case "payment_balance":
+		return centsToAmount(e.PaymentBalance)
+	case "current_dues":
+		return centsToAmount(e.TotalDues - e.PaymentBalance)
+	default:
+		return ""
+	}
+}
+
+func centsToAmount(cents int64) float64 {
+	return float64(cents) / 100.0
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func parseBodyToListExportRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) (*attendee.AttendeeListExportRequest, error) {
+	decoder := json.NewDecoder(r.Body)
+	dto := &attendee.AttendeeListExportRequest{}
+	err := decoder.Decode(dto)
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("export request body could not be parsed: %s", err.Error())
+		ctlutil.ErrorHandler(ctx, w, r, "export.parse.error", http.StatusBadRequest, url.Values{})
+	}
+	return dto, err
+}
+
+func listExportValidationErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, errs url.Values) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received export request with validation errors: %v", errs)
+	ctlutil.ErrorHandler(ctx, w, r, "export.data.invalid", http.StatusBadRequest, errs)
+}
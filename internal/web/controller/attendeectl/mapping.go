@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"strings"
 )
 
 func mapDtoToAttendee(dto *attendee.AttendeeDto, a *entity.Attendee) {
@@ -29,6 +30,8 @@ func mapDtoToAttendee(dto *attendee.AttendeeDto, a *entity.Attendee) {
 	a.Packages = dto.Packages
 	a.Options = dto.Options
 	a.UserComments = dto.UserComments
+	a.Voucher = strings.ToUpper(dto.Voucher)
+	a.Language = dto.Language
 }
 
 func mapAttendeeToDto(a *entity.Attendee, dto *attendee.AttendeeDto) {
@@ -55,4 +58,7 @@ func mapAttendeeToDto(a *entity.Attendee, dto *attendee.AttendeeDto) {
 	dto.Packages = a.Packages
 	dto.Options = a.Options
 	dto.UserComments = a.UserComments
+	dto.Voucher = a.Voucher
+	dto.Language = a.Language
+	dto.EmailVerified = a.EmailVerified
 }
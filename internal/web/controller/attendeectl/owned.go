@@ -0,0 +1,86 @@
+package attendeectl
+
+import (
+	"context"
+	"errors"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/go-http-utils/headers"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+// listMyRegistrationsHandler returns the badge numbers of the registrations owned by the logged in user.
+func listMyRegistrationsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	owned, err := ownedRegistrationsMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	dto := attendee.AttendeeIdList{
+		Ids: make([]int64, 0, len(owned)),
+	}
+	for _, a := range owned {
+		dto.Ids = append(dto.Ids, int64(a.ID))
+	}
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, dto)
+}
+
+// getMyRegistrationsHandler returns the registrations owned by the logged in user, with their current status.
+func getMyRegistrationsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	owned, err := ownedRegistrationsMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	dto := attendee.AttendeeWithStatusList{
+		Attendees: make([]attendee.AttendeeWithStatusDto, 0, len(owned)),
+	}
+	for _, a := range owned {
+		history, err := attendeeService.GetFullStatusHistory(ctx, a)
+		if err != nil {
+			ownedReadErrorHandler(ctx, w, r, err)
+			return
+		}
+		entry := attendee.AttendeeWithStatusDto{
+			Status: history[len(history)-1].Status,
+		}
+		mapAttendeeToDto(a, &entry.Attendee)
+		dto.Attendees = append(dto.Attendees, entry)
+	}
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, dto)
+}
+
+// ownedRegistrationsMustReturnOnError returns the owned registrations in the order of their badge numbers.
+func ownedRegistrationsMustReturnOnError(ctx context.Context, w http.ResponseWriter, r *http.Request) ([]*entity.Attendee, error) {
+	owned, err := attendeeService.IsOwnerFor(ctx)
+	if err != nil {
+		ownedReadErrorHandler(ctx, w, r, err)
+		return nil, err
+	}
+	if len(owned) == 0 {
+		aulogging.Logger.Ctx(ctx).Info().Printf("no registrations owned by %s", ctxvalues.Subject(ctx))
+		ctlutil.ErrorHandler(ctx, w, r, "attendee.owned.notfound", http.StatusNotFound, url.Values{})
+		return nil, errors.New("no owned registrations")
+	}
+	sort.Slice(owned, func(i, j int) bool {
+		return owned[i].ID < owned[j].ID
+	})
+	return owned, nil
+}
+
+func ownedReadErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("could not read owned registrations: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "attendee.owned.error", http.StatusInternalServerError, url.Values{})
+}
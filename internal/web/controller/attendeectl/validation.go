@@ -94,6 +94,9 @@ func validate(ctx context.Context, a *attendee.AttendeeDto, trustedOriginalState
 	validation.CheckCombinationOfAllowedValues(&errs, config.AllowedFlagsNoAdmin(), "flags", a.Flags)
 	validation.CheckCombinationOfAllowedValues(&errs, config.AllowedPackages(), "packages", a.Packages)
 	validation.CheckCombinationOfAllowedValues(&errs, config.AllowedOptions(), "options", a.Options)
+	if a.Language != "" && validation.NotInAllowedValues(config.MailLanguages(), a.Language) {
+		errs.Add("language", "optional language field must be one of "+strings.Join(config.MailLanguages(), ", ")+", or it can be left blank, which means the default language")
+	}
 	if a.TshirtSize != "" && validation.NotInAllowedValues(config.AllowedTshirtSizes(), a.TshirtSize) {
 		errs.Add("tshirt_size", "optional tshirt_size field must be empty or one of "+strings.Join(config.AllowedTshirtSizes(), ","))
 	}
@@ -109,6 +112,24 @@ func validate(ctx context.Context, a *attendee.AttendeeDto, trustedOriginalState
 		errs.Add("options", err.Error())
 	}
 
+	// check eligibility for flags, packages, options based on the new values of birthday and country
+	eligibilityState := &entity.Attendee{Birthday: a.Birthday, Country: a.Country}
+	eligibilityState.ID = trustedOriginalState.ID
+	if err := attendeeService.CheckChoiceEligibility(ctx, eligibilityState, trustedOriginalState.Flags, a.Flags, config.FlagsConfigNoAdmin()); err != nil {
+		errs.Add("flags", err.Error())
+	}
+	if err := attendeeService.CheckChoiceEligibility(ctx, eligibilityState, trustedOriginalState.Packages, a.Packages, config.PackagesConfig()); err != nil {
+		errs.Add("packages", err.Error())
+	}
+	if err := attendeeService.CheckChoiceEligibility(ctx, eligibilityState, trustedOriginalState.Options, a.Options, config.OptionsConfig()); err != nil {
+		errs.Add("options", err.Error())
+	}
+
+	validation.CheckLength(&errs, 0, 32, "voucher", a.Voucher)
+	if err := attendeeService.CanRedeemVoucher(ctx, trustedOriginalState.Voucher, strings.ToUpper(a.Voucher), a.Packages); err != nil {
+		errs.Add("voucher", err.Error())
+	}
+
 	if err := attendeeService.CanRegisterAtThisTime(ctx); err != nil {
 		errs.Add("timing", err.Error())
 	}
@@ -123,3 +144,30 @@ func validate(ctx context.Context, a *attendee.AttendeeDto, trustedOriginalState
 	}
 	return errs
 }
+
+var allowedExportColumns = []string{
+	"id", "nickname", "first_name", "last_name", "street", "zip", "city", "country", "country_badge", "state",
+	"email", "phone", "telegram", "partner", "birthday", "gender", "pronouns", "tshirt_size",
+	"flags", "options", "packages", "user_comments",
+	"status", "total_dues", "payment_balance", "current_dues",
+}
+
+var allowedExportFormats = []string{"csv", "xlsx"}
+
+func validateListExportRequest(ctx context.Context, dto *attendee.AttendeeListExportRequest) url.Values {
+	errs := url.Values{}
+
+	if validation.NotInAllowedValues(allowedExportFormats, dto.Format) {
+		errs.Add("format", "format field must be one of "+strings.Join(allowedExportFormats, ", ")+" or it can be left blank, which means csv")
+	}
+	for _, c := range dto.Columns {
+		if validation.NotInAllowedValues(allowedExportColumns, c) {
+			errs.Add("columns", "invalid column "+c+", must be one of "+strings.Join(allowedExportColumns, ", "))
+		}
+	}
+	if (dto.Criteria.SortBy != "" && dto.Criteria.SortBy != "id") || (dto.Criteria.SortOrder != "" && dto.Criteria.SortOrder != "ascending") {
+		errs.Add("criteria", "exports are always sorted by badge number, please leave sort_by and sort_order blank")
+	}
+
+	return errs
+}
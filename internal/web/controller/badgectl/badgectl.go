@@ -0,0 +1,263 @@
+package badgectl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/badges"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/service/authsrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/filter"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/spreadsheet"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/validation"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-http-utils/headers"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var attendeeService attendeesrv.AttendeeService
+
+// TODO we should not wire this up here
+func init() {
+	attendeeService = &attendeesrv.AttendeeServiceImplData{}
+}
+
+// use only for testing
+func OverrideAttendeeService(overrideAttendeeServiceForTesting attendeesrv.AttendeeService) {
+	attendeeService = overrideAttendeeServiceForTesting
+}
+
+const formatJson = "json"
+
+// each page of an export must complete within this time
+const exportPageTimeout = 3 * time.Second
+
+// the export is read completely before responding, this limits the whole request
+const exportTimeout = 60 * time.Second
+
+// the time allowed for writing the export to the client, in addition to the time for reading it
+const exportWriteTimeout = 10 * time.Second
+
+var csvColumns = []string{"id", "nickname", "country_badge", "sponsor_tier", "markers", "status", "print_count", "last_printed", "changed_since_print"}
+
+func Create(server chi.Router) {
+	server.Get("/api/rest/v1/badges", filter.HasPermission(authsrv.PermissionRegdesk, config.ApiScopeRead, filter.WithTimeout(exportTimeout, exportBadgesHandler)))
+	server.Post("/api/rest/v1/badges/printed", filter.HasPermission(authsrv.PermissionRegdesk, config.ApiScopeBadges, filter.WithTimeout(10*time.Second, badgesPrintedHandler)))
+}
+
+// --- handlers ---
+
+// exportBadgesHandler lists the badge data of all attendees in status paid or checked in.
+//
+// Query parameters are filter (all, not_printed, changed, queue) and format (json, csv).
+func exportBadgesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	badgeFilter, format, errs := parseExportParams(r.URL.Query())
+	if len(errs) != 0 {
+		exportValidationErrorHandler(ctx, w, r, errs)
+		return
+	}
+
+	// the server write timeout only fits normal requests
+	ctlutil.ExtendWriteDeadline(ctx, w, exportTimeout+exportWriteTimeout)
+
+	// always read everything before responding, badge exports are small enough,
+	// and this way we can still send a proper error response
+	result := make([]badges.BadgeDto, 0)
+	err := attendeeService.ExportBadges(ctx, badgeFilter, exportPageTimeout, func(entries []*attendeesrv.BadgeInfo) error {
+		for _, e := range entries {
+			result = append(result, mapBadgeInfoToDto(e))
+		}
+		return nil
+	})
+	if err != nil {
+		badgeReadErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	if format == formatJson {
+		w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+		ctlutil.WriteJson(ctx, w, badges.BadgeListDto{Badges: result})
+		return
+	}
+
+	w.Header().Set(headers.ContentType, spreadsheet.ContentType(format))
+	w.Header().Set(headers.ContentDisposition, `attachment; filename="badges.`+format+`"`)
+	w.WriteHeader(http.StatusOK)
+	if err := writeSpreadsheet(w, format, result); err != nil {
+		// too late for an error response, the client will receive an incomplete file
+		aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("badge export aborted: %s", err.Error())
+		return
+	}
+	aulogging.Logger.Ctx(ctx).Info().Printf("exported %d badges as %s", len(result), format)
+}
+
+func badgesPrintedHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	dto, err := parseBodyToBadgesPrintedDto(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	validationErrs := validate(ctx, dto)
+	if len(validationErrs) != 0 {
+		badgesPrintedValidationErrorHandler(ctx, w, r, validationErrs)
+		return
+	}
+
+	attendees := make([]*entity.Attendee, 0, len(dto.Ids))
+	for _, id := range dto.Ids {
+		attendee, err := attendeeService.GetAttendee(ctx, id)
+		if err != nil {
+			ctlutil.AttendeeNotFoundErrorHandler(ctx, w, r, id)
+			return
+		}
+		attendees = append(attendees, attendee)
+	}
+
+	if err := attendeeService.MarkBadgesPrinted(ctx, attendees, dto.Reason); err != nil {
+		badgesPrintedErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- error handlers ---
+
+func exportValidationErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, errs url.Values) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received badge export request with validation errors: %v", errs)
+	ctlutil.ErrorHandler(ctx, w, r, "badge.query.invalid", http.StatusBadRequest, errs)
+}
+
+func badgeReadErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("could not read badge data: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "badge.read.error", http.StatusInternalServerError, url.Values{})
+}
+
+func badgesPrintedParseErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("badges printed body could not be parsed: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "badge.parse.error", http.StatusBadRequest, url.Values{})
+}
+
+func badgesPrintedValidationErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, errs url.Values) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received badges printed data with validation errors: %v", errs)
+	ctlutil.ErrorHandler(ctx, w, r, "badge.data.invalid", http.StatusBadRequest, errs)
+}
+
+func badgesPrintedErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	message := ""
+	if errors.Is(err, attendeesrv.BadgeNotPrintableError) {
+		message = "badge.not.printable"
+	} else if errors.Is(err, attendeesrv.ReprintReasonMissingError) {
+		message = "badge.reprint.reason"
+	}
+	if message != "" {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("badges could not be marked as printed: %s - %s", message, err.Error())
+		ctlutil.ErrorHandler(ctx, w, r, message, http.StatusConflict, url.Values{"details": []string{err.Error()}})
+		return
+	}
+
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("could not write badge prints: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "badge.write.error", http.StatusInternalServerError, url.Values{})
+}
+
+// --- helpers ---
+
+func parseExportParams(query url.Values) (string, string, url.Values) {
+	errs := url.Values{}
+
+	badgeFilter := strings.TrimSpace(query.Get("filter"))
+	if badgeFilter == "" {
+		badgeFilter = attendeesrv.BadgeFilterAll
+	}
+	allowedFilters := []string{attendeesrv.BadgeFilterAll, attendeesrv.BadgeFilterNotPrinted, attendeesrv.BadgeFilterChanged, attendeesrv.BadgeFilterQueue}
+	if validation.NotInAllowedValues(allowedFilters, badgeFilter) {
+		errs.Add("filter", "filter must be one of "+strings.Join(allowedFilters, ","))
+	}
+
+	format := strings.TrimSpace(query.Get("format"))
+	if format == "" {
+		format = formatJson
+	}
+	if format != formatJson && format != spreadsheet.FormatCsv {
+		errs.Add("format", "format must be one of json,csv")
+	}
+	return badgeFilter, format, errs
+}
+
+func writeSpreadsheet(w http.ResponseWriter, format string, result []badges.BadgeDto) error {
+	sw, err := spreadsheet.New(format, w)
+	if err != nil {
+		return err
+	}
+	header := make([]interface{}, len(csvColumns))
+	for i, c := range csvColumns {
+		header[i] = c
+	}
+	if err := sw.WriteRow(header); err != nil {
+		return err
+	}
+	for _, b := range result {
+		row := []interface{}{
+			b.Id,
+			b.Nickname,
+			b.CountryBadge,
+			b.SponsorTier,
+			strings.Join(b.Markers, ","),
+			b.Status,
+			int64(b.PrintCount),
+			b.LastPrinted,
+			b.ChangedSincePrint,
+		}
+		if err := sw.WriteRow(row); err != nil {
+			return err
+		}
+	}
+	return sw.Close()
+}
+
+func parseBodyToBadgesPrintedDto(ctx context.Context, w http.ResponseWriter, r *http.Request) (*badges.BadgesPrintedDto, error) {
+	decoder := json.NewDecoder(r.Body)
+	dto := &badges.BadgesPrintedDto{}
+	err := decoder.Decode(dto)
+	if err != nil {
+		badgesPrintedParseErrorHandler(ctx, w, r, err)
+	}
+	return dto, err
+}
+
+func mapBadgeInfoToDto(info *attendeesrv.BadgeInfo) badges.BadgeDto {
+	dto := badges.BadgeDto{
+		Id:                fmt.Sprint(info.Attendee.ID),
+		Nickname:          info.Attendee.Nickname,
+		CountryBadge:      info.Attendee.CountryBadge,
+		SponsorTier:       info.SponsorTier,
+		Markers:           info.Markers,
+		Status:            info.Status,
+		PrintCount:        len(info.Prints),
+		ChangedSincePrint: info.ChangedSincePrint,
+		Prints:            make([]badges.BadgePrintDto, 0, len(info.Prints)),
+	}
+	for _, p := range info.Prints {
+		dto.Prints = append(dto.Prints, badges.BadgePrintDto{
+			Timestamp: p.Timestamp.Format(time.RFC3339),
+			Operator:  p.Operator,
+			Reason:    p.Reason,
+		})
+	}
+	if len(dto.Prints) > 0 {
+		dto.LastPrinted = dto.Prints[len(dto.Prints)-1].Timestamp
+	}
+	return dto
+}
@@ -0,0 +1,41 @@
+package badgectl
+
+import (
+	"context"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/badges"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/validation"
+	"net/url"
+)
+
+// the most badges that can be marked as printed in one request
+const maxBadgesPrinted = 500
+
+func validate(ctx context.Context, b *badges.BadgesPrintedDto) url.Values {
+	errs := url.Values{}
+
+	if len(b.Ids) == 0 || len(b.Ids) > maxBadgesPrinted {
+		errs.Add("ids", "ids must contain at least 1 and at most 500 badge numbers")
+	}
+	seen := make(map[uint]bool)
+	for _, id := range b.Ids {
+		if id == 0 {
+			errs.Add("ids", "ids must be positive integers (badge numbers)")
+		} else if seen[id] {
+			errs.Add("ids", "ids must not contain duplicates")
+		}
+		seen[id] = true
+	}
+	validation.CheckLength(&errs, 0, 256, "reason", b.Reason)
+
+	if len(errs) != 0 {
+		if config.LoggingSeverity() == "DEBUG" {
+			logger := aulogging.Logger.Ctx(ctx).Debug()
+			for key, val := range errs {
+				logger.Printf("badges printed dto validation error for key %s: %s", key, val)
+			}
+		}
+	}
+	return errs
+}
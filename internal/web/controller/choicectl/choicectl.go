@@ -0,0 +1,59 @@
+package choicectl
+
+import (
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/choices"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/web/filter"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-http-utils/headers"
+	"net/http"
+	"sort"
+	"time"
+)
+
+func Create(server chi.Router) {
+	if config.RequireLoginForReg() {
+		server.Get("/api/rest/v1/choices/available", filter.LoggedInOrApiToken(filter.WithTimeout(1*time.Second, availableChoicesHandler)))
+	} else {
+		server.Get("/api/rest/v1/choices/available", filter.WithTimeout(1*time.Second, availableChoicesHandler))
+	}
+}
+
+// availableChoicesHandler lists the flags, packages and options that can currently be picked, so the
+// frontend can offer them. Admin only flags are never listed.
+func availableChoicesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	now := time.Now()
+	dto := choices.AvailableChoicesDto{
+		Flags:    availableChoices(config.FlagsConfigNoAdmin(), now),
+		Packages: availableChoices(config.PackagesConfig(), now),
+		Options:  availableChoices(config.OptionsConfig(), now),
+	}
+
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, dto)
+}
+
+func availableChoices(configuration map[string]config.ChoiceConfig, now time.Time) []choices.ChoiceDto {
+	result := make([]choices.ChoiceDto, 0)
+	for k, v := range configuration {
+		if !v.AvailableAt(now) {
+			continue
+		}
+		result = append(result, choices.ChoiceDto{
+			Key:            k,
+			Description:    v.Description,
+			HelpUrl:        v.HelpUrl,
+			PriceCent:      int64(v.CurrentPrice() * 100),
+			VatPercent:     v.VatPercent,
+			Default:        v.Default,
+			ReadOnly:       v.ReadOnly,
+			AvailableUntil: v.AvailableUntil,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
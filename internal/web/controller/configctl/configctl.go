@@ -0,0 +1,193 @@
+package configctl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/publicconfig"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/service/authsrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/filter"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-http-utils/headers"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var authorizationService authsrv.AuthorizationService = &authsrv.AuthorizationServiceImplData{}
+
+func Create(server chi.Router) {
+	if config.RequireLoginForReg() {
+		server.Get("/api/rest/v1/config", filter.LoggedInOrApiToken(filter.WithTimeout(1*time.Second, getConfigHandler)))
+	} else {
+		server.Get("/api/rest/v1/config", filter.WithTimeout(1*time.Second, getConfigHandler))
+	}
+	server.Post("/api/rest/v1/config/reload", filter.HasPermission(authsrv.PermissionAdmin, config.ApiScopeAll, filter.WithTimeout(3*time.Second, reloadConfigHandler)))
+}
+
+// getConfigHandler returns the non-secret parts of the configuration, so frontends do not need to duplicate them.
+//
+// Admin only flags are only included for callers who may read admin info. The response carries an ETag,
+// and If-None-Match is honoured.
+func getConfigHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	isAdmin, err := authorizationService.HasPermission(ctx, authsrv.PermissionReadAll)
+	if err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("could not determine permissions, leaving out admin only flags: %s", err.Error())
+	}
+	dto := mapConfigToDto(isAdmin)
+
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(dto); err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("could not encode configuration: %s", err.Error())
+		ctlutil.ErrorHandler(ctx, w, r, "config.encode.error", http.StatusInternalServerError, url.Values{})
+		return
+	}
+
+	hash := sha256.Sum256(buffer.Bytes())
+	etag := `"` + hex.EncodeToString(hash[:16]) + `"`
+
+	w.Header().Set(headers.ETag, etag)
+	// the admin only flags depend on who is asking, so shared caches must not store this
+	w.Header().Set(headers.CacheControl, "private, no-cache")
+	w.Header().Set(headers.Vary, "Authorization, Cookie")
+	if etagMatches(r.Header.Get(headers.IfNoneMatch), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	_, _ = w.Write(buffer.Bytes())
+}
+
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func mapConfigToDto(isAdmin bool) publicconfig.ConfigDto {
+	c := config.Configuration()
+	flags := config.FlagsConfigNoAdmin()
+	if isAdmin {
+		flags = c.Choices.Flags
+	}
+
+	return publicconfig.ConfigDto{
+		RequireLoginForReg: config.RequireLoginForReg(),
+		GoLive: publicconfig.GoLiveDto{
+			StartIsoDatetime:         c.GoLive.StartIsoDatetime,
+			EarlyRegStartIsoDatetime: c.GoLive.EarlyRegStartIsoDatetime,
+		},
+		Choices: publicconfig.ChoicesDto{
+			Flags:        mapChoicesToDto(flags),
+			Packages:     mapChoicesToDto(c.Choices.Packages),
+			Options:      mapChoicesToDto(c.Choices.Options),
+			FlagRules:    mapRulesToDto(c.Choices.FlagRules, flags),
+			PackageRules: mapRulesToDto(c.Choices.PackageRules, c.Choices.Packages),
+			OptionRules:  mapRulesToDto(c.Choices.OptionRules, c.Choices.Options),
+		},
+		TshirtSizes: config.AllowedTshirtSizes(),
+		Birthday: publicconfig.BirthdayDto{
+			Earliest:    c.Birthday.Earliest,
+			Latest:      c.Birthday.Latest,
+			FirstConDay: c.Birthday.FirstConDay,
+		},
+		Countries: config.AllowedCountries(),
+		Languages: config.MailLanguages(),
+	}
+}
+
+func mapChoicesToDto(choices map[string]config.ChoiceConfig) map[string]publicconfig.ChoiceDto {
+	result := make(map[string]publicconfig.ChoiceDto)
+	for k, v := range choices {
+		dto := publicconfig.ChoiceDto{
+			Description:         v.Description,
+			HelpUrl:             v.HelpUrl,
+			PriceEarly:          v.PriceEarly,
+			PriceLate:           v.PriceLate,
+			PriceAtCon:          v.PriceAtCon,
+			PriceCurrent:        v.CurrentPrice(),
+			VatPercent:          v.VatPercent,
+			Default:             v.Default,
+			AdminOnly:           v.AdminOnly,
+			ReadOnly:            v.ReadOnly,
+			Constraint:          v.Constraint,
+			ConstraintMsg:       v.ConstraintMsg,
+			AvailableFrom:       v.AvailableFrom,
+			AvailableUntil:      v.AvailableUntil,
+			RemovalPolicy:       v.RemovalPolicy,
+			RemovalAllowedUntil: v.RemovalAllowedUntil,
+		}
+		if v.Eligibility != nil {
+			dto.Eligibility = &publicconfig.EligibilityDto{
+				MinAge:       v.Eligibility.MinAge,
+				MaxAge:       v.Eligibility.MaxAge,
+				Countries:    v.Eligibility.Countries,
+				BeforeStatus: v.Eligibility.BeforeStatus,
+			}
+		}
+		result[k] = dto
+	}
+	return result
+}
+
+// mapRulesToDto only includes rules that reference at least one of the visible choices.
+func mapRulesToDto(rules []config.ChoiceRule, visible map[string]config.ChoiceConfig) []publicconfig.ChoiceRuleDto {
+	result := make([]publicconfig.ChoiceRuleDto, 0)
+	for _, rule := range rules {
+		if rule.ParsedConstraint == nil || !referencesAny(rule.ParsedConstraint.Keys(), visible) {
+			continue
+		}
+		result = append(result, publicconfig.ChoiceRuleDto{
+			Name:          rule.Name,
+			Constraint:    rule.Constraint,
+			ConstraintMsg: rule.ConstraintMsg,
+		})
+	}
+	return result
+}
+
+func referencesAny(keys []string, choices map[string]config.ChoiceConfig) bool {
+	for _, k := range keys {
+		if _, ok := choices[k]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// reloadConfigHandler reads the configuration file again. If it is invalid, the active configuration is kept.
+func reloadConfigHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	aulogging.Logger.Ctx(ctx).Info().Printf("configuration reload requested by %s", ctxvalues.Subject(ctx))
+	changes, errs, err := config.ReloadConfiguration()
+	if err != nil {
+		if errors.Is(err, config.ErrorConfigInvalid) {
+			ctlutil.ErrorHandler(ctx, w, r, "config.reload.invalid", http.StatusBadRequest, errs)
+		} else {
+			aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("configuration reload failed: %s", err.Error())
+			ctlutil.ErrorHandler(ctx, w, r, "config.reload.error", http.StatusInternalServerError, url.Values{})
+		}
+		return
+	}
+
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, publicconfig.ConfigReloadResultDto{Changes: changes})
+}
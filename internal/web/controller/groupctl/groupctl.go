@@ -0,0 +1,471 @@
+package groupctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/groups"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/service/authsrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/filter"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-http-utils/headers"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var attendeeService attendeesrv.AttendeeService
+
+// TODO we should not wire this up here
+func init() {
+	attendeeService = &attendeesrv.AttendeeServiceImplData{}
+}
+
+// use only for testing
+func OverrideAttendeeService(overrideAttendeeServiceForTesting attendeesrv.AttendeeService) {
+	attendeeService = overrideAttendeeServiceForTesting
+}
+
+// each page of the unmatched partner list must complete within this time, the list as a whole may take longer
+const unmatchedPageTimeout = 3 * time.Second
+
+func Create(server chi.Router) {
+	server.Post("/api/rest/v1/groups", filter.LoggedInOrApiScope(config.ApiScopeWrite, filter.WithTimeout(3*time.Second, createGroupHandler)))
+	server.Get("/api/rest/v1/groups", filter.HasPermission(authsrv.PermissionAdmin, config.ApiScopeRead, filter.WithTimeout(10*time.Second, listGroupsHandler)))
+	server.Get("/api/rest/v1/groups/mine", filter.LoggedIn(filter.WithTimeout(3*time.Second, myGroupsHandler)))
+	server.Get("/api/rest/v1/groups/unmatched-partners", filter.HasPermission(authsrv.PermissionAdmin, config.ApiScopeRead, unmatchedPartnersHandler))
+	server.Post("/api/rest/v1/groups/join", filter.LoggedInOrApiScope(config.ApiScopeWrite, filter.WithTimeout(3*time.Second, joinGroupHandler)))
+	server.Get("/api/rest/v1/groups/{id}", filter.LoggedInOrApiScope(config.ApiScopeRead, filter.WithTimeout(3*time.Second, getGroupHandler)))
+	server.Put("/api/rest/v1/groups/{id}", filter.LoggedInOrApiScope(config.ApiScopeWrite, filter.WithTimeout(3*time.Second, updateGroupHandler)))
+	server.Delete("/api/rest/v1/groups/{id}", filter.LoggedInOrApiScope(config.ApiScopeWrite, filter.WithTimeout(3*time.Second, deleteGroupHandler)))
+	server.Post("/api/rest/v1/groups/{id}/invite-code", filter.LoggedInOrApiScope(config.ApiScopeWrite, filter.WithTimeout(3*time.Second, createInviteCodeHandler)))
+	server.Post("/api/rest/v1/groups/{id}/members/{badgenumber}", filter.HasPermission(authsrv.PermissionAdmin, config.ApiScopeWrite, filter.WithTimeout(3*time.Second, addMemberHandler)))
+	server.Delete("/api/rest/v1/groups/{id}/members/{badgenumber}", filter.LoggedInOrApiScope(config.ApiScopeWrite, filter.WithTimeout(3*time.Second, removeMemberHandler)))
+}
+
+// --- handlers ---
+
+func createGroupHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	dto := &groups.GroupCreateDto{}
+	if err := parseBody(ctx, w, r, dto); err != nil {
+		return
+	}
+	validationErrs := validateCreate(ctx, dto)
+	if len(validationErrs) != 0 {
+		groupValidationErrorHandler(ctx, w, r, validationErrs)
+		return
+	}
+
+	owner, err := attendeeMustReturnOnError(ctx, w, r, dto.Owner)
+	if err != nil {
+		return
+	}
+	if err := filter.IsSubjectOrPermissionOrApiScope(w, r, owner.Identity, authsrv.PermissionAdmin, config.ApiScopeWrite); err != nil {
+		return
+	}
+
+	group, err := attendeeService.CreateGroup(ctx, owner, dto.Name, dto.Type)
+	if err != nil {
+		groupErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.Header().Set(headers.Location, fmt.Sprintf("/api/rest/v1/groups/%d", group.ID))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func listGroupsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	infos, err := attendeeService.GetAllGroups(ctx)
+	if err != nil {
+		groupReadErrorHandler(ctx, w, r, err)
+		return
+	}
+	writeGroupList(ctx, w, infos)
+}
+
+// myGroupsHandler lists the groups any of the registrations of the logged in user is a member of.
+func myGroupsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	owned, err := attendeeService.IsOwnerFor(ctx)
+	if err != nil {
+		groupReadErrorHandler(ctx, w, r, err)
+		return
+	}
+	infos, err := attendeeService.GetGroupsForAttendees(ctx, owned)
+	if err != nil {
+		groupReadErrorHandler(ctx, w, r, err)
+		return
+	}
+	writeGroupList(ctx, w, infos)
+}
+
+// unmatchedPartnersHandler lists the attendees who have filled in the partner field, but are not in a room group.
+func unmatchedPartnersHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	entries, err := attendeeService.UnmatchedPartnerRequests(ctx, unmatchedPageTimeout)
+	if err != nil {
+		groupReadErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	result := groups.UnmatchedPartnerListDto{Attendees: make([]groups.UnmatchedPartnerDto, 0, len(entries))}
+	for _, e := range entries {
+		result.Attendees = append(result.Attendees, groups.UnmatchedPartnerDto{
+			Id:       fmt.Sprint(e.Attendee.ID),
+			Nickname: e.Attendee.Nickname,
+			Partner:  e.Attendee.Partner,
+			Status:   e.Status,
+		})
+	}
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, result)
+}
+
+// joinGroupHandler adds one of the registrations of the logged in user to the group the invite code was created for.
+//
+// The Location header points to the group.
+func joinGroupHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	dto := &groups.JoinGroupDto{}
+	if err := parseBody(ctx, w, r, dto); err != nil {
+		return
+	}
+	validationErrs := validateJoin(ctx, dto)
+	if len(validationErrs) != 0 {
+		groupValidationErrorHandler(ctx, w, r, validationErrs)
+		return
+	}
+
+	att, err := attendeeMustReturnOnError(ctx, w, r, dto.Attendee)
+	if err != nil {
+		return
+	}
+	if err := filter.IsSubjectOrPermissionOrApiScope(w, r, att.Identity, authsrv.PermissionAdmin, config.ApiScopeWrite); err != nil {
+		return
+	}
+
+	group, err := attendeeService.JoinGroup(ctx, dto.Code, att)
+	if err != nil {
+		groupErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.Header().Set(headers.Location, fmt.Sprintf("/api/rest/v1/groups/%d", group.ID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getGroupHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	info, err := groupByIdMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	if err := requireMemberOrPermission(w, r, info); err != nil {
+		return
+	}
+
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, mapGroupInfoToDto(info))
+}
+
+func updateGroupHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	info, err := groupByIdMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	if err := requireOwnerOrPermission(w, r, info); err != nil {
+		return
+	}
+
+	dto := &groups.GroupUpdateDto{}
+	if err := parseBody(ctx, w, r, dto); err != nil {
+		return
+	}
+	validationErrs := validateUpdate(ctx, dto)
+	if len(validationErrs) != 0 {
+		groupValidationErrorHandler(ctx, w, r, validationErrs)
+		return
+	}
+
+	info.Group.Name = dto.Name
+	info.Group.OwnerId = dto.Owner
+	if err := attendeeService.UpdateGroup(ctx, info.Group); err != nil {
+		groupErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func deleteGroupHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	info, err := groupByIdMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	if err := requireOwnerOrPermission(w, r, info); err != nil {
+		return
+	}
+
+	if err := attendeeService.DeleteGroup(ctx, info.Group); err != nil {
+		groupErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// createInviteCodeHandler creates a code the owner can pass on to whoever should join the group.
+//
+// Any previous code stops working.
+func createInviteCodeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	info, err := groupByIdMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	if err := requireOwnerOrPermission(w, r, info); err != nil {
+		return
+	}
+
+	code, err := attendeeService.CreateGroupInviteCode(ctx, info.Group)
+	if err != nil {
+		groupErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	w.WriteHeader(http.StatusCreated)
+	ctlutil.WriteJson(ctx, w, groups.InviteCodeDto{Code: code})
+}
+
+// addMemberHandler lets an admin add a registration to a group by badge number, without an invite code.
+func addMemberHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	info, err := groupByIdMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	att, err := memberByBadgeNumberMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	if err := attendeeService.AddGroupMember(ctx, info.Group, att); err != nil {
+		groupErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removeMemberHandler lets the owner of a group remove a member, or a member leave the group.
+func removeMemberHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	info, err := groupByIdMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	att, err := memberByBadgeNumberMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	if !isSubject(ctx, att.Identity) {
+		if err := requireOwnerOrPermission(w, r, info); err != nil {
+			return
+		}
+	}
+
+	if err := attendeeService.RemoveGroupMember(ctx, info.Group, att); err != nil {
+		groupErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- error handlers ---
+
+func invalidGroupIdErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, id string) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received invalid group id '%s'", id)
+	ctlutil.ErrorHandler(ctx, w, r, "group.id.invalid", http.StatusBadRequest, url.Values{})
+}
+
+func groupParseErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("group body could not be parsed: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "group.parse.error", http.StatusBadRequest, url.Values{})
+}
+
+func groupValidationErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, errs url.Values) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received group data with validation errors: %v", errs)
+	ctlutil.ErrorHandler(ctx, w, r, "group.data.invalid", http.StatusBadRequest, errs)
+}
+
+func groupReadErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("could not read groups: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "group.read.error", http.StatusInternalServerError, url.Values{})
+}
+
+func groupErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, attendeesrv.GroupNotFoundError) {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("group not found: %s", err.Error())
+		ctlutil.ErrorHandler(ctx, w, r, "group.id.notfound", http.StatusNotFound, url.Values{})
+		return
+	}
+	if errors.Is(err, attendeesrv.GroupInviteCodeInvalidError) {
+		// no details, so codes cannot be probed
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("invalid group invite code used by %s", ctxvalues.UserId(ctx))
+		ctlutil.ErrorHandler(ctx, w, r, "group.code.invalid", http.StatusNotFound, url.Values{})
+		return
+	}
+	if errors.Is(err, attendeesrv.GroupMemberNotFoundError) {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("group member not found: %s", err.Error())
+		ctlutil.ErrorHandler(ctx, w, r, "group.member.notfound", http.StatusNotFound, url.Values{"details": []string{err.Error()}})
+		return
+	}
+
+	message := ""
+	if errors.Is(err, attendeesrv.GroupMemberInactiveError) {
+		message = "group.member.inactive"
+	} else if errors.Is(err, attendeesrv.GroupMembershipExistsError) {
+		message = "group.member.duplicate"
+	} else if errors.Is(err, attendeesrv.GroupFullError) {
+		message = "group.full"
+	} else if errors.Is(err, attendeesrv.GroupRoomPackageMismatchError) {
+		message = "group.room.mismatch"
+	} else if errors.Is(err, attendeesrv.GroupOwnerCannotLeaveError) {
+		message = "group.owner.leave"
+	} else if errors.Is(err, attendeesrv.GroupOwnerNotMemberError) {
+		message = "group.owner.notmember"
+	}
+	if message != "" {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("group change not possible: %s - %s", message, err.Error())
+		ctlutil.ErrorHandler(ctx, w, r, message, http.StatusConflict, url.Values{"details": []string{err.Error()}})
+		return
+	}
+
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("could not write group: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "group.write.error", http.StatusInternalServerError, url.Values{})
+}
+
+// --- helpers ---
+
+func groupByIdMustReturnOnError(ctx context.Context, w http.ResponseWriter, r *http.Request) (*attendeesrv.GroupInfo, error) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil || id == 0 {
+		invalidGroupIdErrorHandler(ctx, w, r, idStr)
+		return nil, errors.New("invalid group id")
+	}
+	info, err := attendeeService.GetGroup(ctx, uint(id))
+	if err != nil {
+		if errors.Is(err, attendeesrv.GroupNotFoundError) {
+			groupErrorHandler(ctx, w, r, err)
+		} else {
+			groupReadErrorHandler(ctx, w, r, err)
+		}
+		return nil, err
+	}
+	return info, nil
+}
+
+func memberByBadgeNumberMustReturnOnError(ctx context.Context, w http.ResponseWriter, r *http.Request) (*entity.Attendee, error) {
+	idStr := chi.URLParam(r, "badgenumber")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil || id == 0 {
+		ctlutil.InvalidAttendeeIdErrorHandler(ctx, w, r, idStr)
+		return nil, errors.New("invalid badge number")
+	}
+	return attendeeMustReturnOnError(ctx, w, r, uint(id))
+}
+
+func attendeeMustReturnOnError(ctx context.Context, w http.ResponseWriter, r *http.Request, id uint) (*entity.Attendee, error) {
+	attendee, err := attendeeService.GetAttendee(ctx, id)
+	if err != nil {
+		ctlutil.AttendeeNotFoundErrorHandler(ctx, w, r, id)
+		return nil, err
+	}
+	return attendee, nil
+}
+
+func isSubject(ctx context.Context, identity string) bool {
+	return ctxvalues.Subject(ctx) != "" && ctxvalues.Subject(ctx) == identity
+}
+
+func ownerOf(info *attendeesrv.GroupInfo) *entity.Attendee {
+	for _, m := range info.Members {
+		if m.Attendee.ID == info.Group.OwnerId {
+			return m.Attendee
+		}
+	}
+	// cannot happen, the owner is always a member, but an empty identity matches nobody
+	return &entity.Attendee{}
+}
+
+func requireOwnerOrPermission(w http.ResponseWriter, r *http.Request, info *attendeesrv.GroupInfo) error {
+	return filter.IsSubjectOrPermissionOrApiScope(w, r, ownerOf(info).Identity, authsrv.PermissionAdmin, config.ApiScopeWrite)
+}
+
+// requireMemberOrPermission allows the owners of all member registrations to see the group.
+func requireMemberOrPermission(w http.ResponseWriter, r *http.Request, info *attendeesrv.GroupInfo) error {
+	for _, m := range info.Members {
+		if isSubject(r.Context(), m.Attendee.Identity) {
+			return nil
+		}
+	}
+	return filter.IsSubjectOrPermissionOrApiScope(w, r, ownerOf(info).Identity, authsrv.PermissionAdmin, config.ApiScopeRead)
+}
+
+func parseBody(ctx context.Context, w http.ResponseWriter, r *http.Request, dto interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(dto)
+	if err != nil {
+		groupParseErrorHandler(ctx, w, r, err)
+	}
+	return err
+}
+
+func writeGroupList(ctx context.Context, w http.ResponseWriter, infos []*attendeesrv.GroupInfo) {
+	result := groups.GroupListDto{Groups: make([]groups.GroupDto, 0, len(infos))}
+	for _, info := range infos {
+		result.Groups = append(result.Groups, mapGroupInfoToDto(info))
+	}
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, result)
+}
+
+func mapGroupInfoToDto(info *attendeesrv.GroupInfo) groups.GroupDto {
+	dto := groups.GroupDto{
+		Id:       fmt.Sprint(info.Group.ID),
+		Name:     info.Group.Name,
+		Type:     info.Group.Type,
+		Owner:    fmt.Sprint(info.Group.OwnerId),
+		Members:  make([]groups.GroupMemberDto, 0, len(info.Members)),
+		Problems: info.Problems,
+	}
+	for _, m := range info.Members {
+		dto.Members = append(dto.Members, groups.GroupMemberDto{
+			Id:          fmt.Sprint(m.Attendee.ID),
+			Nickname:    m.Attendee.Nickname,
+			Status:      m.Status,
+			RoomPackage: m.RoomPackage,
+		})
+	}
+	return dto
+}
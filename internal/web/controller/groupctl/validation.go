@@ -0,0 +1,62 @@
+package groupctl
+
+import (
+	"context"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/groups"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/validation"
+	"net/url"
+	"strings"
+)
+
+func validateCreate(ctx context.Context, g *groups.GroupCreateDto) url.Values {
+	errs := url.Values{}
+
+	validation.CheckLength(&errs, 1, 80, "name", g.Name)
+	if validation.NotInAllowedValues(attendeesrv.GroupTypes, g.Type) {
+		errs.Add("type", "type field must be one of "+strings.Join(attendeesrv.GroupTypes, ","))
+	}
+	validateBadgeNumber(&errs, "owner", g.Owner)
+
+	logValidationErrors(ctx, errs)
+	return errs
+}
+
+func validateUpdate(ctx context.Context, g *groups.GroupUpdateDto) url.Values {
+	errs := url.Values{}
+
+	validation.CheckLength(&errs, 1, 80, "name", g.Name)
+	validateBadgeNumber(&errs, "owner", g.Owner)
+
+	logValidationErrors(ctx, errs)
+	return errs
+}
+
+func validateJoin(ctx context.Context, j *groups.JoinGroupDto) url.Values {
+	errs := url.Values{}
+
+	validation.CheckLength(&errs, 1, 64, "code", j.Code)
+	validateBadgeNumber(&errs, "attendee", j.Attendee)
+
+	logValidationErrors(ctx, errs)
+	return errs
+}
+
+func validateBadgeNumber(errs *url.Values, key string, value uint) {
+	if value == 0 {
+		errs.Add(key, key+" field must be a badge number")
+	}
+}
+
+func logValidationErrors(ctx context.Context, errs url.Values) {
+	if len(errs) != 0 {
+		if config.LoggingSeverity() == "DEBUG" {
+			logger := aulogging.Logger.Ctx(ctx).Debug()
+			for key, val := range errs {
+				logger.Printf("group dto validation error for key %s: %s", key, val)
+			}
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package regdeskctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/regdesk"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/mailservice"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/service/authsrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/filter"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-http-utils/headers"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var attendeeService attendeesrv.AttendeeService
+
+// TODO we should not wire this up here
+func init() {
+	attendeeService = &attendeesrv.AttendeeServiceImplData{}
+}
+
+// use only for testing
+func OverrideAttendeeService(overrideAttendeeServiceForTesting attendeesrv.AttendeeService) {
+	attendeeService = overrideAttendeeServiceForTesting
+}
+
+func Create(server chi.Router) {
+	server.Get("/api/rest/v1/regdesk/attendees", filter.HasPermission(authsrv.PermissionRegdesk, config.ApiScopeRead, filter.WithTimeout(5*time.Second, lookupHandler)))
+	server.Post("/api/rest/v1/attendees/{id}/checkin", filter.HasPermission(authsrv.PermissionRegdesk, config.ApiScopeCheckin, filter.WithTimeout(3*time.Second, checkInHandler)))
+	server.Delete("/api/rest/v1/attendees/{id}/checkin", filter.HasPermission(authsrv.PermissionRegdesk, config.ApiScopeCheckin, filter.WithTimeout(3*time.Second, undoCheckInHandler)))
+}
+
+// --- handlers ---
+
+// lookupHandler finds attendees by badge number (query parameter id) or part of their nickname (query parameter nickname).
+func lookupHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	badgeNumber, nickname, errs := parseLookupParams(r.URL.Query())
+	if len(errs) != 0 {
+		lookupValidationErrorHandler(ctx, w, r, errs)
+		return
+	}
+
+	infos, err := attendeeService.RegdeskLookup(ctx, badgeNumber, nickname)
+	if err != nil {
+		if errors.Is(err, paymentservice.DownstreamError) {
+			ctlutil.ErrorHandler(ctx, w, r, "regdesk.payment.error", http.StatusBadGateway, url.Values{"details": []string{err.Error()}})
+			return
+		}
+		regdeskReadErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	dto := regdesk.RegdeskAttendeeListDto{
+		Attendees: make([]regdesk.RegdeskAttendeeDto, 0, len(infos)),
+	}
+	for _, info := range infos {
+		dto.Attendees = append(dto.Attendees, mapRegdeskInfoToDto(info))
+	}
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, dto)
+}
+
+func checkInHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	att, err := attendeeByIdMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	dto, err := parseBodyToCheckInDto(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	validationErrs := validate(ctx, dto)
+	if len(validationErrs) != 0 {
+		checkInValidationErrorHandler(ctx, w, r, validationErrs)
+		return
+	}
+
+	if err := attendeeService.CheckIn(ctx, att, dto.Desk, dto.Items, dto.Comment); err != nil {
+		checkInErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func undoCheckInHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	att, err := attendeeByIdMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	if err := attendeeService.UndoCheckIn(ctx, att); err != nil {
+		checkInErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- error handlers ---
+
+func regdeskReadErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("could not look up attendees for the regdesk: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "regdesk.read.error", http.StatusInternalServerError, url.Values{})
+}
+
+func lookupValidationErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, errs url.Values) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received regdesk lookup with validation errors: %v", errs)
+	ctlutil.ErrorHandler(ctx, w, r, "regdesk.lookup.invalid", http.StatusBadRequest, errs)
+}
+
+func checkInParseErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("check in body could not be parsed: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "checkin.parse.error", http.StatusBadRequest, url.Values{})
+}
+
+func checkInValidationErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, errs url.Values) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received check in data with validation errors: %v", errs)
+	ctlutil.ErrorHandler(ctx, w, r, "checkin.data.invalid", http.StatusBadRequest, errs)
+}
+
+func checkInErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	message := ""
+	if errors.Is(err, attendeesrv.AlreadyCheckedInError) {
+		message = "checkin.already.done"
+	} else if errors.Is(err, attendeesrv.NotPaidError) {
+		message = "checkin.not.paid"
+	} else if errors.Is(err, attendeesrv.InsufficientPaymentError) {
+		message = "checkin.unpaid.dues"
+	} else if errors.Is(err, attendeesrv.NotCheckedInError) {
+		message = "checkin.undo.notcheckedin"
+	} else if errors.Is(err, attendeesrv.UndoWindowExpiredError) {
+		message = "checkin.undo.expired"
+	}
+	if message != "" {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("unavailable check in operation attempted: %s - %s", message, err.Error())
+		ctlutil.ErrorHandler(ctx, w, r, message, http.StatusConflict, url.Values{"details": []string{err.Error()}})
+		return
+	}
+
+	if errors.Is(err, paymentservice.DownstreamError) || errors.Is(err, mailservice.DownstreamError) {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("downstream error during check in: %s", err.Error())
+		message = "checkin.payment.error"
+		if errors.Is(err, mailservice.DownstreamError) {
+			message = "checkin.mail.error"
+		}
+		ctlutil.ErrorHandler(ctx, w, r, message, http.StatusBadGateway, url.Values{"details": []string{err.Error()}})
+		return
+	}
+
+	if errors.Is(err, attendeesrv.CheckInNotRecordedError) {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("check in was not recorded: %s", err.Error())
+		ctlutil.ErrorHandler(ctx, w, r, "checkin.write.retry", http.StatusServiceUnavailable, url.Values{"details": []string{attendeesrv.CheckInNotRecordedError.Error()}})
+		return
+	}
+
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("could not write check in: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "checkin.write.error", http.StatusInternalServerError, url.Values{})
+}
+
+// --- helpers ---
+
+func parseLookupParams(query url.Values) (uint, string, url.Values) {
+	errs := url.Values{}
+	idStr := strings.TrimSpace(query.Get("id"))
+	nickname := strings.TrimSpace(query.Get("nickname"))
+	if (idStr == "") == (nickname == "") {
+		errs.Add("query", "exactly one of id or nickname must be given")
+		return 0, "", errs
+	}
+
+	if idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil || id == 0 {
+			errs.Add("id", "id must be a positive integer (the badge number)")
+		}
+		return uint(id), "", errs
+	}
+
+	if len([]rune(nickname)) < 2 {
+		errs.Add("nickname", "nickname must be at least 2 characters long")
+	}
+	if strings.Contains(nickname, "*") {
+		errs.Add("nickname", "nickname must not contain wildcards")
+	}
+	return 0, nickname, errs
+}
+
+func attendeeByIdMustReturnOnError(ctx context.Context, w http.ResponseWriter, r *http.Request) (*entity.Attendee, error) {
+	id, err := ctlutil.AttendeeIdFromVars(ctx, w, r)
+	if err != nil {
+		return &entity.Attendee{}, err
+	}
+	attendee, err := attendeeService.GetAttendee(ctx, id)
+	if err != nil {
+		ctlutil.AttendeeNotFoundErrorHandler(ctx, w, r, id)
+		return &entity.Attendee{}, err
+	}
+	return attendee, nil
+}
+
+func parseBodyToCheckInDto(ctx context.Context, w http.ResponseWriter, r *http.Request) (*regdesk.CheckInDto, error) {
+	decoder := json.NewDecoder(r.Body)
+	dto := &regdesk.CheckInDto{}
+	err := decoder.Decode(dto)
+	if err != nil {
+		checkInParseErrorHandler(ctx, w, r, err)
+	}
+	return dto, err
+}
+
+func mapRegdeskInfoToDto(info *attendeesrv.RegdeskInfo) regdesk.RegdeskAttendeeDto {
+	flags := make([]string, 0)
+	for _, f := range []string{info.Attendee.Flags, info.AdminFlags} {
+		if f != "" {
+			flags = append(flags, f)
+		}
+	}
+	dto := regdesk.RegdeskAttendeeDto{
+		Id:              fmt.Sprint(info.Attendee.ID),
+		Nickname:        info.Attendee.Nickname,
+		FirstName:       info.Attendee.FirstName,
+		LastName:        info.Attendee.LastName,
+		Status:          info.Status,
+		TotalDues:       info.TotalDues,
+		PaymentBalance:  info.PaymentBalance,
+		OutstandingDues: info.TotalDues - info.PaymentBalance,
+		TshirtSize:      info.Attendee.TshirtSize,
+		Packages:        info.Attendee.Packages,
+		Flags:           strings.Join(flags, ","),
+	}
+	if info.CheckIn != nil {
+		dto.CheckIn = &regdesk.CheckInInfoDto{
+			Timestamp: info.CheckIn.Timestamp.Format(time.RFC3339),
+			Desk:      info.CheckIn.Desk,
+			Operator:  info.CheckIn.Operator,
+			Items:     info.CheckIn.Items,
+		}
+	}
+	return dto
+}
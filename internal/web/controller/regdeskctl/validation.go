@@ -0,0 +1,37 @@
+package regdeskctl
+
+import (
+	"context"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/regdesk"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/validation"
+	"net/url"
+	"strings"
+)
+
+func validate(ctx context.Context, c *regdesk.CheckInDto) url.Values {
+	errs := url.Values{}
+
+	validation.CheckLength(&errs, 1, 80, "desk", c.Desk)
+	seen := make(map[string]bool)
+	for _, item := range c.Items {
+		if validation.NotInAllowedValues(config.RegdeskItems(), item) {
+			errs.Add("items", "items must be any of "+strings.Join(config.RegdeskItems(), ","))
+		} else if seen[item] {
+			errs.Add("items", "items must not contain duplicates")
+		}
+		seen[item] = true
+	}
+	validation.CheckLength(&errs, 0, 256, "comment", c.Comment)
+
+	if len(errs) != 0 {
+		if config.LoggingSeverity() == "DEBUG" {
+			logger := aulogging.Logger.Ctx(ctx).Debug()
+			for key, val := range errs {
+				logger.Printf("check in dto validation error for key %s: %s", key, val)
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,85 @@
+package statsctl
+
+import (
+	"context"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/statistics"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/service/authsrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/filter"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-http-utils/headers"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var attendeeService attendeesrv.AttendeeService
+
+// TODO we should not wire this up here
+func init() {
+	attendeeService = &attendeesrv.AttendeeServiceImplData{}
+}
+
+// use only for testing
+func OverrideAttendeeService(overrideAttendeeServiceForTesting attendeesrv.AttendeeService) {
+	attendeeService = overrideAttendeeServiceForTesting
+}
+
+func Create(server chi.Router) {
+	server.Get("/api/rest/v1/statistics", filter.HasPermission(authsrv.PermissionReadAll, config.ApiScopeRead, filter.WithTimeout(3*time.Second, getStatisticsHandler)))
+	server.Get("/api/rest/v1/statistics/public", filter.WithTimeout(3*time.Second, getPublicStatisticsHandler))
+}
+
+func getStatisticsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	stats, err := attendeeService.GetStatistics(ctx)
+	if err != nil {
+		statisticsReadErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	dto := statistics.StatisticsDto{
+		Total:        stats.Total,
+		ByStatus:     stats.ByStatus,
+		ByCountry:    stats.ByCountry,
+		ByPackage:    stats.ByPackage,
+		ByFlag:       stats.ByFlag,
+		ByTshirtSize: stats.ByTshirtSize,
+		PerDay:       make([]statistics.DailyCountDto, 0),
+	}
+	for _, d := range stats.PerDay {
+		dto.PerDay = append(dto.PerDay, statistics.DailyCountDto{Day: d.Day, Count: d.Count})
+	}
+
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, dto)
+}
+
+func getPublicStatisticsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	stats, err := attendeeService.GetPublicStatistics(ctx)
+	if err != nil {
+		statisticsReadErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	dto := statistics.PublicStatisticsDto{
+		Total:     stats.Total,
+		ByStatus:  stats.ByStatus,
+		ByCountry: stats.ByCountry,
+	}
+
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, dto)
+}
+
+func statisticsReadErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("could not compute statistics: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "statistics.read.error", http.StatusInternalServerError, url.Values{})
+}
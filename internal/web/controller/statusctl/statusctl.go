@@ -12,6 +12,7 @@ import (
 	"github.com/eurofurence/reg-attendee-service/internal/repository/mailservice"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
 	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/service/authsrv"
 	"github.com/eurofurence/reg-attendee-service/internal/web/filter"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
@@ -36,9 +37,9 @@ func OverrideAttendeeService(overrideAttendeeServiceForTesting attendeesrv.Atten
 }
 
 func Create(server chi.Router) {
-	server.Get("/api/rest/v1/attendees/{id}/status", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, getStatusHandler)))
-	server.Post("/api/rest/v1/attendees/{id}/status", filter.LoggedInOrApiToken(filter.WithTimeout(3*time.Second, postStatusHandler)))
-	server.Get("/api/rest/v1/attendees/{id}/status-history", filter.HasRoleOrApiToken(config.OidcAdminRole(), filter.WithTimeout(3*time.Second, getStatusHistoryHandler)))
+	server.Get("/api/rest/v1/attendees/{id}/status", filter.LoggedInOrApiScope(config.ApiScopeRead, filter.WithTimeout(3*time.Second, getStatusHandler)))
+	server.Post("/api/rest/v1/attendees/{id}/status", filter.LoggedInOrApiScopes([]string{config.ApiScopePayments, config.ApiScopeCheckin}, filter.WithTimeout(3*time.Second, postStatusHandler)))
+	server.Get("/api/rest/v1/attendees/{id}/status-history", filter.HasPermission(authsrv.PermissionReadAll, config.ApiScopeRead, filter.WithTimeout(3*time.Second, getStatusHistoryHandler)))
 }
 
 // --- handlers ---
@@ -51,7 +52,7 @@ func getStatusHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := filter.IsSubjectOrRoleOrApiToken(w, r, att.Identity, config.OidcAdminRole()); err != nil {
+	if err := filter.IsSubjectOrPermissionOrApiScope(w, r, att.Identity, authsrv.PermissionReadAll, config.ApiScopeRead); err != nil {
 		return
 	}
 
@@ -107,6 +108,9 @@ func postStatusHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if errors.Is(err, paymentservice.DownstreamError) || errors.Is(err, mailservice.DownstreamError) {
 			statusChangeDownstreamError(ctx, w, r, err)
+		} else if errors.Is(err, attendeesrv.VoucherUsedUpError) {
+			// someone else redeemed the last use after StatusChangePossible
+			statusChangeUnavailableErrorHandler(ctx, w, r, err)
 		} else {
 			statusWriteErrorHandler(ctx, w, r, err)
 		}
@@ -188,6 +192,10 @@ func statusChangeUnavailableErrorHandler(ctx context.Context, w http.ResponseWri
 		message = "status.cannot.delete"
 	} else if errors.Is(err, attendeesrv.GoToApprovedFirst) {
 		message = "status.use.approved"
+	} else if errors.Is(err, attendeesrv.EmailNotVerifiedError) {
+		message = "status.email.unverified"
+	} else if errors.Is(err, attendeesrv.VoucherUsedUpError) {
+		message = "status.voucher.used"
 	}
 	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("unavailable status change attempted: %s - %s", message, err.Error())
 	ctlutil.ErrorHandler(ctx, w, r, message, http.StatusConflict, url.Values{"details": []string{err.Error()}})
@@ -0,0 +1,226 @@
+package transferctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/transfer"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/mailservice"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/service/authsrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/filter"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-http-utils/headers"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var attendeeService attendeesrv.AttendeeService
+
+// TODO we should not wire this up here
+func init() {
+	attendeeService = &attendeesrv.AttendeeServiceImplData{}
+}
+
+// use only for testing
+func OverrideAttendeeService(overrideAttendeeServiceForTesting attendeesrv.AttendeeService) {
+	attendeeService = overrideAttendeeServiceForTesting
+}
+
+func Create(server chi.Router) {
+	server.Post("/api/rest/v1/attendees/{id}/transfer", filter.HasPermission(authsrv.PermissionAdmin, config.ApiScopeAll, filter.WithTimeout(3*time.Second, transferHandler)))
+	server.Post("/api/rest/v1/attendees/{id}/transfer-code", filter.LoggedInOrApiScope(config.ApiScopeAll, filter.WithTimeout(3*time.Second, createTransferCodeHandler)))
+	server.Delete("/api/rest/v1/attendees/{id}/transfer-code", filter.LoggedInOrApiScope(config.ApiScopeAll, filter.WithTimeout(3*time.Second, cancelTransferCodeHandler)))
+	server.Post("/api/rest/v1/transfers/redeem", filter.LoggedIn(filter.WithTimeout(3*time.Second, redeemHandler)))
+}
+
+// --- handlers ---
+
+// transferHandler lets an admin move a registration to another account without a transfer code.
+func transferHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	att, err := attendeeByIdMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	dto := &transfer.TransferDto{}
+	if err := parseBody(ctx, w, r, dto); err != nil {
+		return
+	}
+
+	validationErrs := validateTransfer(ctx, dto)
+	if len(validationErrs) != 0 {
+		transferValidationErrorHandler(ctx, w, r, validationErrs)
+		return
+	}
+
+	if err := attendeeService.TransferAttendee(ctx, att, dto.Identity, dto.Email, dto.ResetPersonalData); err != nil {
+		transferErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// createTransferCodeHandler lets the owner of a registration create a code to pass on to the new owner.
+//
+// Any previous code stops working.
+func createTransferCodeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	att, err := attendeeByIdMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	if err := filter.IsSubjectOrPermissionOrApiScope(w, r, att.Identity, authsrv.PermissionAdmin, config.ApiScopeAll); err != nil {
+		return
+	}
+	dto := &transfer.TransferCodeRequestDto{}
+	if err := parseBody(ctx, w, r, dto); err != nil {
+		return
+	}
+
+	code, expires, err := attendeeService.CreateTransferCode(ctx, att, dto.ResetPersonalData)
+	if err != nil {
+		transferErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	w.WriteHeader(http.StatusCreated)
+	ctlutil.WriteJson(ctx, w, transfer.TransferCodeDto{
+		Code:    code,
+		Expires: expires.Format(time.RFC3339),
+	})
+}
+
+func cancelTransferCodeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	att, err := attendeeByIdMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+	if err := filter.IsSubjectOrPermissionOrApiScope(w, r, att.Identity, authsrv.PermissionAdmin, config.ApiScopeAll); err != nil {
+		return
+	}
+
+	if err := attendeeService.CancelTransferCode(ctx, att); err != nil {
+		transferErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// redeemHandler transfers the registration a code was created for to the logged in user.
+//
+// The Location header points to the registration, which the new owner may need to fill in.
+func redeemHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	dto := &transfer.RedeemTransferDto{}
+	if err := parseBody(ctx, w, r, dto); err != nil {
+		return
+	}
+
+	validationErrs := validateRedeem(ctx, dto)
+	if len(validationErrs) != 0 {
+		transferValidationErrorHandler(ctx, w, r, validationErrs)
+		return
+	}
+
+	att, err := attendeeService.RedeemTransferCode(ctx, dto.Code, dto.Email)
+	if err != nil {
+		transferErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.Header().Set(headers.Location, fmt.Sprintf("/api/rest/v1/attendees/%d", att.ID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- error handlers ---
+
+func transferParseErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("transfer body could not be parsed: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "transfer.parse.error", http.StatusBadRequest, url.Values{})
+}
+
+func transferValidationErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, errs url.Values) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received transfer data with validation errors: %v", errs)
+	ctlutil.ErrorHandler(ctx, w, r, "transfer.data.invalid", http.StatusBadRequest, errs)
+}
+
+func transferErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, attendeesrv.TransferCodeInvalidError) {
+		// no details, so codes cannot be probed
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("invalid transfer code redeemed by %s", ctxvalues.Subject(ctx))
+		ctlutil.ErrorHandler(ctx, w, r, "transfer.code.invalid", http.StatusNotFound, url.Values{})
+		return
+	}
+	if errors.Is(err, attendeesrv.NoTransferCodeError) {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("no transfer code to cancel: %s", err.Error())
+		ctlutil.ErrorHandler(ctx, w, r, "transfer.code.notfound", http.StatusNotFound, url.Values{})
+		return
+	}
+
+	message := ""
+	if errors.Is(err, attendeesrv.TransferNotAllowedError) {
+		message = "transfer.not.allowed"
+	} else if errors.Is(err, attendeesrv.TransferToSameOwnerError) {
+		message = "transfer.same.owner"
+	} else if errors.Is(err, attendeesrv.TransferEmailMissingError) {
+		message = "transfer.email.missing"
+	}
+	if message != "" {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("transfer not possible: %s - %s", message, err.Error())
+		ctlutil.ErrorHandler(ctx, w, r, message, http.StatusConflict, url.Values{"details": []string{err.Error()}})
+		return
+	}
+
+	if errors.Is(err, mailservice.DownstreamError) {
+		// the transfer itself has happened at this point
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("could not send transfer email: %s", err.Error())
+		ctlutil.ErrorHandler(ctx, w, r, "transfer.mail.error", http.StatusBadGateway, url.Values{"details": []string{err.Error()}})
+		return
+	}
+
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("could not write transfer: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "transfer.write.error", http.StatusInternalServerError, url.Values{})
+}
+
+// --- helpers ---
+
+func attendeeByIdMustReturnOnError(ctx context.Context, w http.ResponseWriter, r *http.Request) (*entity.Attendee, error) {
+	id, err := ctlutil.AttendeeIdFromVars(ctx, w, r)
+	if err != nil {
+		return &entity.Attendee{}, err
+	}
+	attendee, err := attendeeService.GetAttendee(ctx, id)
+	if err != nil {
+		ctlutil.AttendeeNotFoundErrorHandler(ctx, w, r, id)
+		return &entity.Attendee{}, err
+	}
+	return attendee, nil
+}
+
+// parseBody accepts an empty body, leaving dto unchanged, validation will then complain about missing fields.
+func parseBody(ctx context.Context, w http.ResponseWriter, r *http.Request, dto interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(dto)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		transferParseErrorHandler(ctx, w, r, err)
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package transferctl
+
+import (
+	"context"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/transfer"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/validation"
+	"net/url"
+)
+
+const emailPattern = "^[^\\@\\s]+\\@[^\\@\\s]+$"
+
+func validateTransfer(ctx context.Context, t *transfer.TransferDto) url.Values {
+	errs := url.Values{}
+
+	validation.CheckLength(&errs, 1, 255, "identity", t.Identity)
+	validateEmail(&errs, t.Email, t.ResetPersonalData)
+
+	logValidationErrors(ctx, errs)
+	return errs
+}
+
+func validateRedeem(ctx context.Context, t *transfer.RedeemTransferDto) url.Values {
+	errs := url.Values{}
+
+	validation.CheckLength(&errs, 1, 64, "code", t.Code)
+	validateEmail(&errs, t.Email, false)
+
+	logValidationErrors(ctx, errs)
+	return errs
+}
+
+func validateEmail(errs *url.Values, email string, required bool) {
+	if email == "" && !required {
+		return
+	}
+	validation.CheckLength(errs, 1, 200, "email", email)
+	if validation.ViolatesPattern(emailPattern, email) {
+		errs.Add("email", "email field is not plausible, must match "+emailPattern)
+	}
+}
+
+func logValidationErrors(ctx context.Context, errs url.Values) {
+	if len(errs) != 0 {
+		if config.LoggingSeverity() == "DEBUG" {
+			logger := aulogging.Logger.Ctx(ctx).Debug()
+			for key, val := range errs {
+				logger.Printf("transfer dto validation error for key %s: %s", key, val)
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package voucherctl
+
+import (
+	"context"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/vouchers"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/validation"
+	"net/url"
+	"time"
+)
+
+const codePattern = "^[A-Z0-9-]{3,32}$"
+
+func validate(ctx context.Context, v *vouchers.VoucherDto, originalCode string) url.Values {
+	errs := url.Values{}
+
+	if originalCode == "" {
+		if validation.ViolatesPattern(codePattern, v.Code) {
+			errs.Add("code", "code field must consist of 3 to 32 upper case letters, digits or dashes")
+		}
+	} else if v.Code != originalCode {
+		errs.Add("code", "code field cannot be changed, please create a new voucher instead")
+	}
+	validation.CheckLength(&errs, 0, 255, "description", v.Description)
+	if (v.Percent == 0) == (v.Amount == 0) {
+		errs.Add("percent", "exactly one of the percent and amount fields must be set")
+	}
+	if v.Percent < 0 || v.Percent > 100 {
+		errs.Add("percent", "percent field must be between 1 and 100")
+	}
+	if v.Amount < 0 {
+		errs.Add("amount", "amount field must not be negative")
+	}
+	validation.CheckCombinationOfAllowedValues(&errs, config.AllowedPackages(), "packages", v.Packages)
+	if v.MaxUses < 0 {
+		errs.Add("max_uses", "max_uses field must not be negative")
+	}
+	if v.Expires != "" {
+		if _, err := time.Parse(time.RFC3339, v.Expires); err != nil {
+			errs.Add("expires", "optional expires field must be an RFC 3339 timestamp, or it can be left blank, which means the voucher does not expire")
+		}
+	}
+
+	logValidationErrors(ctx, errs)
+	return errs
+}
+
+func logValidationErrors(ctx context.Context, errs url.Values) {
+	if len(errs) != 0 {
+		if config.LoggingSeverity() == "DEBUG" {
+			logger := aulogging.Logger.Ctx(ctx).Debug()
+			for key, val := range errs {
+				logger.Printf("voucher dto validation error for key %s: %s", key, val)
+			}
+		}
+	}
+}
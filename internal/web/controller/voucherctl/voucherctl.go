@@ -0,0 +1,228 @@
+package voucherctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/vouchers"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/eurofurence/reg-attendee-service/internal/service/authsrv"
+	"github.com/eurofurence/reg-attendee-service/internal/web/filter"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
+	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-http-utils/headers"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var attendeeService attendeesrv.AttendeeService
+
+// TODO we should not wire this up here
+func init() {
+	attendeeService = &attendeesrv.AttendeeServiceImplData{}
+}
+
+// use only for testing
+func OverrideAttendeeService(overrideAttendeeServiceForTesting attendeesrv.AttendeeService) {
+	attendeeService = overrideAttendeeServiceForTesting
+}
+
+func Create(server chi.Router) {
+	server.Get("/api/rest/v1/vouchers", filter.HasPermission(authsrv.PermissionAdmin, config.ApiScopeRead, filter.WithTimeout(10*time.Second, listVouchersHandler)))
+	server.Post("/api/rest/v1/vouchers", filter.HasPermission(authsrv.PermissionAdmin, config.ApiScopeWrite, filter.WithTimeout(3*time.Second, createVoucherHandler)))
+	server.Get("/api/rest/v1/vouchers/{id}", filter.HasPermission(authsrv.PermissionAdmin, config.ApiScopeRead, filter.WithTimeout(3*time.Second, getVoucherHandler)))
+	server.Put("/api/rest/v1/vouchers/{id}", filter.HasPermission(authsrv.PermissionAdmin, config.ApiScopeWrite, filter.WithTimeout(3*time.Second, updateVoucherHandler)))
+}
+
+// --- handlers ---
+
+func listVouchersHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	infos, err := attendeeService.GetAllVouchers(ctx)
+	if err != nil {
+		voucherReadErrorHandler(ctx, w, r, err)
+		return
+	}
+
+	result := vouchers.VoucherListDto{Vouchers: make([]vouchers.VoucherDto, 0, len(infos))}
+	for _, info := range infos {
+		result.Vouchers = append(result.Vouchers, mapVoucherInfoToDto(info))
+	}
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, result)
+}
+
+func createVoucherHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	dto := &vouchers.VoucherDto{}
+	if err := parseBody(ctx, w, r, dto); err != nil {
+		return
+	}
+	validationErrs := validate(ctx, dto, "")
+	if len(validationErrs) != 0 {
+		voucherValidationErrorHandler(ctx, w, r, validationErrs)
+		return
+	}
+
+	voucher := &entity.Voucher{}
+	mapDtoToVoucher(dto, voucher)
+	id, err := attendeeService.CreateVoucher(ctx, voucher)
+	if err != nil {
+		voucherErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.Header().Set(headers.Location, fmt.Sprintf("/api/rest/v1/vouchers/%d", id))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func getVoucherHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	info, err := voucherByIdMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	w.Header().Add(headers.ContentType, media.ContentTypeApplicationJson)
+	ctlutil.WriteJson(ctx, w, mapVoucherInfoToDto(info))
+}
+
+// updateVoucherHandler changes everything but the code of a voucher.
+//
+// To stop a voucher from being redeemed, set expires or max_uses.
+func updateVoucherHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	info, err := voucherByIdMustReturnOnError(ctx, w, r)
+	if err != nil {
+		return
+	}
+
+	dto := &vouchers.VoucherDto{}
+	if err := parseBody(ctx, w, r, dto); err != nil {
+		return
+	}
+	validationErrs := validate(ctx, dto, info.Voucher.Code)
+	if len(validationErrs) != 0 {
+		voucherValidationErrorHandler(ctx, w, r, validationErrs)
+		return
+	}
+
+	mapDtoToVoucher(dto, info.Voucher)
+	if err := attendeeService.UpdateVoucher(ctx, info.Voucher); err != nil {
+		voucherErrorHandler(ctx, w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- error handlers ---
+
+func invalidVoucherIdErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, id string) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received invalid voucher id '%s'", id)
+	ctlutil.ErrorHandler(ctx, w, r, "voucher.id.invalid", http.StatusBadRequest, url.Values{})
+}
+
+func voucherParseErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("voucher body could not be parsed: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "voucher.parse.error", http.StatusBadRequest, url.Values{})
+}
+
+func voucherValidationErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, errs url.Values) {
+	aulogging.Logger.Ctx(ctx).Warn().Printf("received voucher data with validation errors: %v", errs)
+	ctlutil.ErrorHandler(ctx, w, r, "voucher.data.invalid", http.StatusBadRequest, errs)
+}
+
+func voucherReadErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("could not read vouchers: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "voucher.read.error", http.StatusInternalServerError, url.Values{})
+}
+
+func voucherErrorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, attendeesrv.VoucherNotFoundError) {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("voucher not found: %s", err.Error())
+		ctlutil.ErrorHandler(ctx, w, r, "voucher.id.notfound", http.StatusNotFound, url.Values{})
+		return
+	}
+	if errors.Is(err, attendeesrv.VoucherCodeExistsError) {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("duplicate voucher code: %s", err.Error())
+		ctlutil.ErrorHandler(ctx, w, r, "voucher.code.duplicate", http.StatusConflict, url.Values{"code": []string{attendeesrv.VoucherCodeExistsError.Error()}})
+		return
+	}
+
+	aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("could not write voucher: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "voucher.write.error", http.StatusInternalServerError, url.Values{})
+}
+
+// --- helpers ---
+
+func voucherByIdMustReturnOnError(ctx context.Context, w http.ResponseWriter, r *http.Request) (*attendeesrv.VoucherInfo, error) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil || id == 0 {
+		invalidVoucherIdErrorHandler(ctx, w, r, idStr)
+		return nil, errors.New("invalid voucher id")
+	}
+	info, err := attendeeService.GetVoucher(ctx, uint(id))
+	if err != nil {
+		if errors.Is(err, attendeesrv.VoucherNotFoundError) {
+			voucherErrorHandler(ctx, w, r, err)
+		} else {
+			voucherReadErrorHandler(ctx, w, r, err)
+		}
+		return nil, err
+	}
+	return info, nil
+}
+
+func parseBody(ctx context.Context, w http.ResponseWriter, r *http.Request, dto interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(dto)
+	if err != nil {
+		voucherParseErrorHandler(ctx, w, r, err)
+	}
+	return err
+}
+
+func mapDtoToVoucher(dto *vouchers.VoucherDto, v *entity.Voucher) {
+	// do not map id or uses
+	v.Code = dto.Code
+	v.Description = dto.Description
+	v.Percent = dto.Percent
+	v.Amount = dto.Amount
+	v.Packages = dto.Packages
+	v.MaxUses = dto.MaxUses
+	v.Expires = nil
+	if dto.Expires != "" {
+		// already validated
+		expires, _ := time.Parse(time.RFC3339, dto.Expires)
+		expires = expires.UTC()
+		v.Expires = &expires
+	}
+}
+
+func mapVoucherInfoToDto(info *attendeesrv.VoucherInfo) vouchers.VoucherDto {
+	dto := vouchers.VoucherDto{
+		Id:          fmt.Sprint(info.Voucher.ID),
+		Code:        info.Voucher.Code,
+		Description: info.Voucher.Description,
+		Percent:     info.Voucher.Percent,
+		Amount:      info.Voucher.Amount,
+		Packages:    info.Voucher.Packages,
+		MaxUses:     info.Voucher.MaxUses,
+		Uses:        info.Uses,
+	}
+	if info.Voucher.Expires != nil {
+		dto.Expires = info.Voucher.Expires.UTC().Format(time.RFC3339)
+	}
+	return dto
+}
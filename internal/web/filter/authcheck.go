@@ -3,48 +3,127 @@ package filter
 import (
 	"errors"
 	"fmt"
+	aulogging "github.com/StephanHCB/go-autumn-logging"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/service/authsrv"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
-func HasRoleOrApiToken(role string, handler http.HandlerFunc) http.HandlerFunc {
+var authorizationService authsrv.AuthorizationService = &authsrv.AuthorizationServiceImplData{}
+
+// use only for testing
+func OverrideAuthorizationService(overrideAuthorizationServiceForTesting authsrv.AuthorizationService) {
+	authorizationService = overrideAuthorizationServiceForTesting
+}
+
+// HasPermission lets logged in users through whose effective permissions include the given permission,
+// see authsrv. Api clients need the given scope instead.
+func HasPermission(permission string, scope string, handler http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		if ctxvalues.HasApiToken(ctx) || ctxvalues.IsAuthorizedAsRole(ctx, role) {
+		if ctxvalues.HasApiScope(ctx, scope) {
+			handler(w, r)
+			return
+		}
+		if client := ctxvalues.ApiClient(ctx); client != "" {
+			ctlutil.UnauthorizedError(ctx, w, r, "you are not authorized for this operation - the attempt has been logged", fmt.Sprintf("unauthorized access attempt for scope %s by api client %s", scope, client))
+			return
+		}
+		culprit := ctxvalues.Subject(ctx)
+		if culprit == "" {
+			ctlutil.UnauthenticatedError(ctx, w, r, "you must be logged in for this operation", "anonymous access attempt")
+			return
+		}
+
+		allowed, err := authorizationService.HasPermission(ctx, permission)
+		if err != nil {
+			permissionsErrorHandler(w, r, err)
+			return
+		}
+		if allowed {
 			handler(w, r)
 		} else {
-			culprit := ctxvalues.Subject(ctx)
-			if culprit != "" {
-				ctlutil.UnauthorizedError(ctx, w, r, "you are not authorized for this operation - the attempt has been logged", fmt.Sprintf("unauthorized access attempt for role %s by %s", role, culprit))
-			} else {
-				ctlutil.UnauthenticatedError(ctx, w, r, "you must be logged in for this operation", "anonymous access attempt")
-			}
+			ctlutil.UnauthorizedError(ctx, w, r, "you are not authorized for this operation - the attempt has been logged", fmt.Sprintf("unauthorized access attempt for permission %s by %s", permission, culprit))
+		}
+	}
+}
+
+// LoggedIn only lets through logged in users, for endpoints that act on behalf of the user, not even api clients.
+func LoggedIn(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if ctxvalues.Subject(ctx) != "" {
+			handler(w, r)
+		} else if client := ctxvalues.ApiClient(ctx); client != "" {
+			ctlutil.UnauthorizedError(ctx, w, r, "you are not authorized for this operation - the attempt has been logged", fmt.Sprintf("api client %s attempted to access an endpoint for logged in users only", client))
+		} else {
+			ctlutil.UnauthenticatedError(ctx, w, r, "you must be logged in for this operation", "anonymous access attempt")
 		}
 	}
 }
 
 func LoggedInOrApiToken(handler http.HandlerFunc) http.HandlerFunc {
+	return LoggedInOrApiScope(config.ApiScopeAll, handler)
+}
+
+// LoggedInOrApiScope also lets api clients through that have the given scope.
+func LoggedInOrApiScope(scope string, handler http.HandlerFunc) http.HandlerFunc {
+	return LoggedInOrApiScopes([]string{scope}, handler)
+}
+
+// LoggedInOrApiScopes also lets api clients through that have any of the given scopes.
+func LoggedInOrApiScopes(scopes []string, handler http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		if ctxvalues.HasApiToken(ctx) || ctxvalues.Subject(ctx) != "" {
+		if ctxvalues.Subject(ctx) != "" {
 			handler(w, r)
+			return
+		}
+		for _, scope := range scopes {
+			if ctxvalues.HasApiScope(ctx, scope) {
+				handler(w, r)
+				return
+			}
+		}
+		if client := ctxvalues.ApiClient(ctx); client != "" {
+			ctlutil.UnauthorizedError(ctx, w, r, "you are not authorized for this operation - the attempt has been logged", fmt.Sprintf("unauthorized access attempt for scope %s by api client %s", strings.Join(scopes, " or "), client))
 		} else {
 			ctlutil.UnauthenticatedError(ctx, w, r, "you must be logged in for this operation", "anonymous access attempt")
 		}
 	}
 }
 
-// IsSubjectOrRoleOrApiToken cannot be used as a filter because the subject needs to be loaded from the database first (part of the attendee admin data). Use in your handler functions.
+// IsSubjectOrPermissionOrApiScope cannot be used as a filter because the subject needs to be loaded from the database first (part of the attendee admin data). Use in your handler functions.
+//
+// Lets through the owner of the data, users with the given permission, and api clients with the given scope.
 //
 // Do not forget to return from the handler if an error is returned!
-func IsSubjectOrRoleOrApiToken(w http.ResponseWriter, r *http.Request, subject string, role string) error {
+func IsSubjectOrPermissionOrApiScope(w http.ResponseWriter, r *http.Request, subject string, permission string, scope string) error {
 	ctx := r.Context()
-	if ctxvalues.HasApiToken(ctx) || ctxvalues.IsAuthorizedAsRole(ctx, role) || ctxvalues.Subject(ctx) == subject {
+	if ctxvalues.HasApiScope(ctx, scope) || (ctxvalues.Subject(ctx) != "" && ctxvalues.Subject(ctx) == subject) {
+		return nil
+	}
+
+	allowed, err := authorizationService.HasPermission(ctx, permission)
+	if err != nil {
+		permissionsErrorHandler(w, r, err)
+		return err
+	}
+	if allowed {
 		return nil
-	} else {
-		culprit := ctxvalues.Subject(ctx)
-		ctlutil.UnauthorizedError(ctx, w, r, "you are not authorized to access this data - the attempt has been logged", fmt.Sprintf("unauthorized access attempt for %s by %s", subject, culprit))
-		return errors.New("neither api token nor subject match - unauthorized")
 	}
+
+	culprit := ctxvalues.UserId(ctx)
+	ctlutil.UnauthorizedError(ctx, w, r, "you are not authorized to access this data - the attempt has been logged", fmt.Sprintf("unauthorized access attempt for %s by %s", subject, culprit))
+	return errors.New("neither api token nor subject nor permission match - unauthorized")
+}
+
+func permissionsErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	ctx := r.Context()
+	aulogging.Logger.Ctx(ctx).Error().WithErr(err).Printf("failed to determine permissions: %s", err.Error())
+	ctlutil.ErrorHandler(ctx, w, r, "auth.permissions.error", http.StatusInternalServerError, url.Values{})
 }
@@ -1,8 +1,10 @@
 package middleware
 
 import (
-	"crypto/rsa"
+	"crypto"
+	"errors"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/jwks"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctlutil"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/ctxvalues"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
@@ -10,6 +12,7 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 	"net/http"
 	"strings"
+	"time"
 )
 
 // --- getting the values from the request ---
@@ -49,27 +52,77 @@ func fromApiTokenHeader(r *http.Request) string {
 
 // --- middleware validating the values and adding to context values ---
 
-func keyFuncForKey(rsaPublicKey *rsa.PublicKey) func(token *jwt.Token) (interface{}, error) {
+func keyFuncForKey(publicKey crypto.PublicKey) func(token *jwt.Token) (interface{}, error) {
 	return func(token *jwt.Token) (interface{}, error) {
-		return rsaPublicKey, nil
+		return publicKey, nil
 	}
 }
 
-// TODO example - no idea if this matches the idp claims structure - compare to room service!
+var validSigningMethods = []string{"RS256", "RS512", "ES256"}
 
-type GlobalClaims struct {
-	Name  string   `json:"name"`
-	EMail string   `json:"email"`
-	Roles []string `json:"roles"`
+// keysForToken returns the keys from the keyset endpoint that match the key id in the token header,
+// followed by the keys configured in PEM format.
+func keysForToken(tokenString string) []crypto.PublicKey {
+	kid := ""
+	if token, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{}); err == nil {
+		kid, _ = token.Header["kid"].(string)
+	}
+	return append(jwks.Get().KeysFor(kid), config.OidcKeySet()...)
+}
+
+// validateClaims checks issuer and audience, and the time based claims with the configured clock skew tolerance.
+func validateClaims(claims jwt.MapClaims, now time.Time) error {
+	if !claims.VerifyIssuer(config.OidcIssuer(), true) {
+		return errors.New("token has wrong issuer")
+	}
+	if !claims.VerifyAudience(config.OidcAudience(), true) {
+		return errors.New("token has wrong audience")
+	}
+	skew := config.OidcClockSkew()
+	if !claims.VerifyExpiresAt(now.Add(-skew).Unix(), false) {
+		return errors.New("token is expired")
+	}
+	if !claims.VerifyNotBefore(now.Add(skew).Unix(), false) {
+		return errors.New("token is not valid yet")
+	}
+	if !claims.VerifyIssuedAt(now.Add(skew).Unix(), false) {
+		return errors.New("token used before issued")
+	}
+	return nil
+}
+
+// claimByPath looks up a claim by its configured path, with nested levels separated by dots.
+func claimByPath(claims jwt.MapClaims, path string) interface{} {
+	var current interface{} = map[string]interface{}(claims)
+	for _, level := range strings.Split(path, ".") {
+		asMap, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = asMap[level]
+	}
+	return current
 }
 
-type CustomClaims struct {
-	Global GlobalClaims `json:"global"`
+func stringClaim(claims jwt.MapClaims, path string) string {
+	value, _ := claimByPath(claims, path).(string)
+	return value
 }
 
-type AllClaims struct {
-	jwt.RegisteredClaims
-	CustomClaims
+// rolesClaim accepts a list of strings or a single string.
+func rolesClaim(claims jwt.MapClaims, path string) []string {
+	result := make([]string, 0)
+	switch value := claimByPath(claims, path).(type) {
+	case string:
+		result = append(result, value)
+	case []interface{}:
+		for _, entry := range value {
+			if role, ok := entry.(string); ok {
+				result = append(result, role)
+			}
+		}
+	}
+	return result
 }
 
 func TokenValidator(next http.Handler) http.Handler {
@@ -80,8 +133,8 @@ func TokenValidator(next http.Handler) http.Handler {
 		apiTokenValue := fromApiTokenHeader(r)
 		if apiTokenValue != "" {
 			// ignore jwt if set (may still need to pass it through to other service)
-			if apiTokenValue == config.FixedApiToken() {
-				ctxvalues.SetApiToken(ctx, apiTokenValue)
+			if clientName, scopes, ok := config.ApiClientForToken(apiTokenValue); ok {
+				ctxvalues.SetApiClient(ctx, clientName, scopes)
 				next.ServeHTTP(w, r)
 			} else {
 				ctlutil.UnauthenticatedError(ctx, w, r, "invalid api token", "request supplied invalid api token, denying")
@@ -100,26 +153,28 @@ func TokenValidator(next http.Handler) http.Handler {
 
 			tokenString := strings.TrimSpace(strings.TrimPrefix(bearerTokenValue, bearerPrefix))
 
-			errorMessage := ""
-			for _, key := range config.OidcKeySet() {
-				claims := AllClaims{}
-				token, err := jwt.ParseWithClaims(tokenString, &claims, keyFuncForKey(key), jwt.WithValidMethods([]string{"RS256", "RS512"}))
+			errorMessage := "no keys configured"
+			for _, key := range keysForToken(tokenString) {
+				claims := jwt.MapClaims{}
+				// the time based claims are checked in validateClaims, because they need the clock skew tolerance
+				token, err := jwt.ParseWithClaims(tokenString, &claims, keyFuncForKey(key), jwt.WithValidMethods(validSigningMethods), jwt.WithoutClaimsValidation())
 				if err == nil && token.Valid {
-					parsedClaims, ok := token.Claims.(*AllClaims)
-					if ok {
-						// TODO this is probably not the exact token structure
-						ctxvalues.SetBearerToken(ctx, bearerTokenValue)
-						ctxvalues.SetEmail(ctx, parsedClaims.Global.EMail)
-						ctxvalues.SetName(ctx, parsedClaims.Global.Name)
-						ctxvalues.SetSubject(ctx, parsedClaims.Subject)
-						for _, role := range parsedClaims.Global.Roles {
-							ctxvalues.SetAuthorizedAsRole(ctx, role)
-						}
-
-						next.ServeHTTP(w, r)
-						return
+					if err := validateClaims(claims, time.Now()); err != nil {
+						// the signature was valid, so no other key will do better
+						errorMessage = err.Error()
+						break
+					}
+
+					ctxvalues.SetBearerToken(ctx, bearerTokenValue)
+					ctxvalues.SetEmail(ctx, stringClaim(claims, config.OidcEmailClaim()))
+					ctxvalues.SetName(ctx, stringClaim(claims, config.OidcNameClaim()))
+					ctxvalues.SetSubject(ctx, stringClaim(claims, config.OidcSubjectClaim()))
+					for _, role := range rolesClaim(claims, config.OidcRolesClaim()) {
+						ctxvalues.SetAuthorizedAsRole(ctx, role)
 					}
-					errorMessage = "empty claims substructure"
+
+					next.ServeHTTP(w, r)
+					return
 				} else if err != nil {
 					errorMessage = err.Error()
 				} else {
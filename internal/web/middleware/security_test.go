@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func tstClaims(t *testing.T, json string) jwt.MapClaims {
+	claims := jwt.MapClaims{}
+	_, _, err := jwt.NewParser().ParseUnverified("eyJhbGciOiJSUzI1NiJ9."+jwt.EncodeSegment([]byte(json))+".c2ln", &claims)
+	require.Nil(t, err)
+	return claims
+}
+
+func TestClaimByPath(t *testing.T) {
+	docs.Description("check that claims are found by their configured path, including nested claims")
+	claims := tstClaims(t, `{"sub":"101","email":"jsquirrel@example.com","groups":"staff","realm_access":{"roles":["admin","staff",17]}}`)
+
+	require.Equal(t, "101", stringClaim(claims, "sub"))
+	require.Equal(t, "jsquirrel@example.com", stringClaim(claims, "email"))
+	require.Equal(t, "", stringClaim(claims, "global.name"))
+	require.Equal(t, []string{"admin", "staff"}, rolesClaim(claims, "realm_access.roles"))
+	require.Equal(t, []string{"staff"}, rolesClaim(claims, "groups"))
+	require.Equal(t, []string{}, rolesClaim(claims, "sub.roles"))
+}
+
+func TestValidateClaims(t *testing.T) {
+	docs.Description("check that issuer and audience are required, and the time based claims allow for clock skew")
+	config.LoadTestingConfigurationFromPathOrAbort("../../../test/testconfig-public.yaml")
+	now := time.Unix(1600000000, 0)
+
+	require.Nil(t, validateClaims(tstClaims(t, `{"iss":"http://identity.localhost/","aud":["other","reg-attendee-service"],"exp":1599999990}`), now))
+	require.EqualError(t, validateClaims(tstClaims(t, `{"iss":"http://identity.localhost/","aud":"reg-attendee-service","exp":1599999960}`), now), "token is expired")
+	require.Nil(t, validateClaims(tstClaims(t, `{"iss":"http://identity.localhost/","aud":"reg-attendee-service","nbf":1600000010,"iat":1600000010}`), now))
+	require.EqualError(t, validateClaims(tstClaims(t, `{"iss":"http://identity.localhost/","aud":"reg-attendee-service","nbf":1600000040}`), now), "token is not valid yet")
+	require.EqualError(t, validateClaims(tstClaims(t, `{"aud":"reg-attendee-service"}`), now), "token has wrong issuer")
+	require.EqualError(t, validateClaims(tstClaims(t, `{"iss":"http://identity.localhost/"}`), now), "token has wrong audience")
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	aulogging "github.com/StephanHCB/go-autumn-logging"
 	"net/http"
+	"time"
 )
 
 // --- response helpers ---
@@ -18,3 +19,13 @@ func WriteJson(ctx context.Context, w http.ResponseWriter, v interface{}) {
 		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("error while encoding json response: %s", err.Error())
 	}
 }
+
+// ExtendWriteDeadline lets a long running response, such as an export, exceed the server write timeout,
+// without raising the write timeout for all other requests.
+//
+// Call it before producing each part of the response, with enough time to produce and write that part.
+func ExtendWriteDeadline(ctx context.Context, w http.ResponseWriter, timeout time.Duration) {
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		aulogging.Logger.Ctx(ctx).Warn().WithErr(err).Printf("failed to extend write deadline: %s", err.Error())
+	}
+}
@@ -4,17 +4,20 @@ import (
 	"context"
 	"fmt"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"strings"
 )
 
 const ContextMap = "map"
 
 const ContextRequestId = "requestid"
 const ContextBearerToken = "bearertoken"
-const ContextApiToken = "apitoken"
+const ContextApiClient = "apiclient"
+const ContextApiScopes = "apiscopes"
 const ContextAuthorizedAs = "authorizedas"
 const ContextEmail = "email"
 const ContextName = "name"
 const ContextSubject = "subject"
+const ContextPermissions = "permissions"
 
 func CreateContextWithValueMap(ctx context.Context) context.Context {
 	// this is so we can add values to our context, like ... I don't know ... the http status from the response!
@@ -25,17 +28,22 @@ func CreateContextWithValueMap(ctx context.Context) context.Context {
 }
 
 func valueOrDefault(ctx context.Context, key string, defaultValue string) string {
+	if val, ok := lookupValue(ctx, key); ok {
+		return val
+	} else {
+		return defaultValue
+	}
+}
+
+func lookupValue(ctx context.Context, key string) (string, bool) {
 	contextMapUntyped := ctx.Value(ContextMap)
 	if contextMapUntyped == nil {
-		return defaultValue
+		return "", false
 	}
 	contextMap := contextMapUntyped.(map[string]string)
 
-	if val, ok := contextMap[key]; ok {
-		return val
-	} else {
-		return defaultValue
-	}
+	val, ok := contextMap[key]
+	return val, ok
 }
 
 func setValue(ctx context.Context, key string, value string) {
@@ -86,13 +94,59 @@ func SetSubject(ctx context.Context, Subject string) {
 	setValue(ctx, ContextSubject, Subject)
 }
 
+// ApiClient is the name of the api client that authenticated with its api token, or "".
+func ApiClient(ctx context.Context) string {
+	return valueOrDefault(ctx, ContextApiClient, "")
+}
+
+func SetApiClient(ctx context.Context, name string, scopes []string) {
+	setValue(ctx, ContextApiClient, name)
+	setValue(ctx, ContextApiScopes, strings.Join(scopes, ","))
+}
+
+// HasApiToken is true if the request was made by an api client with full access.
+//
+// Api clients with limited scopes need to be checked using HasApiScope.
 func HasApiToken(ctx context.Context) bool {
-	v := valueOrDefault(ctx, ContextApiToken, "")
-	return v == config.FixedApiToken()
+	return HasApiScope(ctx, config.ApiScopeAll)
+}
+
+// HasApiScope is true if the request was made by an api client with the given scope, or full access.
+func HasApiScope(ctx context.Context, scope string) bool {
+	if ApiClient(ctx) == "" {
+		return false
+	}
+	for _, s := range strings.Split(valueOrDefault(ctx, ContextApiScopes, ""), ",") {
+		if s == scope || s == config.ApiScopeAll {
+			return true
+		}
+	}
+	return false
+}
+
+// UserId identifies who made the request, for the history. Api clients are prefixed with "api:".
+func UserId(ctx context.Context) string {
+	if client := ApiClient(ctx); client != "" {
+		return "api:" + client
+	}
+	return Subject(ctx)
+}
+
+// Permissions returns the effective permissions of the caller, and false if they have not been resolved
+// during this request yet.
+func Permissions(ctx context.Context) ([]string, bool) {
+	value, ok := lookupValue(ctx, ContextPermissions)
+	if !ok {
+		return nil, false
+	}
+	if value == "" {
+		return []string{}, true
+	}
+	return strings.Split(value, ","), true
 }
 
-func SetApiToken(ctx context.Context, apiToken string) {
-	setValue(ctx, ContextApiToken, apiToken)
+func SetPermissions(ctx context.Context, permissions []string) {
+	setValue(ctx, ContextPermissions, strings.Join(permissions, ","))
 }
 
 func IsAuthorizedAsRole(ctx context.Context, role string) bool {
@@ -18,3 +18,24 @@ func TestRetrieveRequestId(t *testing.T) {
 	SetRequestId(ctx, "hallo")
 	require.Equal(t, "hallo", RequestId(ctx), "unexpected value retrieving request id that was just set")
 }
+
+func TestPermissionsNotResolved(t *testing.T) {
+	docs.Description("permissions that have not been resolved during this request should be reported as such")
+	ctx := CreateContextWithValueMap(context.TODO())
+	_, ok := Permissions(ctx)
+	require.False(t, ok, "unexpected resolved permissions in fresh context")
+}
+
+func TestRetrievePermissions(t *testing.T) {
+	docs.Description("it should be possible to cache resolved permissions, including none at all, in an initialized context")
+	ctx := CreateContextWithValueMap(context.TODO())
+	SetPermissions(ctx, []string{})
+	permissions, ok := Permissions(ctx)
+	require.True(t, ok, "empty permissions were not cached")
+	require.Empty(t, permissions)
+
+	SetPermissions(ctx, []string{"read_all", "regdesk"})
+	permissions, ok = Permissions(ctx)
+	require.True(t, ok, "permissions were not cached")
+	require.Equal(t, []string{"read_all", "regdesk"}, permissions)
+}
@@ -0,0 +1,40 @@
+package spreadsheet
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCsvWriter(w io.Writer) Writer {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) WriteRow(cells []interface{}) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		switch v := cell.(type) {
+		case string:
+			record[i] = v
+		case float64:
+			record[i] = fmt.Sprintf("%.2f", v)
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	if err := c.w.Write(record); err != nil {
+		return err
+	}
+	// flush every row so the output is actually streamed
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,38 @@
+// Package spreadsheet writes tabular data row by row, so large lists can be streamed to the client.
+package spreadsheet
+
+import (
+	"errors"
+	"io"
+)
+
+const (
+	FormatCsv  = "csv"
+	FormatXlsx = "xlsx"
+)
+
+// Writer writes rows of cells. Cells may be strings, int64 or float64 values.
+//
+// You must call Close() after the last row, or the output will be incomplete.
+type Writer interface {
+	WriteRow(cells []interface{}) error
+	Close() error
+}
+
+func New(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCsv:
+		return newCsvWriter(w), nil
+	case FormatXlsx:
+		return newXlsxWriter(w)
+	default:
+		return nil, errors.New("unsupported spreadsheet format " + format)
+	}
+}
+
+func ContentType(format string) string {
+	if format == FormatXlsx {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
@@ -0,0 +1,62 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+func TestCsv(t *testing.T) {
+	docs.Description("csv output quotes values as needed and formats amounts with two decimals")
+	buf := &bytes.Buffer{}
+	cut, err := New(FormatCsv, buf)
+	require.Nil(t, err)
+	require.Nil(t, cut.WriteRow([]interface{}{"id", "nickname", "dues"}))
+	require.Nil(t, cut.WriteRow([]interface{}{int64(1), "Black, Cheetah", 255.0}))
+	require.Nil(t, cut.Close())
+	require.Equal(t, "id,nickname,dues\n1,\"Black, Cheetah\",255.00\n", buf.String())
+}
+
+func TestXlsx(t *testing.T) {
+	docs.Description("xlsx output is a zip file containing a worksheet with inline strings and numeric cells")
+	buf := &bytes.Buffer{}
+	cut, err := New(FormatXlsx, buf)
+	require.Nil(t, err)
+	require.Nil(t, cut.WriteRow([]interface{}{"id", "nickname"}))
+	require.Nil(t, cut.WriteRow([]interface{}{int64(1), "Black & Cheetah"}))
+	require.Nil(t, cut.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.Nil(t, err)
+	names := make([]string, 0)
+	var sheet string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			r, err := f.Open()
+			require.Nil(t, err)
+			content, _ := io.ReadAll(r)
+			sheet = string(content)
+		}
+	}
+	require.EqualValues(t, []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"}, names)
+	require.Contains(t, sheet, `<row r="2"><c r="A2"><v>1</v></c><c r="B2" t="inlineStr"><is><t xml:space="preserve">Black &amp; Cheetah</t></is></c></row>`)
+}
+
+func TestUnknownFormat(t *testing.T) {
+	docs.Description("unknown formats are rejected")
+	_, err := New("ods", &bytes.Buffer{})
+	require.NotNil(t, err)
+}
+
+func TestColumnName(t *testing.T) {
+	docs.Description("column names are formed like in common spreadsheet software")
+	require.Equal(t, "A", columnName(0))
+	require.Equal(t, "Z", columnName(25))
+	require.Equal(t, "AA", columnName(26))
+	require.Equal(t, "AZ", columnName(51))
+	require.Equal(t, "BA", columnName(52))
+}
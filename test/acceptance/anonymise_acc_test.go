@@ -0,0 +1,172 @@
+package acceptance
+
+import (
+	"context"
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/service/attendeesrv"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// --------------------------------------------------
+// acceptance tests for the anonymisation of attendees
+// --------------------------------------------------
+
+func TestAnonymise_AnonDeny(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a deleted attendee")
+	location1, _ := tstRegisterAttendeeAndTransitionToStatus(t, "anon1-", "deleted")
+
+	docs.When("when an unauthenticated user attempts to anonymise them")
+	response := tstPerformPost(location1+"/anonymise", "", tstNoToken())
+
+	docs.Then("then the request is denied as unauthenticated (401) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusUnauthorized, "auth.unauthorized", "you must be logged in for this operation")
+}
+
+func TestAnonymise_UserDenySelf(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a deleted attendee")
+	location1, attendee1 := tstRegisterAttendeeAndTransitionToStatus(t, "anon2-", "deleted")
+
+	docs.When("when they attempt to anonymise their own registration")
+	response := tstPerformPost(location1+"/anonymise", "", tstValidUserToken(t, attendee1.Id))
+
+	docs.Then("then the request is denied as unauthorized (403) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+func TestAnonymise_AdminNotEligible(t *testing.T) {
+	docs.Given("given the configuration for standard registration, which does not specify the end of the convention")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee who has paid")
+	location1, attendee1 := tstRegisterAttendeeAndTransitionToStatus(t, "anon3-", "paid")
+
+	docs.When("when an admin attempts to anonymise them")
+	response := tstPerformPost(location1+"/anonymise", "", tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the appropriate error")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "attendee.anonymise.notallowed", url.Values{"details": []string{"attendee can only be anonymised after deletion or once the convention is over"}})
+
+	docs.Then("and the attendee data is unchanged")
+	require.EqualValues(t, attendee1, tstReadAttendee(t, location1))
+}
+
+func TestAnonymise_AdminDeleted(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee who has changed their name, has additional info and admin comments, and was then deleted")
+	token := tstValidUserToken(t, "101")
+	location1, attendee1 := tstRegisterAttendeeWithToken(t, "anon4-", token)
+	changedAttendee := attendee1
+	changedAttendee.FirstName = "Eva"
+	updateResponse := tstPerformPut(location1, tstRenderJson(changedAttendee), token)
+	require.Equal(t, http.StatusOK, updateResponse.status, "unexpected http response status for update")
+	tstWriteAdditionalInfo(t, attendee1.Id, "regdesk", `{"comment":"Hans Mustermann"}`)
+	adminResponse := tstPerformPut(location1+"/admin", `{"admin_comments":"called Hans at +49-30-123"}`, tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, adminResponse.status, "unexpected http response status for admin info update")
+	attid, _ := strconv.Atoi(attendee1.Id)
+	_ = database.GetRepository().AddStatusChange(context.Background(), tstCreateStatusChange(attid, "deleted"))
+
+	docs.When("when an admin anonymises them")
+	response := tstPerformPost(location1+"/anonymise", "", tstValidAdminToken(t))
+
+	docs.Then("then the request is successful")
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+
+	docs.Then("and their personal data has been replaced by placeholders, while badge number and choices are kept")
+	tstRequireAnonymised(t, attendee1)
+
+	docs.Then("and their personal data has been removed from the history")
+	tstRequireHistoryScrubbed(t, "Attendee", uint(attid), "Hans", "Eva", "Mustermann", "packetloss.de")
+	tstRequireHistoryScrubbed(t, "AdminInfo", uint(attid), "called Hans")
+}
+
+func TestAnonymise_RetentionPolicy(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee who was deleted, and one who has paid")
+	_, attendee1 := tstRegisterAttendeeAndTransitionToStatus(t, "anon5a-", "deleted")
+	location2, attendee2 := tstRegisterAttendeeAndTransitionToStatus(t, "anon5b-", "paid")
+
+	docs.Given("given a retention period of 30 days, and no end of the convention")
+	config.Configuration().Retention.AnonymiseAfterDays = 30
+
+	docs.When("when the retention policy is applied")
+	count, err := (&attendeesrv.AttendeeServiceImplData{}).ApplyRetentionPolicy(context.Background())
+
+	docs.Then("then only the deleted attendee is anonymised")
+	require.Nil(t, err)
+	require.Equal(t, 1, count)
+	tstRequireAnonymised(t, attendee1)
+	require.EqualValues(t, attendee2, tstReadAttendee(t, location2))
+
+	docs.When("when the convention has ended long ago and the retention policy is applied again")
+	config.Configuration().Retention.ConventionEndIsoDate = "2020-08-30"
+	count, err = (&attendeesrv.AttendeeServiceImplData{}).ApplyRetentionPolicy(context.Background())
+
+	docs.Then("then the remaining attendee is also anonymised")
+	require.Nil(t, err)
+	require.Equal(t, 1, count)
+	tstRequireAnonymised(t, attendee2)
+}
+
+// helper functions
+
+func tstRequireAnonymised(t *testing.T, original attendee.AttendeeDto) {
+	attid, err := strconv.Atoi(original.Id)
+	require.Nil(t, err)
+	actual, err := database.GetRepository().GetAttendeeById(context.Background(), uint(attid))
+	require.Nil(t, err)
+	require.Equal(t, "anonymised", actual.FirstName)
+	require.Equal(t, "anonymised", actual.LastName)
+	require.Equal(t, "anonymised", actual.Street)
+	require.Equal(t, "anonymised@anonymised.invalid", actual.Email)
+	require.Equal(t, "1900-01-01", actual.Birthday)
+	require.Equal(t, "", actual.Telegram)
+	require.Equal(t, "", actual.Identity)
+	require.True(t, actual.Anonymised)
+	require.Equal(t, original.Nickname, actual.Nickname)
+	require.Equal(t, original.Packages, actual.Packages)
+
+	adminInfo, err := database.GetRepository().GetAdminInfoByAttendeeId(context.Background(), uint(attid))
+	require.Nil(t, err)
+	require.Equal(t, "", adminInfo.AdminComments)
+
+	additionalInfo, err := database.GetRepository().GetAllAdditionalInfoFor(context.Background(), uint(attid))
+	require.Nil(t, err)
+	for _, ad := range additionalInfo {
+		require.Equal(t, "{}", ad.JsonValue)
+		tstRequireHistoryScrubbed(t, "AdditionalInfo", ad.ID, "Hans")
+	}
+}
+
+func tstRequireHistoryScrubbed(t *testing.T, entityName string, entityId uint, forbidden ...string) {
+	entries, err := database.GetRepository().GetHistoryByEntity(context.Background(), entityName, entityId)
+	require.Nil(t, err)
+	require.NotEmpty(t, entries, "expected history entries for "+entityName)
+	for _, h := range entries {
+		for _, f := range forbidden {
+			require.False(t, strings.Contains(h.Diff, f), "history still contains '"+f+"': "+h.Diff)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package acceptance
+
+import (
+	"context"
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+// ------------------------------------------
+// acceptance tests for scoped api clients
+// ------------------------------------------
+
+const tstKioskApiToken = "api-token-for-the-regdesk-kiosk"
+const tstPaymentServiceApiToken = "api-token-for-the-payment-service"
+
+func TestApiClientReadScopeAllowRead(t *testing.T) {
+	docs.Given("given the configuration for standard registration, with an api client that may only read")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	location, att := tstRegisterAttendee(t, "api1-")
+
+	docs.When("when the api client reads the attendee")
+	response := tstPerformWithApiToken(http.MethodGet, location, "", tstKioskApiToken)
+
+	docs.Then("then the request is successful")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	require.Contains(t, response.body, att.Nickname)
+}
+
+func TestApiClientReadScopeDenyWrite(t *testing.T) {
+	docs.Given("given the configuration for standard registration, with an api client that may only read")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	location, att := tstRegisterAttendee(t, "api2-")
+
+	docs.When("when the api client attempts to update the attendee")
+	att.Nickname = "Kiosk"
+	response := tstPerformWithApiToken(http.MethodPut, location, tstRenderJson(att), tstKioskApiToken)
+
+	docs.Then("then the request is denied")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+func TestApiClientReadScopeDenyStatusChange(t *testing.T) {
+	docs.Given("given the configuration for standard registration, with a kiosk api client that may only read and check in")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	location, _ := tstRegisterAttendee(t, "api3-")
+
+	docs.When("when the api client attempts a status change")
+	body := status.StatusChangeDto{Status: "approved", Comment: "api3"}
+	response := tstPerformWithApiToken(http.MethodPost, location+"/status", tstRenderJson(body), tstKioskApiToken)
+
+	docs.Then("then the request is denied")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", url.Values{"details": []string{"you are not allowed to make this status transition - the attempt has been logged"}})
+	tstVerifyStatus(t, location, "new")
+}
+
+func TestApiClientPaymentsScopeAllowPaymentStatusChange(t *testing.T) {
+	docs.Given("given the configuration for standard registration, with an api client that may only change the payment status")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an approved attendee who has paid part of their dues")
+	location, att := tstRegisterAttendeeAndTransitionToStatus(t, "api4-", "approved")
+	attid, _ := strconv.Atoi(att.Id)
+	_ = paymentMock.InjectTransaction(context.Background(), tstCreateTransaction(attid, paymentservice.Payment, 15500))
+
+	docs.When("when the api client changes the status to partially paid")
+	body := status.StatusChangeDto{Status: "partially paid", Comment: "api4"}
+	response := tstPerformWithApiToken(http.MethodPost, location+"/status", tstRenderJson(body), tstPaymentServiceApiToken)
+
+	docs.Then("then the request is successful and the status has changed")
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+	tstVerifyStatus(t, location, "partially paid")
+}
+
+func TestApiClientPaymentsScopeDenyApprove(t *testing.T) {
+	docs.Given("given the configuration for standard registration, with an api client that may only change the payment status")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	location, _ := tstRegisterAttendee(t, "api7-")
+
+	docs.When("when the api client attempts to approve the attendee")
+	body := status.StatusChangeDto{Status: "approved", Comment: "api7"}
+	response := tstPerformWithApiToken(http.MethodPost, location+"/status", tstRenderJson(body), tstPaymentServiceApiToken)
+
+	docs.Then("then the request is denied and the status is unchanged")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", url.Values{"details": []string{"you are not allowed to make this status transition - the attempt has been logged"}})
+	tstVerifyStatus(t, location, "new")
+}
+
+func TestApiClientCheckinScopeAllowCheckIn(t *testing.T) {
+	docs.Given("given the configuration for standard registration, with a kiosk api client that may check in attendees")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a paid attendee")
+	location, _ := tstRegisterAttendeeAndTransitionToStatus(t, "api8-", "paid")
+
+	docs.When("when the kiosk checks in the attendee by status change")
+	body := status.StatusChangeDto{Status: "checked in", Comment: "api8"}
+	response := tstPerformWithApiToken(http.MethodPost, location+"/status", tstRenderJson(body), tstKioskApiToken)
+
+	docs.Then("then the request is successful and the attendee is checked in")
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+	tstVerifyStatus(t, location, "checked in")
+}
+
+func TestApiClientCheckinScopeDenyCancel(t *testing.T) {
+	docs.Given("given the configuration for standard registration, with a kiosk api client that may check in attendees")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a paid attendee")
+	location, _ := tstRegisterAttendeeAndTransitionToStatus(t, "api9-", "paid")
+
+	docs.When("when the kiosk attempts to cancel the attendee")
+	body := status.StatusChangeDto{Status: "cancelled", Comment: "api9"}
+	response := tstPerformWithApiToken(http.MethodPost, location+"/status", tstRenderJson(body), tstKioskApiToken)
+
+	docs.Then("then the request is denied and the status is unchanged")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", url.Values{"details": []string{"you are not allowed to make this status transition - the attempt has been logged"}})
+	tstVerifyStatus(t, location, "paid")
+}
+
+func TestApiClientCheckinScopeDenyBadgesPrinted(t *testing.T) {
+	docs.Given("given the configuration for standard registration, with a kiosk api client that may check in attendees")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when the kiosk attempts to mark badges as printed")
+	response := tstPerformWithApiToken(http.MethodPost, "/api/rest/v1/badges/printed", `{"ids":[1]}`, tstKioskApiToken)
+
+	docs.Then("then the request is denied")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+func TestApiClientPaymentsScopeDenyRead(t *testing.T) {
+	docs.Given("given the configuration for standard registration, with an api client that may only change the payment status")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	location, _ := tstRegisterAttendee(t, "api5-")
+
+	docs.When("when the api client attempts to read the attendee")
+	response := tstPerformWithApiToken(http.MethodGet, location, "", tstPaymentServiceApiToken)
+
+	docs.Then("then the request is denied")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+func TestApiClientInvalidToken(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	location, _ := tstRegisterAttendee(t, "api6-")
+
+	docs.When("when someone attempts to read the attendee with an unknown api token")
+	response := tstPerformWithApiToken(http.MethodGet, location, "", "api-token-that-nobody-configured")
+
+	docs.Then("then the request is denied as unauthenticated (401)")
+	tstRequireErrorResponse(t, response, http.StatusUnauthorized, "auth.unauthorized", "invalid api token")
+}
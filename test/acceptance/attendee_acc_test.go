@@ -130,6 +130,48 @@ func TestCreateNewAttendeeDefaultReadOnlyPackage(t *testing.T) {
 	})
 }
 
+func TestUpdateExistingAttendeeChangeNickname(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	token := tstValidUserToken(t, "101")
+	location1, attendee1 := tstRegisterAttendeeWithToken(t, "ua3-", token)
+
+	docs.When("when they change their nickname, which is one of the fields of the duplicate check")
+	changedAttendee := attendee1
+	changedAttendee.Nickname = "Changed Nickname"
+	updateResponse := tstPerformPut(location1, tstRenderJson(changedAttendee), token)
+
+	docs.Then("then the attendee is successfully updated and not rejected as a duplicate of themselves")
+	require.Equal(t, http.StatusOK, updateResponse.status, "unexpected http response status for update")
+	require.Equal(t, "Changed Nickname", tstReadAttendee(t, location1).Nickname)
+}
+
+func TestUpdateExistingAttendeeDuplicate(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given two existing attendees")
+	_, attendee1 := tstRegisterAttendee(t, "ua4a-")
+	token := tstValidUserToken(t, "101")
+	location2, attendee2 := tstRegisterAttendeeWithToken(t, "ua4b-", token)
+
+	docs.When("when the second one changes nickname, zip and email to those of the first one")
+	changedAttendee := attendee2
+	changedAttendee.Nickname = attendee1.Nickname
+	changedAttendee.Zip = attendee1.Zip
+	changedAttendee.Email = attendee1.Email
+	response := tstPerformPut(location2, tstRenderJson(changedAttendee), token)
+
+	docs.Then("then the update is rejected with an error response indicating a duplicate")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "attendee.data.duplicate", url.Values{
+		"attendee": []string{"there is already an attendee with this information (looking at nickname, email, and zip code)"},
+	})
+}
+
 func TestCreateNewAttendeeDuplicateHandling(t *testing.T) {
 	docs.Given("given the configuration for standard public registration")
 	tstSetup(tstConfigFile(false, false, true))
@@ -685,6 +727,124 @@ func TestUpdateExistingAttendeeDataInvalid(t *testing.T) {
 	})
 }
 
+func TestUpdateExistingAttendeeChoiceRuleViolated(t *testing.T) {
+	docs.Given("given the configuration for standard registration, which has a rule for exactly one ticket")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	location1, attendee1 := tstRegisterAttendee(t, "ua6-")
+
+	docs.When("when an admin tries to change them to two day guest tickets")
+	changedAttendee := attendee1
+	changedAttendee.Packages = "room-none,day-thu,day-fri"
+	response := tstPerformPut(location1, tstRenderJson(changedAttendee), tstValidAdminToken(t))
+
+	docs.Then("then the update is rejected with an error response that names the rule")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "attendee.data.invalid", url.Values{
+		"packages": []string{"rule one-ticket violated: Please choose either the Convention Ticket or a single Day Guest ticket."},
+	})
+}
+
+func TestUpdateExistingAttendeeChoiceRuleOk(t *testing.T) {
+	docs.Given("given the configuration for standard registration, which has a rule for exactly one ticket")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	location1, attendee1 := tstRegisterAttendee(t, "ua7-")
+
+	docs.When("when an admin changes them to a single day guest ticket")
+	changedAttendee := attendee1
+	changedAttendee.Packages = "room-none,day-thu"
+	response := tstPerformPut(location1, tstRenderJson(changedAttendee), tstValidAdminToken(t))
+
+	docs.Then("then the update is successful")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	require.Equal(t, "room-none,day-thu", tstReadAttendee(t, location1).Packages)
+}
+
+func TestUpdateExistingAttendeeRemovePackageAfterPaymentDeny(t *testing.T) {
+	docs.Given("given the configuration for standard registration, where the supersponsor upgrade is non-refundable")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee who has made a payment")
+	token := tstValidStaffToken(t, "1")
+	location1, attendee1 := tstRegisterAttendeeAndTransitionToStatus(t, "ua8-", "partially paid")
+
+	docs.When("when they try to remove the supersponsor upgrade")
+	changedAttendee := attendee1
+	changedAttendee.Packages = "room-none,attendance,stage"
+	response := tstPerformPut(location1, tstRenderJson(changedAttendee), token)
+
+	docs.Then("then the update is rejected with an error response keyed on packages")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "attendee.data.invalid", url.Values{
+		"packages": []string{"cannot remove package sponsor2: packages cannot be removed after the first payment, please contact the registration team"},
+	})
+	require.Equal(t, "room-none,attendance,stage,sponsor2", tstReadAttendee(t, location1).Packages)
+}
+
+func TestUpdateExistingAttendeeRemovePackageBeforePaymentOk(t *testing.T) {
+	docs.Given("given the configuration for standard registration, where the supersponsor upgrade is non-refundable")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an approved attendee who has not made any payments yet")
+	token := tstValidStaffToken(t, "1")
+	location1, attendee1 := tstRegisterAttendeeAndTransitionToStatus(t, "ua9-", "approved")
+
+	docs.When("when they remove the supersponsor upgrade")
+	changedAttendee := attendee1
+	changedAttendee.Packages = "room-none,attendance,stage"
+	response := tstPerformPut(location1, tstRenderJson(changedAttendee), token)
+
+	docs.Then("then the update is successful")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	require.Equal(t, "room-none,attendance,stage", tstReadAttendee(t, location1).Packages)
+}
+
+func TestUpdateExistingAttendeeRemovePackageAfterPaymentAdminOk(t *testing.T) {
+	docs.Given("given the configuration for standard registration, where the supersponsor upgrade is non-refundable")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee who has paid")
+	location1, attendee1 := tstRegisterAttendeeAndTransitionToStatus(t, "ua10-", "paid")
+
+	docs.When("when an admin removes the supersponsor upgrade")
+	changedAttendee := attendee1
+	changedAttendee.Packages = "room-none,attendance,stage"
+	response := tstPerformPut(location1, tstRenderJson(changedAttendee), tstValidAdminToken(t))
+
+	docs.Then("then the update is successful")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	require.Equal(t, "room-none,attendance,stage", tstReadAttendee(t, location1).Packages)
+}
+
+func TestUpdateExistingAttendeeDowngradePackageAfterDeadlineDeny(t *testing.T) {
+	docs.Given("given the configuration for standard registration, where the sponsor upgrade can only be removed until a date in the past")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee who has paid, and whose package was changed to the sponsor upgrade by an admin")
+	token := tstValidStaffToken(t, "1")
+	location1, attendee1 := tstRegisterAttendeeAndTransitionToStatus(t, "ua11-", "paid")
+	changedAttendee := attendee1
+	changedAttendee.Packages = "room-none,attendance,stage,sponsor"
+	adminResponse := tstPerformPut(location1, tstRenderJson(changedAttendee), tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, adminResponse.status, "unexpected http response status")
+
+	docs.When("when they try to remove the sponsor upgrade")
+	changedAttendee.Packages = "room-none,attendance,stage"
+	response := tstPerformPut(location1, tstRenderJson(changedAttendee), token)
+
+	docs.Then("then the update is rejected with an error response that names the deadline")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "attendee.data.invalid", url.Values{
+		"packages": []string{"cannot remove package sponsor after 2019-10-01: packages cannot be removed after the first payment, please contact the registration team"},
+	})
+}
+
 func TestUpdateNonExistingAttendee(t *testing.T) {
 	docs.Given("given the configuration for standard registration")
 	tstSetup(tstConfigFile(false, false, true))
@@ -0,0 +1,221 @@
+package acceptance
+
+import (
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/badges"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// ----------------------------------------------
+// acceptance tests for badge export and printing
+// ----------------------------------------------
+
+// --- export
+
+func TestBadgeExport_Json(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee who has paid, an attendee who has not, and a third attendee with the regdesk permission")
+	_, att := tstRegisterAttendeeAndTransitionToStatus(t, "badge1-", "paid")
+	tstRegisterAttendeeAndTransitionToStatus(t, "badge1b-", "approved")
+	token := tstRegisterRegdeskAttendee(t, "badge1-")
+
+	docs.When("when the regdesk attendee exports the badge data")
+	response := tstPerformGet("/api/rest/v1/badges", token)
+
+	docs.Then("then the request is successful and only the attendee who has paid is included, with their badge data")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	actual := badges.BadgeListDto{}
+	tstParseJson(response.body, &actual)
+	expected := badges.BadgeListDto{
+		Badges: []badges.BadgeDto{{
+			Id:           att.Id,
+			Nickname:     "BlackCheetah",
+			CountryBadge: "DE",
+			SponsorTier:  "sponsor2",
+			Markers:      []string{"hc"},
+			Status:       "paid",
+			Prints:       []badges.BadgePrintDto{},
+		}},
+	}
+	require.EqualValues(t, expected, actual)
+}
+
+func TestBadgeExport_Csv(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee who is checked in, and a second attendee with the regdesk permission")
+	_, att := tstRegisterAttendeeAndTransitionToStatus(t, "badge2-", "checked in")
+	token := tstRegisterRegdeskAttendee(t, "badge2-")
+
+	docs.When("when the regdesk attendee exports the badge data as csv")
+	response := tstPerformGet("/api/rest/v1/badges?format=csv", token)
+
+	docs.Then("then the request is successful and the csv contains a header row and the attendee")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	require.Equal(t, "text/csv; charset=utf-8", response.contentType)
+	lines := strings.Split(strings.TrimSpace(response.body), "\n")
+	require.Equal(t, []string{
+		"id,nickname,country_badge,sponsor_tier,markers,status,print_count,last_printed,changed_since_print",
+		att.Id + ",BlackCheetah,DE,sponsor2,hc,checked in,0,,false",
+	}, lines)
+}
+
+func TestBadgeExport_InvalidFilter(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee with the regdesk permission")
+	token := tstRegisterRegdeskAttendee(t, "badge3-")
+
+	docs.When("when they attempt a badge export with an invalid filter and format")
+	response := tstPerformGet("/api/rest/v1/badges?filter=unicorn&format=pdf", token)
+
+	docs.Then("then the request fails (400) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "badge.query.invalid", url.Values{
+		"filter": []string{"filter must be one of all,not_printed,changed,queue"},
+		"format": []string{"format must be one of json,csv"},
+	})
+}
+
+func TestBadgeExport_UserDeny(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a regular attendee without the regdesk permission")
+	token := tstValidUserToken(t, "101")
+	tstRegisterAttendeeWithToken(t, "badge4-", token)
+
+	docs.When("when they attempt a badge export")
+	response := tstPerformGet("/api/rest/v1/badges", token)
+
+	docs.Then("then the request is denied as unauthorized (403) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+// --- print queue
+
+func TestBadgesPrinted_QueueAndReprint(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee who has paid, and a second attendee with the regdesk permission")
+	loc, att := tstRegisterAttendeeAndTransitionToStatus(t, "badge5-", "paid")
+	token := tstRegisterRegdeskAttendee(t, "badge5-")
+
+	docs.Given("given the badge of the first attendee is in the print queue")
+	require.Equal(t, []string{att.Id}, tstBadgeIds(t, "queue", token))
+
+	docs.When("when the regdesk attendee marks the badge as printed")
+	response := tstPerformPost("/api/rest/v1/badges/printed", tstRenderJson(badges.BadgesPrintedDto{Ids: []uint{tstAttendeeId(att)}}), token)
+
+	docs.Then("then the request is successful and the badge has left the print queue")
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+	require.Equal(t, []string{}, tstBadgeIds(t, "queue", token))
+
+	docs.When("when an admin changes a value that is not printed on the badge")
+	changed := tstReadAttendee(t, loc)
+	changed.TshirtSize = "XL"
+	updateResponse := tstPerformPut(loc, tstRenderJson(changed), tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, updateResponse.status, "unexpected http response status")
+
+	docs.Then("then the badge does not count as changed")
+	require.Equal(t, []string{}, tstBadgeIds(t, "changed", token))
+
+	docs.When("when an admin changes the country shown on the badge")
+	changed.CountryBadge = "CH"
+	updateResponse = tstPerformPut(loc, tstRenderJson(changed), tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, updateResponse.status, "unexpected http response status")
+
+	docs.Then("then the badge counts as changed and is back in the print queue")
+	require.Equal(t, []string{att.Id}, tstBadgeIds(t, "changed", token))
+	require.Equal(t, []string{att.Id}, tstBadgeIds(t, "queue", token))
+
+	docs.When("when the regdesk attendee marks the badge as printed again without giving a reason")
+	response = tstPerformPost("/api/rest/v1/badges/printed", tstRenderJson(badges.BadgesPrintedDto{Ids: []uint{tstAttendeeId(att)}}), token)
+
+	docs.Then("then the request fails (409) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "badge.reprint.reason", url.Values{"details": []string{"badge was already printed, a reprint needs a reason: attendee " + att.Id}})
+
+	docs.When("when the regdesk attendee marks the badge as reprinted with a reason")
+	response = tstPerformPost("/api/rest/v1/badges/printed", tstRenderJson(badges.BadgesPrintedDto{Ids: []uint{tstAttendeeId(att)}, Reason: "country changed"}), token)
+
+	docs.Then("then the request is successful, the badge has left the print queue, and both prints are listed")
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+	require.Equal(t, []string{}, tstBadgeIds(t, "queue", token))
+	exportResponse := tstPerformGet("/api/rest/v1/badges", token)
+	actual := badges.BadgeListDto{}
+	tstParseJson(exportResponse.body, &actual)
+	require.Equal(t, 1, len(actual.Badges))
+	require.Equal(t, "CH", actual.Badges[0].CountryBadge)
+	require.Equal(t, 2, actual.Badges[0].PrintCount)
+	require.False(t, actual.Badges[0].ChangedSincePrint)
+	require.Equal(t, "101", actual.Badges[0].Prints[1].Operator)
+	require.Equal(t, "country changed", actual.Badges[0].Prints[1].Reason)
+	require.Equal(t, actual.Badges[0].Prints[1].Timestamp, actual.Badges[0].LastPrinted)
+}
+
+func TestBadgesPrinted_NotPrintable(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee who has paid, one who has not, and a third attendee with the regdesk permission")
+	_, paid := tstRegisterAttendeeAndTransitionToStatus(t, "badge6-", "paid")
+	_, approved := tstRegisterAttendeeAndTransitionToStatus(t, "badge6b-", "approved")
+	token := tstRegisterRegdeskAttendee(t, "badge6-")
+
+	docs.When("when the regdesk attendee attempts to mark both badges as printed")
+	body := badges.BadgesPrintedDto{Ids: []uint{tstAttendeeId(paid), tstAttendeeId(approved)}}
+	response := tstPerformPost("/api/rest/v1/badges/printed", tstRenderJson(body), token)
+
+	docs.Then("then the request fails (409) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "badge.not.printable", url.Values{"details": []string{"only attendees in status paid or checked in get a badge: attendee " + approved.Id + " is in status approved"}})
+
+	docs.Then("and no print was recorded for the attendee who has paid")
+	require.Equal(t, []string{paid.Id}, tstBadgeIds(t, "not_printed", token))
+}
+
+func TestBadgesPrinted_Invalid(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee with the regdesk permission")
+	token := tstRegisterRegdeskAttendee(t, "badge7-")
+
+	docs.When("when they attempt to mark badges as printed with invalid values")
+	body := badges.BadgesPrintedDto{Ids: []uint{0}, Reason: strings.Repeat("x", 257)}
+	response := tstPerformPost("/api/rest/v1/badges/printed", tstRenderJson(body), token)
+
+	docs.Then("then the request fails (400) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "badge.data.invalid", url.Values{
+		"ids":    []string{"ids must be positive integers (badge numbers)"},
+		"reason": []string{"reason field must be at least 0 and at most 256 characters long"},
+	})
+}
+
+// --- helpers ---
+
+func tstBadgeIds(t *testing.T, filter string, token string) []string {
+	response := tstPerformGet("/api/rest/v1/badges?filter="+filter, token)
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	list := badges.BadgeListDto{}
+	tstParseJson(response.body, &list)
+	result := make([]string, 0)
+	for _, b := range list.Badges {
+		result = append(result, b.Id)
+	}
+	return result
+}
+
+func tstAttendeeId(att attendee.AttendeeDto) uint {
+	id, _ := strconv.Atoi(att.Id)
+	return uint(id)
+}
@@ -0,0 +1,89 @@
+package acceptance
+
+import (
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/choices"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// ------------------------------------------
+// acceptance tests for choice availability
+// ------------------------------------------
+
+func TestCreateNewAttendeeChoiceNotYetAvailable(t *testing.T) {
+	docs.Given("given the configuration for public standard registration, where the artist option only becomes available in the future")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when someone tries to register with the artist option")
+	attendeeSent := tstBuildValidAttendee("ch1-")
+	attendeeSent.Options = "art,music"
+	response := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(attendeeSent), tstNoToken())
+
+	docs.Then("then the attendee is rejected with an appropriate error response")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "attendee.data.invalid", url.Values{
+		"options": []string{"choice key art is not available at this time"},
+	})
+}
+
+func TestUpdateExistingAttendeeKeepUnavailableChoice(t *testing.T) {
+	docs.Given("given the configuration for standard registration, where the artist option only becomes available in the future")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee who was given the artist option by an admin")
+	token := tstValidStaffToken(t, "1")
+	location1, attendee1 := tstRegisterAttendee(t, "ch2-")
+	changedAttendee := attendee1
+	changedAttendee.Options = "art,music,suit"
+	adminResponse := tstPerformPut(location1, tstRenderJson(changedAttendee), tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, adminResponse.status, "unexpected http response status")
+
+	docs.When("when they make an unrelated change")
+	changedAttendee.UserComments = "still an artist"
+	response := tstPerformPut(location1, tstRenderJson(changedAttendee), token)
+
+	docs.Then("then the update is successful and they keep the artist option")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	require.Equal(t, "art,music,suit", tstReadAttendee(t, location1).Options)
+}
+
+func TestAvailableChoices(t *testing.T) {
+	docs.Given("given the configuration for public standard registration, where the artist option only becomes available in the future")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when the frontend requests the currently available choices")
+	response := tstPerformGet("/api/rest/v1/choices/available", tstNoToken())
+
+	docs.Then("then they are listed with description, help url and current price, without admin only flags and unavailable choices")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	actual := choices.AvailableChoicesDto{}
+	tstParseJson(response.body, &actual)
+
+	flagKeys := make([]string, 0)
+	for _, c := range actual.Flags {
+		flagKeys = append(flagKeys, c.Key)
+	}
+	require.Equal(t, []string{"anon", "ev", "hc"}, flagKeys)
+
+	optionKeys := make([]string, 0)
+	for _, c := range actual.Options {
+		optionKeys = append(optionKeys, c.Key)
+	}
+	require.Equal(t, []string{"anim", "music", "suit"}, optionKeys)
+
+	require.Equal(t, 8, len(actual.Packages))
+	require.Equal(t, choices.ChoiceDto{
+		Key:         "attendance",
+		Description: "Entrance Fee (Convention Ticket)",
+		HelpUrl:     "help/fee_basic.html",
+		PriceCent:   9000,
+		VatPercent:  19,
+		Default:     true,
+		ReadOnly:    true,
+	}, actual.Packages[0])
+}
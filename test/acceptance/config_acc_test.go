@@ -0,0 +1,148 @@
+package acceptance
+
+import (
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/publicconfig"
+	"github.com/go-http-utils/headers"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+// ------------------------------------------
+// acceptance tests for the public configuration endpoint
+// ------------------------------------------
+
+func TestPublicConfigAnonymous(t *testing.T) {
+	docs.Given("given the configuration for public standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when an anonymous user requests the configuration")
+	response := tstPerformGet("/api/rest/v1/config", tstNoToken())
+
+	docs.Then("then the non-secret configuration is returned, without admin only flags")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	actual := publicconfig.ConfigDto{}
+	tstParseJson(response.body, &actual)
+	require.Equal(t, "2019-10-31T20:00:00+01:00", actual.GoLive.StartIsoDatetime)
+	require.Equal(t, "1901-01-01", actual.Birthday.Earliest)
+	require.Equal(t, "2022-08-24", actual.Birthday.FirstConDay)
+	require.Contains(t, actual.Countries, "DE")
+	require.Contains(t, actual.TshirtSizes, "XXL")
+	require.Contains(t, actual.Choices.Flags, "hc")
+	require.NotContains(t, actual.Choices.Flags, "guest")
+	require.Equal(t, publicconfig.ChoiceDto{
+		Description:   "Supersponsor Upgrade",
+		HelpUrl:       "help/fee_sponsor2.html",
+		PriceEarly:    160,
+		PriceLate:     160,
+		PriceAtCon:    160,
+		PriceCurrent:  160,
+		VatPercent:    19,
+		Constraint:    "!sponsor",
+		ConstraintMsg: "Please choose only one of Sponsor or Supersponsor.",
+		Eligibility:   &publicconfig.EligibilityDto{BeforeStatus: "paid"},
+	}, actual.Choices.Packages["sponsor2"])
+	require.Equal(t, []publicconfig.ChoiceRuleDto{{
+		Name:          "one-ticket",
+		Constraint:    "exactly-one-of(attendance, day-thu, day-fri, day-sat)",
+		ConstraintMsg: "Please choose either the Convention Ticket or a single Day Guest ticket.",
+	}}, actual.Choices.PackageRules)
+}
+
+func TestPublicConfigAdmin(t *testing.T) {
+	docs.Given("given the configuration for public standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when an admin requests the configuration")
+	response := tstPerformGet("/api/rest/v1/config", tstValidAdminToken(t))
+
+	docs.Then("then the admin only flags are included")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	actual := publicconfig.ConfigDto{}
+	tstParseJson(response.body, &actual)
+	require.Contains(t, actual.Choices.Flags, "guest")
+	require.True(t, actual.Choices.Flags["guest"].AdminOnly)
+}
+
+func TestPublicConfigETag(t *testing.T) {
+	docs.Given("given the configuration for public standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a client that has already requested the configuration")
+	first, err := http.Get(ts.URL + "/api/rest/v1/config")
+	require.Nil(t, err)
+	_ = first.Body.Close()
+	etag := first.Header.Get(headers.ETag)
+	require.NotEmpty(t, etag)
+	require.Equal(t, "private, no-cache", first.Header.Get(headers.CacheControl))
+	require.Equal(t, "Authorization, Cookie", first.Header.Get(headers.Vary))
+
+	docs.When("when it requests the configuration again with the ETag it received")
+	request, err := http.NewRequest(http.MethodGet, ts.URL+"/api/rest/v1/config", nil)
+	require.Nil(t, err)
+	request.Header.Set(headers.IfNoneMatch, etag)
+	second := tstDo(t, request)
+	_ = second.Body.Close()
+
+	docs.Then("then it is told that the configuration has not changed")
+	require.Equal(t, http.StatusNotModified, second.StatusCode, "unexpected http response status")
+	require.Equal(t, etag, second.Header.Get(headers.ETag))
+}
+
+func TestPublicConfigETagDiffersByRole(t *testing.T) {
+	docs.Given("given the configuration for public standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a client that has requested the configuration anonymously")
+	first, err := http.Get(ts.URL + "/api/rest/v1/config")
+	require.Nil(t, err)
+	_ = first.Body.Close()
+
+	docs.When("when an admin requests the configuration with that ETag")
+	request, err := http.NewRequest(http.MethodGet, ts.URL+"/api/rest/v1/config", nil)
+	require.Nil(t, err)
+	request.Header.Set(headers.IfNoneMatch, first.Header.Get(headers.ETag))
+	request.Header.Set(headers.Authorization, "Bearer "+tstValidAdminToken(t))
+	response := tstWebResponseFromResponse(tstDo(t, request))
+
+	docs.Then("then the full configuration is returned, because the admin view differs")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+}
+
+func tstDo(t *testing.T, request *http.Request) *http.Response {
+	response, err := http.DefaultClient.Do(request)
+	require.Nil(t, err)
+	return response
+}
+
+func TestReloadConfigAdmin(t *testing.T) {
+	docs.Given("given the configuration for public standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when an admin requests a configuration reload while the file is unchanged")
+	response := tstPerformPost("/api/rest/v1/config/reload", "", tstValidAdminToken(t))
+
+	docs.Then("then the reload is successful and no changes are reported")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	actual := publicconfig.ConfigReloadResultDto{}
+	tstParseJson(response.body, &actual)
+	require.Empty(t, actual.Changes)
+}
+
+func TestReloadConfigUserDeny(t *testing.T) {
+	docs.Given("given the configuration for public standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when a normal user requests a configuration reload")
+	response := tstPerformPost("/api/rest/v1/config/reload", "", tstValidUserToken(t, "101"))
+
+	docs.Then("then the request is denied")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
@@ -0,0 +1,236 @@
+package acceptance
+
+import (
+	"context"
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/admin"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/transfer"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/mailservice"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+// ------------------------------------------------------
+// acceptance tests for registrations created by admins
+// ------------------------------------------------------
+
+func TestCreateFor_GuestSuccess(t *testing.T) {
+	docs.Given("given the configuration for public registration before the registration start time")
+	tstSetup(tstConfigFile(false, false, false))
+	defer tstShutdown()
+
+	docs.When("when an admin creates an approved registration for a guest with a full comp")
+	guest := tstBuildValidAttendee("cfor1-")
+	body := attendee.AttendeeCreateForDto{
+		Attendee:   guest,
+		AdminFlags: "guest",
+		Status:     "approved",
+		CompReason: "guest of honour",
+	}
+	response := tstPerformPost("/api/rest/v1/attendees/create-for", tstRenderJson(body), tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and an invitation code is returned")
+	require.Equal(t, http.StatusCreated, response.status, "unexpected http response status")
+	require.Equal(t, "/api/rest/v1/attendees/1", response.location)
+	invitation := attendee.InvitationDto{}
+	tstParseJson(response.body, &invitation)
+	require.NotEmpty(t, invitation.Code)
+	require.NotEmpty(t, invitation.Expires)
+
+	docs.Then("and the registration is paid without any dues being booked")
+	tstVerifyStatus(t, response.location, "paid")
+	require.Empty(t, paymentMock.Recording())
+
+	docs.Then("and the admin flags have been recorded in the admin info, but no manual dues")
+	require.Equal(t, admin.AdminInfoDto{Id: "1", Flags: "guest"}, tstReadAdminInfo(t, response.location))
+
+	docs.Then("and the comp has been recorded")
+	require.Equal(t, `{"reason":"guest of honour","discount":0,"created_by":"1234567890"}`, tstReadAdditionalInfo(t, "1", "comp"))
+
+	docs.Then("and the guest was informed of the status and invited by email")
+	require.Equal(t, []mailservice.TemplateRequestDto{
+		{
+			Name: "new-status-paid",
+			Variables: map[string]string{
+				"nickname":       "BlackCheetah",
+				"badge_number":   "1",
+				"status":         "paid",
+				"packages":       "Entrance Fee (Convention Ticket), No Room, Supersponsor Upgrade, Entrance Fee (Stage Ticket)",
+				"total_dues":     "0.00",
+				"remaining_dues": "0.00",
+				"due_date":       "",
+			},
+			Email: guest.Email,
+		},
+		{
+			Name: "guest-invitation",
+			Variables: map[string]string{
+				"nickname":     "BlackCheetah",
+				"badge_number": "1",
+				"code":         invitation.Code,
+			},
+			Email: guest.Email,
+		},
+	}, mailMock.Recording())
+	mailMock.Reset()
+
+	docs.When("when the guest redeems the invitation code with their login")
+	guestToken := tstValidUserToken(t, "101")
+	response = tstPerformPost("/api/rest/v1/transfers/redeem", tstRenderJson(transfer.RedeemTransferDto{Code: invitation.Code}), guestToken)
+
+	docs.Then("then the request is successful and the registration now belongs to them")
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+	guest.Id = "1"
+	tstRequireOwnedIds(t, guestToken, guest)
+
+	docs.Then("and their email address counts as verified")
+	require.True(t, tstReadAttendee(t, response.location).EmailVerified)
+
+	docs.Then("and only the guest was informed by email, because there was no previous owner")
+	require.Equal(t, []mailservice.TemplateRequestDto{
+		tstTransferMail("transfer-in", guest, "BlackCheetah", guest.Email),
+	}, mailMock.Recording())
+}
+
+func TestCreateFor_Discount(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when an admin creates an approved registration with a discount")
+	body := attendee.AttendeeCreateForDto{
+		Attendee:     tstBuildValidAttendee("cfor2-"),
+		Status:       "approved",
+		CompReason:   "speaker",
+		CompDiscount: 5000,
+	}
+	response := tstPerformPost("/api/rest/v1/attendees/create-for", tstRenderJson(body), tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and the registration is approved")
+	require.Equal(t, http.StatusCreated, response.status, "unexpected http response status")
+	tstVerifyStatus(t, response.location, "approved")
+
+	docs.Then("and the discounted dues were booked")
+	require.Equal(t, 1, len(paymentMock.Recording()))
+	expected := tstValidAttendeeDues(20500, "dues adjustment due to change in status or selected packages")
+	actual := paymentMock.Recording()[0]
+	expected.DueDate = actual.DueDate // TODO remove when due date logic implemented
+	require.EqualValues(t, expected, actual)
+
+	docs.Then("and the registration does not belong to anyone yet")
+	response = tstPerformGet("/api/rest/v1/attendees", tstValidAdminToken(t))
+	tstRequireErrorResponse(t, response, http.StatusNotFound, "attendee.owned.notfound", url.Values{})
+}
+
+func TestCreateFor_FullCompCoversPackageChange(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an admin has created an approved registration for a guest with a full comp")
+	guest := tstBuildValidAttendee("cfor6-")
+	guest.Packages = "room-none,attendance,stage"
+	body := attendee.AttendeeCreateForDto{
+		Attendee:   guest,
+		Status:     "approved",
+		CompReason: "guest of honour",
+	}
+	response := tstPerformPost("/api/rest/v1/attendees/create-for", tstRenderJson(body), tstValidAdminToken(t))
+	require.Equal(t, http.StatusCreated, response.status, "unexpected http response status")
+	location := response.location
+	tstVerifyStatus(t, location, "paid")
+
+	docs.When("when an admin adds the supersponsor upgrade")
+	changedGuest := tstReadAttendee(t, location)
+	changedGuest.Packages = "room-none,attendance,stage,sponsor2"
+	response = tstPerformPut(location, tstRenderJson(changedGuest), tstValidAdminToken(t))
+
+	docs.Then("then the request is successful")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	require.Equal(t, "room-none,attendance,stage,sponsor2", tstReadAttendee(t, location).Packages)
+
+	docs.Then("and the upgrade is also waived, so the guest does not owe anything")
+	require.Empty(t, paymentMock.Recording())
+	tstVerifyStatus(t, location, "paid")
+}
+
+func TestCreateFor_NoCompStaysNew(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when an admin creates a registration without a status or comp")
+	body := attendee.AttendeeCreateForDto{
+		Attendee: tstBuildValidAttendee("cfor3-"),
+	}
+	response := tstPerformPost("/api/rest/v1/attendees/create-for", tstRenderJson(body), tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and the registration is in status new without dues")
+	require.Equal(t, http.StatusCreated, response.status, "unexpected http response status")
+	tstVerifyStatus(t, response.location, "new")
+	require.Empty(t, paymentMock.Recording())
+	require.Equal(t, admin.AdminInfoDto{Id: "1"}, tstReadAdminInfo(t, response.location))
+}
+
+func TestCreateFor_UserDeny(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when a regular user attempts to create a registration for someone else")
+	body := attendee.AttendeeCreateForDto{
+		Attendee:   tstBuildValidAttendee("cfor4-"),
+		CompReason: "i am my own guest",
+	}
+	response := tstPerformPost("/api/rest/v1/attendees/create-for", tstRenderJson(body), tstValidUserToken(t, "101"))
+
+	docs.Then("then the request is denied as unauthorized (403) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+
+	docs.Then("and no attendees have been created")
+	tstRequireMaxId(t, 0)
+}
+
+func TestCreateFor_Invalid(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when an admin attempts to create a registration with an invalid status, admin flag and discount")
+	body := attendee.AttendeeCreateForDto{
+		Attendee:     tstBuildValidAttendee("cfor5-"),
+		AdminFlags:   "hc",
+		Status:       "paid",
+		CompDiscount: -100,
+	}
+	response := tstPerformPost("/api/rest/v1/attendees/create-for", tstRenderJson(body), tstValidAdminToken(t))
+
+	docs.Then("then the request fails (400) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "attendee.data.invalid", url.Values{
+		"admin_flags":   []string{"admin_flags field must be a comma separated combination of any of guest"},
+		"status":        []string{"optional status field must be one of new, approved or it can be left blank, which means new"},
+		"comp_discount": []string{"comp_discount field must not be negative"},
+	})
+
+	docs.Then("and no attendees have been created")
+	tstRequireMaxId(t, 0)
+}
+
+// --- helpers ---
+
+func tstReadAdditionalInfo(t *testing.T, attendeeId string, area string) string {
+	attid, err := strconv.Atoi(attendeeId)
+	require.Nil(t, err)
+	addInfo, err := database.GetRepository().GetAdditionalInfoFor(context.Background(), uint(attid), area)
+	require.Nil(t, err)
+	return addInfo.JsonValue
+}
+
+func tstReadAdminInfo(t *testing.T, location string) admin.AdminInfoDto {
+	response := tstPerformGet(location+"/admin", tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	result := admin.AdminInfoDto{}
+	tstParseJson(response.body, &result)
+	return result
+}
@@ -0,0 +1,148 @@
+package acceptance
+
+import (
+	"context"
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/dataexport"
+	"github.com/eurofurence/reg-attendee-service/internal/entity"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// ------------------------------------------------------
+// acceptance tests for the personal data export resource
+// ------------------------------------------------------
+
+func TestDataExport_AnonDeny(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	location1, _ := tstRegisterAttendee(t, "exp1-")
+
+	docs.When("when an unauthenticated user attempts to export their personal data")
+	response := tstPerformGet(location1+"/export", tstNoToken())
+
+	docs.Then("then the request is denied as unauthenticated (401) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusUnauthorized, "auth.unauthorized", "you must be logged in for this operation")
+}
+
+func TestDataExport_UserDenyOther(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given two existing regular users, the second of which has registered")
+	token := tstValidUserToken(t, "101")
+	location2, _ := tstRegisterAttendee(t, "exp2-")
+
+	docs.When("when the first user attempts to export somebody else's personal data")
+	response := tstPerformGet(location2+"/export", token)
+
+	docs.Then("then the request is denied as unauthorized (403) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized to access this data - the attempt has been logged")
+}
+
+func TestDataExport_UserAllowSelf(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee who has paid and has additional info and admin flags stored")
+	location1, attendee1 := tstRegisterAttendeeAndTransitionToStatus(t, "exp3-", "paid")
+	tstWriteAdditionalInfo(t, attendee1.Id, "regdesk", `{"tshirt":"handed out"}`)
+	tstWriteAdminFlags(t, attendee1.Id, "guest")
+
+	docs.When("when they export their own personal data")
+	response := tstPerformGet(location1+"/export", tstValidUserToken(t, attendee1.Id))
+
+	docs.Then("then the request is successful and the export contains their data, status history and transactions")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	exportDto := dataexport.PersonalDataExportDto{}
+	tstParseJson(response.body, &exportDto)
+	require.EqualValues(t, attendee1, exportDto.Attendee)
+	require.Equal(t, 4, len(exportDto.StatusHistory))
+	require.Equal(t, "paid", exportDto.StatusHistory[3].Status)
+	require.Equal(t, 3, len(exportDto.Transactions))
+	require.Equal(t, "due", exportDto.Transactions[0].Type)
+	require.Equal(t, int64(25500), exportDto.Transactions[0].Amount.GrossCent)
+	require.Equal(t, "payment", exportDto.Transactions[1].Type)
+	require.Equal(t, "credit", exportDto.Transactions[1].Method)
+
+	docs.Then("and additional info areas they do not have permission for are not included")
+	require.Empty(t, exportDto.AdditionalInfo)
+
+	docs.Then("and the admin-only flags are not included")
+	require.Empty(t, exportDto.AdminFlags)
+}
+
+func TestDataExport_AdminOk(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee with additional info and admin flags stored")
+	location1, attendee1 := tstRegisterAttendee(t, "exp4-")
+	tstWriteAdditionalInfo(t, attendee1.Id, "regdesk", `{"tshirt":"handed out"}`)
+	tstWriteAdminFlags(t, attendee1.Id, "guest")
+
+	docs.When("when an admin exports their personal data")
+	response := tstPerformGet(location1+"/export", tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and the export contains all additional info areas and the admin flags")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	exportDto := dataexport.PersonalDataExportDto{}
+	tstParseJson(response.body, &exportDto)
+	require.EqualValues(t, attendee1, exportDto.Attendee)
+	require.Equal(t, 1, len(exportDto.StatusHistory))
+	require.Equal(t, "new", exportDto.StatusHistory[0].Status)
+	require.Empty(t, exportDto.Transactions)
+	require.EqualValues(t, map[string]interface{}{"regdesk": map[string]interface{}{"tshirt": "handed out"}}, exportDto.AdditionalInfo)
+	require.Equal(t, "guest", exportDto.AdminFlags)
+}
+
+func TestDataExport_PaymentServiceDown(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	location1, _ := tstRegisterAttendee(t, "exp5-")
+
+	docs.Given("given the payment service is unavailable")
+	paymentMock.SimulateGetError(paymentservice.DownstreamError)
+
+	docs.When("when an admin exports their personal data")
+	response := tstPerformGet(location1+"/export", tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the appropriate error")
+	tstRequireErrorResponse(t, response, http.StatusBadGateway, "export.payment.error", "")
+}
+
+// helper functions
+
+func tstWriteAdditionalInfo(t *testing.T, attendeeId string, area string, jsonValue string) {
+	attid, err := strconv.Atoi(attendeeId)
+	require.Nil(t, err)
+	err = database.GetRepository().WriteAdditionalInfo(context.Background(), &entity.AdditionalInfo{
+		AttendeeId: uint(attid),
+		Area:       area,
+		JsonValue:  jsonValue,
+	})
+	require.Nil(t, err)
+}
+
+// tstWriteAdminFlags bypasses the admin endpoint, so no dues are booked for the flags.
+func tstWriteAdminFlags(t *testing.T, attendeeId string, flags string) {
+	attid, err := strconv.Atoi(attendeeId)
+	require.Nil(t, err)
+	adminInfo, err := database.GetRepository().GetAdminInfoByAttendeeId(context.Background(), uint(attid))
+	require.Nil(t, err)
+	adminInfo.Flags = flags
+	require.Nil(t, database.GetRepository().WriteAdminInfo(context.Background(), adminInfo))
+}
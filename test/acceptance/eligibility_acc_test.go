@@ -0,0 +1,182 @@
+package acceptance
+
+import (
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// ------------------------------------------
+// acceptance tests for choice eligibility
+// ------------------------------------------
+
+func TestCreateNewAttendeeIneligibleCountry(t *testing.T) {
+	docs.Given("given the configuration for public standard registration, where the animator option is only available in some countries")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when someone from another country tries to register with the animator option")
+	attendeeSent := tstBuildValidAttendee("el1-")
+	attendeeSent.Country = "US"
+	attendeeSent.Options = "anim,music"
+	response := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(attendeeSent), tstNoToken())
+
+	docs.Then("then the attendee is rejected with an error response that explains the eligibility condition")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "attendee.data.invalid", url.Values{
+		"options": []string{"cannot pick anim - only available for attendees from DE, AT, CH"},
+	})
+}
+
+func TestCreateNewAttendeeIneligibleAge(t *testing.T) {
+	docs.Given("given the configuration for public standard registration, where the animator option has a maximum age")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when someone who is too old on the first day of the convention tries to register with the animator option")
+	attendeeSent := tstBuildValidAttendee("el2-")
+	attendeeSent.Birthday = "1996-08-24"
+	attendeeSent.Options = "anim"
+	response := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(attendeeSent), tstNoToken())
+
+	docs.Then("then the attendee is rejected with an error response that explains the eligibility condition")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "attendee.data.invalid", url.Values{
+		"options": []string{"cannot pick anim - only available for attendees at most 25 years old on the first day of the convention"},
+	})
+}
+
+func TestCreateNewAttendeeEligibleAge(t *testing.T) {
+	docs.Given("given the configuration for public standard registration, where the animator option has a maximum age")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when someone who turns 26 the day after the first day of the convention registers with the animator option")
+	attendeeSent := tstBuildValidAttendee("el3-")
+	attendeeSent.Birthday = "1996-08-25"
+	attendeeSent.Options = "anim"
+	response := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(attendeeSent), tstNoToken())
+
+	docs.Then("then the attendee is successfully created")
+	require.Equal(t, http.StatusCreated, response.status, "unexpected http response status")
+}
+
+func TestUpdateExistingAttendeeIneligibleAfterPaid(t *testing.T) {
+	docs.Given("given the configuration for standard registration, where the supersponsor upgrade can only be picked before paying")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee in status paid whose supersponsor upgrade was removed by an admin")
+	token := tstValidStaffToken(t, "1")
+	location, att := tstRegisterAttendeeAndTransitionToStatus(t, "el4-", "paid")
+	att.Packages = "room-none,attendance,stage"
+	removeResponse := tstPerformPut(location, tstRenderJson(att), tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, removeResponse.status, "unexpected http response status")
+
+	docs.When("when they try to pick the supersponsor upgrade again")
+	att.Packages = "room-none,attendance,stage,sponsor2"
+	response := tstPerformPut(location, tstRenderJson(att), token)
+
+	docs.Then("then the update is rejected with an error response that explains the eligibility condition")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "attendee.data.invalid", url.Values{
+		"packages": []string{"cannot pick sponsor2 - can no longer be picked once the status is paid"},
+	})
+}
+
+func TestUpdateExistingAttendeeKeepAfterPaid(t *testing.T) {
+	docs.Given("given the configuration for standard registration, where the supersponsor upgrade can only be picked before paying")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee in status paid who has the supersponsor upgrade")
+	token := tstValidStaffToken(t, "1")
+	location, att := tstRegisterAttendeeAndTransitionToStatus(t, "el5-", "paid")
+
+	docs.When("when they make an unrelated change")
+	att.UserComments = "still a supersponsor"
+	response := tstPerformPut(location, tstRenderJson(att), token)
+
+	docs.Then("then the update is successful and they keep the supersponsor upgrade")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	require.Equal(t, "room-none,attendance,stage,sponsor2", tstReadAttendee(t, location).Packages)
+}
+
+func TestUpdateExistingAttendeeIneligibleAdminAllow(t *testing.T) {
+	docs.Given("given the configuration for standard registration, where the supersponsor upgrade can only be picked before paying")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee in status paid whose supersponsor upgrade was removed by an admin")
+	location, att := tstRegisterAttendeeAndTransitionToStatus(t, "el6-", "paid")
+	att.Packages = "room-none,attendance,stage"
+	removeResponse := tstPerformPut(location, tstRenderJson(att), tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, removeResponse.status, "unexpected http response status")
+
+	docs.When("when an admin adds the supersponsor upgrade again")
+	att.Packages = "room-none,attendance,stage,sponsor2"
+	response := tstPerformPut(location, tstRenderJson(att), tstValidAdminToken(t))
+
+	docs.Then("then the update is successful")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	require.Equal(t, "room-none,attendance,stage,sponsor2", tstReadAttendee(t, location).Packages)
+}
+
+func TestChoiceEligibilityNewAttendee(t *testing.T) {
+	docs.Given("given the configuration for public standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when the frontend asks which choices someone from another country is not eligible for")
+	attendeeSent := tstBuildValidAttendee("el7-")
+	attendeeSent.Country = "US"
+	response := tstPerformPost("/api/rest/v1/attendees/eligibility", tstRenderJson(attendeeSent), tstNoToken())
+
+	docs.Then("then the animator option is listed with the reason")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	actual := attendee.ChoiceEligibilityDto{}
+	tstParseJson(response.body, &actual)
+	expected := attendee.ChoiceEligibilityDto{
+		Flags:    map[string]string{},
+		Packages: map[string]string{},
+		Options:  map[string]string{"anim": "only available for attendees from DE, AT, CH"},
+	}
+	require.EqualValues(t, expected, actual)
+}
+
+func TestChoiceEligibilityExistingAttendee(t *testing.T) {
+	docs.Given("given the configuration for standard registration, where the supersponsor upgrade can only be picked before paying")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee in status paid whose supersponsor upgrade was removed by an admin")
+	token := tstValidStaffToken(t, "1")
+	location, att := tstRegisterAttendeeAndTransitionToStatus(t, "el8-", "paid")
+	att.Packages = "room-none,attendance,stage"
+	removeResponse := tstPerformPut(location, tstRenderJson(att), tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, removeResponse.status, "unexpected http response status")
+
+	docs.When("when the frontend asks which choices they are not eligible for")
+	response := tstPerformPost("/api/rest/v1/attendees/eligibility", tstRenderJson(att), token)
+
+	docs.Then("then the supersponsor upgrade is listed with the reason")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	actual := attendee.ChoiceEligibilityDto{}
+	tstParseJson(response.body, &actual)
+	require.Equal(t, map[string]string{"sponsor2": "can no longer be picked once the status is paid"}, actual.Packages)
+}
+
+func TestChoiceEligibilityExistingAttendeeOtherUserDeny(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	_, att := tstRegisterAttendee(t, "el9-")
+
+	docs.When("when a different user asks for their choice eligibility")
+	response := tstPerformPost("/api/rest/v1/attendees/eligibility", tstRenderJson(att), tstValidUserToken(t, "101"))
+
+	docs.Then("then the request is denied")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized to access this data - the attempt has been logged")
+}
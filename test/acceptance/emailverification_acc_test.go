@@ -0,0 +1,204 @@
+package acceptance
+
+import (
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// ---------------------------------------------
+// acceptance tests for email verification
+// ---------------------------------------------
+
+func TestEmailVerification_Success(t *testing.T) {
+	tstSetupEmailVerification(t, false)
+	defer tstShutdown()
+
+	docs.Given("given an unauthenticated user who has registered, and was mailed a verification token")
+	creationResponse := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(tstBuildValidAttendee("ev1-")), tstNoToken())
+	require.Equal(t, http.StatusCreated, creationResponse.status, "unexpected http response status")
+	loc := creationResponse.location
+	att := tstReadAttendee(t, loc)
+	require.False(t, att.EmailVerified)
+	token := tstEmailVerificationToken(t, att)
+
+	docs.When("when they confirm their email address using the token")
+	response := tstPerformPost("/api/rest/v1/attendees/email-verification", tstRenderJson(attendee.EmailVerificationDto{Token: token}), tstNoToken())
+
+	docs.Then("then the request is successful and the email address is marked as verified")
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+	require.Equal(t, loc, response.location)
+	require.True(t, tstReadAttendee(t, loc).EmailVerified)
+
+	docs.Then("and confirming a second time is also successful")
+	response = tstPerformPost("/api/rest/v1/attendees/email-verification", tstRenderJson(attendee.EmailVerificationDto{Token: token}), tstNoToken())
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+}
+
+func TestEmailVerification_InvalidToken(t *testing.T) {
+	tstSetupEmailVerification(t, false)
+	defer tstShutdown()
+
+	docs.Given("given a registration with an unverified email address")
+	loc, att := tstRegisterAttendee(t, "ev2-")
+
+	docs.When("when someone attempts to verify it using a forged token")
+	response := tstPerformPost("/api/rest/v1/attendees/email-verification", tstRenderJson(attendee.EmailVerificationDto{Token: att.Id + ".99999999999.forged"}), tstNoToken())
+
+	docs.Then("then the request fails (400) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "verification.token.invalid", url.Values{})
+	require.False(t, tstReadAttendee(t, loc).EmailVerified)
+}
+
+func TestEmailVerification_UnknownId(t *testing.T) {
+	tstSetupEmailVerification(t, false)
+	defer tstShutdown()
+
+	docs.When("when someone attempts to verify an email address using a token for a registration that does not exist")
+	response := tstPerformPost("/api/rest/v1/attendees/email-verification", tstRenderJson(attendee.EmailVerificationDto{Token: "42.99999999999.forged"}), tstNoToken())
+
+	docs.Then("then the request fails (400) with the same error as for any other invalid token")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "verification.token.invalid", url.Values{})
+}
+
+func TestEmailVerification_EmailChanged(t *testing.T) {
+	tstSetupEmailVerification(t, false)
+	defer tstShutdown()
+
+	docs.Given("given a user who has registered and verified their email address")
+	userToken := tstValidUserToken(t, "101")
+	loc, att := tstRegisterAttendeeWithToken(t, "ev3-", userToken)
+	oldToken := tstEmailVerificationToken(t, att)
+	response := tstPerformPost("/api/rest/v1/attendees/email-verification", tstRenderJson(attendee.EmailVerificationDto{Token: oldToken}), tstNoToken())
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+
+	docs.When("when they change their email address")
+	changed := tstReadAttendee(t, loc)
+	changed.Email = "ev3-changed@example.com"
+	mailMock.Reset()
+	response = tstPerformPut(loc, tstRenderJson(changed), userToken)
+
+	docs.Then("then the update is successful, the email address is no longer verified, and a new token is mailed")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	reread := tstReadAttendee(t, loc)
+	require.Equal(t, "ev3-changed@example.com", reread.Email)
+	require.False(t, reread.EmailVerified)
+	newToken := tstEmailVerificationToken(t, reread)
+
+	docs.Then("and the old token no longer works, but the new one does")
+	response = tstPerformPost("/api/rest/v1/attendees/email-verification", tstRenderJson(attendee.EmailVerificationDto{Token: oldToken}), tstNoToken())
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "verification.token.invalid", url.Values{})
+	response = tstPerformPost("/api/rest/v1/attendees/email-verification", tstRenderJson(attendee.EmailVerificationDto{Token: newToken}), tstNoToken())
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+	require.True(t, tstReadAttendee(t, loc).EmailVerified)
+}
+
+func TestEmailVerification_Resend(t *testing.T) {
+	tstSetupEmailVerification(t, false)
+	defer tstShutdown()
+
+	docs.Given("given a user who has registered but lost the verification email")
+	userToken := tstValidUserToken(t, "101")
+	loc, att := tstRegisterAttendeeWithToken(t, "ev4-", userToken)
+	mailMock.Reset()
+
+	docs.When("when they request another verification email")
+	response := tstPerformPost(loc+"/email-verification", "", userToken)
+
+	docs.Then("then the request is successful and a token is mailed")
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+	token := tstEmailVerificationToken(t, att)
+
+	docs.When("when they verify their email address and request another verification email")
+	response = tstPerformPost("/api/rest/v1/attendees/email-verification", tstRenderJson(attendee.EmailVerificationDto{Token: token}), tstNoToken())
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+	response = tstPerformPost(loc+"/email-verification", "", userToken)
+
+	docs.Then("then the request fails (409) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "verification.already.done", url.Values{"details": []string{"the email address is already verified"}})
+}
+
+func TestEmailVerification_ResendOtherDeny(t *testing.T) {
+	tstSetupEmailVerification(t, false)
+	defer tstShutdown()
+
+	docs.Given("given a registration owned by someone else")
+	loc, _ := tstRegisterAttendee(t, "ev5-")
+
+	docs.When("when a user requests a verification email for it")
+	response := tstPerformPost(loc+"/email-verification", "", tstValidUserToken(t, "101"))
+
+	docs.Then("then the request is denied as unauthorized (403) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized to access this data - the attempt has been logged")
+}
+
+func TestEmailVerification_Disabled(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given the configuration does not enable email verification, and a registration")
+	_, att := tstRegisterAttendee(t, "ev6-")
+
+	docs.Then("then no verification email was sent")
+	require.Empty(t, mailMock.Recording())
+
+	docs.When("when someone attempts to verify an email address")
+	response := tstPerformPost("/api/rest/v1/attendees/email-verification", tstRenderJson(attendee.EmailVerificationDto{Token: att.Id + ".99999999999.forged"}), tstNoToken())
+
+	docs.Then("then the request fails (404) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusNotFound, "verification.disabled", url.Values{})
+}
+
+func TestEmailVerification_RequiredForApproval(t *testing.T) {
+	tstSetupEmailVerification(t, true)
+	defer tstShutdown()
+
+	docs.Given("given the configuration requires a verified email address for approval, and a registration that is not verified")
+	loc, att := tstRegisterAttendee(t, "ev7-")
+	token := tstEmailVerificationToken(t, att)
+
+	docs.When("when an admin attempts to approve the registration")
+	body := status.StatusChangeDto{Status: "approved", Comment: "ev7"}
+	response := tstPerformPost(loc+"/status", tstRenderJson(body), tstValidAdminToken(t))
+
+	docs.Then("then the request fails (409) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "status.email.unverified", url.Values{"details": []string{"the email address must be verified before the registration can be approved"}})
+
+	docs.When("when the email address has been verified and the admin tries again")
+	response = tstPerformPost("/api/rest/v1/attendees/email-verification", tstRenderJson(attendee.EmailVerificationDto{Token: token}), tstNoToken())
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+	response = tstPerformPost(loc+"/status", tstRenderJson(body), tstValidAdminToken(t))
+
+	docs.Then("then the registration is approved")
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+	tstVerifyStatus(t, loc, "approved")
+}
+
+// --- helpers ---
+
+func tstSetupEmailVerification(t *testing.T, requireForApproval bool) {
+	t.Setenv("REG_ATTENDEE_EMAIL_VERIFICATION__ENABLED", "true")
+	t.Setenv("REG_ATTENDEE_EMAIL_VERIFICATION__SECRET", "acceptance-test-secret-which-is-long-enough")
+	if requireForApproval {
+		t.Setenv("REG_ATTENDEE_EMAIL_VERIFICATION__REQUIRE_FOR_APPROVAL", "true")
+	}
+	tstSetup(tstConfigFile(false, false, true))
+}
+
+// tstEmailVerificationToken returns the token from the last verification email sent to the attendee.
+func tstEmailVerificationToken(t *testing.T, att attendee.AttendeeDto) string {
+	token := ""
+	for _, mail := range mailMock.Recording() {
+		if mail.Name == "email-verification" && mail.Email == att.Email {
+			require.Equal(t, att.Id, mail.Variables["badge_number"])
+			require.Equal(t, att.Nickname, mail.Variables["nickname"])
+			token = mail.Variables["token"]
+		}
+	}
+	require.NotEmpty(t, token, "no verification email found")
+	return token
+}
@@ -0,0 +1,335 @@
+package acceptance
+
+import (
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/groups"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// ------------------------------------------
+// acceptance tests for groups and roommates
+// ------------------------------------------
+
+// --- create and join
+
+func TestGroups_CreateJoinAndView(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given two users with a registration each")
+	ownerToken := tstValidUserToken(t, "101")
+	_, owner := tstRegisterAttendeeWithToken(t, "grp1-", ownerToken)
+	memberToken := tstValidStaffToken(t, "202")
+	_, member := tstRegisterAttendeeWithToken(t, "grp1b-", memberToken)
+
+	docs.When("when the first user creates a room group and an invite code")
+	groupLoc := tstCreateGroup(t, ownerToken, "Cheetah Den", "room", owner)
+	code := tstCreateInviteCode(t, ownerToken, groupLoc)
+
+	docs.When("when the second user joins the group with the invite code")
+	body := groups.JoinGroupDto{Code: code, Attendee: tstAttendeeId(member)}
+	response := tstPerformPost("/api/rest/v1/groups/join", tstRenderJson(body), memberToken)
+
+	docs.Then("then the request is successful and points to the group")
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+	require.Equal(t, groupLoc, response.location)
+
+	docs.Then("and both users can see the group with both members and no problems")
+	for _, token := range []string{ownerToken, memberToken} {
+		actual := tstReadGroup(t, groupLoc, token)
+		require.Equal(t, "Cheetah Den", actual.Name)
+		require.Equal(t, "room", actual.Type)
+		require.Equal(t, owner.Id, actual.Owner)
+		require.Equal(t, []string{owner.Id, member.Id}, tstGroupMemberIds(actual))
+		require.Equal(t, []string{}, actual.Problems)
+	}
+
+	docs.Then("and the group is listed as one of the groups of the second user")
+	mineResponse := tstPerformGet("/api/rest/v1/groups/mine", memberToken)
+	require.Equal(t, http.StatusOK, mineResponse.status, "unexpected http response status")
+	mine := groups.GroupListDto{}
+	tstParseJson(mineResponse.body, &mine)
+	require.Equal(t, 1, len(mine.Groups))
+	require.Equal(t, "Cheetah Den", mine.Groups[0].Name)
+}
+
+func TestGroups_CreateForOtherDeny(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a registration by another user")
+	_, other := tstRegisterAttendeeWithToken(t, "grp2-", tstValidStaffToken(t, "202"))
+
+	docs.When("when a user attempts to create a group owned by that registration")
+	body := groups.GroupCreateDto{Name: "Not Mine", Type: "fursuit", Owner: tstAttendeeId(other)}
+	response := tstPerformPost("/api/rest/v1/groups", tstRenderJson(body), tstValidUserToken(t, "101"))
+
+	docs.Then("then the request is denied as unauthorized (403) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized to access this data - the attempt has been logged")
+}
+
+func TestGroups_CreateInvalid(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a logged in user")
+	token := tstValidUserToken(t, "101")
+
+	docs.When("when they attempt to create a group with invalid values")
+	body := groups.GroupCreateDto{Name: "", Type: "unicorns"}
+	response := tstPerformPost("/api/rest/v1/groups", tstRenderJson(body), token)
+
+	docs.Then("then the request fails (400) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "group.data.invalid", url.Values{
+		"name":  []string{"name field must be at least 1 and at most 80 characters long"},
+		"type":  []string{"type field must be one of room,dealers,fursuit,other"},
+		"owner": []string{"owner field must be a badge number"},
+	})
+}
+
+func TestGroups_JoinInvalidCode(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a group, and a registration by another user")
+	ownerToken := tstValidUserToken(t, "101")
+	_, owner := tstRegisterAttendeeWithToken(t, "grp4-", ownerToken)
+	groupLoc := tstCreateGroup(t, ownerToken, "Dealers", "dealers", owner)
+	tstCreateInviteCode(t, ownerToken, groupLoc)
+	memberToken := tstValidStaffToken(t, "202")
+	_, member := tstRegisterAttendeeWithToken(t, "grp4b-", memberToken)
+
+	docs.When("when the other user attempts to join with a wrong invite code")
+	body := groups.JoinGroupDto{Code: groupLoc[len("/api/rest/v1/groups/"):] + "-GUESSED", Attendee: tstAttendeeId(member)}
+	response := tstPerformPost("/api/rest/v1/groups/join", tstRenderJson(body), memberToken)
+
+	docs.Then("then the request fails (404) and no details are given")
+	tstRequireErrorResponse(t, response, http.StatusNotFound, "group.code.invalid", url.Values{})
+}
+
+func TestGroups_DuplicateMembership(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a registration that owns a room group")
+	token := tstValidUserToken(t, "101")
+	_, owner := tstRegisterAttendeeWithToken(t, "grp5-", token)
+	tstCreateGroup(t, token, "First Room", "room", owner)
+
+	docs.When("when the user attempts to create a second room group for the same registration")
+	body := groups.GroupCreateDto{Name: "Second Room", Type: "room", Owner: tstAttendeeId(owner)}
+	response := tstPerformPost("/api/rest/v1/groups", tstRenderJson(body), token)
+
+	docs.Then("then the request fails (409) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "group.member.duplicate", url.Values{"details": []string{"a registration can only be in one group of each type: attendee " + owner.Id + " is already in a room group"}})
+
+	docs.Then("but a group of a different type is possible")
+	tstCreateGroup(t, token, "Suiters", "fursuit", owner)
+}
+
+// --- room packages
+
+func TestGroups_RoomPackageConsistency(t *testing.T) {
+	t.Setenv("REG_ATTENDEE_GROUPS__ROOM_PACKAGES", "room-none")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a room group with two members that have the same room package")
+	ownerToken := tstValidUserToken(t, "101")
+	_, owner := tstRegisterAttendeeWithToken(t, "grp6-", ownerToken)
+	groupLoc := tstCreateGroup(t, ownerToken, "Room 101", "room", owner)
+	memberLoc, member := tstRegisterAttendee(t, "grp6b-")
+	tstAdminAddGroupMember(t, groupLoc, member)
+
+	docs.Given("given a third registration with a different room package")
+	otherLoc, other := tstRegisterAttendeeWithToken(t, "grp6c-", tstValidStaffToken(t, "202"))
+	tstRemoveRoomPackage(t, otherLoc, other)
+
+	docs.When("when the third registration attempts to join")
+	code := tstCreateInviteCode(t, ownerToken, groupLoc)
+	body := groups.JoinGroupDto{Code: code, Attendee: tstAttendeeId(other)}
+	response := tstPerformPost("/api/rest/v1/groups/join", tstRenderJson(body), tstValidStaffToken(t, "202"))
+
+	docs.Then("then the request fails (409) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "group.room.mismatch", url.Values{"details": []string{"all members of a room group must have the same room package: the owner has room-none, attendee " + other.Id + " has no room package"}})
+
+	docs.When("when an admin later changes the room package of the second member")
+	tstRemoveRoomPackage(t, memberLoc, member)
+
+	docs.Then("then the group is reported as inconsistent")
+	actual := tstReadGroup(t, groupLoc, ownerToken)
+	require.Equal(t, []string{owner.Id, member.Id}, tstGroupMemberIds(actual))
+	require.Equal(t, "room-none", actual.Members[0].RoomPackage)
+	require.Equal(t, "", actual.Members[1].RoomPackage)
+	require.Equal(t, []string{"attendee " + member.Id + " has no room package, but the owner has room-none"}, actual.Problems)
+}
+
+// --- leaving and deleting
+
+func TestGroups_LeaveAndOwnerCannotLeave(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a group with an owner and a second member")
+	ownerToken := tstValidUserToken(t, "101")
+	_, owner := tstRegisterAttendeeWithToken(t, "grp7-", ownerToken)
+	groupLoc := tstCreateGroup(t, ownerToken, "Table 7", "dealers", owner)
+	memberToken := tstValidStaffToken(t, "202")
+	_, member := tstRegisterAttendeeWithToken(t, "grp7b-", memberToken)
+	tstAdminAddGroupMember(t, groupLoc, member)
+
+	docs.When("when the second member leaves the group")
+	response := tstPerformDelete(groupLoc+"/members/"+member.Id, memberToken)
+
+	docs.Then("then the request is successful and only the owner remains")
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+	require.Equal(t, []string{owner.Id}, tstGroupMemberIds(tstReadGroup(t, groupLoc, ownerToken)))
+
+	docs.When("when the owner attempts to leave the group")
+	response = tstPerformDelete(groupLoc+"/members/"+owner.Id, ownerToken)
+
+	docs.Then("then the request fails (409) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "group.owner.leave", url.Values{"details": []string{"the owner cannot leave the group, please delete it or choose another owner"}})
+}
+
+func TestGroups_ViewDeny(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a group")
+	ownerToken := tstValidUserToken(t, "101")
+	_, owner := tstRegisterAttendeeWithToken(t, "grp8-", ownerToken)
+	groupLoc := tstCreateGroup(t, ownerToken, "Private", "other", owner)
+
+	docs.When("when a user who is not a member attempts to view it")
+	response := tstPerformGet(groupLoc, tstValidStaffToken(t, "202"))
+
+	docs.Then("then the request is denied as unauthorized (403) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized to access this data - the attempt has been logged")
+}
+
+func TestGroups_AdminListAndDelete(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a group")
+	ownerToken := tstValidUserToken(t, "101")
+	_, owner := tstRegisterAttendeeWithToken(t, "grp9-", ownerToken)
+	groupLoc := tstCreateGroup(t, ownerToken, "Listed", "fursuit", owner)
+
+	docs.When("when an admin lists all groups")
+	response := tstPerformGet("/api/rest/v1/groups", tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and the group is listed")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	actual := groups.GroupListDto{}
+	tstParseJson(response.body, &actual)
+	require.Equal(t, 1, len(actual.Groups))
+	require.Equal(t, "Listed", actual.Groups[0].Name)
+
+	docs.When("when the owner deletes the group")
+	response = tstPerformDelete(groupLoc, ownerToken)
+
+	docs.Then("then the request is successful and the group is gone")
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+	tstRequireErrorResponse(t, tstPerformGet(groupLoc, ownerToken), http.StatusNotFound, "group.id.notfound", url.Values{})
+}
+
+func TestGroups_ListUserDeny(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a regular user")
+	token := tstValidUserToken(t, "101")
+
+	docs.When("when they attempt to list all groups")
+	response := tstPerformGet("/api/rest/v1/groups", token)
+
+	docs.Then("then the request is denied as unauthorized (403) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+// --- unmatched partners
+
+func TestGroups_UnmatchedPartners(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given two registrations with a partner, only one of which is in a room group, and one without a partner")
+	ownerToken := tstValidUserToken(t, "101")
+	ownerLoc, owner := tstRegisterAttendeeWithToken(t, "grp11-", ownerToken)
+	tstSetPartner(t, ownerLoc, owner, "Snowfox")
+	tstCreateGroup(t, ownerToken, "Matched", "room", owner)
+	unmatchedLoc, unmatched := tstRegisterAttendee(t, "grp11b-")
+	unmatched = tstSetPartner(t, unmatchedLoc, unmatched, "Somebody")
+	tstRegisterAttendee(t, "grp11c-")
+
+	docs.When("when an admin lists the unmatched partner requests")
+	response := tstPerformGet("/api/rest/v1/groups/unmatched-partners", tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and only the registration that is not in a room group is listed")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	actual := groups.UnmatchedPartnerListDto{}
+	tstParseJson(response.body, &actual)
+	require.Equal(t, groups.UnmatchedPartnerListDto{Attendees: []groups.UnmatchedPartnerDto{{
+		Id:       unmatched.Id,
+		Nickname: "BlackCheetah",
+		Partner:  "Somebody",
+		Status:   "new",
+	}}}, actual)
+}
+
+// --- helpers ---
+
+func tstCreateGroup(t *testing.T, token string, name string, groupType string, owner attendee.AttendeeDto) string {
+	body := groups.GroupCreateDto{Name: name, Type: groupType, Owner: tstAttendeeId(owner)}
+	response := tstPerformPost("/api/rest/v1/groups", tstRenderJson(body), token)
+	require.Equal(t, http.StatusCreated, response.status, "unexpected http response status")
+	return response.location
+}
+
+func tstCreateInviteCode(t *testing.T, token string, groupLoc string) string {
+	response := tstPerformPost(groupLoc+"/invite-code", "", token)
+	require.Equal(t, http.StatusCreated, response.status, "unexpected http response status")
+	dto := groups.InviteCodeDto{}
+	tstParseJson(response.body, &dto)
+	return dto.Code
+}
+
+func tstAdminAddGroupMember(t *testing.T, groupLoc string, member attendee.AttendeeDto) {
+	response := tstPerformPost(groupLoc+"/members/"+member.Id, "", tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+}
+
+func tstReadGroup(t *testing.T, groupLoc string, token string) groups.GroupDto {
+	response := tstPerformGet(groupLoc, token)
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	dto := groups.GroupDto{}
+	tstParseJson(response.body, &dto)
+	return dto
+}
+
+func tstGroupMemberIds(group groups.GroupDto) []string {
+	result := make([]string, 0)
+	for _, m := range group.Members {
+		result = append(result, m.Id)
+	}
+	return result
+}
+
+func tstRemoveRoomPackage(t *testing.T, loc string, att attendee.AttendeeDto) {
+	att.Packages = "attendance,stage,sponsor2"
+	response := tstPerformPut(loc, tstRenderJson(att), tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+}
+
+func tstSetPartner(t *testing.T, loc string, att attendee.AttendeeDto, partner string) attendee.AttendeeDto {
+	att.Partner = partner
+	response := tstPerformPut(loc, tstRenderJson(att), tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	return att
+}
@@ -0,0 +1,205 @@
+package acceptance
+
+import (
+	"context"
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/admin"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/database"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// -----------------------------------------------
+// acceptance tests for the bulk attendee import
+// -----------------------------------------------
+
+const tstImportHeader = "nickname,first_name,last_name,street,zip,city,country,country_badge,email,phone,birthday,tshirt_size,status,admin_flags\n"
+
+func TestImport_AnonDeny(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when an unauthenticated user attempts to import attendees")
+	response := tstPerformPost("/api/rest/v1/attendees/import", tstImportHeader+tstImportRow("imp1a", "", ""), tstNoToken())
+
+	docs.Then("then the request is denied as unauthenticated (401) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusUnauthorized, "auth.unauthorized", "you must be logged in for this operation")
+}
+
+func TestImport_UserDeny(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when a regular user attempts to import attendees")
+	response := tstPerformPost("/api/rest/v1/attendees/import", tstImportHeader+tstImportRow("imp2a", "", ""), tstValidUserToken(t, "101"))
+
+	docs.Then("then the request is denied as unauthorized (403) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+
+	docs.Then("and no attendees have been created")
+	tstRequireMaxId(t, 0)
+}
+
+func TestImport_AdminParseError(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when an admin attempts to import a file with an unknown column")
+	response := tstPerformPost("/api/rest/v1/attendees/import", "nickname,favourite_colour\nimp3a,blue\n", tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the appropriate error")
+	require.Equal(t, http.StatusBadRequest, response.status, "unexpected http response status")
+	require.Contains(t, response.body, "import.parse.error")
+	require.Contains(t, response.body, "invalid column favourite_colour")
+}
+
+func TestImport_AdminDryRunWithErrors(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	_, existing := tstRegisterAttendee(t, "imp4-")
+
+	docs.When("when an admin performs a dry run of an import with invalid rows")
+	body := tstImportHeader +
+		tstImportRow("imp4a", "", "") +
+		"imp4b,Hans,Mustermann,Teststraße 24,12345,Berlin,DE,DE,not-an-email,+49-30-123,1998-11-23,XXL,,\n" +
+		tstImportRow("imp4c", "paid", "") +
+		tstImportRow("imp4a", "", "") +
+		tstImportRow("imp4d", "", "ev") +
+		existing.Nickname + ",Hans,Mustermann,Teststraße 24," + existing.Zip + ",Berlin,DE,DE," + existing.Email + ",+49-30-123,1998-11-23,XXL,,\n"
+	response := tstPerformPost("/api/rest/v1/attendees/import?dry_run=true", body, tstValidAdminToken(t))
+
+	docs.Then("then the request fails and the errors are reported for each offending row")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "import.data.invalid", url.Values{
+		"row-3.email":       []string{"email field is not plausible, must match ^[^\\@\\s]+\\@[^\\@\\s]+$"},
+		"row-4.status":      []string{"optional status field must be one of new, approved, cancelled or it can be left blank, which means new"},
+		"row-5.attendee":    []string{"same nickname, zip code and email as row 2"},
+		"row-6.admin_flags": []string{"admin_flags field must be a comma separated combination of any of guest"},
+		"row-7.attendee":    []string{"there is already an attendee with this information (looking at nickname, email, and zip code)"},
+	})
+
+	docs.Then("and no attendees have been created")
+	tstRequireMaxId(t, 1)
+}
+
+func TestImport_AdminDryRunOk(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when an admin performs a dry run of a valid import")
+	body := tstImportHeader + tstImportRow("imp5a", "", "") + tstImportRow("imp5b", "approved", "guest")
+	response := tstPerformPost("/api/rest/v1/attendees/import?dry_run=true", body, tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and reports the rows that would be imported")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	resultDto := attendee.AttendeeImportResultDto{}
+	tstParseJson(response.body, &resultDto)
+	require.EqualValues(t, attendee.AttendeeImportResultDto{
+		DryRun: true,
+		Count:  2,
+		Rows:   []attendee.AttendeeImportRowResult{{Row: 2}, {Row: 3}},
+	}, resultDto)
+
+	docs.Then("and no attendees have been created")
+	tstRequireMaxId(t, 0)
+}
+
+func TestImport_AdminOk(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when an admin imports two attendees, one of them approved and with an admin flag")
+	body := tstImportHeader + tstImportRow("imp6a", "", "") + tstImportRow("imp6b", "approved", "guest")
+	response := tstPerformPost("/api/rest/v1/attendees/import", body, tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and reports the assigned badge numbers")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	resultDto := attendee.AttendeeImportResultDto{}
+	tstParseJson(response.body, &resultDto)
+	require.EqualValues(t, attendee.AttendeeImportResultDto{
+		DryRun: false,
+		Count:  2,
+		Rows:   []attendee.AttendeeImportRowResult{{Row: 2, Id: "1"}, {Row: 3, Id: "2"}},
+	}, resultDto)
+
+	docs.Then("and the attendees have been created with the configured defaults for choices not in the file")
+	attendee1 := tstReadAttendee(t, "/api/rest/v1/attendees/1")
+	require.Equal(t, "imp6a", attendee1.Nickname)
+	require.Equal(t, "attendance,room-none,stage", attendee1.Packages)
+	tstVerifyStatus(t, "/api/rest/v1/attendees/1", "new")
+
+	docs.Then("and the second attendee has the requested status, dues and admin flags")
+	tstVerifyStatus(t, "/api/rest/v1/attendees/2", "approved")
+	require.Equal(t, 1, len(paymentMock.Recording()))
+	adminResponse := tstPerformGet("/api/rest/v1/attendees/2/admin", tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, adminResponse.status, "unexpected http response status")
+	adminDto := admin.AdminInfoDto{}
+	tstParseJson(adminResponse.body, &adminDto)
+	require.Equal(t, "guest", adminDto.Flags)
+
+	docs.Then("and the imported registrations are not owned by the admin")
+	imported, err := database.GetRepository().GetAttendeeById(context.Background(), 2)
+	require.Nil(t, err)
+	require.Equal(t, "", imported.Identity)
+}
+
+func TestImport_AdminTooLarge(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when an admin attempts to import a file that is too large")
+	body := tstImportHeader + strings.Repeat(tstImportRow("imp7a", "", ""), 30000)
+	response := tstPerformPost("/api/rest/v1/attendees/import", body, tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the appropriate error")
+	tstRequireErrorResponse(t, response, http.StatusRequestEntityTooLarge, "import.parse.error", url.Values{
+		"details": []string{"import file must not be larger than 2097152 bytes"},
+	})
+
+	docs.Then("and no attendees have been created")
+	tstRequireMaxId(t, 0)
+}
+
+func TestImport_AdminApprovedNeedsVerification(t *testing.T) {
+	docs.Given("given the configuration for standard registration with email verification required for approval")
+	tstSetupEmailVerification(t, true)
+	defer tstShutdown()
+
+	docs.When("when an admin attempts to import a new and an approved attendee")
+	body := tstImportHeader + tstImportRow("imp8a", "", "") + tstImportRow("imp8b", "approved", "")
+	response := tstPerformPost("/api/rest/v1/attendees/import", body, tstValidAdminToken(t))
+
+	docs.Then("then the request fails up front, because the approval would fail halfway through the import")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "import.data.invalid", url.Values{
+		"row-3.status": []string{"the email address must be verified before approval, which is not possible for imported registrations"},
+	})
+
+	docs.Then("and no attendees have been created")
+	tstRequireMaxId(t, 0)
+}
+
+// helper functions
+
+func tstImportRow(nickname string, status string, adminFlags string) string {
+	return nickname + ",Hans,Mustermann,Teststraße 24,12345,Berlin,DE,DE," + nickname + "@example.com,+49-30-123,1998-11-23,XXL," + status + "," + adminFlags + "\n"
+}
+
+func tstRequireMaxId(t *testing.T, expected uint) {
+	response := tstPerformGet("/api/rest/v1/attendees/max-id", tstNoToken())
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status for max-id")
+	maxIdDto := attendee.AttendeeMaxIdDto{}
+	tstParseJson(response.body, &maxIdDto)
+	require.Equal(t, expected, maxIdDto.MaxId)
+}
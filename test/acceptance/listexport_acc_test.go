@@ -0,0 +1,158 @@
+package acceptance
+
+import (
+	"archive/zip"
+	"bytes"
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// -----------------------------------------------
+// acceptance tests for the attendee list export
+// -----------------------------------------------
+
+func TestListExport_AnonDeny(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when an unauthenticated user attempts to export the attendee list")
+	response := tstPerformPost("/api/rest/v1/attendees/export", `{}`, tstNoToken())
+
+	docs.Then("then the request is denied as unauthenticated (401) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusUnauthorized, "auth.unauthorized", "you must be logged in for this operation")
+}
+
+func TestListExport_UserDeny(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a regular user who has registered")
+	token := tstValidUserToken(t, "101")
+	_, _ = tstRegisterAttendeeWithToken(t, "lex2-", token)
+
+	docs.When("when they attempt to export the attendee list")
+	response := tstPerformPost("/api/rest/v1/attendees/export", `{}`, token)
+
+	docs.Then("then the request is denied as unauthorized (403) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+func TestListExport_AdminCsv(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given three attendees, one of which has paid, and one of which has been approved")
+	_, attendee1 := tstRegisterAttendeeAndTransitionToStatus(t, "lex3a-", "paid")
+	_, attendee2 := tstRegisterAttendeeAndTransitionToStatus(t, "lex3b-", "approved")
+	_, _ = tstRegisterAttendee(t, "other3c-")
+
+	docs.When("when an admin exports the attendees matching a search as csv, with status and payment columns")
+	body := `{"criteria":{"match_any":[{"email":"lex3*"}]},"columns":["id","nickname","email","status","total_dues","payment_balance","current_dues"]}`
+	response := tstPerformPost("/api/rest/v1/attendees/export", body, tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and the csv contains the matching attendees with the derived values")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	require.Equal(t, "text/csv; charset=utf-8", response.contentType)
+	expected := "id,nickname,email,status,total_dues,payment_balance,current_dues\n" +
+		attendee1.Id + ",BlackCheetah," + attendee1.Email + ",paid,255.00,255.00,0.00\n" +
+		attendee2.Id + ",BlackCheetah," + attendee2.Email + ",approved,255.00,0.00,255.00\n"
+	require.Equal(t, expected, response.body)
+}
+
+func TestListExport_AdminCsvDefaultColumnsLimited(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given two attendees")
+	_, attendee1 := tstRegisterAttendee(t, "lex4a-")
+	_, _ = tstRegisterAttendee(t, "lex4b-")
+
+	docs.When("when an admin exports all attendees, limited to one result, without specifying columns")
+	response := tstPerformPost("/api/rest/v1/attendees/export", `{"criteria":{"num_results":1}}`, tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and the csv contains only the first attendee with the default columns")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	expected := "id,nickname,first_name,last_name,email,country,flags,packages,options,status\n" +
+		attendee1.Id + ",BlackCheetah,Hans,Mustermann," + attendee1.Email + ",DE,\"anon,hc\",\"room-none,attendance,stage,sponsor2\",\"music,suit\",new\n"
+	require.Equal(t, expected, response.body)
+}
+
+func TestListExport_AdminCsvPaymentsSeveralPages(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given more attendees than fit on one page of an export with payment columns")
+	expected := "id,total_dues\n"
+	for i := 0; i < 25; i++ {
+		_, att := tstRegisterAttendee(t, "lex6-")
+		expected += att.Id + ",0.00\n"
+	}
+
+	docs.When("when an admin exports all attendees with a payment column")
+	response := tstPerformPost("/api/rest/v1/attendees/export", `{"columns":["id","total_dues"]}`, tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and the csv contains the attendees from all pages")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	require.Equal(t, expected, response.body)
+}
+
+func TestListExport_AdminXlsx(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee")
+	_, _ = tstRegisterAttendee(t, "lex5-")
+
+	docs.When("when an admin exports the attendee list as xlsx")
+	response := tstPerformPost("/api/rest/v1/attendees/export", `{"format":"xlsx"}`, tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and a valid xlsx file is returned")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	require.Equal(t, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", response.contentType)
+	zr, err := zip.NewReader(bytes.NewReader([]byte(response.body)), int64(len(response.body)))
+	require.Nil(t, err)
+	require.Equal(t, 5, len(zr.File))
+}
+
+func TestListExport_AdminInvalid(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when an admin requests an export with an unknown format and column")
+	response := tstPerformPost("/api/rest/v1/attendees/export", `{"format":"ods","columns":["id","shoe_size"]}`, tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the appropriate error")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "export.data.invalid", url.Values{
+		"format":  []string{"format field must be one of csv, xlsx or it can be left blank, which means csv"},
+		"columns": []string{"invalid column shoe_size, must be one of id, nickname, first_name, last_name, street, zip, city, country, country_badge, state, email, phone, telegram, partner, birthday, gender, pronouns, tshirt_size, flags, options, packages, user_comments, status, total_dues, payment_balance, current_dues"},
+	})
+}
+
+func TestListExport_PaymentServiceDown(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee")
+	_, _ = tstRegisterAttendee(t, "lex7-")
+
+	docs.Given("given the payment service is unavailable")
+	paymentMock.SimulateGetError(paymentservice.DownstreamError)
+
+	docs.When("when an admin exports the attendee list including dues")
+	response := tstPerformPost("/api/rest/v1/attendees/export", `{"columns":["id","total_dues"]}`, tstValidAdminToken(t))
+
+	docs.Then("then the request fails with the appropriate error")
+	tstRequireErrorResponse(t, response, http.StatusBadGateway, "export.payment.error", "")
+}
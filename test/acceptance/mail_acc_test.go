@@ -0,0 +1,151 @@
+package acceptance
+
+import (
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/mailservice"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ------------------------------------------------------------
+// acceptance tests for the configurable mail notifications
+// ------------------------------------------------------------
+
+const tstMailConfig = `
+mail:
+  languages:
+    - 'en-US'
+    - 'de-DE'
+  default_language: 'en-US'
+  registration_url: 'https://reg.example.com/app/register/{badge_number}'
+  templates:
+    new-status-approved: 'registration-approved'
+  suppress_transitions:
+    - 'approved->cancelled'
+  suppress_bulk: true
+`
+
+func TestMail_StatusChangeTemplateAndVariables(t *testing.T) {
+	docs.Given("given the configuration for standard registration with a mail configuration")
+	tstSetupMail(t)
+	defer tstShutdown()
+
+	docs.Given("given an attendee who has chosen German for their mails")
+	dto := tstBuildValidAttendee("mail1-")
+	dto.Language = "de-DE"
+	response := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(dto), tstValidStaffToken(t, "1"))
+	require.Equal(t, http.StatusCreated, response.status, "unexpected http response status")
+	loc := response.location
+	require.Equal(t, "de-DE", tstReadAttendee(t, loc).Language)
+
+	docs.When("when an admin approves the registration")
+	tstChangeStatus(t, loc, "approved")
+
+	docs.Then("then the configured template is sent in the language of the attendee, with all status variables")
+	require.Equal(t, 1, len(mailMock.Recording()))
+	require.Equal(t, mailservice.TemplateRequestDto{
+		Name: "registration-approved",
+		Lang: "de-DE",
+		Variables: map[string]string{
+			"nickname":          "BlackCheetah",
+			"badge_number":      "1",
+			"registration_link": "https://reg.example.com/app/register/1",
+			"status":            "approved",
+			"packages":          "Entrance Fee (Convention Ticket), No Room, Supersponsor Upgrade, Entrance Fee (Stage Ticket)",
+			"total_dues":        "255.00",
+			"remaining_dues":    "255.00",
+			"due_date":          "",
+		},
+		Email: dto.Email,
+	}, mailMock.Recording()[0])
+}
+
+func TestMail_DefaultLanguageAndSuppressedTransition(t *testing.T) {
+	docs.Given("given the configuration for standard registration with a mail configuration")
+	tstSetupMail(t)
+	defer tstShutdown()
+
+	docs.Given("given an approved attendee who has not chosen a language")
+	loc, att := tstRegisterAttendee(t, "mail2-")
+	tstChangeStatus(t, loc, "approved")
+
+	docs.Then("then the approval mail was sent in the default language")
+	require.Equal(t, 1, len(mailMock.Recording()))
+	require.Equal(t, mailservice.TemplateRequestDto{
+		Name: "registration-approved",
+		Lang: "en-US",
+		Variables: map[string]string{
+			"nickname":          "BlackCheetah",
+			"badge_number":      "1",
+			"registration_link": "https://reg.example.com/app/register/1",
+			"status":            "approved",
+			"packages":          "Entrance Fee (Convention Ticket), No Room, Supersponsor Upgrade, Entrance Fee (Stage Ticket)",
+			"total_dues":        "255.00",
+			"remaining_dues":    "255.00",
+			"due_date":          "",
+		},
+		Email: att.Email,
+	}, mailMock.Recording()[0])
+	mailMock.Reset()
+
+	docs.When("when an admin cancels the registration, for which mails are suppressed")
+	tstChangeStatus(t, loc, "cancelled")
+
+	docs.Then("then the status is changed but no mail is sent")
+	tstVerifyStatus(t, loc, "cancelled")
+	require.Empty(t, mailMock.Recording())
+}
+
+func TestMail_BulkImportSuppressed(t *testing.T) {
+	docs.Given("given the configuration for standard registration with mails suppressed for bulk operations")
+	tstSetupMail(t)
+	defer tstShutdown()
+
+	docs.When("when an admin imports an approved attendee")
+	body := tstImportHeader + tstImportRow("mail3a", "approved", "")
+	response := tstPerformPost("/api/rest/v1/attendees/import", body, tstValidAdminToken(t))
+
+	docs.Then("then the attendee is approved and the dues are booked, but no mail is sent")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	tstVerifyStatus(t, "/api/rest/v1/attendees/1", "approved")
+	require.Equal(t, 1, len(paymentMock.Recording()))
+	require.Empty(t, mailMock.Recording())
+}
+
+func TestMail_InvalidLanguage(t *testing.T) {
+	docs.Given("given the configuration for standard registration with a mail configuration")
+	tstSetupMail(t)
+	defer tstShutdown()
+
+	docs.When("when someone attempts to register with a language that is not offered")
+	dto := tstBuildValidAttendee("mail4-")
+	dto.Language = "fr-FR"
+	response := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(dto), tstValidStaffToken(t, "1"))
+
+	docs.Then("then the request fails (400) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "attendee.data.invalid", url.Values{
+		"language": []string{"optional language field must be one of en-US, de-DE, or it can be left blank, which means the default language"},
+	})
+}
+
+// --- helpers ---
+
+// tstSetupMail starts the service with the standard registration configuration plus the mail section.
+func tstSetupMail(t *testing.T) {
+	base, err := os.ReadFile(tstConfigFile(false, false, true))
+	require.Nil(t, err)
+	configFile := filepath.Join(t.TempDir(), "testconfig-mail.yaml")
+	require.Nil(t, os.WriteFile(configFile, append(base, []byte(tstMailConfig)...), 0600))
+	tstSetup(configFile)
+}
+
+func tstChangeStatus(t *testing.T, location string, newStatus string) {
+	body := status.StatusChangeDto{Status: newStatus, Comment: "mail test"}
+	response := tstPerformPost(location+"/status", tstRenderJson(body), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+}
@@ -0,0 +1,123 @@
+package acceptance
+
+import (
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+// -------------------------------------------------------------
+// acceptance tests for listing the registrations a user owns
+// -------------------------------------------------------------
+
+func TestListMyRegistrations_Success(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a logged in user who has made two registrations, and another registration by someone else")
+	token := tstValidUserToken(t, "101")
+	_, first := tstRegisterAttendeeWithToken(t, "own1-", token)
+	tstRegisterAttendee(t, "own1b-")
+	_, second := tstRegisterAttendeeWithToken(t, "own1c-", token)
+
+	docs.When("when the user lists their registrations")
+	response := tstPerformGet("/api/rest/v1/attendees", token)
+
+	docs.Then("then the request is successful and the badge numbers of both of their registrations are returned")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	actual := attendee.AttendeeIdList{}
+	tstParseJson(response.body, &actual)
+	require.Equal(t, attendee.AttendeeIdList{Ids: []int64{tstIdAsInt64(first), tstIdAsInt64(second)}}, actual)
+}
+
+func TestListMyRegistrations_NotFound(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a logged in user who has not made a registration, and a registration by someone else")
+	token := tstValidUserToken(t, "101")
+	tstRegisterAttendee(t, "own2-")
+
+	docs.When("when the user lists their registrations")
+	response := tstPerformGet("/api/rest/v1/attendees", token)
+
+	docs.Then("then the request fails (404) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusNotFound, "attendee.owned.notfound", url.Values{})
+}
+
+func TestListMyRegistrations_AnonymousDeny(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an unauthenticated user")
+	token := tstNoToken()
+
+	docs.When("when they attempt to list their registrations")
+	response := tstPerformGet("/api/rest/v1/attendees", token)
+
+	docs.Then("then the request is denied as unauthenticated (401) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusUnauthorized, "auth.unauthorized", "you must be logged in for this operation")
+}
+
+func TestListMyRegistrations_ApiTokenDeny(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an api client with the read scope")
+	docs.When("when it attempts to list registrations, which only makes sense for a logged in user")
+	response := tstPerformWithApiToken(http.MethodGet, "/api/rest/v1/attendees", "", tstKioskApiToken)
+
+	docs.Then("then the request is denied as unauthorized (403) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+func TestGetMyRegistrations_Success(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a logged in user who has made a registration that is approved")
+	token := tstValidUserToken(t, "101")
+	loc, _ := tstRegisterAttendeeWithToken(t, "own3-", token)
+	body := status.StatusChangeDto{
+		Status:  "approved",
+		Comment: "own3",
+	}
+	statusResponse := tstPerformPost(loc+"/status", tstRenderJson(body), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, statusResponse.status, "unexpected http response status")
+	docs.When("when the user requests the full data of their registrations")
+	response := tstPerformGet("/api/rest/v1/attendees/mine", token)
+
+	docs.Then("then the request is successful and the registration is returned with its current status")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	actual := attendee.AttendeeWithStatusList{}
+	tstParseJson(response.body, &actual)
+	require.Equal(t, 1, len(actual.Attendees))
+	require.Equal(t, tstReadAttendee(t, loc), actual.Attendees[0].Attendee)
+	require.Equal(t, "approved", actual.Attendees[0].Status)
+}
+
+func TestGetMyRegistrations_NotFound(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a logged in user who has not made a registration")
+	token := tstValidUserToken(t, "101")
+
+	docs.When("when the user requests the full data of their registrations")
+	response := tstPerformGet("/api/rest/v1/attendees/mine", token)
+
+	docs.Then("then the request fails (404) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusNotFound, "attendee.owned.notfound", url.Values{})
+}
+
+// --- helpers ---
+
+func tstIdAsInt64(att attendee.AttendeeDto) int64 {
+	id, _ := strconv.Atoi(att.Id)
+	return int64(id)
+}
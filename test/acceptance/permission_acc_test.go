@@ -0,0 +1,177 @@
+package acceptance
+
+import (
+	"context"
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/admin"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+// ------------------------------------------------------------------
+// acceptance tests for permissions granted via the admin information
+// ------------------------------------------------------------------
+
+func TestPermission_ReadAllCanReadOthers(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	location1, attendee1 := tstRegisterAttendee(t, "perm1-")
+
+	docs.Given("given a second attendee whose registration has the read_all permission")
+	token := tstRegisterAttendeeWithPermissions(t, "perm1b-", "read_all")
+
+	docs.When("when they access the first attendee, their admin information, and the statistics")
+	response := tstPerformGet(location1, token)
+	adminResponse := tstPerformGet(location1+"/admin", token)
+	statsResponse := tstPerformGet("/api/rest/v1/statistics", token)
+
+	docs.Then("then all requests are successful")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	attendeeDto := attendee.AttendeeDto{}
+	tstParseJson(response.body, &attendeeDto)
+	require.Equal(t, attendee1.Nickname, attendeeDto.Nickname)
+	require.Equal(t, http.StatusOK, adminResponse.status, "unexpected http response status for admin info")
+	require.Equal(t, http.StatusOK, statsResponse.status, "unexpected http response status for statistics")
+}
+
+func TestPermission_ReadAllCannotWrite(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	location1, _ := tstRegisterAttendee(t, "perm2-")
+
+	docs.Given("given a second attendee whose registration has the read_all permission")
+	token := tstRegisterAttendeeWithPermissions(t, "perm2b-", "read_all")
+
+	docs.When("when they attempt to change the admin information of the first attendee")
+	body := admin.AdminInfoDto{
+		AdminComments: "perm2",
+	}
+	response := tstPerformPut(location1+"/admin", tstRenderJson(body), token)
+
+	docs.Then("then the request is denied as unauthorized (403) and the correct error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+func TestPermission_AdminCanWrite(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	location1, _ := tstRegisterAttendee(t, "perm3-")
+
+	docs.Given("given a second attendee whose registration has the admin permission, but who does not have the admin role")
+	token := tstRegisterAttendeeWithPermissions(t, "perm3b-", "admin")
+
+	docs.When("when they change the admin information of the first attendee")
+	body := admin.AdminInfoDto{
+		AdminComments: "perm3",
+	}
+	response := tstPerformPut(location1+"/admin", tstRenderJson(body), token)
+
+	docs.Then("then the request is successful")
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+
+	docs.Then("and the change has been written")
+	response2 := tstPerformGet(location1+"/admin", tstValidAdminToken(t))
+	adminInfo := admin.AdminInfoDto{}
+	tstParseJson(response2.body, &adminInfo)
+	require.Equal(t, "perm3", adminInfo.AdminComments)
+}
+
+func TestPermission_OtherPermissionDeny(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	location1, _ := tstRegisterAttendee(t, "perm4-")
+
+	docs.Given("given a second attendee whose registration only has the regdesk permission")
+	token := tstRegisterAttendeeWithPermissions(t, "perm4b-", "regdesk")
+
+	docs.When("when they attempt to access the first attendee's admin information and status history")
+	adminResponse := tstPerformGet(location1+"/admin", token)
+	historyResponse := tstPerformGet(location1+"/status-history", token)
+
+	docs.Then("then both requests are denied as unauthorized (403) and the correct error is returned")
+	tstRequireErrorResponse(t, adminResponse, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+	tstRequireErrorResponse(t, historyResponse, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+func TestPermission_PaymentsCanChangePaymentStatus(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an approved attendee who has paid part of their dues")
+	location1, att := tstRegisterAttendeeAndTransitionToStatus(t, "perm6-", "approved")
+	attid, _ := strconv.Atoi(att.Id)
+	_ = paymentMock.InjectTransaction(context.Background(), tstCreateTransaction(attid, paymentservice.Payment, 15500))
+
+	docs.Given("given a second attendee whose registration has the payments permission")
+	token := tstRegisterAttendeeWithPermissions(t, "perm6b-", "payments")
+
+	docs.When("when they change the status of the first attendee to partially paid")
+	body := status.StatusChangeDto{Status: "partially paid", Comment: "perm6"}
+	response := tstPerformPost(location1+"/status", tstRenderJson(body), token)
+
+	docs.Then("then the request is successful and the status has changed")
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+	tstVerifyStatus(t, location1, "partially paid")
+
+	docs.When("when they attempt to cancel the first attendee")
+	body = status.StatusChangeDto{Status: "cancelled", Comment: "perm6"}
+	response = tstPerformPost(location1+"/status", tstRenderJson(body), token)
+
+	docs.Then("then the request is denied and the status is unchanged")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", url.Values{"details": []string{"you are not allowed to make this status transition - the attempt has been logged"}})
+	tstVerifyStatus(t, location1, "partially paid")
+}
+
+func TestPermission_InvalidDeny(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	location1, _ := tstRegisterAttendee(t, "perm5-")
+
+	docs.When("when an admin attempts to grant a permission that does not exist")
+	body := admin.AdminInfoDto{
+		Permissions: "read_all,superpowers",
+	}
+	response := tstPerformPut(location1+"/admin", tstRenderJson(body), tstValidAdminToken(t))
+
+	docs.Then("then the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "admin.data.invalid", url.Values{"permissions": []string{"permissions field must be a comma separated combination of any of admin,read_all,payments,bans,announcements,regdesk,sponsordesk,view,stats,announce,export_conbook"}})
+}
+
+// --- helpers ---
+
+// tstRegisterAttendeeWithPermissions registers an attendee for user 101, who does not have the admin role,
+// and grants the given permissions to the registration.
+func tstRegisterAttendeeWithPermissions(t *testing.T, testcase string, permissions string) string {
+	token := tstValidUserToken(t, "101")
+
+	location, _ := tstRegisterAttendeeWithToken(t, testcase, token)
+	permBody := admin.AdminInfoDto{
+		Permissions: permissions,
+	}
+	permissionResponse := tstPerformPut(location+"/admin", tstRenderJson(permBody), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, permissionResponse.status)
+
+	return token
+}
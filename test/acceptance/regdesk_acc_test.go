@@ -0,0 +1,339 @@
+package acceptance
+
+import (
+	"fmt"
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/dataexport"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/regdesk"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/mailservice"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// -----------------------------------------
+// acceptance tests for the regdesk check-in
+// -----------------------------------------
+
+// --- lookup
+
+func TestRegdeskLookup_ByBadgeNumber(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee who has paid, and a second attendee with the regdesk permission")
+	_, att := tstRegisterAttendeeAndTransitionToStatus(t, "rdsk1-", "paid")
+	token := tstRegisterRegdeskAttendee(t, "rdsk1-")
+
+	docs.When("when the regdesk attendee looks up the first attendee by badge number")
+	response := tstPerformGet("/api/rest/v1/regdesk/attendees?id="+att.Id, token)
+
+	docs.Then("then the request is successful and everything needed for the check-in is returned")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	actual := regdesk.RegdeskAttendeeListDto{}
+	tstParseJson(response.body, &actual)
+	expected := regdesk.RegdeskAttendeeListDto{
+		Attendees: []regdesk.RegdeskAttendeeDto{{
+			Id:              att.Id,
+			Nickname:        "BlackCheetah",
+			FirstName:       "Hans",
+			LastName:        "Mustermann",
+			Status:          "paid",
+			TotalDues:       25500,
+			PaymentBalance:  25500,
+			OutstandingDues: 0,
+			TshirtSize:      "XXL",
+			Packages:        att.Packages,
+			Flags:           "anon,hc",
+		}},
+	}
+	require.EqualValues(t, expected, actual)
+}
+
+func TestRegdeskLookup_ByNickname(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee who has paid, and a second attendee with the regdesk permission")
+	tstRegisterAttendeeAndTransitionToStatus(t, "rdsk2-", "paid")
+	token := tstRegisterRegdeskAttendee(t, "rdsk2-")
+
+	docs.When("when the regdesk attendee looks up attendees by part of their nickname in a different case")
+	response := tstPerformGet("/api/rest/v1/regdesk/attendees?nickname=cheeTAH", token)
+
+	docs.Then("then the request is successful and both attendees are found")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	actual := regdesk.RegdeskAttendeeListDto{}
+	tstParseJson(response.body, &actual)
+	require.Equal(t, 2, len(actual.Attendees))
+	// both have the same nickname, so their order is not defined
+	require.ElementsMatch(t, []string{"paid", "new"}, []string{actual.Attendees[0].Status, actual.Attendees[1].Status})
+}
+
+func TestRegdeskLookup_UnknownBadgeNumber(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee with the regdesk permission")
+	token := tstRegisterRegdeskAttendee(t, "rdsk10-")
+
+	docs.When("when they look up a badge number that does not exist")
+	response := tstPerformGet("/api/rest/v1/regdesk/attendees?id=42", token)
+
+	docs.Then("then the request is successful and no attendees are returned")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	actual := regdesk.RegdeskAttendeeListDto{}
+	tstParseJson(response.body, &actual)
+	require.Empty(t, actual.Attendees)
+}
+
+func TestRegdeskLookup_Invalid(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee with the regdesk permission")
+	token := tstRegisterRegdeskAttendee(t, "rdsk3-")
+
+	docs.When("when they attempt a lookup without giving a badge number or nickname")
+	response := tstPerformGet("/api/rest/v1/regdesk/attendees", token)
+
+	docs.Then("then the request fails (400) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "regdesk.lookup.invalid", url.Values{"query": []string{"exactly one of id or nickname must be given"}})
+}
+
+func TestRegdeskLookup_UserDeny(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee who has paid, and a regular attendee without the regdesk permission")
+	_, att := tstRegisterAttendeeAndTransitionToStatus(t, "rdsk4-", "paid")
+	token := tstValidUserToken(t, "101")
+
+	docs.When("when the regular attendee attempts to look up the first attendee")
+	response := tstPerformGet("/api/rest/v1/regdesk/attendees?id="+att.Id, token)
+
+	docs.Then("then the request is denied as unauthorized (403) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+// --- check in
+
+func TestRegdeskCheckIn_Success(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	testcase := "rdsk5-"
+	docs.Given("given an attendee who has paid and already has regdesk information, and a second attendee with the regdesk permission")
+	loc, att := tstRegisterAttendeeAndTransitionToStatus(t, testcase, "paid")
+	tstWriteAdditionalInfo(t, att.Id, "regdesk", `{"tshirt":"ordered"}`)
+	token := tstRegisterRegdeskAttendee(t, testcase)
+
+	docs.When("when the regdesk attendee checks them in and hands out their badge and t-shirt")
+	body := regdesk.CheckInDto{
+		Desk:  "desk 3",
+		Items: []string{"badge", "tshirt"},
+	}
+	response := tstPerformPost(loc+"/checkin", tstRenderJson(body), token)
+
+	docs.Then("then the request is successful and the status has changed")
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+	tstVerifyStatus(t, loc, "checked in")
+
+	docs.Then("and the status history records the desk and operator")
+	historyResponse := tstPerformGet(loc+"/status-history", tstValidAdminToken(t))
+	history := status.StatusHistoryDto{}
+	tstParseJson(historyResponse.body, &history)
+	require.Equal(t, "checked in at desk 3 by 101", history.StatusHistory[len(history.StatusHistory)-1].Comment)
+
+	docs.Then("and the lookup shows the check-in")
+	checkIn := tstRegdeskLookupCheckIn(t, att.Id, token)
+	require.NotNil(t, checkIn)
+	require.Equal(t, "desk 3", checkIn.Desk)
+	require.Equal(t, "101", checkIn.Operator)
+	require.Equal(t, []string{"badge", "tshirt"}, checkIn.Items)
+
+	docs.Then("and the other regdesk information is unchanged")
+	exportResponse := tstPerformGet(loc+"/export", tstValidAdminToken(t))
+	export := dataexport.PersonalDataExportDto{}
+	tstParseJson(exportResponse.body, &export)
+	require.Equal(t, "ordered", export.AdditionalInfo["regdesk"].(map[string]interface{})["tshirt"])
+
+	docs.Then("and the appropriate email message was sent via the mail service")
+	require.Equal(t, 1, len(mailMock.Recording()))
+	actualMail := mailMock.Recording()[0]
+	expectedMail := tstNewStatusMail(testcase, "checked in", "255.00", "0.00", tstToday())
+	require.Contains(t, actualMail.Email, expectedMail.Email)
+	actualMail.Email = expectedMail.Email
+	require.EqualValues(t, expectedMail, actualMail)
+}
+
+func TestRegdeskCheckIn_MailFailed(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	testcase := "rdsk9-"
+	docs.Given("given an attendee who has paid, and a second attendee with the regdesk permission")
+	loc, att := tstRegisterAttendeeAndTransitionToStatus(t, testcase, "paid")
+	token := tstRegisterRegdeskAttendee(t, testcase)
+
+	docs.Given("given the mail service is failing")
+	mailMock.SimulateError(mailservice.DownstreamError)
+
+	docs.When("when the regdesk attendee checks them in")
+	body := regdesk.CheckInDto{
+		Desk:  "desk 3",
+		Items: []string{"badge"},
+	}
+	response := tstPerformPost(loc+"/checkin", tstRenderJson(body), token)
+
+	docs.Then("then the mail error is reported")
+	tstRequireErrorResponse(t, response, http.StatusBadGateway, "checkin.mail.error", url.Values{"details": []string{mailservice.DownstreamError.Error()}})
+
+	docs.Then("but the attendee is checked in and the lookup shows the check-in")
+	tstVerifyStatus(t, loc, "checked in")
+	checkIn := tstRegdeskLookupCheckIn(t, att.Id, token)
+	require.NotNil(t, checkIn)
+	require.Equal(t, "desk 3", checkIn.Desk)
+}
+
+func TestRegdeskCheckIn_NotPaid(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee who has only partially paid, and a second attendee with the regdesk permission")
+	loc, _ := tstRegisterAttendeeAndTransitionToStatus(t, "rdsk6-", "partially paid")
+	token := tstRegisterRegdeskAttendee(t, "rdsk6-")
+
+	docs.When("when the regdesk attendee attempts to check them in")
+	body := regdesk.CheckInDto{
+		Desk:  "desk 1",
+		Items: []string{"badge"},
+	}
+	response := tstPerformPost(loc+"/checkin", tstRenderJson(body), token)
+
+	docs.Then("then the request fails as conflict (409) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "checkin.not.paid", "only attendees in status paid can be checked in")
+
+	docs.Then("and the status is unchanged")
+	tstVerifyStatus(t, loc, "partially paid")
+	require.Empty(t, mailMock.Recording())
+}
+
+func TestRegdeskCheckIn_AlreadyCheckedIn(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee who is already checked in, and a second attendee with the regdesk permission")
+	loc, _ := tstRegisterAttendeeAndTransitionToStatus(t, "rdsk7-", "checked in")
+	token := tstRegisterRegdeskAttendee(t, "rdsk7-")
+
+	docs.When("when the regdesk attendee attempts to check them in again")
+	body := regdesk.CheckInDto{
+		Desk: "desk 1",
+	}
+	response := tstPerformPost(loc+"/checkin", tstRenderJson(body), token)
+
+	docs.Then("then the request fails as conflict (409) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "checkin.already.done", "attendee is already checked in")
+	require.Empty(t, mailMock.Recording())
+}
+
+func TestRegdeskCheckIn_InvalidValues(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee who has paid, and a second attendee with the regdesk permission")
+	loc, _ := tstRegisterAttendeeAndTransitionToStatus(t, "rdsk8-", "paid")
+	token := tstRegisterRegdeskAttendee(t, "rdsk8-")
+
+	docs.When("when the regdesk attendee attempts to check them in without a desk and handing out an unknown item")
+	body := regdesk.CheckInDto{
+		Items: []string{"badge", "unicorn"},
+	}
+	response := tstPerformPost(loc+"/checkin", tstRenderJson(body), token)
+
+	docs.Then("then the request fails (400) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "checkin.data.invalid", url.Values{
+		"desk":  []string{"desk field must be at least 1 and at most 80 characters long"},
+		"items": []string{"items must be any of badge,tshirt,bag"},
+	})
+
+	docs.Then("and the status is unchanged")
+	tstVerifyStatus(t, loc, "paid")
+}
+
+// --- undo
+
+func TestRegdeskUndoCheckIn_Success(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee who was just checked in at the regdesk")
+	loc, att := tstRegisterAttendeeAndTransitionToStatus(t, "rdsk9-", "paid")
+	token := tstRegisterRegdeskAttendee(t, "rdsk9-")
+	checkInResponse := tstPerformPost(loc+"/checkin", tstRenderJson(regdesk.CheckInDto{Desk: "desk 2", Items: []string{"badge"}}), token)
+	require.Equal(t, http.StatusNoContent, checkInResponse.status)
+	mailMock.Reset()
+
+	docs.When("when the regdesk attendee undoes the check-in")
+	response := tstPerformDelete(loc+"/checkin", token)
+
+	docs.Then("then the request is successful and the attendee is back in status paid")
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+	tstVerifyStatus(t, loc, "paid")
+
+	docs.Then("and the check-in is no longer shown by the lookup")
+	require.Nil(t, tstRegdeskLookupCheckIn(t, att.Id, token))
+
+	docs.Then("and no email messages have been sent")
+	require.Empty(t, mailMock.Recording())
+}
+
+func TestRegdeskUndoCheckIn_Expired(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee who was checked in at the regdesk an hour ago")
+	loc, att := tstRegisterAttendeeAndTransitionToStatus(t, "rdsk10-", "checked in")
+	timestamp := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	tstWriteAdditionalInfo(t, att.Id, "regdesk", fmt.Sprintf(`{"check_in":{"timestamp":"%s","desk":"desk 1","operator":"101","items":["badge"]}}`, timestamp))
+	token := tstRegisterRegdeskAttendee(t, "rdsk10-")
+
+	docs.When("when the regdesk attendee attempts to undo the check-in")
+	response := tstPerformDelete(loc+"/checkin", token)
+
+	docs.Then("then the request fails as conflict (409) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "checkin.undo.expired", "the check in can no longer be undone at the regdesk, please contact an admin")
+
+	docs.Then("and the status is unchanged")
+	tstVerifyStatus(t, loc, "checked in")
+}
+
+func TestRegdeskUndoCheckIn_NotCheckedIn(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee who has paid but is not checked in, and a second attendee with the regdesk permission")
+	loc, _ := tstRegisterAttendeeAndTransitionToStatus(t, "rdsk11-", "paid")
+	token := tstRegisterRegdeskAttendee(t, "rdsk11-")
+
+	docs.When("when the regdesk attendee attempts to undo a check-in")
+	response := tstPerformDelete(loc+"/checkin", token)
+
+	docs.Then("then the request fails as conflict (409) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "checkin.undo.notcheckedin", "attendee is not checked in")
+}
+
+// --- helpers ---
+
+func tstRegdeskLookupCheckIn(t *testing.T, id string, token string) *regdesk.CheckInInfoDto {
+	response := tstPerformGet("/api/rest/v1/regdesk/attendees?id="+id, token)
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	dto := regdesk.RegdeskAttendeeListDto{}
+	tstParseJson(response.body, &dto)
+	require.Equal(t, 1, len(dto.Attendees))
+	return dto.Attendees[0].CheckIn
+}
@@ -0,0 +1,72 @@
+package acceptance
+
+import (
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+// ------------------------------------------
+// acceptance tests for token validation
+// ------------------------------------------
+
+func TestTokenES256Accept(t *testing.T) {
+	docs.Given("given the configuration for standard registration, which includes an ECDSA public key")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	location, _ := tstRegisterAttendee(t, "sec1-")
+
+	docs.When("when an admin with a token signed using ES256 accesses the admin information for the attendee")
+	response := tstPerformGet(location+"/admin", valid_JWT_is_admin_es256)
+
+	docs.Then("then the request is successful")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+}
+
+func TestTokenWrongIssuerDeny(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	location, _ := tstRegisterAttendee(t, "sec2-")
+
+	docs.When("when someone with an admin token from a different issuer accesses the admin information for the attendee")
+	response := tstPerformGet(location+"/admin", invalid_JWT_wrong_issuer)
+
+	docs.Then("then the request is denied as unauthenticated (401)")
+	tstRequireErrorResponse(t, response, http.StatusUnauthorized, "auth.unauthorized", "invalid bearer token")
+}
+
+func TestTokenWrongAudienceDeny(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	location, _ := tstRegisterAttendee(t, "sec3-")
+
+	docs.When("when someone with an admin token issued for a different service accesses the admin information for the attendee")
+	response := tstPerformGet(location+"/admin", invalid_JWT_wrong_audience)
+
+	docs.Then("then the request is denied as unauthenticated (401)")
+	tstRequireErrorResponse(t, response, http.StatusUnauthorized, "auth.unauthorized", "invalid bearer token")
+}
+
+func TestTokenExpiredDeny(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing attendee")
+	location, _ := tstRegisterAttendee(t, "sec4-")
+
+	docs.When("when someone with an expired admin token accesses the admin information for the attendee")
+	response := tstPerformGet(location+"/admin", invalid_JWT_expired)
+
+	docs.Then("then the request is denied as unauthenticated (401)")
+	tstRequireErrorResponse(t, response, http.StatusUnauthorized, "auth.unauthorized", "invalid bearer token")
+}
@@ -0,0 +1,96 @@
+package acceptance
+
+import (
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/statistics"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// -----------------------------------------------
+// acceptance tests for the statistics resources
+// -----------------------------------------------
+
+func TestStatistics_AnonDeny(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when an unauthenticated user attempts to read the full statistics")
+	response := tstPerformGet("/api/rest/v1/statistics", tstNoToken())
+
+	docs.Then("then the request is denied as unauthenticated (401) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusUnauthorized, "auth.unauthorized", "you must be logged in for this operation")
+}
+
+func TestStatistics_UserDeny(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when a regular user attempts to read the full statistics")
+	response := tstPerformGet("/api/rest/v1/statistics", tstValidUserToken(t, "101"))
+
+	docs.Then("then the request is denied as unauthorized (403) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+func TestStatistics_AdminOk(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given attendees in the status new, approved, paid, and deleted")
+	tstRegisterStatisticsAttendees(t, "stat3-")
+
+	docs.When("when an admin reads the full statistics")
+	response := tstPerformGet("/api/rest/v1/statistics", tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and the counts are as expected")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	statsDto := statistics.StatisticsDto{}
+	tstParseJson(response.body, &statsDto)
+	require.EqualValues(t, statistics.StatisticsDto{
+		Total:        3,
+		ByStatus:     map[string]int64{"new": 1, "approved": 1, "paid": 1, "deleted": 1},
+		ByCountry:    map[string]int64{"DE": 3},
+		ByPackage:    map[string]int64{"room-none": 3, "attendance": 3, "stage": 3, "sponsor2": 3},
+		ByFlag:       map[string]int64{"anon": 3, "hc": 3},
+		ByTshirtSize: map[string]int64{"XXL": 3},
+		PerDay:       []statistics.DailyCountDto{{Day: time.Now().Format("2006-01-02"), Count: 4}},
+	}, statsDto)
+}
+
+func TestStatistics_PublicAnonymised(t *testing.T) {
+	docs.Given("given the configuration for standard registration")
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given attendees in the status new, approved, paid, and deleted")
+	tstRegisterStatisticsAttendees(t, "stat4-")
+
+	docs.When("when an unauthenticated user reads the public statistics")
+	response := tstPerformGet("/api/rest/v1/statistics/public", tstNoToken())
+
+	docs.Then("then the request is successful and only the anonymised counts are returned")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	require.NotContains(t, response.body, "by_package")
+	statsDto := statistics.PublicStatisticsDto{}
+	tstParseJson(response.body, &statsDto)
+	require.EqualValues(t, statistics.PublicStatisticsDto{
+		Total:     3,
+		ByStatus:  map[string]int64{"new": 1, "approved": 1, "paid": 1},
+		ByCountry: map[string]int64{"other": 3},
+	}, statsDto)
+}
+
+// helper functions
+
+func tstRegisterStatisticsAttendees(t *testing.T, testcase string) {
+	_, _ = tstRegisterAttendee(t, testcase+"a-")
+	_, _ = tstRegisterAttendeeAndTransitionToStatus(t, testcase+"b-", "approved")
+	_, _ = tstRegisterAttendeeAndTransitionToStatus(t, testcase+"c-", "paid")
+	_, _ = tstRegisterAttendeeAndTransitionToStatus(t, testcase+"d-", "deleted")
+}
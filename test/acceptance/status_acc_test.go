@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"github.com/eurofurence/reg-attendee-service/docs"
-	"github.com/eurofurence/reg-attendee-service/internal/api/v1/admin"
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
 	"github.com/eurofurence/reg-attendee-service/internal/entity"
@@ -437,7 +436,7 @@ func TestStatusChange_Self_New_Cancelled(t *testing.T) {
 	tstStatusChange_Self_Allow(t, testcase,
 		"new", "cancelled",
 		[]paymentservice.Transaction{},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "cancelled")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "cancelled", "0.00", "0.00", "")},
 	)
 }
 
@@ -446,7 +445,7 @@ func TestStatusChange_Self_Approved_Cancelled(t *testing.T) {
 	tstStatusChange_Self_Allow(t, testcase,
 		"approved", "cancelled",
 		[]paymentservice.Transaction{tstValidAttendeeDues(-25500, "void unpaid dues on cancel")},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "cancelled")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "cancelled", "0.00", "0.00", tstToday())},
 	)
 }
 
@@ -476,7 +475,7 @@ func TestStatusChange_Regdesk_Paid_CheckedIn(t *testing.T) {
 	tstStatusChange_Regdesk_Allow(t, testcase,
 		"paid", "checked in",
 		[]paymentservice.Transaction{},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "checked in")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "checked in", "255.00", "0.00", tstToday())},
 	)
 }
 
@@ -526,7 +525,7 @@ func TestStatusChange_Admin_New_Approved(t *testing.T) {
 		"new", "approved",
 		nil,
 		[]paymentservice.Transaction{tstValidAttendeeDues(25500, "dues adjustment due to change in status or selected packages")},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "approved")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "approved", "255.00", "255.00", "")},
 	)
 }
 
@@ -536,7 +535,7 @@ func TestStatusChange_Admin_New_Cancelled(t *testing.T) {
 		"new", "cancelled",
 		nil,
 		[]paymentservice.Transaction{},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "cancelled")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "cancelled", "0.00", "0.00", "")},
 	)
 }
 
@@ -546,7 +545,7 @@ func TestStatusChange_Admin_New_Deleted(t *testing.T) {
 		"new", "deleted",
 		nil,
 		[]paymentservice.Transaction{},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "deleted")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "deleted", "0.00", "0.00", "")},
 	)
 }
 
@@ -571,7 +570,7 @@ func TestStatusChange_Admin_Approved_New(t *testing.T) {
 		"approved", "new",
 		nil,
 		[]paymentservice.Transaction{tstValidAttendeeDues(-25500, "remove dues balance - status changed to new")},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "new")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "new", "0.00", "0.00", tstToday())},
 	)
 }
 
@@ -581,7 +580,7 @@ func TestStatusChange_Admin_Approved_PartiallyPaid(t *testing.T) {
 		"approved", "partially paid",
 		[]paymentservice.Transaction{tstCreateTransaction(1, paymentservice.Payment, 2040)},
 		[]paymentservice.Transaction{},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "partially paid")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "partially paid", "255.00", "234.60", tstToday())},
 	)
 }
 
@@ -591,7 +590,7 @@ func TestStatusChange_Admin_Approved_Paid_WithGraceAmount(t *testing.T) {
 		"approved", "paid",
 		[]paymentservice.Transaction{tstCreateTransaction(1, paymentservice.Payment, 25400)},
 		[]paymentservice.Transaction{},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "paid")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "paid", "255.00", "1.00", tstToday())},
 	)
 }
 
@@ -601,7 +600,7 @@ func TestStatusChange_Admin_Approved_CheckedIn(t *testing.T) {
 		"approved", "checked in",
 		[]paymentservice.Transaction{tstCreateTransaction(1, paymentservice.Payment, 25500)},
 		[]paymentservice.Transaction{},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "checked in")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "checked in", "255.00", "0.00", tstToday())},
 	)
 }
 
@@ -611,7 +610,7 @@ func TestStatusChange_Admin_Approved_Cancelled(t *testing.T) {
 		"approved", "cancelled",
 		nil,
 		[]paymentservice.Transaction{tstValidAttendeeDues(-25500, "void unpaid dues on cancel")},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "cancelled")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "cancelled", "0.00", "0.00", tstToday())},
 	)
 }
 
@@ -621,7 +620,7 @@ func TestStatusChange_Admin_Approved_Deleted(t *testing.T) {
 		"approved", "deleted",
 		nil,
 		[]paymentservice.Transaction{tstValidAttendeeDues(-25500, "remove dues balance - status changed to deleted")},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "deleted")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "deleted", "0.00", "0.00", tstToday())},
 	)
 }
 
@@ -647,7 +646,7 @@ func TestStatusChange_Admin_PartiallyPaid_Approved_OkAfterRefund(t *testing.T) {
 		"partially paid", "approved",
 		[]paymentservice.Transaction{tstCreateTransaction(1, paymentservice.Payment, -15500)},
 		[]paymentservice.Transaction{},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "approved")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "approved", "255.00", "255.00", tstToday())},
 	)
 }
 
@@ -657,7 +656,7 @@ func TestStatusChange_Admin_PartiallyPaid_Paid(t *testing.T) {
 		"partially paid", "paid",
 		[]paymentservice.Transaction{tstCreateTransaction(1, paymentservice.Payment, 10000)},
 		[]paymentservice.Transaction{},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "paid")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "paid", "255.00", "0.00", tstToday())},
 	)
 }
 
@@ -667,7 +666,7 @@ func TestStatusChange_Admin_PartiallyPaid_CheckedIn(t *testing.T) {
 		"partially paid", "checked in",
 		[]paymentservice.Transaction{tstCreateTransaction(1, paymentservice.Payment, 10000)},
 		[]paymentservice.Transaction{},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "checked in")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "checked in", "255.00", "0.00", tstToday())},
 	)
 }
 
@@ -677,7 +676,7 @@ func TestStatusChange_Admin_PartiallyPaid_Cancelled(t *testing.T) {
 		"partially paid", "cancelled",
 		nil,
 		[]paymentservice.Transaction{tstValidAttendeeDues(-10000, "void unpaid dues on cancel")},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "cancelled")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "cancelled", "155.00", "0.00", tstToday())},
 	)
 }
 
@@ -703,7 +702,7 @@ func TestStatusChange_Admin_Paid_New_OkAfterRefund(t *testing.T) {
 		"paid", "new",
 		[]paymentservice.Transaction{tstCreateTransaction(1, paymentservice.Payment, -25500)},
 		[]paymentservice.Transaction{tstCreateMatcherTransaction(1, paymentservice.Due, -25500, "remove dues balance - status changed to new")},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "new")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "new", "0.00", "0.00", tstToday())},
 	)
 }
 
@@ -721,7 +720,7 @@ func TestStatusChange_Admin_Paid_Approved_OkAfterRefund(t *testing.T) {
 		"paid", "approved",
 		[]paymentservice.Transaction{tstCreateTransaction(1, paymentservice.Payment, -25500)},
 		[]paymentservice.Transaction{},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "approved")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "approved", "255.00", "255.00", tstToday())},
 	)
 }
 
@@ -731,7 +730,7 @@ func TestStatusChange_Admin_Paid_PartiallyPaid(t *testing.T) {
 		"paid", "partially paid",
 		[]paymentservice.Transaction{tstCreateTransaction(1, paymentservice.Payment, -10000)},
 		[]paymentservice.Transaction{},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "partially paid")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "partially paid", "255.00", "100.00", tstToday())},
 	)
 }
 
@@ -749,7 +748,7 @@ func TestStatusChange_Admin_Paid_CheckedIn(t *testing.T) {
 		"paid", "checked in",
 		[]paymentservice.Transaction{},
 		[]paymentservice.Transaction{},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "checked in")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "checked in", "255.00", "0.00", tstToday())},
 	)
 }
 
@@ -759,7 +758,7 @@ func TestStatusChange_Admin_Paid_Cancelled(t *testing.T) {
 		"paid", "cancelled",
 		nil,
 		[]paymentservice.Transaction{},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "cancelled")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "cancelled", "255.00", "0.00", tstToday())},
 	)
 }
 
@@ -785,7 +784,7 @@ func TestStatusChange_Admin_CheckedIn_New_OkAfterRefund(t *testing.T) {
 		"checked in", "new",
 		[]paymentservice.Transaction{tstCreateTransaction(1, paymentservice.Payment, -25500)},
 		[]paymentservice.Transaction{tstCreateMatcherTransaction(1, paymentservice.Due, -25500, "remove dues balance - status changed to new")},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "new")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "new", "0.00", "0.00", tstToday())},
 	)
 }
 
@@ -803,7 +802,7 @@ func TestStatusChange_Admin_CheckedIn_Approved_OkAfterRefund(t *testing.T) {
 		"checked in", "approved",
 		[]paymentservice.Transaction{tstCreateTransaction(1, paymentservice.Payment, -25500)},
 		[]paymentservice.Transaction{},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "approved")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "approved", "255.00", "255.00", tstToday())},
 	)
 }
 
@@ -813,7 +812,7 @@ func TestStatusChange_Admin_CheckedIn_PartiallyPaid(t *testing.T) {
 		"checked in", "partially paid",
 		[]paymentservice.Transaction{tstCreateTransaction(1, paymentservice.Payment, -10000)},
 		[]paymentservice.Transaction{},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "partially paid")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "partially paid", "255.00", "100.00", tstToday())},
 	)
 }
 
@@ -823,7 +822,7 @@ func TestStatusChange_Admin_CheckedIn_Paid(t *testing.T) {
 		"checked in", "paid",
 		[]paymentservice.Transaction{},
 		[]paymentservice.Transaction{},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "paid")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "paid", "255.00", "0.00", tstToday())},
 	)
 }
 
@@ -833,7 +832,7 @@ func TestStatusChange_Admin_CheckedIn_Cancelled(t *testing.T) {
 		"checked in", "cancelled",
 		nil,
 		[]paymentservice.Transaction{},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "cancelled")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "cancelled", "255.00", "0.00", tstToday())},
 	)
 }
 
@@ -859,7 +858,7 @@ func TestStatusChange_Admin_Cancelled_New_OkAfterRefund(t *testing.T) {
 		"cancelled", "new",
 		[]paymentservice.Transaction{tstCreateTransaction(1, paymentservice.Payment, -25500)},
 		[]paymentservice.Transaction{tstCreateMatcherTransaction(1, paymentservice.Due, -25500, "remove dues balance - status changed to new")},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "new")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "new", "0.00", "0.00", tstToday())},
 	)
 }
 
@@ -877,7 +876,7 @@ func TestStatusChange_Admin_Cancelled_Approved_OkAfterRefund(t *testing.T) {
 		"cancelled", "approved",
 		[]paymentservice.Transaction{tstCreateTransaction(1, paymentservice.Payment, -25500)},
 		[]paymentservice.Transaction{},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "approved")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "approved", "255.00", "255.00", tstToday())},
 	)
 }
 
@@ -922,7 +921,7 @@ func TestStatusChange_Admin_Deleted_New(t *testing.T) {
 		"deleted", "new",
 		nil,
 		[]paymentservice.Transaction{},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "new")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "new", "0.00", "0.00", tstToday())},
 	)
 }
 
@@ -932,7 +931,7 @@ func TestStatusChange_Admin_Deleted_Approved(t *testing.T) {
 		"deleted", "approved",
 		nil,
 		[]paymentservice.Transaction{tstValidAttendeeDues(25500, "dues adjustment due to change in status or selected packages")},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "approved")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "approved", "255.00", "255.00", tstToday())},
 	)
 }
 
@@ -969,7 +968,7 @@ func TestStatusChange_Admin_Deleted_Cancelled(t *testing.T) {
 		"deleted", "cancelled",
 		nil,
 		[]paymentservice.Transaction{},
-		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "cancelled")},
+		[]mailservice.TemplateRequestDto{tstNewStatusMail(testcase, "cancelled", "0.00", "0.00", tstToday())},
 	)
 }
 
@@ -1363,16 +1362,7 @@ func tstRequireAttendeeStatus(t *testing.T, expected string, responseBody string
 }
 
 func tstRegisterRegdeskAttendee(t *testing.T, testcase string) string {
-	token := tstValidUserToken(t, "101")
-
-	loc2, _ := tstRegisterAttendeeWithToken(t, testcase+"second", token)
-	permBody := admin.AdminInfoDto{
-		Permissions: "regdesk",
-	}
-	permissionResponse := tstPerformPut(loc2+"/admin", tstRenderJson(permBody), tstValidAdminToken(t))
-	require.Equal(t, http.StatusNoContent, permissionResponse.status)
-
-	return token
+	return tstRegisterAttendeeWithPermissions(t, testcase+"second", "regdesk")
 }
 
 func tstRegisterAttendeeAndTransitionToStatus(t *testing.T, testcase string, status string) (location string, att attendee.AttendeeDto) {
@@ -0,0 +1,356 @@
+package acceptance
+
+import (
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/transfer"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/mailservice"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// ------------------------------------------------------
+// acceptance tests for transferring registrations
+// ------------------------------------------------------
+
+// --- admin transfer
+
+func TestTransfer_AdminSuccess(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a registration owned by one account")
+	loc, att := tstRegisterAttendee(t, "trans1-")
+	mailMock.Reset()
+
+	docs.When("when an admin transfers it to another account")
+	body := transfer.TransferDto{Identity: "101"}
+	response := tstPerformPost(loc+"/transfer", tstRenderJson(body), tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and the registration now belongs to the other account")
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+	tstRequireOwnedIds(t, tstValidUserToken(t, "101"), att)
+
+	docs.Then("and the personal data is unchanged")
+	require.Equal(t, att, tstReadAttendee(t, loc))
+
+	docs.Then("and both parties were informed by email")
+	require.Equal(t, []mailservice.TemplateRequestDto{
+		tstTransferMail("transfer-out", att, "BlackCheetah", att.Email),
+		tstTransferMail("transfer-in", att, "BlackCheetah", att.Email),
+	}, mailMock.Recording())
+}
+
+func TestTransfer_AdminSameOwner(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a registration owned by a user")
+	token := tstValidUserToken(t, "101")
+	loc, _ := tstRegisterAttendeeWithToken(t, "trans2-", token)
+
+	docs.When("when an admin attempts to transfer it to the same user")
+	body := transfer.TransferDto{Identity: "101"}
+	response := tstPerformPost(loc+"/transfer", tstRenderJson(body), tstValidAdminToken(t))
+
+	docs.Then("then the request fails (409) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "transfer.same.owner", url.Values{"details": []string{"the registration already belongs to this account"}})
+}
+
+func TestTransfer_AdminInvalid(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a registration")
+	loc, _ := tstRegisterAttendee(t, "trans3-")
+
+	docs.When("when an admin attempts a resale transfer without an identity and email address")
+	body := transfer.TransferDto{ResetPersonalData: true}
+	response := tstPerformPost(loc+"/transfer", tstRenderJson(body), tstValidAdminToken(t))
+
+	docs.Then("then the request fails (400) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "transfer.data.invalid", url.Values{
+		"identity": []string{"identity field must be at least 1 and at most 255 characters long"},
+		"email":    []string{"email field must be at least 1 and at most 200 characters long", "email field is not plausible, must match ^[^\\@\\s]+\\@[^\\@\\s]+$"},
+	})
+}
+
+func TestTransfer_UserDeny(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a user who owns a registration")
+	token := tstValidUserToken(t, "101")
+	loc, _ := tstRegisterAttendeeWithToken(t, "trans4-", token)
+
+	docs.When("when they attempt to transfer it without a transfer code")
+	body := transfer.TransferDto{Identity: "202"}
+	response := tstPerformPost(loc+"/transfer", tstRenderJson(body), token)
+
+	docs.Then("then the request is denied as unauthorized (403) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+// --- transfer codes
+
+func TestTransferCode_RedeemSuccess(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a registration that has been paid for")
+	loc, att := tstRegisterAttendeeAndTransitionToStatus(t, "trans5-", "paid")
+	ownerToken := tstValidStaffToken(t, "1")
+	mailMock.Reset()
+
+	docs.When("when the owner creates a transfer code")
+	response := tstPerformPost(loc+"/transfer-code", "", ownerToken)
+
+	docs.Then("then the request is successful and a code is returned")
+	require.Equal(t, http.StatusCreated, response.status, "unexpected http response status")
+	codeDto := transfer.TransferCodeDto{}
+	tstParseJson(response.body, &codeDto)
+	require.NotEmpty(t, codeDto.Code)
+	require.NotEmpty(t, codeDto.Expires)
+
+	docs.When("when another user redeems the code")
+	recipientToken := tstValidUserToken(t, "101")
+	response = tstPerformPost("/api/rest/v1/transfers/redeem", tstRenderJson(transfer.RedeemTransferDto{Code: codeDto.Code}), recipientToken)
+
+	docs.Then("then the request is successful and the registration now belongs to them, unchanged")
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+	require.Equal(t, loc, response.location)
+	tstRequireOwnedIds(t, recipientToken, att)
+	require.Equal(t, att, tstReadAttendee(t, loc))
+
+	docs.Then("and both parties were informed by email")
+	require.Equal(t, []mailservice.TemplateRequestDto{
+		tstTransferMail("transfer-out", att, "BlackCheetah", att.Email),
+		tstTransferMail("transfer-in", att, "BlackCheetah", att.Email),
+	}, mailMock.Recording())
+
+	docs.Then("and the code cannot be redeemed a second time")
+	response = tstPerformPost("/api/rest/v1/transfers/redeem", tstRenderJson(transfer.RedeemTransferDto{Code: codeDto.Code}), tstValidUserToken(t, "1"))
+	tstRequireErrorResponse(t, response, http.StatusNotFound, "transfer.code.invalid", url.Values{})
+}
+
+func TestTransferCode_Resale(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a registration that has been paid for")
+	loc, att := tstRegisterAttendeeAndTransitionToStatus(t, "trans6-", "paid")
+	ownerToken := tstValidStaffToken(t, "1")
+
+	docs.Given("given the owner has created a transfer code for a resale")
+	response := tstPerformPost(loc+"/transfer-code", tstRenderJson(transfer.TransferCodeRequestDto{ResetPersonalData: true}), ownerToken)
+	require.Equal(t, http.StatusCreated, response.status, "unexpected http response status")
+	codeDto := transfer.TransferCodeDto{}
+	tstParseJson(response.body, &codeDto)
+	mailMock.Reset()
+
+	docs.When("when another user redeems the code without giving an email address, and their login does not provide one")
+	recipientToken := tstValidUserToken(t, "101")
+	response = tstPerformPost("/api/rest/v1/transfers/redeem", tstRenderJson(transfer.RedeemTransferDto{Code: codeDto.Code}), recipientToken)
+
+	docs.Then("then the request fails (409) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "transfer.email.missing", url.Values{"details": []string{"the personal data is reset on this transfer, so an email address is required"}})
+
+	docs.When("when they redeem the code giving their email address")
+	response = tstPerformPost("/api/rest/v1/transfers/redeem", tstRenderJson(transfer.RedeemTransferDto{Code: codeDto.Code, Email: "trans6-buyer@example.com"}), recipientToken)
+
+	docs.Then("then the request is successful and the registration now belongs to them")
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+	tstRequireOwnedIds(t, recipientToken, att)
+
+	docs.Then("and the personal data of the seller has been removed, flags and options are back to their defaults, but the packages are kept")
+	expected := attendee.AttendeeDto{
+		Id:       att.Id,
+		Email:    "trans6-buyer@example.com",
+		Packages: att.Packages,
+	}
+	require.Equal(t, expected, tstReadAttendee(t, loc))
+
+	docs.Then("and both parties were informed by email")
+	require.Equal(t, []mailservice.TemplateRequestDto{
+		tstTransferMail("transfer-out", att, "BlackCheetah", att.Email),
+		tstTransferMail("transfer-in", att, "", "trans6-buyer@example.com"),
+	}, mailMock.Recording())
+}
+
+func TestTransferCode_NotOwnerDeny(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a registration owned by someone else")
+	loc, _ := tstRegisterAttendee(t, "trans7-")
+
+	docs.When("when a user attempts to create a transfer code for it")
+	response := tstPerformPost(loc+"/transfer-code", "", tstValidUserToken(t, "101"))
+
+	docs.Then("then the request is denied as unauthorized (403) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized to access this data - the attempt has been logged")
+}
+
+func TestTransferCode_NotAllowedStatus(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a registration that has been cancelled")
+	loc, att := tstRegisterAttendeeAndTransitionToStatus(t, "trans8-", "cancelled")
+
+	docs.When("when the owner attempts to create a transfer code")
+	response := tstPerformPost(loc+"/transfer-code", "", tstValidStaffToken(t, "1"))
+
+	docs.Then("then the request fails (409) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "transfer.not.allowed", url.Values{"details": []string{"registrations can only be transferred in status new, approved, partially paid or paid: attendee " + att.Id + " is in status cancelled"}})
+}
+
+func TestTransferCode_Cancel(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a registration for which the owner has created a transfer code")
+	ownerToken := tstValidUserToken(t, "101")
+	loc, _ := tstRegisterAttendeeWithToken(t, "trans9-", ownerToken)
+	response := tstPerformPost(loc+"/transfer-code", "", ownerToken)
+	require.Equal(t, http.StatusCreated, response.status, "unexpected http response status")
+	codeDto := transfer.TransferCodeDto{}
+	tstParseJson(response.body, &codeDto)
+
+	docs.When("when the owner cancels the transfer code")
+	response = tstPerformDelete(loc+"/transfer-code", ownerToken)
+
+	docs.Then("then the request is successful")
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+
+	docs.Then("and the code can no longer be redeemed")
+	response = tstPerformPost("/api/rest/v1/transfers/redeem", tstRenderJson(transfer.RedeemTransferDto{Code: codeDto.Code}), tstValidStaffToken(t, "1"))
+	tstRequireErrorResponse(t, response, http.StatusNotFound, "transfer.code.invalid", url.Values{})
+
+	docs.Then("and cancelling again fails (404)")
+	response = tstPerformDelete(loc+"/transfer-code", ownerToken)
+	tstRequireErrorResponse(t, response, http.StatusNotFound, "transfer.code.notfound", url.Values{})
+}
+
+func TestTransferCode_InvalidCode(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a registration for which the owner has created a transfer code")
+	ownerToken := tstValidUserToken(t, "101")
+	loc, att := tstRegisterAttendeeWithToken(t, "trans10-", ownerToken)
+	response := tstPerformPost(loc+"/transfer-code", "", ownerToken)
+	require.Equal(t, http.StatusCreated, response.status, "unexpected http response status")
+
+	docs.When("when another user attempts to redeem a guessed code for the same registration")
+	response = tstPerformPost("/api/rest/v1/transfers/redeem", tstRenderJson(transfer.RedeemTransferDto{Code: att.Id + "-AAAAAAAAAAAAAAAA"}), tstValidStaffToken(t, "1"))
+
+	docs.Then("then the request fails (404) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusNotFound, "transfer.code.invalid", url.Values{})
+	tstRequireOwnedIds(t, ownerToken, att)
+}
+
+func TestTransferCode_UnknownId(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when a user attempts to redeem a code for a registration that does not exist")
+	response := tstPerformPost("/api/rest/v1/transfers/redeem", tstRenderJson(transfer.RedeemTransferDto{Code: "42-AAAAAAAAAAAAAAAA"}), tstValidStaffToken(t, "1"))
+
+	docs.Then("then the request fails (404) with the same error as for any other invalid code")
+	tstRequireErrorResponse(t, response, http.StatusNotFound, "transfer.code.invalid", url.Values{})
+}
+
+func TestTransferCode_RedeemAnonymousDeny(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an unauthenticated user")
+	token := tstNoToken()
+
+	docs.When("when they attempt to redeem a transfer code")
+	response := tstPerformPost("/api/rest/v1/transfers/redeem", tstRenderJson(transfer.RedeemTransferDto{Code: "1-AAAAAAAAAAAAAAAA"}), token)
+
+	docs.Then("then the request is denied as unauthenticated (401) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusUnauthorized, "auth.unauthorized", "you must be logged in for this operation")
+}
+
+func TestTransferCode_PermissionsNotHandedOver(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a registration that has the read_all permission, which allows its owner to view the statistics")
+	ownerToken := tstRegisterAttendeeWithPermissions(t, "trans12-", "read_all")
+	loc := "/api/rest/v1/attendees/1"
+	require.Equal(t, http.StatusOK, tstPerformGet("/api/rest/v1/statistics", ownerToken).status, "unexpected http response status")
+
+	docs.When("when the owner creates a transfer code and another user redeems it")
+	response := tstPerformPost(loc+"/transfer-code", "", ownerToken)
+	require.Equal(t, http.StatusCreated, response.status, "unexpected http response status")
+	codeDto := transfer.TransferCodeDto{}
+	tstParseJson(response.body, &codeDto)
+	recipientToken := tstValidStaffToken(t, "202")
+	response = tstPerformPost("/api/rest/v1/transfers/redeem", tstRenderJson(transfer.RedeemTransferDto{Code: codeDto.Code}), recipientToken)
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+
+	docs.Then("then the new owner is denied access to the statistics")
+	response = tstPerformGet("/api/rest/v1/statistics", recipientToken)
+	tstRequireErrorResponse(t, response, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+
+	docs.Then("and the permission has been removed from the registration")
+	require.Equal(t, "", tstReadAdminInfo(t, loc).Permissions)
+}
+
+func TestTransfer_GroupOwnershipHandedOver(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a group with an owner and a second member")
+	ownerToken := tstValidUserToken(t, "101")
+	ownerLoc, owner := tstRegisterAttendeeWithToken(t, "trans13-", ownerToken)
+	groupLoc := tstCreateGroup(t, ownerToken, "Table 13", "dealers", owner)
+	memberToken := tstValidStaffToken(t, "202")
+	_, member := tstRegisterAttendeeWithToken(t, "trans13b-", memberToken)
+	tstAdminAddGroupMember(t, groupLoc, member)
+
+	docs.When("when an admin transfers the registration of the owner to another account")
+	body := transfer.TransferDto{Identity: "1"}
+	response := tstPerformPost(ownerLoc+"/transfer", tstRenderJson(body), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+
+	docs.Then("then the registration has left the group, and the second member now owns it")
+	group := tstReadGroup(t, groupLoc, memberToken)
+	require.Equal(t, member.Id, group.Owner)
+	require.Equal(t, []string{member.Id}, tstGroupMemberIds(group))
+
+	docs.Then("and the new owner of the registration cannot see the group")
+	response = tstPerformGet(groupLoc, tstValidStaffToken(t, "1"))
+	require.Equal(t, http.StatusForbidden, response.status, "unexpected http response status")
+}
+
+// --- helpers ---
+
+func tstRequireOwnedIds(t *testing.T, token string, attendees ...attendee.AttendeeDto) {
+	response := tstPerformGet("/api/rest/v1/attendees", token)
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	actual := attendee.AttendeeIdList{}
+	tstParseJson(response.body, &actual)
+	expected := attendee.AttendeeIdList{Ids: []int64{}}
+	for _, a := range attendees {
+		expected.Ids = append(expected.Ids, tstIdAsInt64(a))
+	}
+	require.Equal(t, expected, actual)
+}
+
+func tstTransferMail(name string, att attendee.AttendeeDto, nickname string, email string) mailservice.TemplateRequestDto {
+	return mailservice.TemplateRequestDto{
+		Name: name,
+		Variables: map[string]string{
+			"nickname":     nickname,
+			"badge_number": att.Id,
+		},
+		Email: email,
+	}
+}
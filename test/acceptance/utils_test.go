@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/config"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/mailservice"
 	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
 	"github.com/eurofurence/reg-attendee-service/internal/web/util/media"
@@ -99,6 +100,35 @@ func tstPerformPost(relativeUrlWithLeadingSlash string, requestBody string, bear
 	return tstWebResponseFromResponse(response)
 }
 
+func tstPerformDelete(relativeUrlWithLeadingSlash string, bearerToken string) tstWebResponse {
+	request, err := http.NewRequest(http.MethodDelete, ts.URL+relativeUrlWithLeadingSlash, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if bearerToken != "" {
+		request.Header.Set(headers.Authorization, "Bearer "+bearerToken)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return tstWebResponseFromResponse(response)
+}
+
+func tstPerformWithApiToken(method string, relativeUrlWithLeadingSlash string, requestBody string, apiToken string) tstWebResponse {
+	request, err := http.NewRequest(method, ts.URL+relativeUrlWithLeadingSlash, strings.NewReader(requestBody))
+	if err != nil {
+		log.Fatal(err)
+	}
+	request.Header.Set(media.HeaderXApiKey, apiToken)
+	request.Header.Set(headers.ContentType, media.ContentTypeApplicationJson)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return tstWebResponseFromResponse(response)
+}
+
 func tstBuildValidAttendee(testcase string) attendee.AttendeeDto {
 	timer := time.Now().UnixNano()
 	return attendee.AttendeeDto{
@@ -175,12 +205,24 @@ func tstValidAttendeeDues(amount int64, comment string) paymentservice.Transacti
 	}
 }
 
-func tstNewStatusMail(testcase string, newStatus string) mailservice.TemplateRequestDto {
+// tstNewStatusMail is the status mail for the default attendee with badge number 1 and all packages.
+func tstNewStatusMail(testcase string, newStatus string, totalDues string, remainingDues string, dueDate string) mailservice.TemplateRequestDto {
 	return mailservice.TemplateRequestDto{
 		Name: "new-status-" + newStatus,
 		Variables: map[string]string{
-			"nickname": "BlackCheetah",
+			"nickname":       "BlackCheetah",
+			"badge_number":   "1",
+			"status":         newStatus,
+			"packages":       "Entrance Fee (Convention Ticket), No Room, Supersponsor Upgrade, Entrance Fee (Stage Ticket)",
+			"total_dues":     totalDues,
+			"remaining_dues": remainingDues,
+			"due_date":       dueDate,
 		},
 		Email: testcase,
 	}
 }
+
+// tstToday is the due date of the transactions created by tstCreateTransaction.
+func tstToday() string {
+	return time.Now().Format(config.IsoDateFormat)
+}
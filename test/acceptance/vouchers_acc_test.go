@@ -0,0 +1,336 @@
+package acceptance
+
+import (
+	"github.com/eurofurence/reg-attendee-service/docs"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/attendee"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/status"
+	"github.com/eurofurence/reg-attendee-service/internal/api/v1/vouchers"
+	"github.com/eurofurence/reg-attendee-service/internal/repository/paymentservice"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// ------------------------------------------
+// acceptance tests for voucher codes
+// ------------------------------------------
+
+// --- admin management
+
+func TestVouchers_CreateReadUpdate(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when an admin creates a voucher")
+	created := vouchers.VoucherDto{
+		Code:        "STAFF-2024",
+		Description: "discount for staff",
+		Percent:     50,
+		Packages:    "attendance,sponsor2",
+	}
+	loc := tstCreateVoucher(t, created)
+
+	docs.Then("then it can be read back and has not been used yet")
+	created.Id = "1"
+	require.Equal(t, "/api/rest/v1/vouchers/1", loc)
+	require.Equal(t, created, tstReadVoucher(t, loc))
+
+	docs.When("when the admin changes it to a fixed amount that expires")
+	changed := created
+	changed.Percent = 0
+	changed.Amount = 2000
+	changed.MaxUses = 10
+	changed.Expires = "2099-12-31T23:59:59Z"
+	response := tstPerformPut(loc, tstRenderJson(changed), tstValidAdminToken(t))
+
+	docs.Then("then the request is successful and the changes are listed")
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+	listResponse := tstPerformGet("/api/rest/v1/vouchers", tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, listResponse.status, "unexpected http response status")
+	list := vouchers.VoucherListDto{}
+	tstParseJson(listResponse.body, &list)
+	require.Equal(t, vouchers.VoucherListDto{Vouchers: []vouchers.VoucherDto{changed}}, list)
+}
+
+func TestVouchers_CreateInvalid(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when an admin attempts to create a voucher with invalid data")
+	body := vouchers.VoucherDto{
+		Code:     "lower",
+		Percent:  10,
+		Amount:   1000,
+		Packages: "attendance,unicorn",
+		Expires:  "tomorrow",
+	}
+	response := tstPerformPost("/api/rest/v1/vouchers", tstRenderJson(body), tstValidAdminToken(t))
+
+	docs.Then("then the request fails (400) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "voucher.data.invalid", url.Values{
+		"code":     []string{"code field must consist of 3 to 32 upper case letters, digits or dashes"},
+		"percent":  []string{"exactly one of the percent and amount fields must be set"},
+		"packages": []string{"packages field must be a comma separated combination of any of attendance,day-fri,day-sat,day-thu,room-none,sponsor,sponsor2,stage"},
+		"expires":  []string{"optional expires field must be an RFC 3339 timestamp, or it can be left blank, which means the voucher does not expire"},
+	})
+}
+
+func TestVouchers_CreateDuplicate(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing voucher")
+	tstCreateVoucher(t, vouchers.VoucherDto{Code: "DUP", Percent: 10})
+
+	docs.When("when an admin attempts to create another voucher with the same code")
+	response := tstPerformPost("/api/rest/v1/vouchers", tstRenderJson(vouchers.VoucherDto{Code: "DUP", Amount: 500}), tstValidAdminToken(t))
+
+	docs.Then("then the request fails with a conflict (409)")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "voucher.code.duplicate", url.Values{
+		"code": []string{"there is already a voucher with this code"},
+	})
+}
+
+func TestVouchers_ChangeCodeDeny(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an existing voucher")
+	loc := tstCreateVoucher(t, vouchers.VoucherDto{Code: "KEEP", Percent: 10})
+
+	docs.When("when an admin attempts to change its code")
+	response := tstPerformPut(loc, tstRenderJson(vouchers.VoucherDto{Code: "OTHER", Percent: 10}), tstValidAdminToken(t))
+
+	docs.Then("then the request fails (400) and the code is unchanged")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "voucher.data.invalid", url.Values{
+		"code": []string{"code field cannot be changed, please create a new voucher instead"},
+	})
+	require.Equal(t, "KEEP", tstReadVoucher(t, loc).Code)
+}
+
+func TestVouchers_UserDeny(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when a regular user attempts to list or create vouchers")
+	token := tstValidUserToken(t, "101")
+	listResponse := tstPerformGet("/api/rest/v1/vouchers", token)
+	createResponse := tstPerformPost("/api/rest/v1/vouchers", tstRenderJson(vouchers.VoucherDto{Code: "FREE", Percent: 100}), token)
+
+	docs.Then("then both requests are denied as unauthorized (403)")
+	tstRequireErrorResponse(t, listResponse, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+	tstRequireErrorResponse(t, createResponse, http.StatusForbidden, "auth.forbidden", "you are not authorized for this operation - the attempt has been logged")
+}
+
+func TestVouchers_NotFound(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.When("when an admin reads a voucher that does not exist")
+	response := tstPerformGet("/api/rest/v1/vouchers/42", tstValidAdminToken(t))
+
+	docs.Then("then the request fails (404)")
+	tstRequireErrorResponse(t, response, http.StatusNotFound, "voucher.id.notfound", url.Values{})
+}
+
+// --- redeeming
+
+func TestVouchers_PercentDiscount(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a voucher for 10 percent off all packages")
+	voucherLoc := tstCreateVoucher(t, vouchers.VoucherDto{Code: "TENOFF", Percent: 10})
+
+	docs.When("when an attendee registers with the voucher code in lower case, and is approved")
+	loc, att := tstRegisterAttendeeWithVoucher(t, "vch1-", "tenoff")
+	tstApprove(t, loc)
+
+	docs.Then("then the voucher is stored in upper case and counts as used once")
+	require.Equal(t, "TENOFF", att.Voucher)
+	require.Equal(t, int64(1), tstReadVoucher(t, voucherLoc).Uses)
+
+	docs.Then("and the discount is booked separately from the package dues")
+	tstRequireDues(t, att,
+		tstValidAttendeeDues(25500, "dues adjustment due to change in status or selected packages"),
+		tstValidAttendeeDues(-2550, "voucher discount TENOFF"),
+	)
+}
+
+func TestVouchers_AmountDiscountOnSelectedPackage(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a voucher for a fixed amount off the stage pass, which costs less than the amount")
+	tstCreateVoucher(t, vouchers.VoucherDto{Code: "STAGE", Amount: 2000, Packages: "stage"})
+
+	docs.When("when an attendee registers with the voucher code, and is approved")
+	loc, att := tstRegisterAttendeeWithVoucher(t, "vch2-", "STAGE")
+	tstApprove(t, loc)
+
+	docs.Then("then the discount is limited to the price of the stage pass")
+	tstRequireDues(t, att,
+		tstValidAttendeeDues(25500, "dues adjustment due to change in status or selected packages"),
+		tstValidAttendeeDues(-500, "voucher discount STAGE"),
+	)
+}
+
+func TestVouchers_RemoveBeforePayment(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an approved attendee who has redeemed a voucher, but not paid yet")
+	tstCreateVoucher(t, vouchers.VoucherDto{Code: "TENOFF", Percent: 10})
+	loc, att := tstRegisterAttendeeWithVoucher(t, "vch3-", "TENOFF")
+	tstApprove(t, loc)
+	paymentMock.Reset()
+
+	docs.When("when they remove the voucher code")
+	att.Voucher = ""
+	response := tstPerformPut(loc, tstRenderJson(att), tstValidStaffToken(t, "1"))
+
+	docs.Then("then the request is successful and the discount is reverted")
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	tstRequireDues(t, att, tstValidAttendeeDues(2550, "voucher discount removed"))
+}
+
+func TestVouchers_ChangeAfterPaymentDeny(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an attendee who has made a payment, and a valid voucher")
+	tstCreateVoucher(t, vouchers.VoucherDto{Code: "LATE", Percent: 10})
+	loc, att := tstRegisterAttendeeAndTransitionToStatus(t, "vch4-", "partially paid")
+
+	docs.When("when they try to redeem the voucher")
+	att.Voucher = "LATE"
+	response := tstPerformPut(loc, tstRenderJson(att), tstValidStaffToken(t, "1"))
+
+	docs.Then("then the update is rejected with an error response keyed on voucher")
+	tstRequireErrorResponse(t, response, http.StatusBadRequest, "attendee.data.invalid", url.Values{
+		"voucher": []string{"the voucher cannot be changed after the first payment, please contact the registration team"},
+	})
+	require.Equal(t, "", tstReadAttendee(t, loc).Voucher)
+}
+
+func TestVouchers_InvalidCodes(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given an expired voucher, a voucher that has been used up, and a voucher for a package that is not selected")
+	tstCreateVoucher(t, vouchers.VoucherDto{Code: "EXPIRED", Percent: 10, Expires: "2020-01-01T00:00:00Z"})
+	tstCreateVoucher(t, vouchers.VoucherDto{Code: "ONCE", Percent: 10, MaxUses: 1})
+	tstCreateVoucher(t, vouchers.VoucherDto{Code: "DAYS", Percent: 10, Packages: "day-thu,day-fri"})
+	tstRegisterAttendeeWithVoucher(t, "vch5-", "ONCE")
+
+	for code, message := range map[string]string{
+		"UNKNOWN": "voucher code is invalid or has expired",
+		"EXPIRED": "voucher code is invalid or has expired",
+		"ONCE":    "voucher code has already been used the maximum number of times",
+		"DAYS":    "voucher code does not apply to any of the selected packages",
+	} {
+		docs.When("when an attendee attempts to register with the voucher code " + code)
+		dto := tstBuildValidAttendee("vch5b-")
+		dto.Voucher = code
+		response := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(dto), tstValidUserToken(t, "101"))
+
+		docs.Then("then the registration is rejected with an error response keyed on voucher")
+		tstRequireErrorResponse(t, response, http.StatusBadRequest, "attendee.data.invalid", url.Values{
+			"voucher": []string{message},
+		})
+	}
+
+	docs.Then("and no registrations have been created for the attendee")
+	response := tstPerformGet("/api/rest/v1/attendees", tstValidUserToken(t, "101"))
+	tstRequireErrorResponse(t, response, http.StatusNotFound, "attendee.owned.notfound", url.Values{})
+}
+
+func TestVouchers_CancelledRegistrationDoesNotCount(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a voucher that may be redeemed once, and a registration that redeemed it and was then cancelled")
+	voucherLocation := tstCreateVoucher(t, vouchers.VoucherDto{Code: "ONCE", Percent: 10, MaxUses: 1})
+	location1, _ := tstRegisterAttendeeWithVoucher(t, "vch6-", "ONCE")
+	tstChangeStatus(t, location1, "cancelled")
+
+	docs.When("when another attendee registers with the voucher code")
+	dto := tstBuildValidAttendee("vch6b-")
+	dto.Voucher = "ONCE"
+	response := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(dto), tstValidUserToken(t, "101"))
+
+	docs.Then("then the registration is successful")
+	require.Equal(t, http.StatusCreated, response.status, "unexpected http response status")
+	require.Equal(t, "ONCE", tstReadAttendee(t, response.location).Voucher)
+
+	docs.Then("and the voucher counts one use")
+	require.Equal(t, int64(1), tstReadVoucher(t, voucherLocation).Uses)
+}
+
+func TestVouchers_ReactivationWhenUsedUpDeny(t *testing.T) {
+	tstSetup(tstConfigFile(false, false, true))
+	defer tstShutdown()
+
+	docs.Given("given a voucher that may be redeemed once, and a cancelled registration that redeemed it")
+	tstCreateVoucher(t, vouchers.VoucherDto{Code: "ONCE", Percent: 10, MaxUses: 1})
+	location1, _ := tstRegisterAttendeeWithVoucher(t, "vch7-", "ONCE")
+	tstChangeStatus(t, location1, "cancelled")
+
+	docs.Given("given another attendee has since redeemed the voucher")
+	tstRegisterAttendeeWithVoucher(t, "vch7b-", "ONCE")
+	paymentMock.Reset()
+
+	docs.When("when an admin attempts to approve the cancelled registration again")
+	body := status.StatusChangeDto{Status: "approved", Comment: "vch7"}
+	response := tstPerformPost(location1+"/status", tstRenderJson(body), tstValidAdminToken(t))
+
+	docs.Then("then the request fails as conflict (409) and the appropriate error is returned")
+	tstRequireErrorResponse(t, response, http.StatusConflict, "status.voucher.used", url.Values{
+		"details": []string{"voucher code has already been used the maximum number of times"},
+	})
+
+	docs.Then("and the status is unchanged and no dues have been booked")
+	tstVerifyStatus(t, location1, "cancelled")
+	require.Empty(t, paymentMock.Recording())
+}
+
+// --- helpers ---
+
+func tstCreateVoucher(t *testing.T, dto vouchers.VoucherDto) string {
+	response := tstPerformPost("/api/rest/v1/vouchers", tstRenderJson(dto), tstValidAdminToken(t))
+	require.Equal(t, http.StatusCreated, response.status, "unexpected http response status")
+	return response.location
+}
+
+func tstReadVoucher(t *testing.T, location string) vouchers.VoucherDto {
+	response := tstPerformGet(location, tstValidAdminToken(t))
+	require.Equal(t, http.StatusOK, response.status, "unexpected http response status")
+	result := vouchers.VoucherDto{}
+	tstParseJson(response.body, &result)
+	return result
+}
+
+func tstRegisterAttendeeWithVoucher(t *testing.T, testcase string, code string) (string, attendee.AttendeeDto) {
+	dto := tstBuildValidAttendee(testcase)
+	dto.Voucher = code
+	response := tstPerformPost("/api/rest/v1/attendees", tstRenderJson(dto), tstValidStaffToken(t, "1"))
+	require.Equal(t, http.StatusCreated, response.status, "unexpected http response status")
+	return response.location, tstReadAttendee(t, response.location)
+}
+
+func tstApprove(t *testing.T, location string) {
+	body := status.StatusChangeDto{Status: "approved", Comment: "voucher test"}
+	response := tstPerformPost(location+"/status", tstRenderJson(body), tstValidAdminToken(t))
+	require.Equal(t, http.StatusNoContent, response.status, "unexpected http response status")
+}
+
+func tstRequireDues(t *testing.T, att attendee.AttendeeDto, expected ...paymentservice.Transaction) {
+	actual := paymentMock.Recording()
+	require.Equal(t, len(expected), len(actual))
+	for i := range expected {
+		expected[i].DebitorID = tstAttendeeId(att)
+		expected[i].DueDate = actual[i].DueDate // TODO remove when due date logic implemented
+	}
+	require.EqualValues(t, expected, actual)
+}